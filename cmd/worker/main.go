@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"github.com/decadevs/shoparena/server"
+	"log"
+)
+
+// The worker runs background jobs without the api.
+// Run it alongside the api started with JOBS_IN_PROCESS=false.
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile | log.Lmicroseconds)
+	fmt.Println("Starting... Oja worker")
+
+	err := server.StartWorker()
+	if err != nil {
+		fmt.Println("Error starting worker in main", err)
+		return
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+)
+
+// CreateAPIKey saves a new api key
+func (pdb *PostgresDb) CreateAPIKey(key *models.APIKey) error {
+	return pdb.DB.Create(key).Error
+}
+
+// GetSellerAPIKeys lists a seller's api keys, revoked ones included, newest first
+func (pdb *PostgresDb) GetSellerAPIKeys(sellerID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := pdb.DB.Where("seller_id = ?", sellerID).Order("id desc").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FindAPIKeyByPrefix finds an api key and its seller by the key's prefix
+func (pdb *PostgresDb) FindAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := pdb.DB.Preload("Seller").Where("prefix = ?", prefix).First(key).Error
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey stops one of a seller's api keys working
+func (pdb *PostgresDb) RevokeAPIKey(id, sellerID uint) error {
+	result := pdb.DB.Model(&models.APIKey{}).
+		Where("id = ? AND seller_id = ? AND revoked_at IS NULL", id, sellerID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records when an api key was last used
+func (pdb *PostgresDb) TouchAPIKey(id uint, at time.Time) error {
+	return pdb.DB.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
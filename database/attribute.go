@@ -0,0 +1,60 @@
+package database
+
+import (
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+)
+
+// GetCategoryAttributes returns the attributes products in a category have: its own and those of the
+// categories above it, from the root down
+func (pdb *PostgresDb) GetCategoryAttributes(categoryID uint) ([]models.CategoryAttribute, error) {
+	var category models.Category
+	if err := pdb.DB.Where("id = ?", categoryID).First(&category).Error; err != nil {
+		return nil, err
+	}
+	var attributes []models.CategoryAttribute
+	err := pdb.DB.Joins("JOIN categories ON categories.id = category_attributes.category_id").
+		Where("category_attributes.category_id IN ?", category.PathIDs()).
+		Order("LENGTH(categories.path), category_attributes.position").Find(&attributes).Error
+	if err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// SaveCategoryAttributes replaces a category's own attributes. Values products already have stay
+// in place so they come back if the attribute is added again.
+func (pdb *PostgresDb) SaveCategoryAttributes(categoryID uint, attributes []models.CategoryAttribute) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.Where("id = ?", categoryID).First(&category).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("category_id = ?", categoryID).Delete(&models.CategoryAttribute{}).Error; err != nil {
+			return err
+		}
+		if len(attributes) == 0 {
+			return nil
+		}
+		for i := range attributes {
+			attributes[i].ID = 0
+			attributes[i].CategoryID = categoryID
+			attributes[i].Position = i
+		}
+		return tx.Create(&attributes).Error
+	})
+}
+
+// replaceProductAttributes swaps a product's attribute values for attributes
+func replaceProductAttributes(tx *gorm.DB, productID uint, attributes []models.ProductAttribute) error {
+	if err := tx.Where("product_id = ?", productID).Delete(&models.ProductAttribute{}).Error; err != nil {
+		return err
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+	for i := range attributes {
+		attributes[i].ProductID = productID
+	}
+	return tx.Create(&attributes).Error
+}
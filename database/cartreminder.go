@@ -0,0 +1,102 @@
+package database
+
+import (
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+)
+
+// reminderConversionWindow is how long after a reminder a payment still counts as a conversion
+const reminderConversionWindow = 7 * 24 * time.Hour
+
+// GetAbandonedCarts finds up to limit carts due a reminder. A cart is due its first reminder once its
+// unpaid products have not changed since firstBefore, and its second once the first was sent before
+// secondBefore with no change since. Pass a zero secondBefore to send only first reminders.
+// Buyers who turned reminders off are left out.
+func (pdb *PostgresDb) GetAbandonedCarts(firstBefore, secondBefore time.Time, limit int) ([]models.AbandonedCart, error) {
+	var carts []models.AbandonedCart
+	err := pdb.DB.Raw(`
+WITH activity AS (
+	SELECT cp.cart_id, c.buyer_id, b.email, b.first_name, MAX(cp.updated_at) AS last_activity_at
+	FROM cart_products cp
+	JOIN carts c ON c.id = cp.cart_id AND c.deleted_at IS NULL
+	JOIN buyers b ON b.id = c.buyer_id AND b.deleted_at IS NULL
+	WHERE cp.order_status = false AND cp.deleted_at IS NULL AND b.cart_reminders_off = false
+	GROUP BY cp.cart_id, c.buyer_id, b.email, b.first_name
+)
+SELECT a.cart_id, a.buyer_id, a.email, a.first_name, a.last_activity_at,
+	CASE WHEN r1.id IS NULL THEN 1 ELSE 2 END AS stage
+FROM activity a
+LEFT JOIN cart_reminders r1 ON r1.cart_id = a.cart_id AND r1.stage = 1
+	AND r1.last_activity_at = a.last_activity_at AND r1.deleted_at IS NULL
+LEFT JOIN cart_reminders r2 ON r2.cart_id = a.cart_id AND r2.stage = 2
+	AND r2.last_activity_at = a.last_activity_at AND r2.deleted_at IS NULL
+WHERE r2.id IS NULL
+	AND ((r1.id IS NULL AND a.last_activity_at <= ?) OR (r1.id IS NOT NULL AND r1.created_at <= ?))
+ORDER BY a.last_activity_at
+LIMIT ?`, firstBefore, secondBefore, limit).Scan(&carts).Error
+	if err != nil {
+		return nil, err
+	}
+	return carts, nil
+}
+
+// CreateCartReminder records a reminder, creating its coupon first when there is one
+func (pdb *PostgresDb) CreateCartReminder(reminder *models.CartReminder, coupon *models.Coupon) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		if coupon != nil {
+			if err := tx.Create(coupon).Error; err != nil {
+				return err
+			}
+			reminder.CouponID = &coupon.ID
+			reminder.Coupon = coupon
+		}
+		return tx.Omit("Coupon").Create(reminder).Error
+	})
+}
+
+// ClickCartReminder records that the buyer followed the link in the reminder with token
+func (pdb *PostgresDb) ClickCartReminder(token string) (*models.CartReminder, error) {
+	reminder := &models.CartReminder{}
+	err := pdb.DB.Preload("Coupon").Where("token = ?", token).First(reminder).Error
+	if err != nil {
+		return nil, err
+	}
+	if reminder.ClickedAt == nil {
+		now := time.Now()
+		reminder.ClickedAt = &now
+		err = pdb.DB.Model(reminder).Update("clicked_at", now).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reminder, nil
+}
+
+// SetCartReminders turns abandoned cart reminders off, or back on, for a buyer
+func (pdb *PostgresDb) SetCartReminders(buyerID uint, off bool) error {
+	return pdb.DB.Model(&models.Buyer{}).Where("id = ?", buyerID).Update("cart_reminders_off", off).Error
+}
+
+// GetCartReminderStats counts the reminders sent since a time, and how many were clicked and converted
+func (pdb *PostgresDb) GetCartReminderStats(since time.Time) ([]models.CartReminderStats, error) {
+	var stats []models.CartReminderStats
+	err := pdb.DB.Model(&models.CartReminder{}).
+		Select("stage, COUNT(*) AS sent, COUNT(clicked_at) AS clicked, COUNT(converted_at) AS converted").
+		Where("created_at >= ?", since).
+		Group("stage").
+		Order("stage").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// convertCartReminders credits the cart's recent reminders with the payment made with reference
+func convertCartReminders(tx *gorm.DB, cartID uint, reference string) error {
+	return tx.Model(&models.CartReminder{}).
+		Where("cart_id = ? AND converted_at IS NULL AND created_at >= ?", cartID, time.Now().Add(-reminderConversionWindow)).
+		Updates(map[string]interface{}{"converted_at": time.Now(), "payment_reference": reference}).Error
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors changing the category tree
+var (
+	ErrCategorySlugTaken = errors.New("another category has this slug")
+	ErrCategoryCycle     = errors.New("a category can't be moved under itself or its children")
+	ErrCategoryInUse     = errors.New("category has products, choose a category to move them to")
+)
+
+// GetCategories lists every category, flat, by name
+func (pdb *PostgresDb) GetCategories() ([]models.Category, error) {
+	var categories []models.Category
+	if err := pdb.DB.Order("name").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// GetCategoryByID finds a category by its id
+func (pdb *PostgresDb) GetCategoryByID(id uint) (*models.Category, error) {
+	category := &models.Category{}
+	if err := pdb.DB.Where("id = ?", id).First(category).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// CreateCategory adds a category at the root of the tree, or under its ParentID
+func (pdb *PostgresDb) CreateCategory(category *models.Category) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		parent, err := categoryParent(tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := checkCategorySlug(tx, category.Slug, 0); err != nil {
+			return err
+		}
+		category.Path = ""
+		if err := tx.Create(category).Error; err != nil {
+			return err
+		}
+		category.Path = parent.ChildPath(category.ID)
+		return tx.Model(category).UpdateColumn("path", category.Path).Error
+	})
+}
+
+// UpdateCategory renames a category and moves it, with everything under it, to its ParentID
+func (pdb *PostgresDb) UpdateCategory(category *models.Category) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Category
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", category.ID).First(&current).Error
+		if err != nil {
+			return err
+		}
+		parent, err := categoryParent(tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+		if current.IsAncestorOf(parent) {
+			return ErrCategoryCycle
+		}
+		if err := checkCategorySlug(tx, category.Slug, category.ID); err != nil {
+			return err
+		}
+
+		category.Path = parent.ChildPath(category.ID)
+		err = tx.Model(&current).Updates(map[string]interface{}{
+			"name":      category.Name,
+			"slug":      category.Slug,
+			"parent_id": category.ParentID,
+			"path":      category.Path,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if category.Path == current.Path {
+			return nil
+		}
+		return moveCategoryPaths(tx, current.Path, category.Path)
+	})
+}
+
+// DeleteCategory removes a category. Its children move up to its parent and its products move to
+// reassignTo, or to its parent when reassignTo is nil; a root category with products needs reassignTo.
+// It returns how many products were moved.
+func (pdb *PostgresDb) DeleteCategory(id uint, reassignTo *uint) (int64, error) {
+	var moved int64
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&category).Error
+		if err != nil {
+			return err
+		}
+		parent, err := categoryParent(tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+
+		target := category.ParentID
+		if reassignTo != nil {
+			replacement, err := categoryParent(tx, reassignTo)
+			if err != nil {
+				return err
+			}
+			if category.IsAncestorOf(replacement) {
+				return ErrCategoryCycle
+			}
+			target = reassignTo
+		}
+		// deleted products keep pointing at a category too, so they move with the rest
+		products := tx.Unscoped().Model(&models.Product{}).Where("category_id = ?", category.ID)
+		if target == nil {
+			var count int64
+			if err := products.Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrCategoryInUse
+			}
+		} else {
+			result := products.UpdateColumn("category_id", *target)
+			if result.Error != nil {
+				return result.Error
+			}
+			moved = result.RowsAffected
+		}
+
+		err = tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).
+			UpdateColumn("parent_id", category.ParentID).Error
+		if err != nil {
+			return err
+		}
+		if err := moveCategoryPaths(tx, category.Path, parent.Path); err != nil {
+			return err
+		}
+		if err := tx.Where("category_id = ?", category.ID).Delete(&models.CategoryAttribute{}).Error; err != nil {
+			return err
+		}
+		// the products take on their new category's tax rule
+		if err := tx.Unscoped().Where("category_id = ?", category.ID).Delete(&models.TaxRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&category).Error
+	})
+	return moved, err
+}
+
+// backfillCategories gives categories from before the tree a slug and a path
+func (pdb *PostgresDb) backfillCategories() error {
+	var categories []models.Category
+	err := pdb.DB.Where("slug IS NULL OR slug = '' OR path IS NULL OR path = ''").Order("id").Find(&categories).Error
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		slug := category.Slug
+		if slug == "" {
+			slug = models.Slugify(category.Name)
+			if err := checkCategorySlug(pdb.DB, slug, category.ID); err != nil {
+				slug = fmt.Sprintf("%s-%d", slug, category.ID)
+			}
+		}
+		path := category.Path
+		if path == "" {
+			parent, err := categoryParent(pdb.DB, category.ParentID)
+			if err != nil {
+				return err
+			}
+			path = parent.ChildPath(category.ID)
+		}
+		err := pdb.DB.Model(&models.Category{}).Where("id = ?", category.ID).
+			UpdateColumns(map[string]interface{}{"slug": slug, "path": path}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// categoryBreadcrumbs sets each product's breadcrumbs from its preloaded category
+func categoryBreadcrumbs(db *gorm.DB, products ...*models.Product) error {
+	var ids []uint
+	for _, product := range products {
+		ids = append(ids, product.Category.PathIDs()...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var categories []models.Category
+	if err := db.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]models.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+	for _, product := range products {
+		product.Breadcrumbs = []models.Breadcrumb{}
+		for _, id := range product.Category.PathIDs() {
+			if category, ok := byID[id]; ok {
+				product.Breadcrumbs = append(product.Breadcrumbs, models.Breadcrumb{ID: category.ID, Name: category.Name, Slug: category.Slug})
+			}
+		}
+	}
+	return nil
+}
+
+// productBreadcrumbs sets the breadcrumbs of a list of products
+func productBreadcrumbs(db *gorm.DB, products []models.Product) error {
+	pointers := make([]*models.Product, 0, len(products))
+	for i := range products {
+		pointers = append(pointers, &products[i])
+	}
+	return categoryBreadcrumbs(db, pointers...)
+}
+
+// categoryParent loads the category with id, or the root of the tree when id is nil
+func categoryParent(tx *gorm.DB, id *uint) (*models.Category, error) {
+	if id == nil {
+		return &models.Category{Path: "/"}, nil
+	}
+	var parent models.Category
+	if err := tx.Where("id = ?", *id).First(&parent).Error; err != nil {
+		return nil, err
+	}
+	return &parent, nil
+}
+
+// checkCategorySlug makes sure no category other than id has slug
+func checkCategorySlug(tx *gorm.DB, slug string, id uint) error {
+	var count int64
+	if err := tx.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategorySlugTaken
+	}
+	return nil
+}
+
+// moveCategoryPaths swaps the oldPath prefix for newPath on every category below oldPath
+func moveCategoryPaths(tx *gorm.DB, oldPath, newPath string) error {
+	return tx.Model(&models.Category{}).Where("path LIKE ? AND path <> ?", oldPath+"%", oldPath).
+		UpdateColumn("path", gorm.Expr("? || SUBSTRING(path FROM ?)", newPath, len(oldPath)+1)).Error
+}
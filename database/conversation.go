@@ -0,0 +1,231 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// previewLength is how much of the last message a conversation list shows
+const previewLength = 100
+
+// participantSummary keeps a conversation's buyer and seller down to what the other side may see
+func participantSummary(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "first_name", "last_name", "username", "image")
+}
+
+// StartConversation finds the buyer and seller's thread about the same product and order, or starts one
+func (pdb *PostgresDb) StartConversation(conversation *models.Conversation) error {
+	query := pdb.DB.Where("buyer_id = ? AND seller_id = ?", conversation.BuyerID, conversation.SellerID)
+	if conversation.ProductID != nil {
+		query = query.Where("product_id = ?", *conversation.ProductID)
+	} else {
+		query = query.Where("product_id IS NULL")
+	}
+	if conversation.OrderID != nil {
+		query = query.Where("order_id = ?", *conversation.OrderID)
+	} else {
+		query = query.Where("order_id IS NULL")
+	}
+	var existing models.Conversation
+	err := query.Session(&gorm.Session{}).Order("id").First(&existing).Error
+	if err == nil {
+		*conversation = existing
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+	// two first messages can race here, the thread index keeps one and the other joins it
+	conversation.LastMessageAt = time.Now()
+	result := pdb.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(conversation)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	if err := query.Order("id").First(&existing).Error; err != nil {
+		return err
+	}
+	*conversation = existing
+	return nil
+}
+
+// GetConversation returns a conversation with its buyer and seller
+func (pdb *PostgresDb) GetConversation(id uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := pdb.DB.Preload("Buyer", participantSummary).Preload("Seller", participantSummary).
+		First(&conversation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetConversations returns a page of a buyer's or seller's conversations, most recently active first
+func (pdb *PostgresDb) GetConversations(participantType string, participantID uint, page, limit int) (*models.ConversationPage, error) {
+	result := &models.ConversationPage{Page: page, Limit: limit, Conversations: []models.Conversation{}}
+	column, unreadColumn := "buyer_id", "buyer_unread"
+	if participantType == models.RecipientSeller {
+		column, unreadColumn = "seller_id", "seller_unread"
+	}
+	query := pdb.DB.Model(&models.Conversation{}).Where(column+" = ?", participantID)
+	err := query.Session(&gorm.Session{}).
+		Select("COUNT(*) AS total, COALESCE(SUM("+unreadColumn+"), 0) AS unread").
+		Row().Scan(&result.Total, &result.Unread)
+	if err != nil {
+		return nil, err
+	}
+	err = query.Preload("Buyer", participantSummary).Preload("Seller", participantSummary).
+		Order("last_message_at desc").Offset((page - 1) * limit).Limit(limit).
+		Find(&result.Conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetMessages returns up to limit messages of a conversation older than beforeID, newest first.
+// A beforeID of 0 starts from the newest message.
+func (pdb *PostgresDb) GetMessages(conversationID, beforeID uint, limit int) ([]models.Message, error) {
+	messages := []models.Message{}
+	query := pdb.DB.Preload("Attachments").Where("conversation_id = ?", conversationID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	err := query.Order("id desc").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// GetMessage returns a message with its conversation
+func (pdb *PostgresDb) GetMessage(id uint) (*models.Message, error) {
+	var message models.Message
+	if err := pdb.DB.Preload("Conversation").Preload("Attachments").First(&message, id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// SendMessage saves a message with its attachments, bumps the conversation and the recipient's unread count,
+// and pushes the message to the recipient's open event streams. message.Conversation is the updated conversation.
+func (pdb *PostgresDb) SendMessage(message *models.Message) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var conversation models.Conversation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&conversation, message.ConversationID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+
+		recipientType, recipientID := conversation.Counterpart(message.SenderType)
+		unreadColumn := "seller_unread"
+		if recipientType == models.RecipientBuyer {
+			unreadColumn = "buyer_unread"
+			conversation.BuyerUnread++
+		} else {
+			conversation.SellerUnread++
+		}
+		conversation.LastMessage = messagePreview(message)
+		conversation.LastMessageAt = message.CreatedAt
+		err = tx.Model(&conversation).UpdateColumns(map[string]interface{}{
+			"last_message":    conversation.LastMessage,
+			"last_message_at": conversation.LastMessageAt,
+			unreadColumn:      gorm.Expr(unreadColumn + " + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		message.Conversation = &conversation
+
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return publishRealtime(tx, []models.RealtimeMessage{{
+			RecipientType: recipientType,
+			RecipientID:   recipientID,
+			Event:         models.RealtimeMessageSent,
+			Data:          data,
+		}})
+	})
+}
+
+func messagePreview(message *models.Message) string {
+	if message.Body == "" && len(message.Attachments) > 0 {
+		return "[image]"
+	}
+	if utf8.RuneCountInString(message.Body) <= previewLength {
+		return message.Body
+	}
+	return string([]rune(message.Body)[:previewLength]) + "…"
+}
+
+// MarkConversationRead sets the read receipt on every message the reader has been sent in a conversation,
+// clears their unread count and lets the sender's open event streams know. It returns how many messages it marked.
+func (pdb *PostgresDb) MarkConversationRead(conversation *models.Conversation, readerType string) (int64, error) {
+	var marked int64
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		senderType, senderID := conversation.Counterpart(readerType)
+		now := time.Now()
+		result := tx.Model(&models.Message{}).
+			Where("conversation_id = ? AND sender_type = ? AND read_at IS NULL", conversation.ID, senderType).
+			Update("read_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		marked = result.RowsAffected
+
+		unreadColumn := "buyer_unread"
+		conversation.BuyerUnread = 0
+		if readerType == models.RecipientSeller {
+			unreadColumn = "seller_unread"
+			conversation.SellerUnread = 0
+		}
+		if err := tx.Model(conversation).UpdateColumn(unreadColumn, 0).Error; err != nil {
+			return err
+		}
+		if marked == 0 {
+			return nil
+		}
+		data, err := json.Marshal(map[string]interface{}{"conversation_id": conversation.ID, "read_at": now})
+		if err != nil {
+			return err
+		}
+		return publishRealtime(tx, []models.RealtimeMessage{{
+			RecipientType: senderType,
+			RecipientID:   senderID,
+			Event:         models.RealtimeMessagesRead,
+			Data:          data,
+		}})
+	})
+	return marked, err
+}
+
+// ReportMessage flags a message for review. Reporting the same message twice keeps the first report.
+func (pdb *PostgresDb) ReportMessage(report *models.MessageReport) error {
+	report.Status = models.ReportStatusOpen
+	return pdb.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(report).Error
+}
+
+// GetMessageReports lists message reports with a status, oldest first, with the reported message
+func (pdb *PostgresDb) GetMessageReports(status string) ([]models.MessageReport, error) {
+	reports := []models.MessageReport{}
+	err := pdb.DB.Preload("Message.Attachments").Where("status = ?", status).Order("id").Find(&reports).Error
+	return reports, err
+}
+
+// SetMessageReportStatus closes a message report as dismissed or actioned
+func (pdb *PostgresDb) SetMessageReportStatus(id uint, status string) error {
+	result := pdb.DB.Model(&models.MessageReport{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
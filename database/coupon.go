@@ -0,0 +1,48 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+)
+
+// ErrCouponInvalid is returned for a coupon that does not exist, is not the buyer's,
+// has expired or has already been used
+var ErrCouponInvalid = errors.New("coupon is invalid or has expired")
+
+// ApplyCoupon puts the coupon with code on the buyer's cart, replacing any coupon already there
+func (pdb *PostgresDb) ApplyCoupon(buyerID uint, code string) (*models.Coupon, error) {
+	coupon := &models.Coupon{}
+	err := pdb.DB.Where("code = ?", code).First(coupon).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCouponInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !coupon.Usable(buyerID, time.Now()) {
+		return nil, ErrCouponInvalid
+	}
+	err = pdb.DB.Model(&models.Cart{}).Where("buyer_id = ?", buyerID).Update("coupon_id", coupon.ID).Error
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// RemoveCoupon takes any coupon off the buyer's cart
+func (pdb *PostgresDb) RemoveCoupon(buyerID uint) error {
+	return pdb.DB.Model(&models.Cart{}).Where("buyer_id = ?", buyerID).Update("coupon_id", nil).Error
+}
+
+// redeemCoupon marks the cart's coupon as used by the payment with reference and takes it off the cart
+func redeemCoupon(tx *gorm.DB, cart *models.Cart, code, reference string) error {
+	err := tx.Model(&models.Coupon{}).Where("code = ? AND redeemed_at IS NULL", code).
+		Updates(map[string]interface{}{"redeemed_at": time.Now(), "payment_reference": reference}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(cart).Update("coupon_id", nil).Error
+}
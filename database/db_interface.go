@@ -2,14 +2,14 @@ package database
 
 import (
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/decadevs/shoparena/models"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/joho/godotenv"
+	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
 	"os"
+	"time"
 )
 
 // DB provides access to the different db
@@ -23,18 +23,17 @@ type DB interface {
 	FindBuyerByUsername(username string) (*models.Buyer, error)
 	FindSellerByEmail(email string) (*models.Seller, error)
 	FindSellerByPhone(phone string) (*models.Seller, error)
-	UpdateBuyerImageURL(username, url string, buyerID uint) error
-	UpdateSellerImageURL(username, url string, sellerID uint) error
+	UpdateBuyerImageURL(username, url string, buyerID uint, variants models.ImageVariants) error
+	UpdateSellerImageURL(username, url string, sellerID uint, variants models.ImageVariants) error
 	FindSellerByUsername(username string) (*models.Seller, error)
 	FindSellerById(Id uint) (*models.Seller, error)
 	FindProductById(Id uint) (*models.Product, error)
-	SearchProduct(lowerPrice, upperPrice, category, name string) ([]models.Product, error)
+	SearchProduct(lowerPrice, upperPrice, category, name string, attributes map[string][]string) ([]models.Product, error)
 	TokenInBlacklist(token *string) bool
 	UpdateBuyerProfile(id uint, update *models.UpdateUser) error
 	UpdateSellerProfile(id uint, update *models.UpdateUser) error
 	UpdateSellerRating(id uint, update *models.UpdateRating) error
 	UpdateProductRating(id uint, update *models.UpdateRating) error
-	UploadFileToS3(h *session.Session, file multipart.File, fileName string, size int64) (string, error)
 	CreateProduct(product models.Product) error
 	GetCategory(category string) (*models.Category, error)
 	DeleteProduct(productID, sellerID uint) error
@@ -53,10 +52,10 @@ type DB interface {
 	GetAllSellerOrder(sellerId uint) ([]models.Order, error)
 	GetAllSellerOrderCount(sellerId uint) (int, error)
 	FindPaidProduct(sellerID uint) ([]models.CartProduct, error)
-	AddToCart(product models.Product, buyer *models.Buyer) error
+	AddToCart(product models.Product, variantID *uint, buyer *models.Buyer) error
 	GetCartProducts(buyer *models.Buyer) ([]models.CartProduct, error)
 	ViewCartProducts(addedProducts []models.CartProduct) ([]models.ProductDetails, error)
-	DeletePaidFromCart(cartID uint) error
+	DeletePaidFromCart(cartID uint, reference string, amountPaid uint) ([]models.Invoice, error)
 	GetSellersProducts(sellerID uint) ([]models.Product, error)
 	FindSellerIndividualProduct(sellerID uint) (*models.Product, error)
 	FindCartProductSeller(sellerID, productID uint) (*models.CartProduct, error)
@@ -66,11 +65,115 @@ type DB interface {
 	DeleteAllSellerProducts(sellerID uint) error
 	GetAllSellerOrders(sellerId uint) ([]models.OrderProducts, error)
 	GetAllBuyerOrders(buyerId uint) ([]models.OrderProducts, error)
+	FindBuyerByReferralCode(code string) (*models.Buyer, error)
+	CreateReferral(referral *models.Referral) (*models.Referral, error)
+	GetBuyerReferrals(buyerID uint) ([]models.Referral, error)
+	CompleteReferral(refereeID, reward uint) error
+	GetTaxRules() (models.TaxRules, error)
+	SaveTaxRule(rule *models.TaxRule) (*models.TaxRule, error)
+	DeleteTaxRule(id uint) error
+	GetCheckoutSummary(buyer *models.Buyer) (*models.CheckoutSummary, error)
+	GetSellerSalesReport(sellerID uint, from, to time.Time) (*models.SalesReport, error)
+	GetInvoice(id uint) (*models.Invoice, error)
+	GetBuyerInvoices(buyerID uint) ([]models.Invoice, error)
+	GetSellerInvoices(sellerID uint) ([]models.Invoice, error)
+	MarkInvoiceEmailed(id uint) error
+	GetOrder(id uint) (*models.Order, error)
+	UpdateOrderStatus(id uint, from, to, note string) error
+	EnqueueEmail(message *models.EmailMessage) error
+	ClaimDueEmails(limit int) ([]models.EmailOutbox, error)
+	SaveEmailAttempt(email *models.EmailOutbox) error
+	EnqueueJob(job *models.Job) error
+	ClaimJobs(jobType string, limit int, workerID string, lease time.Duration) ([]models.Job, error)
+	SaveJobResult(job *models.Job) error
+	GetJobs(status string, limit int) ([]models.Job, error)
+	RetryJob(id uint) error
+	PruneJobs(before time.Time) (int64, error)
+	DeleteExpiredBlacklistTokens(before time.Time) (int64, error)
+	ClaimEvents(limit int) ([]models.DomainEvent, error)
+	SaveEventAttempt(event *models.DomainEvent) error
+	CreateWebhookEndpoint(endpoint *models.WebhookEndpoint) error
+	GetSellerWebhookEndpoints(sellerID uint) ([]models.WebhookEndpoint, error)
+	GetWebhookEndpoint(id, sellerID uint) (*models.WebhookEndpoint, error)
+	UpdateWebhookEndpoint(endpoint *models.WebhookEndpoint) error
+	DeleteWebhookEndpoint(id, sellerID uint) error
+	QueueWebhookDeliveries(sellerID, eventID uint, eventType, payload string) (int, error)
+	ClaimWebhookDeliveries(limit int) ([]models.WebhookDelivery, error)
+	SaveWebhookDelivery(delivery *models.WebhookDelivery) error
+	GetWebhookDeliveries(endpointID uint, limit int) ([]models.WebhookDelivery, error)
+	ReplayWebhookDelivery(id, endpointID uint) (*models.WebhookDelivery, error)
+	CreateAPIKey(key *models.APIKey) error
+	GetSellerAPIKeys(sellerID uint) ([]models.APIKey, error)
+	FindAPIKeyByPrefix(prefix string) (*models.APIKey, error)
+	RevokeAPIKey(id, sellerID uint) error
+	TouchAPIKey(id uint, at time.Time) error
+	ApplyCoupon(buyerID uint, code string) (*models.Coupon, error)
+	RemoveCoupon(buyerID uint) error
+	GetAbandonedCarts(firstBefore, secondBefore time.Time, limit int) ([]models.AbandonedCart, error)
+	CreateCartReminder(reminder *models.CartReminder, coupon *models.Coupon) error
+	ClickCartReminder(token string) (*models.CartReminder, error)
+	SetCartReminders(buyerID uint, off bool) error
+	GetCartReminderStats(since time.Time) ([]models.CartReminderStats, error)
+	SaveProductAlert(alert *models.ProductAlert) error
+	GetBuyerProductAlerts(buyerID uint) ([]models.ProductAlert, error)
+	DeleteProductAlert(id, buyerID uint) error
+	QueueBackInStockAlerts(productID, eventID, price uint) (int, error)
+	QueuePriceDropAlerts(productID, eventID, oldPrice, newPrice uint) (int, error)
+	ClaimAlertNotifications(limit int) ([]models.AlertNotification, error)
+	SendAlertEmail(message *models.EmailMessage, notificationIDs []uint) error
+	CreateNotifications(notifications []models.Notification) error
+	GetNotifications(recipientType string, recipientID uint, unreadOnly bool, page, limit int) (*models.NotificationPage, error)
+	SetNotificationRead(id uint, recipientType string, recipientID uint, read bool) error
+	MarkAllNotificationsRead(recipientType string, recipientID uint) (int64, error)
+	CountUnreadNotifications(recipientType string, recipientID uint) (map[string]int64, error)
+	PublishRealtime(messages []models.RealtimeMessage) error
+	StartConversation(conversation *models.Conversation) error
+	GetConversation(id uint) (*models.Conversation, error)
+	GetConversations(participantType string, participantID uint, page, limit int) (*models.ConversationPage, error)
+	GetMessages(conversationID, beforeID uint, limit int) ([]models.Message, error)
+	GetMessage(id uint) (*models.Message, error)
+	SendMessage(message *models.Message) error
+	MarkConversationRead(conversation *models.Conversation, readerType string) (int64, error)
+	ReportMessage(report *models.MessageReport) error
+	GetMessageReports(status string) ([]models.MessageReport, error)
+	SetMessageReportStatus(id uint, status string) error
+	CreateProductQuestion(question *models.ProductQuestion) error
+	GetProductQuestion(id uint) (*models.ProductQuestion, error)
+	GetProductQuestions(productID uint, page, limit int) (*models.ProductQuestionPage, error)
+	GetUnansweredQuestions(sellerID uint, page, limit int) (*models.ProductQuestionPage, error)
+	CreateProductAnswer(answer *models.ProductAnswer) error
+	HasBoughtProduct(buyerID, productID uint) (bool, error)
+	SetAnswerUpvote(answerID, buyerID uint, upvote bool) (int64, error)
+	SaveProductVariants(productID uint, options []models.ProductOption, variants []models.ProductVariant) error
+	AddVariantImages(productID, variantID uint, images []models.VariantImage) ([]models.VariantImage, error)
+	GetCategories() ([]models.Category, error)
+	GetCategoryByID(id uint) (*models.Category, error)
+	CreateCategory(category *models.Category) error
+	UpdateCategory(category *models.Category) error
+	DeleteCategory(id uint, reassignTo *uint) (int64, error)
+	GetCategoryAttributes(categoryID uint) ([]models.CategoryAttribute, error)
+	SaveCategoryAttributes(categoryID uint, attributes []models.CategoryAttribute) error
+	CreateProductImport(productImport *models.ProductImport) error
+	GetProductImport(id uint) (*models.ProductImport, error)
+	SaveProductImport(productImport *models.ProductImport) error
+	FindProductSKUs(sellerID uint, skus []string) (map[string]bool, error)
+	ImportProduct(sellerID uint, row models.ProductImportRow, attributes []models.ProductAttribute, flags []string) (*models.Product, bool, error)
+	ReplaceProductImages(productID uint, images []models.Image) error
+	GetSellerCatalogue(sellerID uint) ([]models.Product, error)
+	UpdateProductStatus(productID uint, status string, publishAt, unpublishAt *time.Time) (string, error)
+	PublishScheduledProducts(now time.Time) (published, unpublished int, err error)
+	ReportListing(report *models.ListingReport) error
+	GetListingReviews(status string) ([]models.ListingReview, error)
+	DecideListingReview(id uint, status, reason string) (*models.ListingReview, error)
+	AddProductImages(productID uint, images []models.Image) error
+	UpdateProductImageAltText(productID, imageID uint, altText string) (*models.Image, error)
+	ReorderProductImages(productID uint, imageIDs []uint) error
+	DeleteProductImage(productID, imageID uint) error
 }
 
 // Mailer interface to implement mailing service
 type Mailer interface {
-	SendMail(subject, body, to, Private, Domain string) error
+	Send(message *models.EmailMessage) error
 	GenerateNonAuthToken(UserEmail string, secret string) (*string, error)
 	DecodeToken(token, secret string) (string, error)
 }
@@ -82,6 +185,24 @@ type Paystack interface {
 	PayStackDecodeToken(token, secret string) (jwt.MapClaims, error)
 }
 
+// Storage keeps uploaded files under keys like product/<uuid>.png
+type Storage interface {
+	Put(key string, body io.Reader, size int64) error
+	// Get opens the file stored as key and returns its size, or services.ErrFileNotFound
+	Get(key string) (io.ReadCloser, int64, error)
+	Delete(key string) error
+	// URL is where the file stored as key is served from
+	URL(key string) string
+	// Key is the key of the file served at url, false when it isn't one of the storage's
+	Key(url string) (string, bool)
+	// SignedURL lets whoever has it make a method request for key until expires has passed
+	SignedURL(method, key string, expires time.Duration) (string, error)
+	// SignedUpload lets whoever has it PUT exactly size bytes of contentType as key until expires has passed
+	SignedUpload(key, contentType string, size int64, expires time.Duration) (string, error)
+	// DeleteOlderThan removes the files under folder last changed before before and returns how many it removed
+	DeleteOlderThan(folder string, before time.Time) (int, error)
+}
+
 // ValidationError defines error that occur due to validation
 type ValidationError struct {
 	Field   string `json:"field"`
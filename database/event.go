@@ -0,0 +1,76 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// eventClaimLease is how long claimed events are hidden from other relays while they are published
+const eventClaimLease = 2 * time.Minute
+
+// recordEvent writes a domain event to the outbox as part of tx, numbered after the aggregate's last event.
+// The unique index on the sequence fails the transaction if two writers race for the same number.
+func recordEvent(tx *gorm.DB, aggregateType string, aggregateID uint, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var last uint
+	err = tx.Model(&models.DomainEvent{}).
+		Where("aggregate_type = ? AND aggregate_id = ?", aggregateType, aggregateID).
+		Select("COALESCE(MAX(sequence), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.DomainEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Sequence:      last + 1,
+		Payload:       string(data),
+		Status:        models.EventStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// ClaimEvents takes up to limit events that are due to be published, oldest first.
+// Only the earliest pending event of each aggregate is eligible, so an aggregate's events
+// go out one at a time and in order even with several relays running.
+func (pdb *PostgresDb) ClaimEvents(limit int) ([]models.DomainEvent, error) {
+	var events []models.DomainEvent
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.EventStatusPending, time.Now()).
+			Where(`NOT EXISTS (SELECT 1 FROM domain_events earlier
+				WHERE earlier.aggregate_type = domain_events.aggregate_type
+				AND earlier.aggregate_id = domain_events.aggregate_id
+				AND earlier.sequence < domain_events.sequence
+				AND earlier.status = ?)`, models.EventStatusPending).
+			Order("id").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]uint, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&models.DomainEvent{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(eventClaimLease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// SaveEventAttempt records the outcome of publishing an event
+func (pdb *PostgresDb) SaveEventAttempt(event *models.DomainEvent) error {
+	return pdb.DB.Model(event).Select("status", "attempts", "next_attempt_at", "last_error", "published_at").
+		Updates(event).Error
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// createInvoices raises an invoice for each seller in a freshly paid set of orders.
+// The seller row is locked while its next sequence number is taken so numbers stay sequential.
+func createInvoices(tx *gorm.DB, orders []models.Order) ([]models.Invoice, error) {
+	var sellerIDs []uint
+	bySeller := map[uint][]int{}
+	for i, order := range orders {
+		if _, ok := bySeller[order.SellerId]; !ok {
+			sellerIDs = append(sellerIDs, order.SellerId)
+		}
+		bySeller[order.SellerId] = append(bySeller[order.SellerId], i)
+	}
+
+	var invoices []models.Invoice
+	for _, sellerID := range sellerIDs {
+		seller := &models.Seller{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", sellerID).First(seller).Error; err != nil {
+			return nil, err
+		}
+		sequence := seller.LastInvoiceSequence + 1
+		if err := tx.Model(&models.Seller{}).Where("id = ?", sellerID).
+			Update("last_invoice_sequence", sequence).Error; err != nil {
+			return nil, err
+		}
+
+		invoice := models.Invoice{
+			Number:   models.InvoiceNumber(sellerID, sequence),
+			SellerID: sellerID,
+			Sequence: sequence,
+		}
+		var orderIDs []uint
+		for _, i := range bySeller[sellerID] {
+			order := orders[i]
+			invoice.BuyerID = order.BuyerId
+			invoice.PaymentReference = order.PaymentReference
+			invoice.Subtotal += order.Subtotal
+			invoice.Tax += order.TaxAmount
+			invoice.Total += order.Total
+			orderIDs = append(orderIDs, order.ID)
+		}
+		invoice.Total += invoice.Shipping
+
+		if err := tx.Create(&invoice).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.Order{}).Where("id IN ?", orderIDs).
+			Update("invoice_id", invoice.ID).Error; err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, nil
+}
+
+// GetInvoice returns an invoice with everything needed to print it
+func (pdb *PostgresDb) GetInvoice(id uint) (*models.Invoice, error) {
+	invoice := &models.Invoice{}
+	err := pdb.DB.Where("id = ?", id).
+		Preload("Seller").
+		Preload("Buyer").
+		Preload("Orders").
+		Preload("Orders.Product").
+		First(invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// GetBuyerInvoices returns the buyer's invoices, newest first
+func (pdb *PostgresDb) GetBuyerInvoices(buyerID uint) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := pdb.DB.Where("buyer_id = ?", buyerID).Order("created_at desc").Find(&invoices).Error
+	if err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// GetSellerInvoices returns the seller's invoices, newest first
+func (pdb *PostgresDb) GetSellerInvoices(sellerID uint) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := pdb.DB.Where("seller_id = ?", sellerID).Order("sequence desc").Find(&invoices).Error
+	if err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// MarkInvoiceEmailed records when the invoice was queued to be emailed to the buyer
+func (pdb *PostgresDb) MarkInvoiceEmailed(id uint) error {
+	return pdb.DB.Model(&models.Invoice{}).Where("id = ?", id).Update("emailed_at", time.Now()).Error
+}
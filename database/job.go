@@ -0,0 +1,112 @@
+package database
+
+import (
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnqueueJob adds a job to the queue. A job whose UniqueKey is already queued is dropped.
+func (pdb *PostgresDb) EnqueueJob(job *models.Job) error {
+	if job.Status == "" {
+		job.Status = models.JobStatusPending
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	return pdb.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(job).Error
+}
+
+// ClaimJobs locks up to limit jobs of a type that are due, for workerID, until the lease runs out.
+// Running jobs whose lease has expired belonged to a worker that died and are claimed again.
+func (pdb *PostgresDb) ClaimJobs(jobType string, limit int, workerID string, lease time.Duration) ([]models.Job, error) {
+	var jobs []models.Job
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("type = ?", jobType).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.JobStatusPending, now, models.JobStatusRunning, now).
+			Order("run_at").
+			Limit(limit).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		lockedUntil := now.Add(lease)
+		ids := make([]uint, len(jobs))
+		for i := range jobs {
+			ids[i] = jobs[i].ID
+			jobs[i].Status = models.JobStatusRunning
+			jobs[i].Attempts++
+			jobs[i].LockedBy = workerID
+			jobs[i].LockedUntil = &lockedUntil
+		}
+		return tx.Model(&models.Job{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       models.JobStatusRunning,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_by":    workerID,
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// SaveJobResult records how a claimed job went
+func (pdb *PostgresDb) SaveJobResult(job *models.Job) error {
+	return pdb.DB.Model(job).
+		Select("status", "run_at", "locked_by", "locked_until", "last_error", "finished_at").
+		Updates(job).Error
+}
+
+// GetJobs lists jobs, newest first, optionally only those with status
+func (pdb *PostgresDb) GetJobs(status string, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	query := pdb.DB.Order("id desc").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// RetryJob puts a failed job back on the queue to run now
+func (pdb *PostgresDb) RetryJob(id uint) error {
+	result := pdb.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ?", id, models.JobStatusFailed).
+		Updates(map[string]interface{}{
+			"status":      models.JobStatusPending,
+			"attempts":    0,
+			"run_at":      time.Now(),
+			"finished_at": nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// PruneJobs deletes jobs that finished successfully before the given time
+func (pdb *PostgresDb) PruneJobs(before time.Time) (int64, error) {
+	result := pdb.DB.Unscoped().
+		Where("status = ? AND finished_at < ?", models.JobStatusDone, before).
+		Delete(&models.Job{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpiredBlacklistTokens removes blacklisted tokens added before the given time,
+// by which point the tokens have expired and would be rejected anyway
+func (pdb *PostgresDb) DeleteExpiredBlacklistTokens(before time.Time) (int64, error) {
+	result := pdb.DB.Where("created_at < ?", before).Delete(&models.Blacklist{})
+	return result.RowsAffected, result.Error
+}
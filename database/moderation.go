@@ -0,0 +1,143 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReviewDecided is returned when an admin decides a review that has already been decided
+var ErrReviewDecided = errors.New("this review has already been decided")
+
+// ReportListing records a buyer's report of a product and adds it to the product's review.
+// A buyer reporting the same product again is ignored and leaves report.ID at zero.
+func (pdb *PostgresDb) ReportListing(report *models.ListingReport) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		product := &models.Product{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", report.ProductID).First(product).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(report)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			report.ID = 0
+			return nil
+		}
+		review, err := queueListingReview(tx, product, models.NewListingReview(models.ReviewReported, nil))
+		if err != nil {
+			return err
+		}
+		report.ReviewID = review.ID
+		return tx.Model(report).Update("review_id", review.ID).Error
+	})
+}
+
+// GetListingReviews lists the reviews with a status, flagged and most reported first, then oldest first
+func (pdb *PostgresDb) GetListingReviews(status string) ([]models.ListingReview, error) {
+	reviews := []models.ListingReview{}
+	err := pdb.DB.Preload("Product.Images").Preload("Reports").Where("status = ?", status).
+		Order("flags <> '' DESC, report_count DESC, id").Find(&reviews).Error
+	return reviews, err
+}
+
+// DecideListingReview approves or rejects a pending review. Approving puts a product held for review
+// live; rejecting takes the product down to a draft until the seller edits it, so carts holding it
+// can't be checked out either.
+func (pdb *PostgresDb) DecideListingReview(id uint, status, reason string) (*models.ListingReview, error) {
+	review := &models.ListingReview{}
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(review).Error
+		if err != nil {
+			return err
+		}
+		if review.Status != models.ModerationPending {
+			return ErrReviewDecided
+		}
+		now := time.Now()
+		review.Status, review.Reason, review.ReviewedAt = status, reason, &now
+		err = tx.Model(review).Select("status", "reason", "reviewed_at").Updates(review).Error
+		if err != nil {
+			return err
+		}
+
+		product := &models.Product{}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", review.ProductID).First(product).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// the seller deleted the product while it waited
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		review.Product = product
+		if err := tx.Model(product).UpdateColumn("moderation_status", status).Error; err != nil {
+			return err
+		}
+		product.ModerationStatus = status
+		switch {
+		case status == models.ModerationApproved && product.Status == models.ProductPendingReview:
+			return setProductStatus(tx, product, models.ProductActive, nil, product.UnpublishAt)
+		case status == models.ModerationRejected &&
+			(product.Status == models.ProductActive || product.Status == models.ProductPendingReview):
+			return setProductStatus(tx, product, models.ProductDraft, nil, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// queueListingReview adds review to the product's pending review as part of tx, starting one if it
+// has none. Changes to the listing need approving again; reports leave it as it is. A listing with
+// banned keywords is kept from buyers, and out of their checkouts, until it is approved.
+func queueListingReview(tx *gorm.DB, product *models.Product, review models.ListingReview) (*models.ListingReview, error) {
+	open := &models.ListingReview{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND status = ?", product.ID, models.ModerationPending).First(open).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		*open = review
+		open.ProductID, open.SellerID = product.ID, product.SellerId
+		if review.Trigger == models.ReviewReported {
+			open.ReportCount = 1
+		}
+		if err := tx.Create(open).Error; err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		open.AddFlags(strings.Split(review.Flags, ","))
+		if review.Trigger == models.ReviewReported {
+			open.ReportCount++
+		} else if open.Trigger == models.ReviewReported {
+			// the listing has changed since it was reported, so it is the changes that need approving
+			open.Trigger = review.Trigger
+		}
+		if err := tx.Model(open).Select("trigger", "flags", "report_count").Updates(open).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if review.Trigger != models.ReviewReported && product.ModerationStatus != models.ModerationPending {
+		if err := tx.Model(product).UpdateColumn("moderation_status", models.ModerationPending).Error; err != nil {
+			return nil, err
+		}
+		product.ModerationStatus = models.ModerationPending
+	}
+	if review.Holds() && product.Status == models.ProductActive {
+		if err := setProductStatus(tx, product, models.ProductPendingReview, nil, product.UnpublishAt); err != nil {
+			return nil, err
+		}
+	}
+	return open, nil
+}
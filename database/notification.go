@@ -0,0 +1,111 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateNotifications saves new notifications, skipping any already saved for the same event,
+// and pushes the new ones to their recipients' open event streams
+func (pdb *PostgresDb) CreateNotifications(notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var messages []models.RealtimeMessage
+		for i := range notifications {
+			notification := &notifications[i]
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			data, err := json.Marshal(notification)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, models.RealtimeMessage{
+				RecipientType: notification.RecipientType,
+				RecipientID:   notification.RecipientID,
+				Event:         models.RealtimeNotification,
+				Data:          data,
+			})
+		}
+		return publishRealtime(tx, messages)
+	})
+}
+
+// GetNotifications returns a page of a user's notifications, newest first, with their totals
+func (pdb *PostgresDb) GetNotifications(recipientType string, recipientID uint, unreadOnly bool, page, limit int) (*models.NotificationPage, error) {
+	result := &models.NotificationPage{Page: page, Limit: limit, Notifications: []models.Notification{}}
+	query := pdb.DB.Model(&models.Notification{}).
+		Where("recipient_type = ? AND recipient_id = ?", recipientType, recipientID)
+	if err := query.Session(&gorm.Session{}).Where("read = ?", false).Count(&result.Unread).Error; err != nil {
+		return nil, err
+	}
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	err := query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&result.Notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetNotificationRead marks one of a user's notifications read or unread
+func (pdb *PostgresDb) SetNotificationRead(id uint, recipientType string, recipientID uint, read bool) error {
+	var readAt *time.Time
+	if read {
+		now := time.Now()
+		readAt = &now
+	}
+	result := pdb.DB.Model(&models.Notification{}).
+		Where("id = ? AND recipient_type = ? AND recipient_id = ?", id, recipientType, recipientID).
+		Updates(map[string]interface{}{"read": read, "read_at": readAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of a user read and returns how many there were
+func (pdb *PostgresDb) MarkAllNotificationsRead(recipientType string, recipientID uint) (int64, error) {
+	result := pdb.DB.Model(&models.Notification{}).
+		Where("recipient_type = ? AND recipient_id = ? AND read = ?", recipientType, recipientID, false).
+		Updates(map[string]interface{}{"read": true, "read_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// CountUnreadNotifications counts a user's unread notifications by type
+func (pdb *PostgresDb) CountUnreadNotifications(recipientType string, recipientID uint) (map[string]int64, error) {
+	var rows []struct {
+		Type  string
+		Count int64
+	}
+	err := pdb.DB.Model(&models.Notification{}).
+		Select("type, COUNT(*) AS count").
+		Where("recipient_type = ? AND recipient_id = ? AND read = ?", recipientType, recipientID, false).
+		Group("type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Type] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+)
+
+// ErrOrderStatusChanged is returned when an order's status moved on before an update was applied
+var ErrOrderStatusChanged = errors.New("order status has changed")
+
+// GetOrder returns an order with its buyer, seller and product
+func (pdb *PostgresDb) GetOrder(id uint) (*models.Order, error) {
+	order := &models.Order{}
+	err := pdb.DB.Where("id = ?", id).
+		Preload("Seller").
+		Preload("Buyer").
+		Preload("Product").
+		First(order).Error
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// UpdateOrderStatus moves an order from one status to another and records the matching order event.
+// It fails with ErrOrderStatusChanged if the order is no longer in the from status.
+func (pdb *PostgresDb) UpdateOrderStatus(id uint, from, to, note string) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", id, from).
+			Updates(map[string]interface{}{
+				"status":            to,
+				"status_note":       note,
+				"status_updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderStatusChanged
+		}
+
+		eventType, ok := models.OrderStatusEvents[to]
+		if !ok {
+			return nil
+		}
+		order := &models.Order{}
+		if err := tx.Where("id = ?", id).First(order).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, models.AggregateOrder, id, eventType, models.NewOrderEvent(order))
+	})
+}
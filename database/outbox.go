@@ -0,0 +1,63 @@
+package database
+
+import (
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// emailClaimLease is how long a claimed email is hidden from other senders while it is being sent
+const emailClaimLease = 5 * time.Minute
+
+// EnqueueEmail adds an email to the outbox to be sent in the background
+func (pdb *PostgresDb) EnqueueEmail(message *models.EmailMessage) error {
+	return enqueueEmail(pdb.DB, message)
+}
+
+// enqueueEmail adds an email to the outbox as part of tx
+func enqueueEmail(tx *gorm.DB, message *models.EmailMessage) error {
+	return tx.Create(&models.EmailOutbox{
+		To:            message.To,
+		Subject:       message.Subject,
+		HTML:          message.HTML,
+		Text:          message.Text,
+		Attachments:   message.Attachments,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// ClaimDueEmails takes up to limit pending emails that are due to be sent.
+// Claimed emails are pushed back by a lease so that other senders skip them,
+// and rows another sender has locked are skipped rather than waited on.
+func (pdb *PostgresDb) ClaimDueEmails(limit int) ([]models.EmailOutbox, error) {
+	var emails []models.EmailOutbox
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.EmailStatusPending, time.Now()).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&emails).Error
+		if err != nil || len(emails) == 0 {
+			return err
+		}
+		ids := make([]uint, len(emails))
+		for i := range emails {
+			ids[i] = emails[i].ID
+		}
+		return tx.Model(&models.EmailOutbox{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(emailClaimLease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// SaveEmailAttempt records the outcome of trying to send an outbox email
+func (pdb *PostgresDb) SaveEmailAttempt(email *models.EmailOutbox) error {
+	return pdb.DB.Model(email).Select("status", "attempts", "next_attempt_at", "last_error", "sent_at").
+		Updates(email).Error
+}
@@ -1,21 +1,17 @@
 package database
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"mime/multipart"
-	"net/http"
 	"os"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/decadevs/shoparena/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -56,15 +52,31 @@ func (pdb *PostgresDb) Init(host, user, password, dbName, port string) error {
 
 func (pdb *PostgresDb) PrePopulateTables() error {
 	err := pdb.DB.AutoMigrate(&models.Category{}, &models.Seller{}, &models.Product{}, &models.Image{},
-		&models.Buyer{}, &models.Cart{}, &models.CartProduct{}, &models.Order{}, &models.Blacklist{})
+		&models.Buyer{}, &models.Cart{}, &models.CartProduct{}, &models.Order{}, &models.Blacklist{},
+		&models.Referral{}, &models.TaxRule{}, &models.Invoice{}, &models.EmailOutbox{},
+		&models.Job{}, &models.DomainEvent{}, &models.WebhookEndpoint{}, &models.WebhookDelivery{}, &models.APIKey{},
+		&models.Coupon{}, &models.CartReminder{}, &models.ProductAlert{}, &models.AlertNotification{},
+		&models.Notification{}, &models.Conversation{}, &models.Message{}, &models.MessageAttachment{},
+		&models.MessageReport{}, &models.ProductQuestion{}, &models.ProductAnswer{}, &models.AnswerUpvote{},
+		&models.ProductOption{}, &models.ProductVariant{}, &models.VariantImage{}, &models.CategoryAttribute{},
+		&models.ProductAttribute{}, &models.ProductImport{}, &models.ListingReview{}, &models.ListingReport{})
 	if err != nil {
 		return fmt.Errorf("migration error: %v", err)
 	}
+	if err := pdb.seedTaxRules(); err != nil {
+		return fmt.Errorf("tax rule seed error: %v", err)
+	}
+	if err := pdb.backfillReferralCodes(); err != nil {
+		return fmt.Errorf("referral code backfill error: %v", err)
+	}
 	categories := []models.Category{{Name: "fashion"}, {Name: "electronics"}, {Name: "health & beauty"}, {Name: "baby products"}, {Name: "phones & tablets"}, {Name: "food drinks"}, {Name: "computing"}, {Name: "sporting goods"}, {Name: "others"}}
 	result := pdb.DB.Find(&models.Category{})
 	if result.RowsAffected < 1 {
 		pdb.DB.Create(&categories)
 	}
+	if err := pdb.backfillCategories(); err != nil {
+		return fmt.Errorf("category backfill error: %v", err)
+	}
 
 	//user := models.User{
 	//	Model:           gorm.Model{},
@@ -166,154 +178,149 @@ func (pdb *PostgresDb) PrePopulateTables() error {
 //GET ALL PRODUCTS FROM DB
 func (pdb *PostgresDb) GetAllProducts() []models.Product {
 	var products []models.Product
-	if err := pdb.DB.Preload("Images").Find(&products).Error; err != nil {
+	err := pdb.DB.Preload("Category").Preload("Images").Preload("AttributeValues").
+		Where("status = ?", models.ProductActive).Find(&products).Error
+	if err != nil {
 		log.Println("Could not find product", err)
 	}
+	if err := productBreadcrumbs(pdb.DB, products); err != nil {
+		log.Println("Could not find product categories", err)
+	}
 
 	return products
 }
 
 //UPDATE PRODUCT BY ID
 func (pdb *PostgresDb) UpdateProductByID(Id uint, prod models.Product) error {
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		return updateProduct(tx, Id, prod)
+	})
+	if err != nil {
+		fmt.Println("error in updating in postgres db")
+		return err
+	}
+	return nil
+}
+
+// updateProduct changes a product's details as part of tx, recording price and stock changes
+func updateProduct(tx *gorm.DB, Id uint, prod models.Product) error {
 	products := models.Product{}
+	// lock the product so price change events are recorded in the order the changes happen
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", Id).First(&products).Error
+	if err != nil {
+		return err
+	}
+	oldPrice, oldQuantity := products.Price, products.Quantity
+
+	// a product with variants has their stock, which is changed through the variants
+	var variants int64
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", Id).Count(&variants).Error; err != nil {
+		return err
+	}
+	if variants > 0 {
+		prod.Quantity = oldQuantity
+	}
+	// attribute values are only replaced when they are sent
+	if prod.AttributeValues != nil {
+		if err := replaceProductAttributes(tx, Id, prod.AttributeValues); err != nil {
+			return err
+		}
+	}
 
-	err := pdb.DB.Model(&products).Where("id = ?", Id).Update("title", prod.Title).
+	err = tx.Model(&products).Where("id = ?", Id).Update("title", prod.Title).
 		Update("description", prod.Description).Update("price", prod.Price).
 		Update("rating", prod.Rating).Update("quantity", prod.Quantity).Error
 	if err != nil {
-		fmt.Println("error in updating in postgres db")
 		return err
 	}
+	// changes to the listing go back in the moderation queue
+	for _, review := range prod.Reviews {
+		if _, err := queueListingReview(tx, &products, review); err != nil {
+			return err
+		}
+	}
+
+	if oldPrice != prod.Price {
+		err = recordEvent(tx, models.AggregateProduct, Id, models.EventProductPriceChanged, models.PriceChangedEvent{
+			ProductID: Id,
+			SellerID:  products.SellerId,
+			OldPrice:  oldPrice,
+			NewPrice:  prod.Price,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if oldQuantity != prod.Quantity {
+		return recordEvent(tx, models.AggregateProduct, Id, models.EventProductStockChanged, models.StockChangedEvent{
+			ProductID:   Id,
+			SellerID:    products.SellerId,
+			OldQuantity: oldQuantity,
+			NewQuantity: prod.Quantity,
+			Price:       prod.Price,
+		})
+	}
 	return nil
 }
 
-// SearchProduct Searches all products from DB
-func (pdb *PostgresDb) SearchProduct(lowerPrice, upperPrice, categoryName, name string) ([]models.Product, error) {
+// SearchProduct searches products by price range, category and title, leaving out whichever are empty.
+// The category is an id or a slug and takes in the categories below it.
+// Variants are grouped under their product: it matches when any variant is in the price range or has name as its SKU.
+// attributes filters on attribute values, matching any of a key's values regardless of case.
+func (pdb *PostgresDb) SearchProduct(lowerPrice, upperPrice, categoryName, name string, attributes map[string][]string) ([]models.Product, error) {
 	var products []models.Product
 
-	var LPInt int
-	var UPInt int
-	var categoryInt int
-
-	LPInt, _ = strconv.Atoi(lowerPrice)
-	UPInt, _ = strconv.Atoi(upperPrice)
-	categoryInt, _ = strconv.Atoi(categoryName)
+	LPInt, _ := strconv.Atoi(lowerPrice)
+	UPInt, _ := strconv.Atoi(upperPrice)
 
-	if categoryInt == 0 {
-		if LPInt == 0 && UPInt == 0 && name == "" {
-			err := pdb.DB.Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-			return products, nil
-		} else if LPInt == 0 && UPInt != 0 && name == "" {
-			err := pdb.DB.Where("price <= ?", uint(UPInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt != 0 && UPInt == 0 && name == "" {
-			err := pdb.DB.Where("price >= ?", uint(LPInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt != 0 && UPInt != 0 && name == "" {
-			err := pdb.DB.Where("price >= ?", uint(LPInt)).
-				Where("price <= ?", uint(UPInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt == 0 && UPInt == 0 && name != "" {
-			err := pdb.DB.Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt == 0 && name != "" {
-			err := pdb.DB.Where("price <= ?", uint(UPInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if UPInt == 0 && name != "" {
-			err := pdb.DB.Where("price >= ?", uint(LPInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt != 0 && UPInt != 0 && name != "" {
-			err := pdb.DB.Where("price >= ?", uint(LPInt)).Where("price <= ?", uint(UPInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
+	query := pdb.DB.Model(&models.Product{}).Where("status = ?", models.ProductActive)
+	if categoryName != "" {
+		category, err := pdb.GetCategory(categoryName)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []models.Product{}, nil
 		}
-	} else if categoryInt != 0 {
-		if LPInt == 0 && UPInt == 0 && name == "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt == 0 && name == "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).
-				Where("price <= ?", uint(UPInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if UPInt == 0 && name == "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).
-				Where("price >= ?", uint(LPInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt != 0 && UPInt != 0 && name == "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).Where("price >= ?", uint(LPInt)).
-				Where("price <= ?", uint(UPInt)).Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt == 0 && UPInt == 0 && name != "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if LPInt == 0 && name != "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).
-				Where("price <= ?", uint(UPInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else if UPInt == 0 && name != "" {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).
-				Where("price >= ?", uint(LPInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
-		} else {
-			err := pdb.DB.Where("category_id = ?", uint(categoryInt)).Where("price >= ?", uint(LPInt)).
-				Where("price <= ?", uint(UPInt)).
-				Where("title LIKE ?", "%"+name+"%").Preload("Images").Find(&products).Error
-			if err != nil {
-				fmt.Println(err)
-				return nil, err
-			}
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("category_id IN (SELECT id FROM categories WHERE path LIKE ?)", category.Path+"%")
+	}
+	if LPInt != 0 || UPInt != 0 {
+		var bounds []string
+		var args []interface{}
+		if LPInt != 0 {
+			bounds = append(bounds, "%[1]s >= ?")
+			args = append(args, uint(LPInt))
 		}
+		if UPInt != 0 {
+			bounds = append(bounds, "%[1]s <= ?")
+			args = append(args, uint(UPInt))
+		}
+		priceRange := strings.Join(bounds, " AND ")
+		query = query.Where(
+			"("+fmt.Sprintf(priceRange, "price")+") OR EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.deleted_at IS NULL AND "+
+				fmt.Sprintf(priceRange, "COALESCE(pv.price, products.price)")+")",
+			append(args, args...)...)
+	}
+	for key, values := range attributes {
+		lowered := make([]string, 0, len(values))
+		for _, value := range values {
+			lowered = append(lowered, strings.ToLower(value))
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM product_attributes pa WHERE pa.product_id = products.id AND pa.key = ? AND LOWER(pa.value) IN ?)",
+			key, lowered)
+	}
+	if name != "" {
+		query = query.Where("title LIKE ? OR EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.deleted_at IS NULL AND pv.sku = ?)",
+			"%"+name+"%", name)
+	}
+
+	err := preloadVariants(query).Preload("Category").Preload("Images").Preload("AttributeValues").Find(&products).Error
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	if err := productBreadcrumbs(pdb.DB, products); err != nil {
+		return nil, err
 	}
 	return products, nil
 }
@@ -333,6 +340,9 @@ func (pdb *PostgresDb) CreateBuyer(user *models.Buyer) (*models.Buyer, error) {
 	user.CreatedAt = time.Now()
 	user.IsActive = true
 	err = pdb.DB.Create(user).Error
+	if isUniqueViolation(err, referralCodeIndex) {
+		return user, ErrReferralCodeTaken
+	}
 	return user, err
 }
 
@@ -510,43 +520,20 @@ func (pdb *PostgresDb) UpdateProductRating(id uint, update *models.UpdateRating)
 	return result.Error
 }
 
-// UploadFileToS3 send FileToS3 saves a file to aws bucket and returns the url to the file and an error if there's any
-func (pdb *PostgresDb) UploadFileToS3(h *session.Session, file multipart.File, fileName string, size int64) (string, error) {
-	// get the file size and read the file content into a buffer
-	buffer := make([]byte, size)
-	_, err2 := file.Read(buffer)
-	if err2 != nil {
-		return "", err2
-	}
-	// config settings: this is where you choose the bucket,
-	// filename, content-type and storage class of the file you're uploading
-	url := "https://s3-eu-west-3.amazonaws.com/arp-rental/" + fileName
-	_, err := s3.New(h).PutObject(&s3.PutObjectInput{
-		Bucket:               aws.String(os.Getenv("S3_BUCKET_NAME")),
-		Key:                  aws.String(fileName),
-		ACL:                  aws.String("public-read"),
-		Body:                 bytes.NewReader(buffer),
-		ContentLength:        aws.Int64(int64(size)),
-		ContentType:          aws.String(http.DetectContentType(buffer)),
-		ContentDisposition:   aws.String("attachment"),
-		ServerSideEncryption: aws.String("AES256"),
-		StorageClass:         aws.String("INTELLIGENT_TIERING"),
-	})
-	return url, err
-}
-
-func (pdb *PostgresDb) UpdateBuyerImageURL(username, url string, buyerID uint) error {
+func (pdb *PostgresDb) UpdateBuyerImageURL(username, url string, buyerID uint, variants models.ImageVariants) error {
 	buyer := models.Buyer{}
 	buyer.Image = url
+	buyer.ImageVariants = variants
 	result :=
 		pdb.DB.Model(models.Buyer{}).
 			Where("username = ?", username).
 			Updates(buyer)
 	return result.Error
 }
-func (pdb *PostgresDb) UpdateSellerImageURL(username, url string, sellerID uint) error {
+func (pdb *PostgresDb) UpdateSellerImageURL(username, url string, sellerID uint, variants models.ImageVariants) error {
 	seller := models.Seller{}
 	seller.Image = url
+	seller.ImageVariants = variants
 	result :=
 		pdb.DB.Model(models.Seller{}).
 			Where("username = ?", username).
@@ -587,7 +574,8 @@ func (pdb *PostgresDb) FindIndividualSellerShop(sellerID uint) (*models.Seller,
 	//create instance of a seller and its respective product, and unmarshal data into them
 	seller := &models.Seller{}
 
-	if err := pdb.DB.Preload("Product").Where("id = ?", sellerID).Find(&seller).Error; err != nil {
+	err := pdb.DB.Preload("Product").Where("id = ?", sellerID).Find(&seller).Error
+	if err != nil {
 		log.Println("Error in finding", err)
 		return nil, err
 	}
@@ -630,12 +618,17 @@ func (pdb *PostgresDb) GetAllBuyerOrders(buyerId uint) ([]models.OrderProducts,
 	}
 	for i := 0; i < len(buyerOrder); i++ {
 		re := models.OrderProducts{
+			OrderID:      buyerOrder[i].ID,
+			Status:       buyerOrder[i].Status,
 			Fname:        buyerOrder[i].Seller.FirstName,
 			Lname:        buyerOrder[i].Seller.LastName,
 			CategoryName: buyerOrder[i].Product.Category.Name,
 			Title:        buyerOrder[i].Product.Title,
 			Price:        buyerOrder[i].Product.Price,
 			Quantity:     buyerOrder[i].Product.Quantity,
+			Subtotal:     buyerOrder[i].Subtotal,
+			TaxAmount:    buyerOrder[i].TaxAmount,
+			Total:        buyerOrder[i].Total,
 		}
 		result = append(result, re)
 	}
@@ -671,12 +664,17 @@ func (pdb *PostgresDb) GetAllSellerOrders(sellerId uint) ([]models.OrderProducts
 	}
 	for i := 0; i < len(sellerOrder); i++ {
 		re := models.OrderProducts{
+			OrderID:      sellerOrder[i].ID,
+			Status:       sellerOrder[i].Status,
 			Fname:        sellerOrder[i].Buyer.FirstName,
 			Lname:        sellerOrder[i].Buyer.LastName,
 			CategoryName: sellerOrder[i].Product.Category.Name,
 			Title:        sellerOrder[i].Product.Title,
 			Price:        sellerOrder[i].Product.Price,
 			Quantity:     sellerOrder[i].Product.Quantity,
+			Subtotal:     sellerOrder[i].Subtotal,
+			TaxAmount:    sellerOrder[i].TaxAmount,
+			Total:        sellerOrder[i].Total,
 		}
 		result = append(result, re)
 	}
@@ -712,7 +710,12 @@ func (pdb *PostgresDb) GetAllSellers() ([]models.Seller, error) {
 // GetProductByID returns a particular product by it's ID
 func (pdb *PostgresDb) GetProductByID(id uint) (*models.Product, error) {
 	product := &models.Product{}
-	if err := pdb.DB.Where("ID=?", id).Preload("Images").First(product).Error; err != nil {
+	err := preloadVariants(pdb.DB).Where("ID=?", id).Preload("Category").Preload("Images").Preload("AttributeValues").
+		First(product).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := categoryBreadcrumbs(pdb.DB, product); err != nil {
 		return nil, err
 	}
 	return product, nil
@@ -750,7 +753,9 @@ func (pdb *PostgresDb) FindPaidProduct(sellerID uint) ([]models.CartProduct, err
 
 func (pdb *PostgresDb) CreateProduct(product models.Product) error {
 
-	err := pdb.DB.Create(&product).Error
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		return createProduct(tx, &product)
+	})
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -758,10 +763,30 @@ func (pdb *PostgresDb) CreateProduct(product models.Product) error {
 	return nil
 }
 
+// createProduct adds a product as part of tx and records that it was created
+func createProduct(tx *gorm.DB, product *models.Product) error {
+	if err := tx.Create(product).Error; err != nil {
+		return err
+	}
+	return recordEvent(tx, models.AggregateProduct, product.ID, models.EventProductCreated, models.ProductEvent{
+		ProductID:  product.ID,
+		SellerID:   product.SellerId,
+		CategoryID: product.CategoryId,
+		Title:      product.Title,
+		Price:      product.Price,
+		Quantity:   product.Quantity,
+	})
+}
+
+// GetCategory finds a category by its id, slug or name
 func (pdb *PostgresDb) GetCategory(category string) (*models.Category, error) {
 	categories := models.Category{}
 
-	err := pdb.DB.Where("name = ?", category).First(&categories).Error
+	query := pdb.DB.Where("slug = ? OR name = ?", category, category)
+	if id, err := strconv.Atoi(category); err == nil {
+		query = pdb.DB.Where("id = ?", id)
+	}
+	err := query.First(&categories).Error
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -769,18 +794,29 @@ func (pdb *PostgresDb) GetCategory(category string) (*models.Category, error) {
 	return &categories, nil
 }
 
+// DeleteProduct deletes one of the seller's products along with its pictures
 func (pdb *PostgresDb) DeleteProduct(productID, sellerID uint) error {
-	product := models.Product{}
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteProducts(tx, tx.Where("id = ?", productID).Where("seller_id = ?", sellerID))
+	})
+}
 
-	err := pdb.DB.Where("id = ?", productID).Where("seller_id = ?", sellerID).Delete(&product).Error
-	if err != nil {
+// deleteProducts deletes the products query finds as part of tx, along with their pictures
+func deleteProducts(tx *gorm.DB, query *gorm.DB) error {
+	var products []models.Product
+	err := preloadVariants(query).Preload("Images").Clauses(clause.Locking{Strength: "UPDATE"}).Find(&products).Error
+	if err != nil || len(products) == 0 {
 		return err
 	}
-
-	return nil
+	if err := tx.Delete(&products).Error; err != nil {
+		return err
+	}
+	return removeProductImages(tx, products)
 }
 
-func (pdb *PostgresDb) AddToCart(product models.Product, buyer *models.Buyer) error {
+// AddToCart puts quantity of a product in the buyer's cart. A product with variants needs variantID,
+// which sets the price and must have the quantity in stock.
+func (pdb *PostgresDb) AddToCart(product models.Product, variantID *uint, buyer *models.Buyer) error {
 	var prod *models.Product
 	var userBuyer *models.Buyer
 	var cart *models.Cart
@@ -789,6 +825,9 @@ func (pdb *PostgresDb) AddToCart(product models.Product, buyer *models.Buyer) er
 	if err != nil {
 		return err
 	}
+	if !prod.IsActive() {
+		return ErrProductUnavailable
+	}
 
 	err = pdb.DB.Where("id = ?", buyer.ID).First(&userBuyer).Error
 	if err != nil {
@@ -800,14 +839,22 @@ func (pdb *PostgresDb) AddToCart(product models.Product, buyer *models.Buyer) er
 		return err
 	}
 
+	variant, err := cartVariant(pdb.DB, prod, variantID, product.Quantity)
+	if err != nil {
+		return err
+	}
+
 	cartProduct := models.CartProduct{
 		CartID:        cart.ID,
 		ProductID:     product.ID,
-		TotalPrice:    prod.Price * product.Quantity,
+		TotalPrice:    prod.PriceFor(variant) * product.Quantity,
 		TotalQuantity: product.Quantity,
 		OrderStatus:   false,
 		BuyerId:       buyer.ID,
-		SellerId:      product.SellerId,
+		SellerId:      prod.SellerId,
+	}
+	if variant != nil {
+		cartProduct.VariantID = &variant.ID
 	}
 
 	cart.Product = append(cart.Product, cartProduct)
@@ -857,6 +904,15 @@ func (pdb *PostgresDb) ViewCartProducts(addedProducts []models.CartProduct) ([]m
 			Images:        product.Images,
 			CartProductID: addedProducts[i].ID,
 		}
+		if addedProducts[i].VariantID != nil {
+			// a removed variant is still shown so the buyer can take it out of their cart
+			var variant models.ProductVariant
+			err = pdb.DB.Unscoped().Where("id = ?", *addedProducts[i].VariantID).First(&variant).Error
+			if err != nil {
+				return nil, err
+			}
+			prodDetail.VariantTitle = variant.Title
+		}
 
 		details = append(details, prodDetail)
 	}
@@ -864,49 +920,123 @@ func (pdb *PostgresDb) ViewCartProducts(addedProducts []models.CartProduct) ([]m
 	return details, nil
 }
 
-func (pdb *PostgresDb) DeletePaidFromCart(cartID uint) error {
-	var cartProducts []models.CartProduct
-	var cart models.Cart
+// DeletePaidFromCart turns the buyer's unpaid cart products into orders paid with reference,
+// empties the cart and raises one invoice per seller, all in a single transaction.
+// amountPaid, in naira, and the buyer's wallet must cover the total exactly.
+func (pdb *PostgresDb) DeletePaidFromCart(cartID uint, reference string, amountPaid uint) ([]models.Invoice, error) {
+	var invoices []models.Invoice
 
-	log.Println("buyer_1d coming in", cartID)
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var cartProducts []models.CartProduct
+		var cart models.Cart
 
-	err := pdb.DB.Where("buyer_id = ?", cartID).First(&cart).Error
-	if err != nil {
-		return err
-	}
+		log.Println("buyer_1d coming in", cartID)
+
+		err := tx.Where("buyer_id = ?", cartID).First(&cart).Error
+		if err != nil {
+			return err
+		}
 
-	log.Println("cartid coming in", cart.ID)
+		log.Println("cartid coming in", cart.ID)
 
-	err = pdb.DB.Where("cart_id = ?", cart.ID).Where("order_status = ?", false).
-		Find(&cartProducts).Error
-	if err != nil {
-		return err
-	}
+		err = tx.Where("cart_id = ?", cart.ID).Where("order_status = ?", false).
+			Find(&cartProducts).Error
+		if err != nil {
+			return err
+		}
+
+		// the callback was already handled for this cart
+		if len(cartProducts) == 0 {
+			return nil
+		}
 
-	log.Println("hello world", cartProducts)
+		summary, err := pdb.checkoutSummary(tx, &cart, cartProducts, true)
+		if err != nil {
+			return err
+		}
+		// the wallet pays what the payment didn't, which can differ from when the payment started
+		due := summary.Total + summary.WalletCredit
+		if amountPaid > due || due-amountPaid > summary.WalletCredit {
+			return fmt.Errorf("%d paid for %d: %w", amountPaid, summary.Total, ErrPaymentAmount)
+		}
+		summary.WalletCredit = due - amountPaid
+
+		var totalOrders []models.Order
+		for i := 0; i < len(summary.Lines); i++ {
+			line := summary.Lines[i]
+			orders := models.Order{
+				SellerId:         line.SellerID,
+				BuyerId:          line.BuyerID,
+				ProductId:        line.ProductID,
+				VariantID:        line.VariantID,
+				VariantTitle:     line.VariantTitle,
+				SKU:              line.SKU,
+				Quantity:         line.Quantity,
+				Subtotal:         line.Net,
+				TaxName:          line.Name,
+				TaxRate:          line.RateBasisPoints,
+				TaxInclusive:     line.Inclusive,
+				TaxExempt:        line.Exempt,
+				TaxAmount:        line.Tax,
+				Total:            line.Gross,
+				Discount:         line.Discount,
+				PaymentReference: reference,
+				Status:           models.OrderStatusPaid,
+			}
+			totalOrders = append(totalOrders, orders)
 
-	var totalOrders []models.Order
-	for i := 0; i < len(cartProducts); i++ {
+		}
 
-		orders := models.Order{
-			SellerId:  cartProducts[i].SellerId,
-			BuyerId:   cartProducts[i].BuyerId,
-			ProductId: cartProducts[i].ProductID,
+		err = tx.Create(&totalOrders).Error
+		if err != nil {
+			return err
 		}
-		totalOrders = append(totalOrders, orders)
 
-	}
+		for i := range totalOrders {
+			if err = takeVariantStock(tx, &totalOrders[i]); err != nil {
+				return err
+			}
+		}
 
-	err = pdb.DB.Create(&totalOrders).Error
-	if err != nil {
-		return err
-	}
+		invoices, err = createInvoices(tx, totalOrders)
+		if err != nil {
+			return err
+		}
+
+		numbers := map[uint]string{}
+		for _, invoice := range invoices {
+			numbers[invoice.SellerID] = invoice.Number
+		}
+		for i := range totalOrders {
+			payload := models.NewOrderEvent(&totalOrders[i])
+			payload.InvoiceNumber = numbers[totalOrders[i].SellerId]
+			err = recordEvent(tx, models.AggregateOrder, totalOrders[i].ID, models.EventOrderPaid, payload)
+			if err != nil {
+				return err
+			}
+		}
+
+		if summary.CouponCode != "" {
+			err = redeemCoupon(tx, &cart, summary.CouponCode, reference)
+			if err != nil {
+				return err
+			}
+		}
+		if summary.WalletCredit > 0 {
+			if err = spendWallet(tx, cart.BuyerID, summary.WalletCredit); err != nil {
+				return err
+			}
+		}
+		if err = convertCartReminders(tx, cart.ID, reference); err != nil {
+			return err
+		}
 
-	err = pdb.DB.Where("cart_id = ?", cart.ID).Delete(&cartProducts).Error
+		return tx.Where("cart_id = ?", cart.ID).Delete(&cartProducts).Error
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return invoices, nil
 }
 
 func (pdb *PostgresDb) GetSellersProducts(sellerID uint) ([]models.Product, error) {
@@ -962,13 +1092,11 @@ func (pdb *PostgresDb) FindCartProductSeller(sellerID, productID uint) (*models.
 	return cartProduct, nil
 }
 
+// DeleteAllSellerProducts deletes all the seller's products along with their pictures
 func (pdb *PostgresDb) DeleteAllSellerProducts(sellerID uint) error {
-	product := models.Product{}
-	err := pdb.DB.Where("seller_id = ?", sellerID).Delete(&product).Error
-	if err != nil {
-		return err
-	}
-	return nil
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteProducts(tx, tx.Where("seller_id = ?", sellerID))
+	})
 }
 
 func (pdb *PostgresDb) DeleteCartProduct(buyerID, cartProductID uint) error {
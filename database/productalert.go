@@ -0,0 +1,109 @@
+package database
+
+import (
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// alertClaimLease is how long claimed alert notifications are hidden from other senders while they are emailed
+const alertClaimLease = 5 * time.Minute
+
+// SaveProductAlert creates the buyer's alert for a product, or replaces what the existing one watches for
+func (pdb *PostgresDb) SaveProductAlert(alert *models.ProductAlert) error {
+	return pdb.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "buyer_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"back_in_stock", "price_below", "updated_at"}),
+	}).Create(alert).Error
+}
+
+// GetBuyerProductAlerts lists the buyer's alerts with their products
+func (pdb *PostgresDb) GetBuyerProductAlerts(buyerID uint) ([]models.ProductAlert, error) {
+	var alerts []models.ProductAlert
+	err := pdb.DB.Preload("Product").Preload("Product.Images").
+		Where("buyer_id = ?", buyerID).Order("id desc").Find(&alerts).Error
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// DeleteProductAlert removes one of the buyer's alerts
+func (pdb *PostgresDb) DeleteProductAlert(id, buyerID uint) error {
+	result := pdb.DB.Unscoped().Where("id = ? AND buyer_id = ?", id, buyerID).Delete(&models.ProductAlert{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// QueueBackInStockAlerts sets off the back in stock alerts on a product for the event.
+// Queueing the same event again adds nothing.
+func (pdb *PostgresDb) QueueBackInStockAlerts(productID, eventID, price uint) (int, error) {
+	result := pdb.DB.Exec(`
+INSERT INTO alert_notifications (created_at, updated_at, alert_id, buyer_id, product_id, kind, price, dedup_key)
+SELECT now(), now(), id, buyer_id, product_id, ?, ?, id::text || ':' || ?::text
+FROM product_alerts
+WHERE product_id = ? AND back_in_stock AND deleted_at IS NULL
+ON CONFLICT (dedup_key) DO NOTHING`, models.AlertBackInStock, price, eventID, productID)
+	return int(result.RowsAffected), result.Error
+}
+
+// QueuePriceDropAlerts sets off the price alerts on a product whose threshold the price has just
+// gone below, going from oldPrice to newPrice. Queueing the same event again adds nothing.
+func (pdb *PostgresDb) QueuePriceDropAlerts(productID, eventID, oldPrice, newPrice uint) (int, error) {
+	result := pdb.DB.Exec(`
+INSERT INTO alert_notifications (created_at, updated_at, alert_id, buyer_id, product_id, kind, price, dedup_key)
+SELECT now(), now(), id, buyer_id, product_id, ?, ?, id::text || ':' || ?::text
+FROM product_alerts
+WHERE product_id = ? AND price_below > ? AND price_below <= ? AND deleted_at IS NULL
+ON CONFLICT (dedup_key) DO NOTHING`, models.AlertPriceDrop, newPrice, eventID, productID, newPrice, oldPrice)
+	return int(result.RowsAffected), result.Error
+}
+
+// ClaimAlertNotifications takes up to limit unsent notifications with their buyers and products, hiding
+// them from other senders for a while. They are marked sent by SendAlertEmail.
+func (pdb *PostgresDb) ClaimAlertNotifications(limit int) ([]models.AlertNotification, error) {
+	var notifications []models.AlertNotification
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND (claimed_until IS NULL OR claimed_until <= ?)", time.Now()).
+			Order("buyer_id, id").
+			Limit(limit).
+			Find(&notifications).Error
+		if err != nil || len(notifications) == 0 {
+			return err
+		}
+		ids := make([]uint, len(notifications))
+		for i := range notifications {
+			ids[i] = notifications[i].ID
+		}
+		err = tx.Model(&models.AlertNotification{}).Where("id IN ?", ids).
+			Update("claimed_until", time.Now().Add(alertClaimLease)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Preload("Buyer").Preload("Product").Where("id IN ?", ids).Order("buyer_id, id").Find(&notifications).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// SendAlertEmail queues the email telling a buyer about their alert notifications and marks them sent,
+// so they are marked only once the email is sure to go out
+func (pdb *PostgresDb) SendAlertEmail(message *models.EmailMessage, notificationIDs []uint) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.AlertNotification{}).Where("id IN ?", notificationIDs).Update("sent_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return enqueueEmail(tx, message)
+	})
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTooManyImages is returned when adding pictures would take a product over models.MaxProductImages
+var ErrTooManyImages = fmt.Errorf("a product can have at most %d images", models.MaxProductImages)
+
+// ErrImageOrder is returned when a new order for a product's pictures doesn't list each of them once
+var ErrImageOrder = errors.New("image_ids must list each of the product's images once")
+
+// AddProductImages adds pictures after a product's existing ones, filling in their ids and positions
+func (pdb *PostgresDb) AddProductImages(productID uint, images []models.Image) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		product := &models.Product{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(product).Error
+		if err != nil {
+			return err
+		}
+		var existing []models.Image
+		if err := tx.Where("product_id = ?", productID).Order("position").Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing)+len(images) > models.MaxProductImages {
+			return ErrTooManyImages
+		}
+		next := 0
+		if len(existing) > 0 {
+			next = existing[len(existing)-1].Position + 1
+		}
+		for i := range images {
+			images[i].ProductId, images[i].Position = productID, next+i
+		}
+		return tx.Create(&images).Error
+	})
+}
+
+// UpdateProductImageAltText changes the text describing one of a product's pictures
+func (pdb *PostgresDb) UpdateProductImageAltText(productID, imageID uint, altText string) (*models.Image, error) {
+	image := &models.Image{}
+	err := pdb.DB.Where("id = ? AND product_id = ?", imageID, productID).First(image).Error
+	if err != nil {
+		return nil, err
+	}
+	image.AltText = altText
+	if err := pdb.DB.Model(image).UpdateColumn("alt_text", altText).Error; err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// ReorderProductImages puts a product's pictures in the order of imageIDs, the first being its primary image
+func (pdb *PostgresDb) ReorderProductImages(productID uint, imageIDs []uint) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		product := &models.Product{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(product).Error
+		if err != nil {
+			return err
+		}
+		var images []models.Image
+		if err := tx.Where("product_id = ?", productID).Find(&images).Error; err != nil {
+			return err
+		}
+		positions := map[uint]int{}
+		for i, id := range imageIDs {
+			positions[id] = i
+		}
+		if len(images) != len(imageIDs) || len(positions) != len(imageIDs) {
+			return ErrImageOrder
+		}
+		for _, image := range images {
+			position, ok := positions[image.ID]
+			if !ok {
+				return ErrImageOrder
+			}
+			if err := tx.Model(&image).UpdateColumn("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteProductImage removes one of a product's pictures. Its files, scaled down versions included,
+// are deleted from storage once the ProductImagesRemoved event is relayed.
+func (pdb *PostgresDb) DeleteProductImage(productID, imageID uint) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		product := &models.Product{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(product).Error
+		if err != nil {
+			return err
+		}
+		image := &models.Image{}
+		if err := tx.Where("id = ? AND product_id = ?", imageID, productID).First(image).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(image).Error; err != nil {
+			return err
+		}
+		return imagesRemoved(tx, product, append([]string{image.Url}, image.Variants.URLs()...))
+	})
+}
+
+// removeProductImages deletes the pictures of products and their variants as part of tx, recording
+// which files are no longer used. The products must have been loaded with their images and variants'.
+func removeProductImages(tx *gorm.DB, products []models.Product) error {
+	for i := range products {
+		product := &products[i]
+		err := tx.Unscoped().Where("product_id = ?", product.ID).Delete(&models.Image{}).Error
+		if err != nil {
+			return err
+		}
+		if len(product.Variants) > 0 {
+			variantIDs := make([]uint, 0, len(product.Variants))
+			for _, variant := range product.Variants {
+				variantIDs = append(variantIDs, variant.ID)
+			}
+			if err := tx.Where("variant_id IN ?", variantIDs).Delete(&models.VariantImage{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := imagesRemoved(tx, product, product.ImageURLs()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imagesRemoved records that the files at urls are no longer used by the product
+func imagesRemoved(tx *gorm.DB, product *models.Product, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	return recordEvent(tx, models.AggregateProduct, product.ID, models.EventProductImagesRemoved,
+		models.ImagesRemovedEvent{ProductID: product.ID, SellerID: product.SellerId, URLs: urls})
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateProductImport saves an import so a job can run it
+func (pdb *PostgresDb) CreateProductImport(productImport *models.ProductImport) error {
+	return pdb.DB.Create(productImport).Error
+}
+
+// GetProductImport finds an import by its id
+func (pdb *PostgresDb) GetProductImport(id uint) (*models.ProductImport, error) {
+	productImport := &models.ProductImport{}
+	if err := pdb.DB.Where("id = ?", id).First(productImport).Error; err != nil {
+		return nil, err
+	}
+	return productImport, nil
+}
+
+// SaveProductImport records how far an import has got
+func (pdb *PostgresDb) SaveProductImport(productImport *models.ProductImport) error {
+	return pdb.DB.Model(productImport).
+		Select("status", "total_rows", "processed_rows", "created", "updated", "failed", "data", "error_list", "finished_at").
+		Updates(productImport).Error
+}
+
+// FindProductSKUs reports which of skus the seller already has, deleted products included
+func (pdb *PostgresDb) FindProductSKUs(sellerID uint, skus []string) (map[string]bool, error) {
+	found := map[string]bool{}
+	if len(skus) == 0 {
+		return found, nil
+	}
+	var existing []string
+	err := pdb.DB.Unscoped().Model(&models.Product{}).Where("seller_id = ? AND sku IN ?", sellerID, skus).
+		Pluck("sku", &existing).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, sku := range existing {
+		found[sku] = true
+	}
+	return found, nil
+}
+
+// ImportProduct creates the seller's product with the row's SKU, or updates it if they have one.
+// A deleted product with the SKU comes back. New products and changed listings are queued for review,
+// flagged with the banned keywords found in them. It reports whether the product was created.
+func (pdb *PostgresDb) ImportProduct(sellerID uint, row models.ProductImportRow, attributes []models.ProductAttribute,
+	flags []string) (*models.Product, bool, error) {
+	product := &models.Product{}
+	created := false
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Preload("AttributeValues").
+			Where("seller_id = ? AND sku = ?", sellerID, row.SKU).First(product).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created = true
+			*product = models.Product{
+				SellerId:        sellerID,
+				SKU:             row.SKU,
+				CategoryId:      row.CategoryID,
+				Title:           row.Title,
+				Description:     row.Description,
+				Price:           row.Price,
+				Quantity:        row.Quantity,
+				Status:          models.ProductActive,
+				AttributeValues: attributes,
+			}
+			product.HoldForReview(flags)
+			return createProduct(tx, product)
+		}
+		if err != nil {
+			return err
+		}
+
+		restored := product.DeletedAt.Valid
+		changes := map[string]interface{}{"category_id": row.CategoryID}
+		if restored {
+			changes["deleted_at"] = nil
+		}
+		if err := tx.Unscoped().Model(product).UpdateColumns(changes).Error; err != nil {
+			return err
+		}
+		if attributes == nil {
+			attributes = []models.ProductAttribute{}
+		}
+		update := models.Product{
+			Title:           row.Title,
+			Description:     row.Description,
+			Price:           row.Price,
+			Quantity:        row.Quantity,
+			Rating:          product.Rating,
+			AttributeValues: attributes,
+		}
+		if restored || listingChanged(product, &update) {
+			update.Reviews = []models.ListingReview{models.NewListingReview(models.ReviewEditedListing, flags)}
+		}
+		return updateProduct(tx, product.ID, update)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return product, created, nil
+}
+
+// listingChanged reports whether changes has a different title, description or attribute values to product
+func listingChanged(product, changes *models.Product) bool {
+	if product.Title != changes.Title || product.Description != changes.Description ||
+		len(product.AttributeValues) != len(changes.AttributeValues) {
+		return true
+	}
+	values := map[string]string{}
+	for _, attribute := range product.AttributeValues {
+		values[attribute.Key] = attribute.Value
+	}
+	for _, attribute := range changes.AttributeValues {
+		if value, ok := values[attribute.Key]; !ok || value != attribute.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceProductImages swaps a product's pictures for images, in order
+func (pdb *PostgresDb) ReplaceProductImages(productID uint, images []models.Image) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		product := &models.Product{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Images").Where("id = ?", productID).
+			First(product).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("product_id = ?", productID).Delete(&models.Image{}).Error; err != nil {
+			return err
+		}
+		if err := imagesRemoved(tx, product, product.ImageURLs()); err != nil {
+			return err
+		}
+		for i := range images {
+			images[i].ProductId, images[i].Position = productID, i
+		}
+		if len(images) == 0 {
+			return nil
+		}
+		return tx.Create(&images).Error
+	})
+}
+
+// GetSellerCatalogue returns all the seller's products with their images and attributes, oldest first
+func (pdb *PostgresDb) GetSellerCatalogue(sellerID uint) ([]models.Product, error) {
+	var products []models.Product
+	err := pdb.DB.Preload("Images").Preload("AttributeValues").Where("seller_id = ?", sellerID).Order("id").
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrProductUnavailable is returned when a buyer tries to buy a product that isn't active
+var ErrProductUnavailable = errors.New("this product is not available")
+
+// ErrListingRejected is returned when a seller tries to publish a listing an admin rejected
+var ErrListingRejected = errors.New("this listing was rejected, edit it to have it reviewed again")
+
+// scheduledProductBatch is how many products are published or unpublished per run
+const scheduledProductBatch = 100
+
+// UpdateProductStatus moves a product to status with the given schedule, replacing any it had,
+// and returns the status it was given. A listing goes live once an admin has approved it, so
+// publishing one still waiting to be reviewed leaves it pending review.
+func (pdb *PostgresDb) UpdateProductStatus(productID uint, status string, publishAt, unpublishAt *time.Time) (string, error) {
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		product := &models.Product{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(product).Error
+		if err != nil {
+			return err
+		}
+		// the moderation status is read under the lock so a decision made meanwhile can't be skipped
+		if status == models.ProductActive {
+			switch product.ModerationStatus {
+			case models.ModerationRejected:
+				return ErrListingRejected
+			case models.ModerationPending:
+				status = models.ProductPendingReview
+			}
+		}
+		return setProductStatus(tx, product, status, publishAt, unpublishAt)
+	})
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// PublishScheduledProducts makes the drafts due to go live active and archives the active products
+// due to come down, returning how many of each it changed. A draft still waiting to be reviewed
+// goes live once it is approved.
+func (pdb *PostgresDb) PublishScheduledProducts(now time.Time) (published, unpublished int, err error) {
+	var due []models.Product
+	err = pdb.DB.Select("id").
+		Where("(status = ? AND publish_at <= ?) OR (status = ? AND unpublish_at <= ?)",
+			models.ProductDraft, now, models.ProductActive, now).
+		Order("id").Limit(scheduledProductBatch).Find(&due).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, d := range due {
+		var status string
+		err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+			product := &models.Product{}
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", d.ID).First(product).Error
+			if err != nil {
+				return err
+			}
+			// the seller may have changed the product since it was found
+			switch {
+			case product.Status == models.ProductDraft && product.PublishAt != nil && !product.PublishAt.After(now):
+				switch product.ModerationStatus {
+				case models.ModerationRejected:
+					// a rejected listing stays a draft until the seller edits it
+					return setProductStatus(tx, product, models.ProductDraft, nil, product.UnpublishAt)
+				case models.ModerationPending:
+					status = models.ProductPendingReview
+				default:
+					status = models.ProductActive
+				}
+				return setProductStatus(tx, product, status, nil, product.UnpublishAt)
+			case product.Status == models.ProductActive && product.UnpublishAt != nil && !product.UnpublishAt.After(now):
+				status = models.ProductArchived
+				return setProductStatus(tx, product, status, nil, nil)
+			}
+			status = ""
+			return nil
+		})
+		if err != nil {
+			return published, unpublished, err
+		}
+		switch status {
+		case models.ProductActive:
+			published++
+		case models.ProductArchived:
+			unpublished++
+		}
+	}
+	return published, unpublished, nil
+}
+
+// setProductStatus saves a product's status and schedule as part of tx, recording the change of status
+func setProductStatus(tx *gorm.DB, product *models.Product, status string, publishAt, unpublishAt *time.Time) error {
+	oldStatus := product.Status
+	err := tx.Model(product).Select("status", "publish_at", "unpublish_at").Updates(&models.Product{
+		Status:      status,
+		PublishAt:   publishAt,
+		UnpublishAt: unpublishAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+	product.Status, product.PublishAt, product.UnpublishAt = status, publishAt, unpublishAt
+	if oldStatus == status {
+		return nil
+	}
+	return recordEvent(tx, models.AggregateProduct, product.ID, models.EventProductStatusChanged, models.ProductStatusEvent{
+		ProductID: product.ID,
+		SellerID:  product.SellerId,
+		OldStatus: oldStatus,
+		NewStatus: status,
+	})
+}
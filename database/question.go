@@ -0,0 +1,111 @@
+package database
+
+import (
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// answersByVotes puts the most upvoted answers first, then the oldest
+func answersByVotes(db *gorm.DB) *gorm.DB {
+	return db.Order("upvotes desc, id")
+}
+
+// CreateProductQuestion saves a buyer's question on a product
+func (pdb *PostgresDb) CreateProductQuestion(question *models.ProductQuestion) error {
+	return pdb.DB.Create(question).Error
+}
+
+// GetProductQuestion returns a question with its product
+func (pdb *PostgresDb) GetProductQuestion(id uint) (*models.ProductQuestion, error) {
+	var question models.ProductQuestion
+	if err := pdb.DB.Preload("Product").First(&question, id).Error; err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// GetProductQuestions returns a page of a product's questions, newest first, with their answers
+func (pdb *PostgresDb) GetProductQuestions(productID uint, page, limit int) (*models.ProductQuestionPage, error) {
+	result := &models.ProductQuestionPage{Page: page, Limit: limit, Questions: []models.ProductQuestion{}}
+	query := pdb.DB.Model(&models.ProductQuestion{}).Where("product_id = ?", productID)
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	err := query.Preload("Answers", answersByVotes).
+		Order("id desc").Offset((page - 1) * limit).Limit(limit).
+		Find(&result.Questions).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetUnansweredQuestions returns a page of the questions on a seller's products they haven't answered, oldest first
+func (pdb *PostgresDb) GetUnansweredQuestions(sellerID uint, page, limit int) (*models.ProductQuestionPage, error) {
+	result := &models.ProductQuestionPage{Page: page, Limit: limit, Questions: []models.ProductQuestion{}}
+	query := pdb.DB.Model(&models.ProductQuestion{}).
+		Joins("JOIN products ON products.id = product_questions.product_id AND products.deleted_at IS NULL").
+		Where("products.seller_id = ? AND product_questions.seller_answered = ?", sellerID, false)
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	err := query.Preload("Product").Preload("Answers", answersByVotes).
+		Order("product_questions.id").Offset((page - 1) * limit).Limit(limit).
+		Find(&result.Questions).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateProductAnswer saves an answer and counts it on the question. An answer from the seller
+// takes the question off their unanswered list.
+func (pdb *PostgresDb) CreateProductAnswer(answer *models.ProductAnswer) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(answer).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"answer_count": gorm.Expr("answer_count + 1")}
+		if answer.AnswererType == models.RecipientSeller {
+			updates["seller_answered"] = true
+		}
+		return tx.Model(&models.ProductQuestion{}).Where("id = ?", answer.QuestionID).UpdateColumns(updates).Error
+	})
+}
+
+// HasBoughtProduct reports whether a buyer has an order for a product that wasn't cancelled or refunded
+func (pdb *PostgresDb) HasBoughtProduct(buyerID, productID uint) (bool, error) {
+	var count int64
+	err := pdb.DB.Model(&models.Order{}).
+		Where("buyer_id = ? AND product_id = ? AND status NOT IN ?", buyerID, productID,
+			[]string{models.OrderStatusCancelled, models.OrderStatusRefunded}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SetAnswerUpvote adds or takes back a buyer's upvote on an answer and returns the answer's upvotes.
+// Upvoting twice counts once.
+func (pdb *PostgresDb) SetAnswerUpvote(answerID, buyerID uint, upvote bool) (int64, error) {
+	var answer models.ProductAnswer
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&answer, answerID).Error; err != nil {
+			return err
+		}
+		vote := models.AnswerUpvote{AnswerID: answerID, BuyerID: buyerID}
+		var result *gorm.DB
+		change := 1
+		if upvote {
+			result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		} else {
+			result = tx.Delete(&vote)
+			change = -1
+		}
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		answer.Upvotes += int64(change)
+		return tx.Model(&answer).UpdateColumn("upvotes", gorm.Expr("upvotes + ?", change)).Error
+	})
+	return answer.Upvotes, err
+}
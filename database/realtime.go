@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/jackc/pgx/v4/stdlib"
+	"gorm.io/gorm"
+)
+
+// maxNotifyPayload keeps a live update under Postgres' 8000 byte NOTIFY limit
+const maxNotifyPayload = 7900
+
+// PublishRealtime sends live updates to the event streams open on every api process
+func (pdb *PostgresDb) PublishRealtime(messages []models.RealtimeMessage) error {
+	return publishRealtime(pdb.DB, messages)
+}
+
+// publishRealtime sends messages over NOTIFY. Inside a transaction they only go out once it commits.
+func publishRealtime(tx *gorm.DB, messages []models.RealtimeMessage) error {
+	for _, msg := range messages {
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if len(payload) > maxNotifyPayload {
+			log.Printf("realtime %s update for %s %d is too large to send\n", msg.Event, msg.RecipientType, msg.RecipientID)
+			continue
+		}
+		if err := tx.Exec("SELECT pg_notify(?, ?)", models.RealtimeChannel, string(payload)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListenRealtime passes live updates from any process to handle until ctx is done or the connection drops.
+// It holds one connection out of the pool while it runs.
+func (pdb *PostgresDb) ListenRealtime(ctx context.Context, handle func(models.RealtimeMessage)) error {
+	sqlDB, err := pdb.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("cannot listen on a %T connection", driverConn)
+		}
+		pgConn := stdConn.Conn()
+		// the connection can't go back to the pool still listening
+		defer pgConn.Close(context.Background())
+
+		if _, err := pgConn.Exec(ctx, "LISTEN "+models.RealtimeChannel); err != nil {
+			return err
+		}
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			var msg models.RealtimeMessage
+			if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+				log.Printf("realtime payload error: %v\n", err)
+				continue
+			}
+			handle(msg)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrReferralCodeTaken is returned creating a buyer with a referral code another buyer already has
+var ErrReferralCodeTaken = errors.New("referral code is taken")
+
+// referralCodeIndex keeps referral codes unique. It is named apart from the plain index it replaced,
+// which migrations would otherwise keep, and leaves out buyers still waiting for a code.
+const referralCodeIndex = "idx_buyers_referral_code_unique"
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate in the unique index named index
+func isUniqueViolation(err error, index string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == index
+}
+
+// FindBuyerByReferralCode finds the buyer who owns an invite code
+func (pdb *PostgresDb) FindBuyerByReferralCode(code string) (*models.Buyer, error) {
+	buyer := &models.Buyer{}
+	err := pdb.DB.Where("referral_code = ?", code).First(buyer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%s does not exist referral code not found: %w", code, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buyer, nil
+}
+
+// CreateReferral records that a buyer signed up with another buyer's code
+func (pdb *PostgresDb) CreateReferral(referral *models.Referral) (*models.Referral, error) {
+	err := pdb.DB.Create(referral).Error
+	return referral, err
+}
+
+// GetBuyerReferrals returns the referrals a buyer has made, newest first
+func (pdb *PostgresDb) GetBuyerReferrals(buyerID uint) ([]models.Referral, error) {
+	var referrals []models.Referral
+	err := pdb.DB.Where("referrer_id = ?", buyerID).Order("created_at desc").Find(&referrals).Error
+	if err != nil {
+		return nil, err
+	}
+	return referrals, nil
+}
+
+// CompleteReferral rewards both parties of a pending referral once the referee has paid for an order.
+// It is a no-op when the buyer was not referred or the referral was already settled.
+func (pdb *PostgresDb) CompleteReferral(refereeID, reward uint) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		referral := &models.Referral{}
+		err := tx.Where("referee_id = ?", refereeID).Where("status = ?", models.ReferralStatusPending).
+			First(referral).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var paidOrders int64
+		if err := tx.Model(&models.Order{}).Where("buyer_id = ?", refereeID).Count(&paidOrders).Error; err != nil {
+			return err
+		}
+		if paidOrders == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Referral{}).
+			Where("id = ?", referral.ID).Where("status = ?", models.ReferralStatusPending).
+			Updates(map[string]interface{}{
+				"status":        models.ReferralStatusRewarded,
+				"reward_amount": reward,
+				"rewarded_at":   now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		// another callback settled it first
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.Buyer{}).
+			Where("id IN ?", []uint{referral.ReferrerID, referral.RefereeID}).
+			Update("wallet_balance", gorm.Expr("wallet_balance + ?", reward)).Error
+	})
+}
+
+// spendWallet takes what the buyer's wallet paid at checkout out of it
+func spendWallet(tx *gorm.DB, buyerID, amount uint) error {
+	result := tx.Model(&models.Buyer{}).Where("id = ? AND wallet_balance >= ?", buyerID, amount).
+		Update("wallet_balance", gorm.Expr("wallet_balance - ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("wallet balance changed during checkout")
+	}
+	return nil
+}
+
+// backfillReferralCodes gives buyers created before referrals existed a code
+func (pdb *PostgresDb) backfillReferralCodes() error {
+	var buyers []models.Buyer
+	if err := pdb.DB.Where("referral_code = ? OR referral_code IS NULL", "").Find(&buyers).Error; err != nil {
+		return err
+	}
+	for _, buyer := range buyers {
+		// a code another buyer has is replaced with a new one
+		for attempt := 1; ; attempt++ {
+			code, err := services.GenerateReferralCode()
+			if err != nil {
+				return err
+			}
+			err = pdb.DB.Model(&models.Buyer{}).Where("id = ?", buyer.ID).Update("referral_code", code).Error
+			if isUniqueViolation(err, referralCodeIndex) && attempt < 3 {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultVATRate is the Nigerian VAT rate of 7.5%
+const defaultVATRate = 750
+
+// ErrPaymentAmount is returned completing a checkout with a payment that doesn't match its total
+var ErrPaymentAmount = errors.New("the amount paid does not match the order total")
+
+// seedTaxRules creates the default VAT rule the first time the app runs.
+// VAT_RATE_BASIS_POINTS and VAT_INCLUSIVE override the defaults.
+func (pdb *PostgresDb) seedTaxRules() error {
+	var count int64
+	if err := pdb.DB.Model(&models.TaxRule{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	rate, err := strconv.Atoi(os.Getenv("VAT_RATE_BASIS_POINTS"))
+	if err != nil || rate < 0 {
+		rate = defaultVATRate
+	}
+	inclusive, _ := strconv.ParseBool(os.Getenv("VAT_INCLUSIVE"))
+	return pdb.DB.Create(&models.TaxRule{Name: "VAT", RateBasisPoints: uint(rate), Inclusive: inclusive}).Error
+}
+
+// GetTaxRules returns every configured tax rule
+func (pdb *PostgresDb) GetTaxRules() (models.TaxRules, error) {
+	var rules models.TaxRules
+	if err := pdb.DB.Order("category_id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveTaxRule creates or replaces the rule for the rule's category
+func (pdb *PostgresDb) SaveTaxRule(rule *models.TaxRule) (*models.TaxRule, error) {
+	err := pdb.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "rate_basis_points", "exempt", "inclusive", "updated_at", "deleted_at"}),
+	}).Create(rule).Error
+	return rule, err
+}
+
+// DeleteTaxRule removes a category rule so the category falls back to the default rule
+func (pdb *PostgresDb) DeleteTaxRule(id uint) error {
+	return pdb.DB.Unscoped().Where("id = ?", id).Delete(&models.TaxRule{}).Error
+}
+
+// GetCheckoutSummary prices the buyer's unpaid cart products including tax and any coupon applied to the cart,
+// less what their wallet covers
+func (pdb *PostgresDb) GetCheckoutSummary(buyer *models.Buyer) (*models.CheckoutSummary, error) {
+	cart := &models.Cart{}
+	if err := pdb.DB.Where("buyer_id = ?", buyer.ID).First(cart).Error; err != nil {
+		return nil, err
+	}
+	var cartProducts []models.CartProduct
+	err := pdb.DB.Where("cart_id = ? AND order_status = ?", cart.ID, false).Find(&cartProducts).Error
+	if err != nil {
+		return nil, err
+	}
+	summary, err := pdb.checkoutSummary(pdb.DB, cart, cartProducts, false)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// checkoutSummary prices cartProducts. Until they are paid for, a cart with a product that is no longer
+// active, or a variant that has since been removed, can't be checked out; once paid, the order is made
+// with the product as it was.
+func (pdb *PostgresDb) checkoutSummary(tx *gorm.DB, cart *models.Cart, cartProducts []models.CartProduct, paid bool) (models.CheckoutSummary, error) {
+	var rules models.TaxRules
+	if err := tx.Find(&rules).Error; err != nil {
+		return models.CheckoutSummary{}, err
+	}
+
+	ids := make([]uint, 0, len(cartProducts))
+	for _, cartProduct := range cartProducts {
+		ids = append(ids, cartProduct.ProductID)
+	}
+	var products []models.Product
+	if len(ids) > 0 {
+		if err := tx.Where("id IN ?", ids).Find(&products).Error; err != nil {
+			return models.CheckoutSummary{}, err
+		}
+	}
+	byID := make(map[uint]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok {
+			return models.CheckoutSummary{}, errors.New("product in cart no longer exists")
+		}
+		if !paid && !product.IsActive() {
+			return models.CheckoutSummary{}, fmt.Errorf("%s: %w", product.Title, ErrProductUnavailable)
+		}
+	}
+
+	// a coupon that has expired or been used since it was applied is ignored
+	var coupon *models.Coupon
+	if cart.CouponID != nil {
+		applied := &models.Coupon{}
+		err := tx.Where("id = ?", *cart.CouponID).First(applied).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.CheckoutSummary{}, err
+		}
+		if err == nil && applied.Usable(cart.BuyerID, time.Now()) {
+			coupon = applied
+		}
+	}
+
+	summary := models.BuildCheckoutSummary(cartProducts, byID, rules, coupon)
+	if err := nameVariants(tx, summary.Lines, paid); err != nil {
+		return models.CheckoutSummary{}, err
+	}
+
+	// referral rewards in the buyer's wallet are spent before anything is charged
+	buyer := &models.Buyer{}
+	if err := tx.Select("wallet_balance").Where("id = ?", cart.BuyerID).First(buyer).Error; err != nil {
+		return models.CheckoutSummary{}, err
+	}
+	summary.ApplyWallet(buyer.WalletBalance)
+	return summary, nil
+}
+
+// nameVariants adds the title and SKU of the variant each line is for
+func nameVariants(tx *gorm.DB, lines []models.CheckoutLine, paid bool) error {
+	var ids []uint
+	for _, line := range lines {
+		if line.VariantID != nil {
+			ids = append(ids, *line.VariantID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var variants []models.ProductVariant
+	if err := tx.Unscoped().Where("id IN ?", ids).Find(&variants).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]models.ProductVariant, len(variants))
+	for _, variant := range variants {
+		byID[variant.ID] = variant
+	}
+	for i := range lines {
+		if lines[i].VariantID == nil {
+			continue
+		}
+		variant, ok := byID[*lines[i].VariantID]
+		if !ok {
+			return errors.New("product variant in cart no longer exists")
+		}
+		if variant.DeletedAt.Valid && !paid {
+			return ErrVariantRemoved
+		}
+		lines[i].VariantTitle, lines[i].SKU = variant.Title, variant.SKU
+	}
+	return nil
+}
+
+// GetSellerSalesReport totals the seller's orders created between from and to, broken down by tax rate
+func (pdb *PostgresDb) GetSellerSalesReport(sellerID uint, from, to time.Time) (*models.SalesReport, error) {
+	report := &models.SalesReport{
+		From: from.Format("2006-01-02"),
+		To:   to.Format("2006-01-02"),
+	}
+
+	query := pdb.DB.Model(&models.Order{}).
+		Where("seller_id = ?", sellerID).
+		Where("created_at >= ? AND created_at < ?", from, to.AddDate(0, 0, 1))
+
+	var lines []struct {
+		TaxName      string
+		TaxRate      uint
+		TaxInclusive bool
+		TaxExempt    bool
+		Orders       int64
+		Net          uint
+		Tax          uint
+		Gross        uint
+	}
+	err := query.Select("tax_name, tax_rate, tax_inclusive, tax_exempt, COUNT(*) AS orders, " +
+		"COALESCE(SUM(subtotal), 0) AS net, COALESCE(SUM(tax_amount), 0) AS tax, COALESCE(SUM(total), 0) AS gross").
+		Group("tax_name, tax_rate, tax_inclusive, tax_exempt").
+		Order("tax_rate desc").
+		Scan(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		report.OrderCount += line.Orders
+		report.Net += line.Net
+		report.Tax += line.Tax
+		report.Gross += line.Gross
+		report.TaxLines = append(report.TaxLines, models.TaxLine{
+			Name:            line.TaxName,
+			RateBasisPoints: line.TaxRate,
+			Inclusive:       line.TaxInclusive,
+			Exempt:          line.TaxExempt,
+			Net:             line.Net,
+			Tax:             line.Tax,
+			Gross:           line.Gross,
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors adding a product with variants to a cart
+var (
+	ErrVariantRequired = errors.New("choose a variant of this product")
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrOutOfStock      = errors.New("not enough of this variant in stock")
+	// ErrVariantRemoved is returned checking out a cart with a variant the seller has since removed
+	ErrVariantRemoved = errors.New("a variant in your cart is no longer sold, remove it to check out")
+)
+
+// preloadVariants loads a product's options in order and its variants with their images
+func preloadVariants(db *gorm.DB) *gorm.DB {
+	return db.Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Variants.Images")
+}
+
+// SaveProductVariants replaces a product's options and variant matrix. Variants keep their ids by SKU so
+// they stay in buyers' carts, variants left out are deleted along with their pictures, and the product's quantity
+// becomes their total stock. A variant added back with a removed one's SKU gets its id back.
+// options and variants must have been checked with models.SetVariants.
+func (pdb *PostgresDb) SaveProductVariants(productID uint, options []models.ProductOption, variants []models.ProductVariant) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(&product).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ProductOption{}).Error; err != nil {
+			return err
+		}
+		for i := range options {
+			options[i].ID = 0
+			options[i].ProductID = productID
+		}
+		if len(options) > 0 {
+			if err := tx.Create(&options).Error; err != nil {
+				return err
+			}
+		}
+
+		var existing []models.ProductVariant
+		if err := tx.Unscoped().Where("product_id = ?", productID).Find(&existing).Error; err != nil {
+			return err
+		}
+		bySKU := make(map[string]uint, len(existing))
+		removed := make(map[uint]bool, len(existing))
+		for _, variant := range existing {
+			bySKU[variant.SKU] = variant.ID
+			removed[variant.ID] = variant.DeletedAt.Valid
+		}
+		var quantity uint
+		for i := range variants {
+			variant := &variants[i]
+			variant.ProductID = productID
+			variant.Images = nil
+			quantity += variant.Quantity
+			id, ok := bySKU[variant.SKU]
+			if !ok {
+				if err := tx.Omit("Images").Create(variant).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			delete(bySKU, variant.SKU)
+			variant.ID = id
+			err := tx.Unscoped().Model(variant).
+				Select("title", "option_list", "price", "quantity", "updated_at", "deleted_at").Updates(variant).Error
+			if err != nil {
+				return err
+			}
+		}
+		gone := make([]uint, 0, len(bySKU))
+		for _, id := range bySKU {
+			if !removed[id] {
+				gone = append(gone, id)
+			}
+		}
+		if len(gone) > 0 {
+			var images []models.VariantImage
+			if err := tx.Where("variant_id IN ?", gone).Find(&images).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("variant_id IN ?", gone).Delete(&models.VariantImage{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", gone).Delete(&models.ProductVariant{}).Error; err != nil {
+				return err
+			}
+			var urls []string
+			for _, image := range images {
+				urls = append(append(urls, image.Url), image.Variants.URLs()...)
+			}
+			if err := imagesRemoved(tx, &product, urls); err != nil {
+				return err
+			}
+		}
+
+		if len(variants) == 0 || quantity == product.Quantity {
+			return nil
+		}
+		return setProductQuantity(tx, &product, quantity)
+	})
+}
+
+// AddVariantImages adds pictures to one of a product's variants
+func (pdb *PostgresDb) AddVariantImages(productID, variantID uint, images []models.VariantImage) ([]models.VariantImage, error) {
+	var variant models.ProductVariant
+	if err := pdb.DB.Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		return nil, err
+	}
+	for i := range images {
+		images[i].VariantID = variant.ID
+	}
+	if err := pdb.DB.Create(&images).Error; err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// setProductQuantity changes a product's stock and records the change for stock alerts and webhooks
+func setProductQuantity(tx *gorm.DB, product *models.Product, quantity uint) error {
+	oldQuantity := product.Quantity
+	if err := tx.Model(product).UpdateColumn("quantity", quantity).Error; err != nil {
+		return err
+	}
+	product.Quantity = quantity
+	return recordEvent(tx, models.AggregateProduct, product.ID, models.EventProductStockChanged, models.StockChangedEvent{
+		ProductID:   product.ID,
+		SellerID:    product.SellerId,
+		OldQuantity: oldQuantity,
+		NewQuantity: quantity,
+		Price:       product.Price,
+	})
+}
+
+// cartVariant checks the variant picked for a product going into a cart
+func cartVariant(tx *gorm.DB, product *models.Product, variantID *uint, quantity uint) (*models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	if err := tx.Where("product_id = ?", product.ID).Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		if variantID != nil {
+			return nil, ErrVariantNotFound
+		}
+		return nil, nil
+	}
+	if variantID == nil {
+		return nil, ErrVariantRequired
+	}
+	for i := range variants {
+		if variants[i].ID != *variantID {
+			continue
+		}
+		if variants[i].Quantity < quantity {
+			return nil, ErrOutOfStock
+		}
+		return &variants[i], nil
+	}
+	return nil, ErrVariantNotFound
+}
+
+// takeVariantStock takes a paid order's quantity off its variant and the product's total.
+// The buyer has already paid, so stock that ran out meanwhile goes to zero rather than failing the order.
+func takeVariantStock(tx *gorm.DB, order *models.Order) error {
+	if order.VariantID == nil {
+		return nil
+	}
+	err := tx.Model(&models.ProductVariant{}).Where("id = ?", *order.VariantID).
+		UpdateColumn("quantity", gorm.Expr("GREATEST(quantity - ?, 0)", order.Quantity)).Error
+	if err != nil {
+		return err
+	}
+	var product models.Product
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", order.ProductId).First(&product).Error
+	if err != nil {
+		return err
+	}
+	quantity := uint(0)
+	if product.Quantity > order.Quantity {
+		quantity = product.Quantity - order.Quantity
+	}
+	return setProductQuantity(tx, &product, quantity)
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deliveryClaimLease is how long a claimed delivery is hidden from other senders while it is posted
+const deliveryClaimLease = 2 * time.Minute
+
+// CreateWebhookEndpoint saves a new webhook endpoint
+func (pdb *PostgresDb) CreateWebhookEndpoint(endpoint *models.WebhookEndpoint) error {
+	return pdb.DB.Create(endpoint).Error
+}
+
+// GetSellerWebhookEndpoints lists a seller's webhook endpoints
+func (pdb *PostgresDb) GetSellerWebhookEndpoints(sellerID uint) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := pdb.DB.Where("seller_id = ?", sellerID).Order("id").Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// GetWebhookEndpoint finds one of a seller's webhook endpoints
+func (pdb *PostgresDb) GetWebhookEndpoint(id, sellerID uint) (*models.WebhookEndpoint, error) {
+	endpoint := &models.WebhookEndpoint{}
+	err := pdb.DB.Where("id = ? AND seller_id = ?", id, sellerID).First(endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// UpdateWebhookEndpoint saves changes to an endpoint's url, description, events and active flag
+func (pdb *PostgresDb) UpdateWebhookEndpoint(endpoint *models.WebhookEndpoint) error {
+	return pdb.DB.Model(endpoint).Select("url", "description", "event_types", "active").Updates(endpoint).Error
+}
+
+// DeleteWebhookEndpoint removes one of a seller's webhook endpoints
+func (pdb *PostgresDb) DeleteWebhookEndpoint(id, sellerID uint) error {
+	return pdb.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND seller_id = ?", id, sellerID).Delete(&models.WebhookEndpoint{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// deliveries still waiting have nowhere to go
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("endpoint_id = ? AND status = ?", id, models.DeliveryStatusPending).
+			Updates(map[string]interface{}{"status": models.DeliveryStatusFailed, "last_error": "endpoint was deleted"}).Error
+	})
+}
+
+// QueueWebhookDeliveries queues the event for each of the seller's active endpoints that subscribe to it.
+// Queueing the same event again adds nothing, so it is safe when events are redelivered.
+func (pdb *PostgresDb) QueueWebhookDeliveries(sellerID, eventID uint, eventType, payload string) (int, error) {
+	var endpoints []models.WebhookEndpoint
+	err := pdb.DB.Where("seller_id = ? AND active = ?", sellerID, true).Find(&endpoints).Error
+	if err != nil {
+		return 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(eventType) {
+			continue
+		}
+		key := fmt.Sprintf("%d:%d", endpoint.ID, eventID)
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			SellerID:      sellerID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       payload,
+			DedupKey:      &key,
+			Status:        models.DeliveryStatusPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	result := pdb.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries)
+	return int(result.RowsAffected), result.Error
+}
+
+// ClaimWebhookDeliveries takes up to limit deliveries that are due, with their endpoints
+func (pdb *PostgresDb) ClaimWebhookDeliveries(limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := pdb.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, time.Now()).
+			Order("id").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		err = tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(deliveryClaimLease)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Preload("Endpoint").Where("id IN ?", ids).Order("id").Find(&deliveries).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// SaveWebhookDelivery records the outcome of posting a delivery
+func (pdb *PostgresDb) SaveWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return pdb.DB.Model(delivery).
+		Select("status", "attempts", "next_attempt_at", "response_status", "response_body",
+			"last_error", "duration_ms", "delivered_at").
+		Updates(delivery).Error
+}
+
+// GetWebhookDeliveries lists the latest deliveries to an endpoint, newest first
+func (pdb *PostgresDb) GetWebhookDeliveries(endpointID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := pdb.DB.Where("endpoint_id = ?", endpointID).Order("id desc").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayWebhookDelivery queues a fresh copy of one of an endpoint's deliveries to be sent now
+func (pdb *PostgresDb) ReplayWebhookDelivery(id, endpointID uint) (*models.WebhookDelivery, error) {
+	original := &models.WebhookDelivery{}
+	err := pdb.DB.Where("id = ? AND endpoint_id = ?", id, endpointID).First(original).Error
+	if err != nil {
+		return nil, err
+	}
+	replay := &models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		SellerID:      original.SellerID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		ReplayOfID:    &original.ID,
+		Status:        models.DeliveryStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := pdb.DB.Create(replay).Error; err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
@@ -0,0 +1,94 @@
+// Package events publishes the domain events recorded in the outbox.
+//
+// Changes write their events in the same transaction as the change itself (see the
+// database package). The Relay reads the outbox and hands each event to a Bus, whose
+// subscribers deliver it onwards, for example to a log or a webhook. An event is retried
+// until every subscriber accepts it, so subscribers see each event at least once and must
+// be idempotent, using the event ID to spot repeats. Events of one aggregate, such as a
+// product, are published one at a time and in the order they happened.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// Event is a domain event as subscribers see it
+type Event struct {
+	ID            uint            `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uint            `json:"aggregate_id"`
+	Sequence      uint            `json:"sequence"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// FromModel turns an outbox row into an Event
+func FromModel(e *models.DomainEvent) Event {
+	payload := json.RawMessage(e.Payload)
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	}
+	return Event{
+		ID:            e.ID,
+		Type:          e.Type,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		Sequence:      e.Sequence,
+		OccurredAt:    e.CreatedAt,
+		Payload:       payload,
+	}
+}
+
+// Decode reads the event's payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Handler receives events from the bus. An error means the event is published again later.
+type Handler func(ctx context.Context, event Event) error
+
+type subscription struct {
+	name      string
+	eventType string
+	handle    Handler
+}
+
+// Bus dispatches events to the subscribers of their type
+type Bus struct {
+	subscriptions []subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls handle for every event of eventType, or for every event when eventType is "*".
+// The name identifies the subscriber in errors.
+func (b *Bus) Subscribe(eventType, name string, handle Handler) {
+	b.subscriptions = append(b.subscriptions, subscription{name: name, eventType: eventType, handle: handle})
+}
+
+// Publish hands the event to each of its subscribers in turn.
+// All of them are called even if one fails, and their failures are returned together.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	var failures []string
+	for _, s := range b.subscriptions {
+		if s.eventType != "*" && s.eventType != event.Type {
+			continue
+		}
+		if err := s.handle(ctx, event); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", s.name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("publish %s %d: %s", event.Type, event.ID, strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// Store is the outbox the relay publishes from
+type Store interface {
+	ClaimEvents(limit int) ([]models.DomainEvent, error)
+	SaveEventAttempt(event *models.DomainEvent) error
+}
+
+// Relay moves events from the outbox to the bus.
+// An event that keeps failing is retried with backoff and, after MaxAttempts, marked dead
+// so that the later events of its aggregate can go out.
+type Relay struct {
+	Store       Store
+	Bus         *Bus
+	BatchSize   int
+	MaxAttempts uint
+}
+
+func NewRelay(store Store, bus *Bus) *Relay {
+	return &Relay{Store: store, Bus: bus, BatchSize: 50, MaxAttempts: 10}
+}
+
+// Flush publishes every event that is due and returns how many were published
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		events, err := r.Store.ClaimEvents(r.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		for i := range events {
+			if ctx.Err() != nil {
+				// unpublished events come back once their claim runs out
+				return published, ctx.Err()
+			}
+			if r.publish(ctx, &events[i]) {
+				published++
+			}
+		}
+		if len(events) < r.BatchSize {
+			return published, nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, event *models.DomainEvent) bool {
+	now := time.Now()
+	event.Attempts++
+	err := r.Bus.Publish(ctx, FromModel(event))
+	if err == nil {
+		event.Status = models.EventStatusPublished
+		event.PublishedAt = &now
+		event.LastError = ""
+	} else {
+		event.LastError = err.Error()
+		if event.Attempts >= r.MaxAttempts {
+			event.Status = models.EventStatusDead
+			log.Printf("event %d is dead after %d attempts: %v\n", event.ID, event.Attempts, err)
+		} else {
+			event.NextAttemptAt = now.Add(retryDelay(event.Attempts))
+		}
+	}
+	if saveErr := r.Store.SaveEventAttempt(event); saveErr != nil {
+		log.Printf("save event attempt error: %v\n", saveErr)
+	}
+	return err == nil
+}
+
+// retryDelay is five seconds, doubling with each failure, up to an hour
+func retryDelay(attempts uint) time.Duration {
+	delay := 5 * time.Second
+	for i := uint(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= time.Hour {
+			return time.Hour
+		}
+	}
+	return delay
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the timestamp and body of a webhook request
+const SignatureHeader = "X-Oja-Signature"
+
+// LogSink writes each event to the log as a line of json
+func LogSink() Handler {
+	return func(ctx context.Context, event Event) error {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		log.Printf("domain event: %s\n", line)
+		return nil
+	}
+}
+
+// WebhookSink posts each event as json to url.
+// When secret is set the request is signed, see Sign.
+func WebhookSink(url, secret string, client *http.Client) Handler {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return func(ctx context.Context, event Event) error {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Oja-Event", event.Type)
+		req.Header.Set("X-Oja-Event-Id", strconv.Itoa(int(event.ID)))
+		if secret != "" {
+			req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook %s responded %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Sign returns the signature header value "t=<unix time>,v1=<hex hmac>".
+// The HMAC-SHA256 is taken over "<unix time>.<body>" with the secret, so receivers can
+// check both that the body is ours and that the request is recent.
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DefaultBus logs every event and posts it to each of the comma separated EVENT_WEBHOOK_URLS,
+// signed with EVENT_WEBHOOK_SECRET
+func DefaultBus() *Bus {
+	bus := NewBus()
+	bus.Subscribe("*", "log", LogSink())
+	secret := os.Getenv("EVENT_WEBHOOK_SECRET")
+	for _, url := range strings.Split(os.Getenv("EVENT_WEBHOOK_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			bus.Subscribe("*", "webhook "+url, WebhookSink(url, secret, nil))
+		}
+	}
+	return bus
+}
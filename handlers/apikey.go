@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type apiKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKey gives the seller a new api key with the scopes asked for.
+// The key is returned once, here, and cannot be read again.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request apiKeyRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	var errs []string
+	if len(request.Scopes) == 0 {
+		errs = append(errs, "choose at least one scope")
+	}
+	for _, scope := range request.Scopes {
+		known := false
+		for _, allowed := range models.APIKeyScopes {
+			if scope == allowed {
+				known = true
+				break
+			}
+		}
+		if !known {
+			errs = append(errs, fmt.Sprintf("unknown scope %s", scope))
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		errs = append(errs, "expires_at must be in the future")
+	}
+	if errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	key, prefix, hash, err := services.GenerateAPIKey()
+	if err != nil {
+		log.Printf("generate api key error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to create api key"})
+		return
+	}
+	apiKey := &models.APIKey{
+		SellerID:  seller.ID,
+		Name:      request.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		ExpiresAt: request.ExpiresAt,
+	}
+	apiKey.SetScopes(request.Scopes)
+	if err := h.DB.CreateAPIKey(apiKey); err != nil {
+		log.Printf("create api key error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to create api key"})
+		return
+	}
+	response.JSON(c, "api key created successfully, keep it safe as it will not be shown again",
+		http.StatusCreated, gin.H{"api_key": apiKey, "key": key}, nil)
+}
+
+// GetAPIKeys lists the seller's api keys
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	keys, err := h.DB.GetSellerAPIKeys(seller.ID)
+	if err != nil {
+		log.Printf("get api keys error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get api keys"})
+		return
+	}
+	response.JSON(c, "api keys retrieved successfully", http.StatusOK, keys, nil)
+}
+
+// RevokeAPIKey stops one of the seller's api keys working
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid api key id"})
+		return
+	}
+	err = h.DB.RevokeAPIKey(uint(id), seller.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"api key not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("revoke api key error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to revoke api key"})
+		return
+	}
+	response.JSON(c, "api key revoked successfully", http.StatusOK, nil, nil)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type categoryAttributeRequest struct {
+	Key           string   `json:"key"`
+	Name          string   `json:"name" binding:"required"`
+	Type          string   `json:"type" binding:"required"`
+	Required      bool     `json:"required"`
+	AllowedValues []string `json:"allowed_values"`
+}
+
+// GetCategoryAttributes returns the attributes a product in the category has, including the ones it
+// inherits from the categories above it, for seller product forms
+func (h *Handler) GetCategoryAttributes(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid category id"})
+		return
+	}
+	attributes, err := h.DB.GetCategoryAttributes(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"category not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("get category attributes error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get attributes"})
+		return
+	}
+	response.JSON(c, "attributes retrieved successfully", http.StatusOK, attributes, nil)
+}
+
+// SaveCategoryAttributes replaces a category's own attributes with a list of
+// {"name":"RAM","key":"ram","type":"select","required":true,"allowed_values":["4GB","8GB"]}.
+// The type is text, number, boolean or select and only select attributes have allowed values.
+func (h *Handler) SaveCategoryAttributes(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid category id"})
+		return
+	}
+	var request []categoryAttributeRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	attributes := make([]models.CategoryAttribute, 0, len(request))
+	keys := map[string]bool{}
+	for _, attribute := range request {
+		definition := models.CategoryAttribute{
+			Key:           attribute.Key,
+			Name:          attribute.Name,
+			Type:          attribute.Type,
+			Required:      attribute.Required,
+			AllowedValues: attribute.AllowedValues,
+		}
+		if err := definition.Check(); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+			return
+		}
+		if keys[definition.Key] {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"more than one attribute is " + definition.Key})
+			return
+		}
+		keys[definition.Key] = true
+		attributes = append(attributes, definition)
+	}
+
+	err = h.DB.SaveCategoryAttributes(uint(id), attributes)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"category not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("save category attributes error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to save attributes"})
+		return
+	}
+	response.JSON(c, "attributes saved successfully", http.StatusOK, attributes, nil)
+}
+
+// productAttributes checks a product's attribute values against its category, returning the
+// errors to show the seller
+func (h *Handler) productAttributes(categoryID uint, values map[string]string) ([]models.ProductAttribute, []string) {
+	definitions, err := h.DB.GetCategoryAttributes(categoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, []string{"category not found"}
+	}
+	if err != nil {
+		log.Printf("get category attributes error: %v\n", err)
+		return nil, []string{"unable to check attributes"}
+	}
+	attributes, err := models.ValidateAttributes(definitions, values)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	return attributes, nil
+}
+
+// formAttributes reads a product's attribute values from the attr.<key> fields of a form
+func formAttributes(form *multipart.Form) map[string]string {
+	values := map[string]string{}
+	for field, value := range form.Value {
+		key := strings.TrimPrefix(field, models.AttributeFilterPrefix)
+		if key != field && key != "" && len(value) > 0 {
+			values[key] = value[0]
+		}
+	}
+	return values
+}
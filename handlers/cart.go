@@ -1,12 +1,20 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"github.com/decadevs/shoparena/database"
 	"github.com/decadevs/shoparena/models"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
+// addToCartRequest is the product going into the cart, with the variant picked when it has variants
+type addToCartRequest struct {
+	models.Product
+	VariantID *uint `json:"variant_id"`
+}
+
 func (h *Handler) AddToCart(c *gin.Context) {
 	user1, exist := c.Get("user")
 	if !exist {
@@ -15,15 +23,20 @@ func (h *Handler) AddToCart(c *gin.Context) {
 	}
 	user := user1.(*models.Buyer)
 
-	var product models.Product
+	var request addToCartRequest
 
-	err := c.BindJSON(&product)
+	err := c.BindJSON(&request)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "bad request"})
 		return
 	}
 
-	err = h.DB.AddToCart(product, user)
+	err = h.DB.AddToCart(request.Product, request.VariantID, user)
+	if errors.Is(err, database.ErrVariantRequired) || errors.Is(err, database.ErrVariantNotFound) ||
+		errors.Is(err, database.ErrOutOfStock) || errors.Is(err, database.ErrProductUnavailable) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "cannot add to cart"})
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ClickCartReminder is the link in a cart reminder email. It records the click and sends the buyer
+// to their cart, with the reminder's coupon when it has one.
+func (h *Handler) ClickCartReminder(c *gin.Context) {
+	link := services.FrontendURL() + "/buyer/cart"
+	reminder, err := h.DB.ClickCartReminder(c.Param("token"))
+	if err != nil {
+		log.Printf("click cart reminder error: %v\n", err)
+	} else if reminder.Coupon != nil {
+		link += "?coupon=" + url.QueryEscape(reminder.Coupon.Code)
+	}
+	c.Redirect(http.StatusFound, link)
+}
+
+// UnsubscribeCartReminders is the unsubscribe link in a cart reminder email
+func (h *Handler) UnsubscribeCartReminders(c *gin.Context) {
+	buyerID, ok := services.ParseUnsubscribeToken(c.Query("token"))
+	if !ok {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid unsubscribe link"})
+		return
+	}
+	if err := h.DB.SetCartReminders(buyerID, true); err != nil {
+		log.Printf("unsubscribe cart reminders error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to unsubscribe"})
+		return
+	}
+	response.JSON(c, "you will no longer get cart reminder emails", http.StatusOK, nil, nil)
+}
+
+type cartRemindersRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// UpdateCartReminders lets a buyer turn cart reminder emails off or back on
+func (h *Handler) UpdateCartReminders(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request cartRemindersRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if err := h.DB.SetCartReminders(buyer.ID, !request.Enabled); err != nil {
+		log.Printf("update cart reminders error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update cart reminders"})
+		return
+	}
+	response.JSON(c, "cart reminders updated successfully", http.StatusOK, gin.H{"enabled": request.Enabled}, nil)
+}
+
+type couponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ApplyCoupon puts a coupon on the buyer's cart, to be taken off at checkout
+func (h *Handler) ApplyCoupon(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request couponRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	coupon, err := h.DB.ApplyCoupon(buyer.ID, request.Code)
+	if errors.Is(err, database.ErrCouponInvalid) {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("apply coupon error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to apply coupon"})
+		return
+	}
+	response.JSON(c, "coupon applied successfully", http.StatusOK, coupon, nil)
+}
+
+// RemoveCoupon takes the coupon off the buyer's cart
+func (h *Handler) RemoveCoupon(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	if err := h.DB.RemoveCoupon(buyer.ID); err != nil {
+		log.Printf("remove coupon error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to remove coupon"})
+		return
+	}
+	response.JSON(c, "coupon removed successfully", http.StatusOK, nil, nil)
+}
+
+// CartReminderStats shows how many cart reminders were sent over the last ?days=30 days,
+// and how many were clicked and led to a payment
+func (h *Handler) CartReminderStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"days must be between 1 and 365"})
+		return
+	}
+	stats, err := h.DB.GetCartReminderStats(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("cart reminder stats error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get cart reminder stats"})
+		return
+	}
+	response.JSON(c, "cart reminder stats retrieved successfully", http.StatusOK, stats, nil)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxCatalogueSize caps the size of an imported catalogue file
+const maxCatalogueSize = 10 << 20
+
+// ImportCatalogue creates and updates the seller's products from a CSV or JSON lines file uploaded
+// under "file", matching products on their SKU. The format comes from ?format= or the file's extension.
+// With ?dry_run=true the rows are only checked and the report comes straight back; otherwise the
+// import runs in the background and GetProductImport shows how it is going.
+func (h *Handler) ImportCatalogue(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"upload a catalogue file as file"})
+		return
+	}
+	if file.Size > maxCatalogueSize {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"catalogue files can be at most 10MB"})
+		return
+	}
+	format := c.DefaultQuery("format", strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), "."))
+	f, err := file.Open()
+	if err != nil {
+		log.Printf("open catalogue error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to read catalogue"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxCatalogueSize))
+	if err != nil {
+		log.Printf("read catalogue error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to read catalogue"})
+		return
+	}
+	rows, rowErrors, err := services.ParseCatalogue(format, bytes.NewReader(data))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	productImport := &models.ProductImport{SellerID: seller.ID, Format: format, Status: models.JobStatusPending}
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun {
+		importer := &services.ProductImporter{Store: h.DB, DryRun: true}
+		if err := importer.Run(c.Request.Context(), productImport, rows, rowErrors); err != nil {
+			log.Printf("check catalogue error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to check catalogue"})
+			return
+		}
+		now := time.Now()
+		productImport.Status = models.JobStatusDone
+		productImport.FinishedAt = &now
+		response.JSON(c, "catalogue checked successfully", http.StatusOK, productImport, nil)
+		return
+	}
+
+	productImport.TotalRows = len(rows) + len(rowErrors)
+	productImport.Data = string(data)
+	productImport.Errors = []models.ImportRowError{}
+	if err := h.DB.CreateProductImport(productImport); err != nil {
+		log.Printf("create product import error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to start import"})
+		return
+	}
+	err = jobs.Enqueue(h.DB, jobs.TypeProductImport, jobs.ProductImportPayload{ImportID: productImport.ID})
+	if err != nil {
+		log.Printf("queue product import error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to start import"})
+		return
+	}
+	response.JSON(c, "import started successfully", http.StatusAccepted, productImport, nil)
+}
+
+// GetProductImport shows how one of the seller's imports is going, with the rows that failed
+func (h *Handler) GetProductImport(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid import id"})
+		return
+	}
+	productImport, err := h.DB.GetProductImport(uint(id))
+	if err != nil || productImport.SellerID != seller.ID {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"import not found"})
+		return
+	}
+	response.JSON(c, "import retrieved successfully", http.StatusOK, productImport, nil)
+}
+
+// ExportCatalogue downloads the seller's products as ?format=csv or jsonl, ready to edit and import again
+func (h *Handler) ExportCatalogue(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	format := c.DefaultQuery("format", models.CatalogueCSV)
+	if format != models.CatalogueCSV && format != models.CatalogueJSONL {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"format must be csv or jsonl"})
+		return
+	}
+	products, err := h.DB.GetSellerCatalogue(seller.ID)
+	if err != nil {
+		log.Printf("get seller catalogue error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to export catalogue"})
+		return
+	}
+	var buf bytes.Buffer
+	if err := services.WriteCatalogue(format, &buf, products); err != nil {
+		log.Printf("write catalogue error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to export catalogue"})
+		return
+	}
+	contentType := "text/csv"
+	if format == models.CatalogueJSONL {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="catalogue.%s"`, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
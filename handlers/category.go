@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type categoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// GetCategories returns the category tree, each category with its children
+func (h *Handler) GetCategories(c *gin.Context) {
+	categories, err := h.DB.GetCategories()
+	if err != nil {
+		log.Printf("get categories error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get categories"})
+		return
+	}
+	response.JSON(c, "categories retrieved successfully", http.StatusOK, models.CategoryTree(categories), nil)
+}
+
+// CreateCategory adds a category under parent_id, or at the root without one.
+// The slug is made from the name unless it is given.
+func (h *Handler) CreateCategory(c *gin.Context) {
+	category, ok := h.decodeCategory(c)
+	if !ok {
+		return
+	}
+	if err := h.DB.CreateCategory(category); err != nil {
+		h.categoryError(c, err, "create")
+		return
+	}
+	response.JSON(c, "category created successfully", http.StatusCreated, category, nil)
+}
+
+// UpdateCategory renames a category and moves it, along with the categories below it, to parent_id.
+// Leaving out parent_id moves it to the root.
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid category id"})
+		return
+	}
+	category, ok := h.decodeCategory(c)
+	if !ok {
+		return
+	}
+	category.ID = uint(id)
+	if err := h.DB.UpdateCategory(category); err != nil {
+		h.categoryError(c, err, "update")
+		return
+	}
+	response.JSON(c, "category updated successfully", http.StatusOK, category, nil)
+}
+
+// DeleteCategory removes a category. The categories below it move up a level and its products move
+// to ?reassign_to= or, without it, to its parent. A top level category with products needs reassign_to.
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid category id"})
+		return
+	}
+	var reassignTo *uint
+	if value := c.Query("reassign_to"); value != "" {
+		target, err := strconv.Atoi(value)
+		if err != nil || target < 1 {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid reassign_to"})
+			return
+		}
+		reassign := uint(target)
+		reassignTo = &reassign
+	}
+	moved, err := h.DB.DeleteCategory(uint(id), reassignTo)
+	if err != nil {
+		h.categoryError(c, err, "delete")
+		return
+	}
+	response.JSON(c, "category deleted successfully", http.StatusOK, gin.H{"products_moved": moved}, nil)
+}
+
+// decodeCategory reads a category from the request, responding with an error when it is invalid
+func (h *Handler) decodeCategory(c *gin.Context) (*models.Category, bool) {
+	var request categoryRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return nil, false
+	}
+	name := strings.TrimSpace(request.Name)
+	slug := request.Slug
+	if slug == "" {
+		slug = models.Slugify(name)
+	}
+	if name == "" || slug == "" || slug != models.Slugify(slug) {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"slug must be lowercase letters and numbers separated by dashes"})
+		return nil, false
+	}
+	return &models.Category{Name: name, Slug: slug, ParentID: request.ParentID}, true
+}
+
+// categoryError responds to a failed change to the category tree
+func (h *Handler) categoryError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"category not found"})
+	case errors.Is(err, database.ErrCategorySlugTaken), errors.Is(err, database.ErrCategoryCycle),
+		errors.Is(err, database.ErrCategoryInUse):
+		response.JSON(c, "", http.StatusConflict, nil, []string{err.Error()})
+	default:
+		log.Printf("%s category error: %v\n", action, err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to " + action + " category"})
+	}
+}
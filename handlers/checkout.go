@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/decadevs/shoparena/database"
 	"github.com/decadevs/shoparena/models"
 	"github.com/decadevs/shoparena/server/response"
 	"github.com/decadevs/shoparena/services"
@@ -15,10 +17,6 @@ import (
 	"strconv"
 )
 
-type Fees struct {
-	Amount float64 `json:"amount" binding:"required"`
-}
-
 type Transaction struct {
 	UserID      uint    `json:"user_id"`
 	Amount      float64 `json:"amount"`
@@ -37,11 +35,19 @@ func (h *Handler) Pay(c *gin.Context) {
 	}
 	user := userI.(*models.Buyer)
 
-	var Incoming Fees
-
-	err := c.BindJSON(&Incoming)
+	// the amount is worked out from the cart so the buyer pays the tax-inclusive total
+	summary, err := h.DB.GetCheckoutSummary(user)
+	if errors.Is(err, database.ErrVariantRemoved) || errors.Is(err, database.ErrProductUnavailable) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "bad request"})
+		log.Printf("checkout summary error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal server error"})
+		return
+	}
+	if len(summary.Lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "cart is empty"})
 		return
 	}
 
@@ -60,9 +66,24 @@ func (h *Handler) Pay(c *gin.Context) {
 		return
 	}
 
+	// a coupon or wallet credit can cover the whole cart, and paystack can't charge nothing,
+	// so those orders are completed here with the checkout token as their reference
+	if summary.Total == 0 {
+		if err := h.completeOrder(user.ID, *token, 0); err != nil {
+			log.Printf("complete free order error: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal server error"})
+			return
+		}
+		response.JSON(c, "Order placed", http.StatusOK, gin.H{
+			"authorization_url": "https://shoparena-frontend-phi.vercel.app/buyer/payment/successful",
+			"summary":           summary,
+		}, nil)
+		return
+	}
+
 	transaction := Transaction{
 		UserID:      user.ID,
-		Amount:      Incoming.Amount * 100,
+		Amount:      float64(summary.Total) * 100,
 		FirstName:   user.FirstName,
 		LastName:    user.LastName,
 		Email:       user.Email,
@@ -83,6 +104,7 @@ func (h *Handler) Pay(c *gin.Context) {
 
 	response.JSON(c, "Transaction initialized", http.StatusOK, gin.H{
 		"authorization_url": authorizationUrl,
+		"summary":           summary,
 	}, nil)
 
 }
@@ -90,12 +112,24 @@ func (h *Handler) Pay(c *gin.Context) {
 func (h *Handler) Callback(c *gin.Context) {
 	reference := c.Query("reference")
 
-	_, err := h.Paystack.VerifyReference(reference)
+	resp, err := h.Paystack.VerifyReference(reference)
 	if err != nil {
 		log.Println(err)
 		c.Redirect(http.StatusFound, "https://shoparena-frontend-phi.vercel.app/buyer/payment/unsuccessful")
 		return
 	}
+	verification, err := services.ReadVerification(resp)
+	if err != nil {
+		log.Println(err)
+		c.Redirect(http.StatusFound, "https://shoparena-frontend-phi.vercel.app/buyer/payment/unsuccessful")
+		return
+	}
+	// only a successful payment of the cart's total completes the order
+	amountPaid, ok := verification.Paid()
+	if !ok {
+		c.Redirect(http.StatusFound, "https://shoparena-frontend-phi.vercel.app/buyer/payment/unsuccessful")
+		return
+	}
 
 	//fmt.Printf("this is before the decode %v \n", resp)
 	//claims, err := DecodeTokenForPayment(reference)
@@ -120,8 +154,7 @@ func (h *Handler) Callback(c *gin.Context) {
 		return
 	}
 
-	err = h.DB.DeletePaidFromCart(uint(cartID))
-	if err != nil {
+	if err := h.completeOrder(uint(cartID), reference, amountPaid); err != nil {
 		log.Println(err)
 		c.Redirect(http.StatusFound, "https://shoparena-frontend-phi.vercel.app/buyer/payment/unsuccessful")
 		return
@@ -130,3 +163,19 @@ func (h *Handler) Callback(c *gin.Context) {
 	c.Redirect(http.StatusFound, "https://shoparena-frontend-phi.vercel.app/buyer/payment/successful")
 	return
 }
+
+// completeOrder turns the paid cart into orders, pays out any referral and emails the invoices
+func (h *Handler) completeOrder(cartID uint, reference string, amountPaid uint) error {
+	invoices, err := h.DB.DeletePaidFromCart(cartID, reference, amountPaid)
+	if err != nil {
+		return err
+	}
+
+	// the payment has gone through, so a failed referral payout must not fail the order
+	if err := h.DB.CompleteReferral(cartID, services.ReferralRewardAmount()); err != nil {
+		log.Printf("complete referral error: %v\n", err)
+	}
+
+	h.emailInvoices(invoices)
+	return nil
+}
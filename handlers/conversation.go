@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits on what a message can carry
+const (
+	maxMessageLength      = 2000
+	maxMessageAttachments = 4
+)
+
+type startConversationRequest struct {
+	SellerID  uint   `json:"seller_id"`
+	ProductID *uint  `json:"product_id"`
+	OrderID   *uint  `json:"order_id"`
+	Body      string `json:"body" binding:"required"`
+}
+
+// StartConversation opens a thread with the other side, or adds to the one already open about the same thing,
+// and sends the first message. A buyer picks a seller, one of their products or one of the buyer's orders;
+// a seller can only start a conversation about one of their orders.
+func (h *Handler) StartConversation(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		participantID, ok := h.notificationRecipient(c, participantType)
+		if !ok {
+			return
+		}
+		var request startConversationRequest
+		if errs := h.Decode(c, &request); errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		body, errs := messageBody(request.Body, 0)
+		if errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+
+		conversation := &models.Conversation{ProductID: request.ProductID, OrderID: request.OrderID}
+		switch {
+		case request.OrderID != nil:
+			order, err := h.DB.GetOrder(*request.OrderID)
+			if err != nil || (participantType == models.RecipientSeller && order.SellerId != participantID) ||
+				(participantType == models.RecipientBuyer && order.BuyerId != participantID) {
+				response.JSON(c, "", http.StatusNotFound, nil, []string{"order not found"})
+				return
+			}
+			conversation.BuyerID, conversation.SellerID = order.BuyerId, order.SellerId
+			conversation.ProductID = &order.ProductId
+		case participantType == models.RecipientSeller:
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"order_id is required"})
+			return
+		case request.ProductID != nil:
+			product, err := h.DB.GetProductByID(*request.ProductID)
+			if err != nil {
+				response.JSON(c, "", http.StatusNotFound, nil, []string{"product not found"})
+				return
+			}
+			conversation.BuyerID, conversation.SellerID = participantID, product.SellerId
+		default:
+			if request.SellerID == 0 {
+				response.JSON(c, "", http.StatusBadRequest, nil, []string{"choose a seller_id, product_id or order_id"})
+				return
+			}
+			if _, err := h.DB.FindSellerById(request.SellerID); err != nil {
+				response.JSON(c, "", http.StatusNotFound, nil, []string{"seller not found"})
+				return
+			}
+			conversation.BuyerID, conversation.SellerID = participantID, request.SellerID
+		}
+
+		if err := h.DB.StartConversation(conversation); err != nil {
+			log.Printf("start conversation error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to start conversation"})
+			return
+		}
+		message := &models.Message{
+			ConversationID: conversation.ID,
+			SenderType:     participantType,
+			SenderID:       participantID,
+			Body:           body,
+		}
+		if !h.sendMessage(c, message) {
+			return
+		}
+		response.JSON(c, "conversation started successfully", http.StatusCreated, gin.H{
+			"conversation": message.Conversation,
+			"message":      message,
+		}, nil)
+	}
+}
+
+// ListConversations lists the user's conversations, most recently active first, ?page=1&limit=20,
+// with each one's unread count and the user's unread total
+func (h *Handler) ListConversations(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		participantID, ok := h.notificationRecipient(c, participantType)
+		if !ok {
+			return
+		}
+		page, limit, ok := pageParams(c, 20)
+		if !ok {
+			return
+		}
+		conversations, err := h.DB.GetConversations(participantType, participantID, page, limit)
+		if err != nil {
+			log.Printf("get conversations error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get conversations"})
+			return
+		}
+		response.JSON(c, "conversations retrieved successfully", http.StatusOK, conversations, nil)
+	}
+}
+
+// GetConversationMessages lists a conversation's messages newest first, ?limit=30.
+// Older messages are fetched with ?before= set to the next_before of the previous page.
+func (h *Handler) GetConversationMessages(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conversation, ok := h.participantConversation(c, participantType)
+		if !ok {
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "30"))
+		if err != nil || limit < 1 || limit > 100 {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"limit must be between 1 and 100"})
+			return
+		}
+		before, err := strconv.Atoi(c.DefaultQuery("before", "0"))
+		if err != nil || before < 0 {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid before"})
+			return
+		}
+		messages, err := h.DB.GetMessages(conversation.ID, uint(before), limit)
+		if err != nil {
+			log.Printf("get messages error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get messages"})
+			return
+		}
+		var nextBefore *uint
+		if len(messages) == limit {
+			nextBefore = &messages[len(messages)-1].ID
+		}
+		response.JSON(c, "messages retrieved successfully", http.StatusOK, gin.H{
+			"conversation": conversation,
+			"messages":     messages,
+			"next_before":  nextBefore,
+		}, nil)
+	}
+}
+
+// SendMessage adds a message to a conversation. It takes JSON with a body, or a multipart form
+// with a body and up to four images under "images".
+func (h *Handler) SendMessage(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conversation, ok := h.participantConversation(c, participantType)
+		if !ok {
+			return
+		}
+		message := &models.Message{
+			ConversationID: conversation.ID,
+			SenderType:     participantType,
+			SenderID:       conversation.BuyerID,
+		}
+		if participantType == models.RecipientSeller {
+			message.SenderID = conversation.SellerID
+		}
+
+		var body string
+		var images []*multipart.FileHeader
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			if err := c.Request.ParseMultipartForm(maxImageSize * maxMessageAttachments); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, []string{"images too large"})
+				return
+			}
+			body = c.Request.FormValue("body")
+			images = c.Request.MultipartForm.File["images"]
+		} else {
+			var request struct {
+				Body string `json:"body" binding:"required"`
+			}
+			if errs := h.Decode(c, &request); errs != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, errs)
+				return
+			}
+			body = request.Body
+		}
+		body, errs := messageBody(body, len(images))
+		if errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		message.Body = body
+
+		for _, image := range images {
+			uploaded, errs := h.uploadImage(image, "messages", nil)
+			if errs != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, errs)
+				return
+			}
+			if uploaded == nil {
+				response.JSON(c, "", http.StatusInternalServerError, nil, []string{"an error occurred while uploading the image"})
+				return
+			}
+			message.Attachments = append(message.Attachments, models.MessageAttachment{URL: uploaded.Url})
+		}
+
+		if !h.sendMessage(c, message) {
+			return
+		}
+		response.JSON(c, "message sent successfully", http.StatusCreated, message, nil)
+	}
+}
+
+// MarkConversationRead sets the read receipt on the messages the user has been sent in a conversation
+func (h *Handler) MarkConversationRead(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conversation, ok := h.participantConversation(c, participantType)
+		if !ok {
+			return
+		}
+		marked, err := h.DB.MarkConversationRead(conversation, participantType)
+		if err != nil {
+			log.Printf("mark conversation read error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update conversation"})
+			return
+		}
+		response.JSON(c, "conversation marked read successfully", http.StatusOK, gin.H{"marked": marked}, nil)
+	}
+}
+
+type messageReportRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ReportMessage flags a message the user received as abusive, for an admin to review
+func (h *Handler) ReportMessage(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		participantID, ok := h.notificationRecipient(c, participantType)
+		if !ok {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid message id"})
+			return
+		}
+		var request messageReportRequest
+		if errs := h.Decode(c, &request); errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		reason := strings.TrimSpace(request.Reason)
+		if reason == "" || utf8.RuneCountInString(reason) > 500 {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"reason must be between 1 and 500 characters"})
+			return
+		}
+		message, err := h.DB.GetMessage(uint(id))
+		if err != nil || message.Conversation == nil || !message.Conversation.HasParticipant(participantType, participantID) {
+			response.JSON(c, "", http.StatusNotFound, nil, []string{"message not found"})
+			return
+		}
+		if message.SenderType == participantType {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"you can only report messages you received"})
+			return
+		}
+
+		report := &models.MessageReport{
+			MessageID:    message.ID,
+			ReporterType: participantType,
+			ReporterID:   participantID,
+			Reason:       reason,
+		}
+		if err := h.DB.ReportMessage(report); err != nil {
+			log.Printf("report message error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to report message"})
+			return
+		}
+		if report.ID == 0 {
+			response.JSON(c, "message already reported", http.StatusOK, nil, nil)
+			return
+		}
+		response.JSON(c, "message reported successfully", http.StatusCreated, report, nil)
+	}
+}
+
+// GetMessageReports lists message reports for review, ?status=open by default
+func (h *Handler) GetMessageReports(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ReportStatusOpen)
+	if status != models.ReportStatusOpen && status != models.ReportStatusDismissed && status != models.ReportStatusActioned {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"status must be open, dismissed or actioned"})
+		return
+	}
+	reports, err := h.DB.GetMessageReports(status)
+	if err != nil {
+		log.Printf("get message reports error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get message reports"})
+		return
+	}
+	response.JSON(c, "message reports retrieved successfully", http.StatusOK, reports, nil)
+}
+
+type messageReportStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// UpdateMessageReport closes a message report as dismissed or actioned
+func (h *Handler) UpdateMessageReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid report id"})
+		return
+	}
+	var request messageReportStatusRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if request.Status != models.ReportStatusDismissed && request.Status != models.ReportStatusActioned {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"status must be dismissed or actioned"})
+		return
+	}
+	err = h.DB.SetMessageReportStatus(uint(id), request.Status)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"message report not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("update message report error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update message report"})
+		return
+	}
+	response.JSON(c, "message report updated successfully", http.StatusOK, gin.H{"status": request.Status}, nil)
+}
+
+// participantConversation loads the :id conversation, responding with an error unless the user is in it
+func (h *Handler) participantConversation(c *gin.Context, participantType string) (*models.Conversation, bool) {
+	participantID, ok := h.notificationRecipient(c, participantType)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid conversation id"})
+		return nil, false
+	}
+	conversation, err := h.DB.GetConversation(uint(id))
+	if err != nil || !conversation.HasParticipant(participantType, participantID) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"conversation not found"})
+		return nil, false
+	}
+	return conversation, true
+}
+
+// sendMessage saves a message and tells the recipient about it, responding with an error when it can't be saved
+func (h *Handler) sendMessage(c *gin.Context, message *models.Message) bool {
+	if err := h.DB.SendMessage(message); err != nil {
+		log.Printf("send message error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to send message"})
+		return false
+	}
+	conversation := message.Conversation
+	recipientType, recipientID := conversation.Counterpart(message.SenderType)
+	unread := conversation.BuyerUnread
+	if recipientType == models.RecipientSeller {
+		unread = conversation.SellerUnread
+	}
+	// one notification per unread run, the conversation's unread count covers the rest
+	if unread == 1 {
+		h.notify(models.Notification{
+			RecipientType: recipientType,
+			RecipientID:   recipientID,
+			Type:          models.NotificationNewMessage,
+			Title:         fmt.Sprintf("New message from %s", h.participantName(c, message.SenderType)),
+			Body:          conversation.LastMessage,
+			Link:          fmt.Sprintf("%s/%s/messages/%d", services.FrontendURL(), recipientType, conversation.ID),
+		})
+	}
+	return true
+}
+
+// participantName is the logged in buyer's or seller's name as the other side sees it
+func (h *Handler) participantName(c *gin.Context, participantType string) string {
+	var user models.User
+	if participantType == models.RecipientSeller {
+		if seller, err := h.GetUserFromContext(c); err == nil {
+			user = seller.User
+		}
+	} else if buyer, err := h.GetBuyerFromContext(c); err == nil {
+		user = buyer.User
+	}
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	if user.Username != "" {
+		return user.Username
+	}
+	return "a " + participantType
+}
+
+// messageBody trims a message and checks its length. The body can only be empty when there are images.
+func messageBody(body string, images int) (string, []string) {
+	body = strings.TrimSpace(body)
+	if body == "" && images == 0 {
+		return "", []string{"a message needs a body or an image"}
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		return "", []string{fmt.Sprintf("a message can be at most %d characters", maxMessageLength)}
+	}
+	if images > maxMessageAttachments {
+		return "", []string{fmt.Sprintf("a message can have at most %d images", maxMessageAttachments)}
+	}
+	return body, nil
+}
@@ -1,17 +1,13 @@
 package handlers
 
 import (
-	"fmt"
 	"github.com/decadevs/shoparena/models"
 	"github.com/decadevs/shoparena/server/response"
 	"github.com/decadevs/shoparena/services"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 	"log"
 	"net/http"
-	"path/filepath"
 	"strconv"
-	"strings"
 )
 
 func (h *Handler) CreateProducts(c *gin.Context) {
@@ -33,44 +29,21 @@ func (h *Handler) CreateProducts(c *gin.Context) {
 		return
 	}
 
-	formImages := form.File["images"]
-	images := []models.Image{}
-	log.Println(formImages)
-	log.Println(images)
-
-	// upload the images to aws.
-	for _, f := range formImages {
-		file, err := f.Open()
-		if err != nil {
-
-		}
-		fileExtension, ok := services.CheckSupportedFile(strings.ToLower(f.Filename))
-		log.Printf(filepath.Ext(strings.ToLower(f.Filename)))
-		fmt.Println(fileExtension)
-		if ok {
-			log.Println(fileExtension)
-			response.JSON(c, "", http.StatusBadRequest, nil, []string{fileExtension + " image file type is not supported"})
-			return
-		}
-
-		session, tempFileName, err := services.PreAWS(fileExtension, "product")
-		if err != nil {
-			log.Println("could not upload file", err)
-		}
-
-		url, err := h.DB.UploadFileToS3(session, file, tempFileName, f.Size)
-		if err != nil {
-			log.Println(err)
-			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"an error occurred while uploading the image"})
-			return
-		}
-
-		log.Printf("filename: %v", f.Filename)
-
-		img := models.Image{
-			Url: url,
-		}
-		images = append(images, img)
+	CategoryID, err := strconv.Atoi(c.PostForm("category_id"))
+	if err != nil {
+		log.Println(err)
+		response.JSON(c, "", http.StatusBadRequest, "in category_id", []string{err.Error()})
+		return
+	}
+	attributes, errs := h.productAttributes(uint(CategoryID), formAttributes(form))
+	if errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	status, err := productStatusForm(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
 	}
 
 	price, err := strconv.Atoi(c.PostForm("price"))
@@ -90,31 +63,26 @@ func (h *Handler) CreateProducts(c *gin.Context) {
 	quantity, err := strconv.Atoi(c.PostForm("quantity"))
 	if err != nil {
 		log.Println(err)
-
-		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
-		return
-
 		response.JSON(c, "", http.StatusBadRequest, "in quantity", []string{err.Error()})
-
-	}
-	CategoryID, err := strconv.Atoi(c.PostForm("category_id"))
-	if err != nil {
-		log.Println(err)
-
-		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
 		return
-    
-		response.JSON(c, "", http.StatusBadRequest, "in category_id", []string{err.Error()})
+	}
 
+	// put the images in storage
+	images := []models.Image{}
+	if len(form.File["images"]) > 0 {
+		images, errs = h.uploadProductImages(form, 0)
+		if errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		if images == nil {
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"an error occurred while uploading the image"})
+			return
+		}
 	}
 
 	products := models.Product{
-		Category: models.Category{
-			Name: c.PostForm("name"),
-			Model: gorm.Model{
-				ID: uint(CategoryID),
-			},
-		},
+		CategoryId:  uint(CategoryID),
 		Title:       c.PostForm("title"),
 		Description: c.PostForm("description"),
 		Price:       uint(price),
@@ -122,10 +90,16 @@ func (h *Handler) CreateProducts(c *gin.Context) {
 		Images:      images,
 		Rating:      uint(rating),
 		Quantity:    uint(quantity),
+		Status:      status.Status,
+		PublishAt:   status.PublishAt,
+		UnpublishAt: status.UnpublishAt,
 	}
+	products.SetAttributes(attributes)
+	products.HoldForReview(services.ScreenProduct(&products))
 	log.Println(products, CategoryID)
 	err = h.DB.CreateProduct(products)
 	if err != nil {
+		h.discardImages(images)
 		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
 		return
 	}
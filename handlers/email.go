@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+// queueEmail renders an email template and queues it in the outbox.
+// The outbox sender delivers it in the background, so a slow or failing mail provider never holds up a request.
+func (h *Handler) queueEmail(to, template string, data services.EmailData, attachments ...models.EmailAttachment) error {
+	message, err := services.RenderEmail(template, to, data)
+	if err != nil {
+		log.Printf("render %s email error: %v\n", template, err)
+		return err
+	}
+	message.Attachments = attachments
+	if err := h.DB.EnqueueEmail(message); err != nil {
+		log.Printf("enqueue %s email error: %v\n", template, err)
+		return err
+	}
+	return nil
+}
+
+// ListEmailTemplates lists the email templates that can be previewed
+func (h *Handler) ListEmailTemplates(c *gin.Context) {
+	response.JSON(c, "email templates retrieved successfully", http.StatusOK, services.EmailTemplates(), nil)
+}
+
+// PreviewEmail renders an email template with sample data.
+// ?format=text shows the plain-text version instead of the html.
+func (h *Handler) PreviewEmail(c *gin.Context) {
+	name := c.Param("template")
+	message, err := services.RenderEmail(name, "preview@example.com", services.EmailPreviewData(name))
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"email template not found"})
+		return
+	}
+	c.Header("X-Email-Subject", message.Subject)
+	if c.Query("format") == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(message.Text))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(message.HTML))
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"log"
@@ -32,16 +33,13 @@ func (h *Handler) BuyerForgotPasswordEMailHandler(c *gin.Context) {
 	// the link to be clicked in order to perform password reset
 	//link := "http://localhost:8085/api/v1/buyerresetpassword?reset_token=" + *resetToken
 	link := "https://shoparena-frontend.vercel.app/buyer/forgot/" + *resetToken
-	// define the body of the email
-	body := "Here is your reset <a href='" + link + "'>link</a>"
-	html := "<strong>" + body + "</strong>"
+	// queue the email, it is sent in the background
+	err = h.queueEmail(buyer.Email, services.EmailPasswordReset, services.EmailData{
+		Name: buyer.FirstName,
+		Link: link,
+	})
 
-	//intialize the email sendout
-	privateAPIKey := os.Getenv("MAILGUN_API_KEY")
-	yourDomain := os.Getenv("DOMAIN_STRING")
-	err = h.Mail.SendMail("forgot Password", html, buyer.Email, privateAPIKey, yourDomain)
-
-	//if email was sent return 200 status code
+	//if email was queued return 200 status code
 	if err == nil {
 		c.JSON(200, gin.H{"message": "please check your email for password reset link"})
 		c.Abort()
@@ -149,16 +147,13 @@ func (h *Handler) SellerForgotPasswordEMailHandler(c *gin.Context) {
 	// the link to be clicked in order to perform password reset
 	//link := "http://localhost:8085/api/v1/sellerresetpassword?reset_token=" + *resetToken
 	link := "https://shoparena-frontend.vercel.app/seller/forgot/" + *resetToken
-	// define the body of the email
-	body := "Here is your reset <a href='" + link + "'>link</a>"
-	html := "<strong>" + body + "</strong>"
-
-	//intialize the email sendout
-	privateAPIKey := os.Getenv("MAILGUN_API_KEY")
-	yourDomain := os.Getenv("DOMAIN_STRING")
-	err = h.Mail.SendMail("forgot Password", html, seller.Email, privateAPIKey, yourDomain)
+	// queue the email, it is sent in the background
+	err = h.queueEmail(seller.Email, services.EmailPasswordReset, services.EmailData{
+		Name: seller.FirstName,
+		Link: link,
+	})
 
-	//if email was sent return 200 status code
+	//if email was queued return 200 status code
 	if err == nil {
 		c.JSON(200, gin.H{"message": "please check your email for password reset link"})
 		c.Abort()
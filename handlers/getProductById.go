@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/decadevs/shoparena/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,8 +21,20 @@ func (h *Handler) GetProductById(c *gin.Context) {
 		log.Println("Error in getting product", err)
 		return
 	}
+	// drafts, archived products and products under review aren't shown to buyers
+	if !product.IsActive() {
+		c.JSON(http.StatusNotFound, gin.H{"Message": "product not found"})
+		return
+	}
+	// the product page still loads without its questions
+	questions, err := h.DB.GetProductQuestions(product.ID, 1, productQuestionsShown)
+	if err != nil {
+		log.Println("Error in getting product questions", err)
+		questions = &models.ProductQuestionPage{Page: 1, Limit: productQuestionsShown, Questions: []models.ProductQuestion{}}
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"Message": "product gotten by ID",
-		"Product": product,
+		"Message":   "product gotten by ID",
+		"Product":   product,
+		"Questions": questions,
 	})
 }
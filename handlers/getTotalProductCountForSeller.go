@@ -39,13 +39,18 @@ func (h *Handler) GetTotalProductCountForSeller(c *gin.Context) {
 		total_quantity += product[i].Quantity
 	}
 
+	var first_quantity uint
+	if len(product) > 0 {
+		first_quantity = product[0].Quantity
+	}
+
 	//total count based on an individual product
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"Message":                fmt.Sprintf("Seller has %d different categories of product to sell", len(product)),
 		"Seller_ID":              sellerID,
 		"Product_Count":          len(product),
 		"Product_Quantity":       total_quantity,
-		"First_Product_Quantity": product[0].Quantity,
+		"First_Product_Quantity": first_quantity,
 	})
 
 }
@@ -32,6 +32,15 @@ func (h *Handler) HandleGetSellerShopByProfileAndProduct() gin.HandlerFunc {
 			})
 			return
 		}
+		// buyers only see the shop's active products
+		active := make([]models.Product, 0, len(Seller.Product))
+		for _, product := range Seller.Product {
+			if product.IsActive() {
+				active = append(active, product)
+			}
+		}
+		Seller.Product = active
+
 		var Shop []models.Seller
 		Shop = append(Shop, *Seller)
 
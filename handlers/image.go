@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+)
+
+// maxImageSize is the largest image, in bytes, that can be uploaded as a picture or attachment
+const maxImageSize = int64(2048000)
+
+// uploadImage checks an image by its content, strips its metadata and puts it in a folder in storage
+// along with a variant for each of sizes. The errors are the user's fault; no image without them means
+// the upload failed.
+func (h *Handler) uploadImage(image *multipart.FileHeader, folder string, sizes []services.ImageSize) (*models.Image, []string) {
+	if image.Size > maxImageSize {
+		return nil, []string{"image too large"}
+	}
+	file, err := image.Open()
+	if err != nil {
+		log.Printf("open image error: %v\n", err)
+		return nil, nil
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize))
+	if err != nil {
+		log.Printf("read image error: %v\n", err)
+		return nil, nil
+	}
+	return h.storeImage(data, image.Filename, folder, sizes)
+}
+
+// storeImage does the checking, processing and storing for uploadImage on the image called name
+func (h *Handler) storeImage(data []byte, name, folder string, sizes []services.ImageSize) (*models.Image, []string) {
+	processed, err := services.ProcessImage(data, sizes)
+	if errors.Is(err, services.ErrUnsupportedImage) || errors.Is(err, services.ErrImageDimensions) {
+		return nil, []string{name + ": " + err.Error()}
+	}
+	if err != nil {
+		log.Printf("process image error: %v\n", err)
+		return nil, nil
+	}
+	url, variants, err := services.StoreImage(h.Storage, folder, processed)
+	if err != nil {
+		log.Printf("upload image error: %v\n", err)
+		return nil, nil
+	}
+	return &models.Image{Url: url, Width: processed.Width, Height: processed.Height, Variants: variants}, nil
+}
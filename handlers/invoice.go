@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+// BuyerInvoices lists the invoices for the buyer's orders
+func (h *Handler) BuyerInvoices(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	invoices, err := h.DB.GetBuyerInvoices(buyer.ID)
+	if err != nil {
+		log.Printf("get buyer invoices error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get invoices"})
+		return
+	}
+	response.JSON(c, "invoices retrieved successfully", http.StatusOK, invoices, nil)
+}
+
+// SellerInvoices lists the invoices the seller has issued
+func (h *Handler) SellerInvoices(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	invoices, err := h.DB.GetSellerInvoices(seller.ID)
+	if err != nil {
+		log.Printf("get seller invoices error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get invoices"})
+		return
+	}
+	response.JSON(c, "invoices retrieved successfully", http.StatusOK, invoices, nil)
+}
+
+// BuyerInvoicePDF downloads one of the buyer's invoices as a PDF
+func (h *Handler) BuyerInvoicePDF(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	h.serveInvoicePDF(c, func(invoice *models.Invoice) bool { return invoice.BuyerID == buyer.ID })
+}
+
+// SellerInvoicePDF downloads one of the seller's invoices as a PDF
+func (h *Handler) SellerInvoicePDF(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	h.serveInvoicePDF(c, func(invoice *models.Invoice) bool { return invoice.SellerID == seller.ID })
+}
+
+// serveInvoicePDF writes the invoice in the :id param when owns accepts it.
+// Invoices belonging to someone else are reported as not found.
+func (h *Handler) serveInvoicePDF(c *gin.Context, owns func(*models.Invoice) bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid invoice id"})
+		return
+	}
+	invoice, err := h.DB.GetInvoice(uint(id))
+	if err != nil || !owns(invoice) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"invoice not found"})
+		return
+	}
+	pdf, err := services.RenderInvoicePDF(invoice)
+	if err != nil {
+		log.Printf("render invoice error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to generate invoice"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoice.FileName()))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// emailInvoices queues a payment confirmation for the buyer for each invoice with the PDF attached,
+// and alerts the seller to the new order
+func (h *Handler) emailInvoices(invoices []models.Invoice) {
+	for _, raised := range invoices {
+		invoice, err := h.DB.GetInvoice(raised.ID)
+		if err != nil {
+			log.Printf("get invoice error: %v\n", err)
+			continue
+		}
+		order := services.InvoiceEmail(invoice)
+
+		_ = h.queueEmail(invoice.Seller.Email, services.EmailNewOrder, services.EmailData{
+			Name:  invoice.Seller.FirstName,
+			Link:  services.FrontendURL() + "/seller/orders",
+			Order: order,
+		})
+
+		pdf, err := services.RenderInvoicePDF(invoice)
+		if err != nil {
+			log.Printf("render invoice error: %v\n", err)
+			continue
+		}
+		err = h.queueEmail(invoice.Buyer.Email, services.EmailPaymentSuccess, services.EmailData{
+			Name:  invoice.Buyer.FirstName,
+			Link:  services.FrontendURL() + "/buyer/orders",
+			Order: order,
+		}, models.EmailAttachment{
+			Filename:    invoice.FileName(),
+			ContentType: "application/pdf",
+			Data:        pdf,
+		})
+		if err != nil {
+			continue
+		}
+		if err := h.DB.MarkInvoiceEmailed(invoice.ID); err != nil {
+			log.Printf("mark invoice emailed error: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetJobs lists the latest background jobs, ?status=failed shows only those that gave up
+func (h *Handler) GetJobs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"limit must be between 1 and 500"})
+		return
+	}
+	jobs, err := h.DB.GetJobs(c.Query("status"), limit)
+	if err != nil {
+		log.Printf("get jobs error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get jobs"})
+		return
+	}
+	response.JSON(c, "jobs retrieved successfully", http.StatusOK, jobs, nil)
+}
+
+// RetryJob queues a failed job to run again
+func (h *Handler) RetryJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid job id"})
+		return
+	}
+	err = h.DB.RetryJob(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"failed job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("retry job error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to retry job"})
+		return
+	}
+	response.JSON(c, "job queued to retry", http.StatusOK, nil, nil)
+}
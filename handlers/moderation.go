@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type listingReportRequest struct {
+	Reason  string `json:"reason" binding:"required"`
+	Details string `json:"details"`
+}
+
+// ReportListing lets a buyer report a listing, putting it in the moderation queue
+func (h *Handler) ReportListing(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid product id"})
+		return
+	}
+	var request listingReportRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	report := &models.ListingReport{
+		BuyerID: buyer.ID,
+		Reason:  strings.ToLower(strings.TrimSpace(request.Reason)),
+		Details: strings.TrimSpace(request.Details),
+	}
+	if err := report.Check(); err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(report.Details) > 1000 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"details can be at most 1000 characters"})
+		return
+	}
+	product, err := h.DB.GetProductByID(uint(id))
+	if err != nil || !product.IsActive() {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"product not found"})
+		return
+	}
+
+	report.ProductID = product.ID
+	if err := h.DB.ReportListing(report); err != nil {
+		log.Printf("report listing error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to report listing"})
+		return
+	}
+	if report.ID == 0 {
+		response.JSON(c, "listing already reported", http.StatusOK, nil, nil)
+		return
+	}
+	response.JSON(c, "listing reported successfully", http.StatusCreated, report, nil)
+}
+
+// GetListingReviews lists the moderation queue, ?status=pending by default. Flagged and
+// reported listings come first.
+func (h *Handler) GetListingReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ModerationPending)
+	if status != models.ModerationPending && status != models.ModerationApproved && status != models.ModerationRejected {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"status must be pending, approved or rejected"})
+		return
+	}
+	reviews, err := h.DB.GetListingReviews(status)
+	if err != nil {
+		log.Printf("get listing reviews error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get listing reviews"})
+		return
+	}
+	response.JSON(c, "listing reviews retrieved successfully", http.StatusOK, reviews, nil)
+}
+
+type listingDecisionRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// DecideListingReview approves or rejects a listing in the moderation queue and emails the seller.
+// A rejection needs a reason, which the seller is told.
+func (h *Handler) DecideListingReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid review id"})
+		return
+	}
+	var request listingDecisionRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if request.Status != models.ModerationApproved && request.Status != models.ModerationRejected {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"status must be approved or rejected"})
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if request.Status == models.ModerationRejected && reason == "" {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"a rejection needs a reason"})
+		return
+	}
+	if utf8.RuneCountInString(reason) > 1000 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"reason can be at most 1000 characters"})
+		return
+	}
+
+	review, err := h.DB.DecideListingReview(uint(id), request.Status, reason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"listing review not found"})
+		return
+	}
+	if errors.Is(err, database.ErrReviewDecided) {
+		response.JSON(c, "", http.StatusConflict, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("decide listing review error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to decide listing review"})
+		return
+	}
+	// the decision stands even if the seller can't be emailed
+	if review.Product != nil {
+		h.emailListingDecision(review)
+	}
+	response.JSON(c, "listing review decided successfully", http.StatusOK, review, nil)
+}
+
+// emailListingDecision tells the seller what was decided about their listing
+func (h *Handler) emailListingDecision(review *models.ListingReview) {
+	seller, err := h.DB.FindSellerById(review.SellerID)
+	if err != nil {
+		log.Printf("find seller %d error: %v\n", review.SellerID, err)
+		return
+	}
+	template := services.EmailListingApproved
+	if review.Status == models.ModerationRejected {
+		template = services.EmailListingRejected
+	}
+	_ = h.queueEmail(seller.Email, template, services.EmailData{
+		Name:    seller.FirstName,
+		Link:    services.FrontendURL() + "/seller/products",
+		Note:    review.Reason,
+		Listing: review.Product.Title,
+	})
+}
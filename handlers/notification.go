@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListNotifications lists the user's notifications, newest first, ?page=1&limit=20.
+// ?unread=true shows only the unread ones.
+func (h *Handler) ListNotifications(recipientType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recipientID, ok := h.notificationRecipient(c, recipientType)
+		if !ok {
+			return
+		}
+		page, limit, ok := pageParams(c, 20)
+		if !ok {
+			return
+		}
+		notifications, err := h.DB.GetNotifications(recipientType, recipientID, c.Query("unread") == "true", page, limit)
+		if err != nil {
+			log.Printf("get notifications error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get notifications"})
+			return
+		}
+		response.JSON(c, "notifications retrieved successfully", http.StatusOK, notifications, nil)
+	}
+}
+
+// CountNotifications counts the user's unread notifications, in all and by type,
+// so a seller's new order badge is new_order
+func (h *Handler) CountNotifications(recipientType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recipientID, ok := h.notificationRecipient(c, recipientType)
+		if !ok {
+			return
+		}
+		counts, err := h.DB.CountUnreadNotifications(recipientType, recipientID)
+		if err != nil {
+			log.Printf("count notifications error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to count notifications"})
+			return
+		}
+		var unread int64
+		for _, count := range counts {
+			unread += count
+		}
+		response.JSON(c, "unread notifications counted successfully", http.StatusOK, gin.H{
+			"unread":  unread,
+			"by_type": counts,
+		}, nil)
+	}
+}
+
+type notificationReadRequest struct {
+	Read bool `json:"read"`
+}
+
+// MarkNotification marks one of the user's notifications read or unread
+func (h *Handler) MarkNotification(recipientType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recipientID, ok := h.notificationRecipient(c, recipientType)
+		if !ok {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid notification id"})
+			return
+		}
+		var request notificationReadRequest
+		if errs := h.Decode(c, &request); errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		err = h.DB.SetNotificationRead(uint(id), recipientType, recipientID, request.Read)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.JSON(c, "", http.StatusNotFound, nil, []string{"notification not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("mark notification error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update notification"})
+			return
+		}
+		response.JSON(c, "notification updated successfully", http.StatusOK, gin.H{"read": request.Read}, nil)
+	}
+}
+
+// MarkAllNotificationsRead marks every one of the user's notifications read
+func (h *Handler) MarkAllNotificationsRead(recipientType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recipientID, ok := h.notificationRecipient(c, recipientType)
+		if !ok {
+			return
+		}
+		marked, err := h.DB.MarkAllNotificationsRead(recipientType, recipientID)
+		if err != nil {
+			log.Printf("mark all notifications error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update notifications"})
+			return
+		}
+		response.JSON(c, "notifications marked read successfully", http.StatusOK, gin.H{"marked": marked}, nil)
+	}
+}
+
+// notificationRecipient is the id of the logged in buyer or seller, responding with an error when there is none
+func (h *Handler) notificationRecipient(c *gin.Context, recipientType string) (uint, bool) {
+	if recipientType == models.RecipientSeller {
+		seller, err := h.GetUserFromContext(c)
+		if err == nil {
+			return seller.ID, true
+		}
+	} else {
+		buyer, err := h.GetBuyerFromContext(c)
+		if err == nil {
+			return buyer.ID, true
+		}
+	}
+	response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+	return 0, false
+}
+
+// notify adds a notification to a user's inbox. It is best effort: the action the user
+// took has already succeeded, so a failure is only logged.
+func (h *Handler) notify(notification models.Notification) {
+	if err := h.DB.CreateNotifications([]models.Notification{notification}); err != nil {
+		log.Printf("create notification error: %v\n", err)
+	}
+}
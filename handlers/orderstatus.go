@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+type orderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
+
+// UpdateOrderStatus lets a seller mark one of their orders as shipped, delivered, cancelled or refunded.
+// The buyer is emailed about the change.
+func (h *Handler) UpdateOrderStatus(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid order id"})
+		return
+	}
+	var request orderStatusRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	order, err := h.DB.GetOrder(uint(id))
+	if err != nil || order.SellerId != seller.ID {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"order not found"})
+		return
+	}
+	if !order.CanMoveTo(request.Status) {
+		response.JSON(c, "", http.StatusBadRequest, nil,
+			[]string{fmt.Sprintf("order cannot be moved from %s to %s", order.Status, request.Status)})
+		return
+	}
+
+	err = h.DB.UpdateOrderStatus(order.ID, order.Status, request.Status, request.Note)
+	if errors.Is(err, database.ErrOrderStatusChanged) {
+		response.JSON(c, "", http.StatusConflict, nil, []string{"order status has changed, please refresh"})
+		return
+	}
+	if err != nil {
+		log.Printf("update order status error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update order status"})
+		return
+	}
+	order.Status = request.Status
+	order.StatusNote = request.Note
+
+	if template, ok := services.OrderStatusEmail(order.Status); ok {
+		_ = h.queueEmail(order.Buyer.Email, template, services.EmailData{
+			Name:  order.Buyer.FirstName,
+			Link:  services.FrontendURL() + "/buyer/orders",
+			Note:  order.StatusNote,
+			Order: services.OrderLineEmail(order),
+		})
+	}
+
+	response.JSON(c, "order status updated successfully", http.StatusOK, order, nil)
+}
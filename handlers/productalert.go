@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type productAlertRequest struct {
+	ProductID   uint `json:"product_id" binding:"required"`
+	BackInStock bool `json:"back_in_stock"`
+	PriceBelow  uint `json:"price_below"`
+}
+
+// CreateProductAlert lets a buyer watch a product for it coming back in stock or dropping below a price.
+// Watching a product again replaces what the buyer was watching it for.
+func (h *Handler) CreateProductAlert(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request productAlertRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if !request.BackInStock && request.PriceBelow == 0 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"choose back_in_stock, price_below or both"})
+		return
+	}
+	product, err := h.DB.GetProductByID(request.ProductID)
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"product not found"})
+		return
+	}
+	if request.PriceBelow > 0 && product.Price < request.PriceBelow {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"the product is already below that price"})
+		return
+	}
+
+	alert := &models.ProductAlert{
+		BuyerID:     buyer.ID,
+		ProductID:   product.ID,
+		BackInStock: request.BackInStock,
+		PriceBelow:  request.PriceBelow,
+	}
+	if err := h.DB.SaveProductAlert(alert); err != nil {
+		log.Printf("save product alert error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to save alert"})
+		return
+	}
+	alert.Product = *product
+	response.JSON(c, "alert saved successfully", http.StatusCreated, alert, nil)
+}
+
+// GetProductAlerts lists the products the buyer is watching
+func (h *Handler) GetProductAlerts(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	alerts, err := h.DB.GetBuyerProductAlerts(buyer.ID)
+	if err != nil {
+		log.Printf("get product alerts error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get alerts"})
+		return
+	}
+	response.JSON(c, "alerts retrieved successfully", http.StatusOK, alerts, nil)
+}
+
+// DeleteProductAlert stops the buyer watching a product
+func (h *Handler) DeleteProductAlert(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid alert id"})
+		return
+	}
+	err = h.DB.DeleteProductAlert(uint(id), buyer.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"alert not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("delete product alert error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to delete alert"})
+		return
+	}
+	response.JSON(c, "alert deleted successfully", http.StatusOK, nil, nil)
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxAltTextLength caps the text describing a picture
+const maxAltTextLength = 250
+
+// UploadProductImages adds pictures, sent as a multipart form under "images", after the ones a seller's
+// product already has. Each "alt_text" value describes the picture in the same place.
+func (h *Handler) UploadProductImages(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"images too large"})
+		return
+	}
+	images, errs := h.uploadProductImages(form, len(product.Images))
+	h.addProductImages(c, product, images, errs)
+}
+
+// ConfirmProductImages adds pictures uploaded with presigned urls after the ones a seller's product already
+// has, e.g. {"keys":["uploads/seller/7/<uuid>.png"],"alt_text":["Kettle from the side"]}
+func (h *Handler) ConfirmProductImages(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Keys    []string `json:"keys" binding:"required"`
+		AltText []string `json:"alt_text"`
+	}
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	altTexts, errs := productImageAltTexts(len(request.Keys), len(product.Images), request.AltText)
+	if errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	images := make([]models.Image, 0, len(request.Keys))
+	for i, key := range request.Keys {
+		image, errs := h.confirmUpload(key, models.RecipientSeller, product.SellerId, "product", services.ImageSizes)
+		if errs != nil || image == nil {
+			h.discardImages(images)
+			h.addProductImages(c, product, nil, errs)
+			return
+		}
+		image.AltText, image.Position = altTexts[i], len(product.Images)+i
+		images = append(images, *image)
+	}
+	h.addProductImages(c, product, images, nil)
+}
+
+// addProductImages saves uploaded pictures after the product's existing ones and responds with them.
+// No images means the upload failed, for the reasons in errs when they are the user's fault.
+func (h *Handler) addProductImages(c *gin.Context, product *models.Product, images []models.Image, errs []string) {
+	if errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if images == nil {
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to upload image"})
+		return
+	}
+
+	err := h.DB.AddProductImages(product.ID, images)
+	if err != nil {
+		h.discardImages(images)
+	}
+	if errors.Is(err, database.ErrTooManyImages) {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("add product images error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to save images"})
+		return
+	}
+	response.JSON(c, "images uploaded successfully", http.StatusCreated, images, nil)
+}
+
+// UpdateProductImage changes the alt text of one of a seller's product's pictures
+func (h *Handler) UpdateProductImage(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"image not found"})
+		return
+	}
+	var request struct {
+		AltText string `json:"alt_text"`
+	}
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	altText := strings.TrimSpace(request.AltText)
+	if utf8.RuneCountInString(altText) > maxAltTextLength {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{fmt.Sprintf("alt_text can be at most %d characters", maxAltTextLength)})
+		return
+	}
+
+	image, err := h.DB.UpdateProductImageAltText(product.ID, uint(imageID), altText)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"image not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("update product image error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update image"})
+		return
+	}
+	response.JSON(c, "image updated successfully", http.StatusOK, image, nil)
+}
+
+// ReorderProductImages puts a seller's product's pictures in the order of image_ids,
+// e.g. {"image_ids":[3,1,2]}. The first becomes the primary image.
+func (h *Handler) ReorderProductImages(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		ImageIDs []uint `json:"image_ids" binding:"required"`
+	}
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	h.reorderProductImages(c, product, request.ImageIDs)
+}
+
+// SetPrimaryProductImage makes one of a seller's product's pictures the first one buyers see
+func (h *Handler) SetPrimaryProductImage(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"image not found"})
+		return
+	}
+	order := []uint{uint(imageID)}
+	for _, image := range product.Images {
+		if image.ID != uint(imageID) {
+			order = append(order, image.ID)
+		}
+	}
+	if len(order) != len(product.Images) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"image not found"})
+		return
+	}
+	h.reorderProductImages(c, product, order)
+}
+
+// reorderProductImages saves a new order for the product's pictures and responds with them in it
+func (h *Handler) reorderProductImages(c *gin.Context, product *models.Product, imageIDs []uint) {
+	err := h.DB.ReorderProductImages(product.ID, imageIDs)
+	if errors.Is(err, database.ErrImageOrder) {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("reorder product images error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to reorder images"})
+		return
+	}
+	for position, id := range imageIDs {
+		for i := range product.Images {
+			if product.Images[i].ID == id {
+				product.Images[i].Position = position
+			}
+		}
+	}
+	product.SortImages()
+	response.JSON(c, "images reordered successfully", http.StatusOK, product.Images, nil)
+}
+
+// DeleteProductImage removes one of a seller's product's pictures, deleting the file from storage
+func (h *Handler) DeleteProductImage(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"image not found"})
+		return
+	}
+	err = h.DB.DeleteProductImage(product.ID, uint(imageID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"image not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("delete product image error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to delete image"})
+		return
+	}
+	response.JSON(c, "image deleted successfully", http.StatusOK, nil, nil)
+}
+
+// uploadProductImages uploads the pictures in a product form, which can take a product that has
+// existing pictures up to models.MaxProductImages, with their alt text. The errors are the user's
+// fault; no images without them means an upload failed.
+func (h *Handler) uploadProductImages(form *multipart.Form, existing int) ([]models.Image, []string) {
+	files := form.File["images"]
+	altTexts, errs := productImageAltTexts(len(files), existing, form.Value["alt_text"])
+	if errs != nil {
+		return nil, errs
+	}
+
+	images := make([]models.Image, 0, len(files))
+	for i, file := range files {
+		image, errs := h.uploadImage(file, "product", services.ImageSizes)
+		if errs != nil || image == nil {
+			h.discardImages(images)
+			return nil, errs
+		}
+		image.AltText, image.Position = altTexts[i], existing+i
+		images = append(images, *image)
+	}
+	return images, nil
+}
+
+// productImageAltTexts checks that count pictures can be added to a product with existing ones and
+// returns the alt text for each, from the values given in order
+func productImageAltTexts(count, existing int, values []string) ([]string, []string) {
+	if count == 0 {
+		return nil, []string{"upload at least one image"}
+	}
+	if existing+count > models.MaxProductImages {
+		return nil, []string{database.ErrTooManyImages.Error()}
+	}
+	altTexts := make([]string, count)
+	for i, altText := range values {
+		if i == count {
+			break
+		}
+		altTexts[i] = strings.TrimSpace(altText)
+		if utf8.RuneCountInString(altTexts[i]) > maxAltTextLength {
+			return nil, []string{fmt.Sprintf("alt_text can be at most %d characters", maxAltTextLength)}
+		}
+	}
+	return altTexts, nil
+}
+
+// discardImages has pictures that were uploaded but not saved deleted from storage
+func (h *Handler) discardImages(images []models.Image) {
+	if len(images) == 0 {
+		return
+	}
+	var urls []string
+	for _, image := range images {
+		urls = append(append(urls, image.Url), image.Variants.URLs()...)
+	}
+	if err := jobs.Enqueue(h.DB, jobs.TypeImagesDelete, jobs.ImagesDeletePayload{URLs: urls}); err != nil {
+		log.Printf("enqueue images delete error: %v\n", err)
+	}
+}
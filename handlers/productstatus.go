@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateProductStatus publishes, unpublishes or archives one of the seller's products, or schedules
+// a draft to go live and an active product to come down
+func (h *Handler) UpdateProductStatus(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	var request models.ProductStatusRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if err := request.Check(time.Now()); err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	status, err := h.DB.UpdateProductStatus(product.ID, request.Status, request.PublishAt, request.UnpublishAt)
+	if errors.Is(err, database.ErrListingRejected) {
+		response.JSON(c, "", http.StatusConflict, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("update product status error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update product status"})
+		return
+	}
+	product.Status, product.PublishAt, product.UnpublishAt = status, request.PublishAt, request.UnpublishAt
+	response.JSON(c, "product status updated successfully", http.StatusOK, product, nil)
+}
+
+// productStatusForm reads a new product's status, publish_at and unpublish_at form fields
+func productStatusForm(c *gin.Context) (*models.ProductStatusRequest, error) {
+	request := &models.ProductStatusRequest{Status: c.PostForm("status")}
+	for field, at := range map[string]**time.Time{"publish_at": &request.PublishAt, "unpublish_at": &request.UnpublishAt} {
+		value := c.PostForm(field)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a time like 2006-01-02T15:04:05Z", field)
+		}
+		*at = &t
+	}
+	return request, request.Check(time.Now())
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Longest question and answer, in characters
+const (
+	maxQuestionLength = 1000
+	maxAnswerLength   = 2000
+)
+
+// productQuestionsShown is how many questions come with a product
+const productQuestionsShown = 5
+
+type questionRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// AskProductQuestion posts a buyer's question on a product and lets the seller know
+func (h *Handler) AskProductQuestion(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid product id"})
+		return
+	}
+	body, errs := h.decodeQABody(c, maxQuestionLength)
+	if errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	product, err := h.DB.GetProductByID(uint(productID))
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"product not found"})
+		return
+	}
+
+	question := &models.ProductQuestion{
+		ProductID: product.ID,
+		BuyerID:   buyer.ID,
+		AskerName: h.participantName(c, models.RecipientBuyer),
+		Body:      body,
+		Answers:   []models.ProductAnswer{},
+	}
+	if err := h.DB.CreateProductQuestion(question); err != nil {
+		log.Printf("create product question error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to post question"})
+		return
+	}
+	h.notify(models.Notification{
+		RecipientType: models.RecipientSeller,
+		RecipientID:   product.SellerId,
+		Type:          models.NotificationQuestion,
+		Title:         fmt.Sprintf("New question on %s", product.Title),
+		Body:          question.Body,
+		Link:          services.FrontendURL() + "/seller/questions",
+	})
+	response.JSON(c, "question posted successfully", http.StatusCreated, question, nil)
+}
+
+// AnswerProductQuestion answers a question. Only the product's seller and buyers who bought it can answer.
+func (h *Handler) AnswerProductQuestion(answererType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		answererID, ok := h.notificationRecipient(c, answererType)
+		if !ok {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid question id"})
+			return
+		}
+		body, errs := h.decodeQABody(c, maxAnswerLength)
+		if errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		question, err := h.DB.GetProductQuestion(uint(id))
+		if err != nil || question.Product == nil {
+			response.JSON(c, "", http.StatusNotFound, nil, []string{"question not found"})
+			return
+		}
+
+		allowed := answererType == models.RecipientSeller && question.Product.SellerId == answererID
+		if answererType == models.RecipientBuyer {
+			allowed, err = h.DB.HasBoughtProduct(answererID, question.ProductID)
+			if err != nil {
+				log.Printf("check bought product error: %v\n", err)
+				response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to post answer"})
+				return
+			}
+		}
+		if !allowed {
+			response.JSON(c, "", http.StatusForbidden, nil, []string{"only the seller and buyers of this product can answer"})
+			return
+		}
+
+		answer := &models.ProductAnswer{
+			QuestionID:   question.ID,
+			AnswererType: answererType,
+			AnswererID:   answererID,
+			AnswererName: h.participantName(c, answererType),
+			Body:         body,
+		}
+		if err := h.DB.CreateProductAnswer(answer); err != nil {
+			log.Printf("create product answer error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to post answer"})
+			return
+		}
+		if answererType != models.RecipientBuyer || answererID != question.BuyerID {
+			h.notify(models.Notification{
+				RecipientType: models.RecipientBuyer,
+				RecipientID:   question.BuyerID,
+				Type:          models.NotificationAnswer,
+				Title:         fmt.Sprintf("Your question on %s has a new answer", question.Product.Title),
+				Body:          answer.Body,
+				Link:          fmt.Sprintf("%s/product/%d", services.FrontendURL(), question.ProductID),
+			})
+		}
+		response.JSON(c, "answer posted successfully", http.StatusCreated, answer, nil)
+	}
+}
+
+// UpvoteAnswer adds the buyer's upvote to an answer, or takes it back on DELETE
+func (h *Handler) UpvoteAnswer(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid answer id"})
+		return
+	}
+	upvote := c.Request.Method != http.MethodDelete
+	upvotes, err := h.DB.SetAnswerUpvote(uint(id), buyer.ID, upvote)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"answer not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("upvote answer error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update upvote"})
+		return
+	}
+	response.JSON(c, "upvote updated successfully", http.StatusOK, gin.H{"upvoted": upvote, "upvotes": upvotes}, nil)
+}
+
+// GetProductQuestions lists a product's questions and answers, newest question first, ?page=1&limit=10
+func (h *Handler) GetProductQuestions(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid product id"})
+		return
+	}
+	page, limit, ok := pageParams(c, 10)
+	if !ok {
+		return
+	}
+	questions, err := h.DB.GetProductQuestions(uint(productID), page, limit)
+	if err != nil {
+		log.Printf("get product questions error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get questions"})
+		return
+	}
+	response.JSON(c, "questions retrieved successfully", http.StatusOK, questions, nil)
+}
+
+// GetUnansweredQuestions lists the questions across the seller's catalogue they haven't answered, oldest first
+func (h *Handler) GetUnansweredQuestions(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	page, limit, ok := pageParams(c, 20)
+	if !ok {
+		return
+	}
+	questions, err := h.DB.GetUnansweredQuestions(seller.ID, page, limit)
+	if err != nil {
+		log.Printf("get unanswered questions error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get questions"})
+		return
+	}
+	response.JSON(c, "unanswered questions retrieved successfully", http.StatusOK, questions, nil)
+}
+
+// pageParams reads ?page= and ?limit=, responding with an error when either is out of range
+func pageParams(c *gin.Context, defaultLimit int) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"page must be a positive number"})
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > 100 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"limit must be between 1 and 100"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+// decodeQABody decodes and trims a question or answer
+func (h *Handler) decodeQABody(c *gin.Context, maxLength int) (string, []string) {
+	var request questionRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		return "", errs
+	}
+	body := strings.TrimSpace(request.Body)
+	if body == "" || utf8.RuneCountInString(body) > maxLength {
+		return "", []string{fmt.Sprintf("body must be between 1 and %d characters", maxLength)}
+	}
+	return body, nil
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"fmt"
 	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
 	"github.com/gin-gonic/gin"
 	"log"
 )
@@ -45,6 +47,14 @@ func (h *Handler) SellerRating(c *gin.Context) {
 		return
 	}
 	log.Println(seller.Rating)
+	h.notify(models.Notification{
+		RecipientType: models.RecipientSeller,
+		RecipientID:   seller.ID,
+		Type:          models.NotificationReview,
+		Title:         fmt.Sprintf("A buyer rated you %d out of 5", rating.Rating),
+		Body:          fmt.Sprintf("Your shop rating is now %d from %d ratings", seller.Rating, seller.NumberOfRatingsReceived),
+		Link:          services.FrontendURL() + "/seller/profile",
+	})
 	c.JSON(200, gin.H{"message": "thank you for your feedback"})
 }
 func (h *Handler) ProductRating(c *gin.Context) {
@@ -86,5 +96,13 @@ func (h *Handler) ProductRating(c *gin.Context) {
 		return
 	}
 	log.Println(product.Rating)
+	h.notify(models.Notification{
+		RecipientType: models.RecipientSeller,
+		RecipientID:   product.SellerId,
+		Type:          models.NotificationReview,
+		Title:         fmt.Sprintf("%s was rated %d out of 5", product.Title, ratingRequest.Rating),
+		Body:          fmt.Sprintf("Its rating is now %d from %d ratings", product.Rating, product.NumberOfRatingsReceived),
+		Link:          services.FrontendURL() + "/seller/allproducts",
+	})
 	c.JSON(200, gin.H{"message": "thank you for your feedback"})
 }
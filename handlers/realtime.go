@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/decadevs/shoparena/realtime"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamHeartbeat is how often an idle event stream gets a comment line, so proxies keep it open
+var StreamHeartbeat = 25 * time.Second
+
+// StreamEvents holds a server-sent events stream open and pushes the user's new orders, payment
+// confirmations, order status changes and notifications to it as they happen
+func (h *Handler) StreamEvents(recipientType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recipientID, ok := h.notificationRecipient(c, recipientType)
+		if !ok {
+			return
+		}
+		if h.Hub == nil {
+			response.JSON(c, "", http.StatusServiceUnavailable, nil, []string{"live updates are unavailable"})
+			return
+		}
+		client, err := h.Hub.Subscribe(recipientType, recipientID)
+		if errors.Is(err, realtime.ErrTooManyStreams) {
+			response.JSON(c, "", http.StatusTooManyRequests, nil, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			response.JSON(c, "", http.StatusServiceUnavailable, nil, []string{"live updates are unavailable"})
+			return
+		}
+		defer h.Hub.Unsubscribe(client)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		_ = sse.Encode(c.Writer, sse.Event{Event: "ready", Data: gin.H{"recipient_type": recipientType}})
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(StreamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case msg, open := <-client.Messages():
+				if !open {
+					return
+				}
+				if err := sse.Encode(c.Writer, sse.Event{Event: msg.Event, Data: msg.Data}); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newReferralCode generates an invite code that no other buyer owns yet
+func (h *Handler) newReferralCode() (string, error) {
+	for i := 0; i < 5; i++ {
+		code, err := services.GenerateReferralCode()
+		if err != nil {
+			return "", err
+		}
+		_, err = h.DB.FindBuyerByReferralCode(code)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return code, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("could not generate a unique referral code")
+}
+
+// createBuyer saves a new buyer with a referral code of their own. Another signup can take a code
+// between checking it is free and the insert, so the insert is retried with a new code when that happens.
+func (h *Handler) createBuyer(buyer *models.Buyer) error {
+	var err error
+	for i := 0; i < 3; i++ {
+		buyer.ReferralCode, err = h.newReferralCode()
+		if err != nil {
+			return err
+		}
+		_, err = h.DB.CreateBuyer(buyer)
+		if !errors.Is(err, database.ErrReferralCodeTaken) {
+			return err
+		}
+	}
+	return err
+}
+
+// recordReferral links a new buyer to the buyer whose code they signed up with.
+// Suspicious referrals are kept as rejected so they never pay out.
+func (h *Handler) recordReferral(referrer, referee *models.Buyer) {
+	referral := &models.Referral{
+		ReferrerID:      referrer.ID,
+		RefereeID:       referee.ID,
+		RefereeUsername: referee.Username,
+		Code:            referrer.ReferralCode,
+		Status:          models.ReferralStatusPending,
+	}
+	if err := services.CheckReferral(referrer, referee); err != nil {
+		referral.Status = models.ReferralStatusRejected
+		referral.RejectReason = err.Error()
+	}
+	if _, err := h.DB.CreateReferral(referral); err != nil {
+		log.Printf("create referral error: %v\n", err)
+	}
+}
+
+// GetBuyerReferrals returns the buyer's invite code, the buyers they referred and what they earned
+func (h *Handler) GetBuyerReferrals(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+
+	referrals, err := h.DB.GetBuyerReferrals(buyer.ID)
+	if err != nil {
+		log.Printf("get referrals error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get referrals"})
+		return
+	}
+
+	var successful int
+	var earnings uint
+	for _, referral := range referrals {
+		if referral.Status == models.ReferralStatusRewarded {
+			successful++
+			earnings += referral.RewardAmount
+		}
+	}
+
+	response.JSON(c, "referrals retrieved successfully", http.StatusOK, gin.H{
+		"referral_code":        buyer.ReferralCode,
+		"wallet_balance":       buyer.WalletBalance,
+		"referrals":            referrals,
+		"total_referrals":      len(referrals),
+		"successful_referrals": successful,
+		"total_earnings":       earnings,
+	}, nil)
+}
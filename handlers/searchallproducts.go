@@ -1,18 +1,40 @@
 package handlers
 
 import (
+	"github.com/decadevs/shoparena/models"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"strings"
 )
 
+// SearchProductHandler searches products. Besides category, the price range and name, attributes filter
+// with attr.<key>=<value>, e.g. attr.brand=samsung,apple for either brand.
 func (h *Handler) SearchProductHandler(c *gin.Context) {
 	categoryName := c.Query("category")
 	lowerPrice := c.Query("lower-price")
 	upperPrice := c.Query("upper-price")
 	name := c.Query("name")
 
-	product, err := h.DB.SearchProduct(lowerPrice, upperPrice, categoryName, name)
+	var attributes map[string][]string
+	for param, values := range c.Request.URL.Query() {
+		key := strings.TrimPrefix(param, models.AttributeFilterPrefix)
+		if key == param || key == "" {
+			continue
+		}
+		for _, value := range values {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					if attributes == nil {
+						attributes = map[string][]string{}
+					}
+					attributes[key] = append(attributes[key], v)
+				}
+			}
+		}
+	}
+
+	product, err := h.DB.SearchProduct(lowerPrice, upperPrice, categoryName, name, attributes)
 	if err != nil {
 		log.Println("handler error in search product", err)
 		c.JSON(http.StatusInternalServerError, "Product Not found")
@@ -4,18 +4,20 @@ import (
 	"net/http"
 
 	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
 	"github.com/gin-gonic/gin"
 )
 
 func (h *Handler) BuyerSignUpHandler(c *gin.Context) {
-	buyer := &models.Buyer{}
-	err := c.ShouldBindJSON(buyer)
+	signup := &models.BuyerSignup{}
+	err := c.ShouldBindJSON(signup)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Unable to bind JSON",
 		})
 		return
 	}
+	buyer := signup.Buyer()
 	if buyer.Username == "" || buyer.FirstName == "" || buyer.LastName == "" || buyer.Password == "" || buyer.Email == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Enter all fields",
@@ -53,6 +55,17 @@ func (h *Handler) BuyerSignUpHandler(c *gin.Context) {
 		return
 	}
 
+	var referrer *models.Buyer
+	if signup.InviteCode != "" {
+		referrer, err = h.DB.FindBuyerByReferralCode(services.NormalizeReferralCode(signup.InviteCode))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid referral code",
+			})
+			return
+		}
+	}
+
 	if err = buyer.HashPassword(); err != nil {
 
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -61,7 +74,7 @@ func (h *Handler) BuyerSignUpHandler(c *gin.Context) {
 		return
 	}
 
-	_, err = h.DB.CreateBuyer(buyer)
+	err = h.createBuyer(buyer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "could not create buyer",
@@ -77,6 +90,16 @@ func (h *Handler) BuyerSignUpHandler(c *gin.Context) {
 		return
 	}
 
+	if referrer != nil {
+		h.recordReferral(referrer, buyer)
+	}
+
+	_ = h.queueEmail(buyer.Email, services.EmailWelcome, services.EmailData{
+		Name: buyer.FirstName,
+		Role: "buyer",
+		Link: services.FrontendURL(),
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Sign Up Successful",
 	})
@@ -145,6 +168,12 @@ func (h *Handler) SellerSignUpHandler(c *gin.Context) {
 		return
 	}
 
+	_ = h.queueEmail(seller.Email, services.EmailWelcome, services.EmailData{
+		Name: seller.FirstName,
+		Role: "seller",
+		Link: services.FrontendURL(),
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Sign Up Successful",
 	})
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+)
+
+type taxRuleRequest struct {
+	CategoryID      uint   `json:"category_id"`
+	Name            string `json:"name" binding:"required"`
+	RateBasisPoints uint   `json:"rate_basis_points" binding:"lte=10000"`
+	Exempt          bool   `json:"exempt"`
+	Inclusive       bool   `json:"inclusive"`
+}
+
+// GetTaxRules lists the configured tax rules
+func (h *Handler) GetTaxRules(c *gin.Context) {
+	rules, err := h.DB.GetTaxRules()
+	if err != nil {
+		log.Printf("get tax rules error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get tax rules"})
+		return
+	}
+	response.JSON(c, "tax rules retrieved successfully", http.StatusOK, rules, nil)
+}
+
+// SaveTaxRule sets the tax rule for a category, or the default rule when category_id is 0
+func (h *Handler) SaveTaxRule(c *gin.Context) {
+	var request taxRuleRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	rule, err := h.DB.SaveTaxRule(&models.TaxRule{
+		CategoryID:      request.CategoryID,
+		Name:            request.Name,
+		RateBasisPoints: request.RateBasisPoints,
+		Exempt:          request.Exempt,
+		Inclusive:       request.Inclusive,
+	})
+	if err != nil {
+		log.Printf("save tax rule error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to save tax rule"})
+		return
+	}
+	response.JSON(c, "tax rule saved successfully", http.StatusOK, rule, nil)
+}
+
+// DeleteTaxRule removes a tax rule
+func (h *Handler) DeleteTaxRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid tax rule id"})
+		return
+	}
+	if err := h.DB.DeleteTaxRule(uint(id)); err != nil {
+		log.Printf("delete tax rule error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to delete tax rule"})
+		return
+	}
+	response.JSON(c, "tax rule deleted successfully", http.StatusOK, nil, nil)
+}
+
+// CheckoutSummary shows the buyer what they will pay for their cart, tax included
+func (h *Handler) CheckoutSummary(c *gin.Context) {
+	buyer, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	summary, err := h.DB.GetCheckoutSummary(buyer)
+	if errors.Is(err, database.ErrVariantRemoved) || errors.Is(err, database.ErrProductUnavailable) {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("checkout summary error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get checkout summary"})
+		return
+	}
+	response.JSON(c, "checkout summary retrieved successfully", http.StatusOK, summary, nil)
+}
+
+// SellerSalesReport totals the seller's sales and the tax collected between ?from= and ?to= (YYYY-MM-DD).
+// It defaults to the current month.
+func (h *Handler) SellerSalesReport(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := now
+	if value := c.Query("from"); value != "" {
+		if from, err = time.ParseInLocation("2006-01-02", value, now.Location()); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"from must be in the format YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, now.Location()); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{"to must be in the format YYYY-MM-DD"})
+			return
+		}
+	}
+	if to.Before(from) {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"from must be before to"})
+		return
+	}
+
+	report, err := h.DB.GetSellerSalesReport(seller.ID, from, to)
+	if err != nil {
+		log.Printf("sales report error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get sales report"})
+		return
+	}
+	response.JSON(c, "sales report retrieved successfully", http.StatusOK, report, nil)
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+)
+
+// the roles requests are sent as, "" sends a request without credentials
+const (
+	roleBuyer  = "buyer"
+	roleSeller = "seller"
+	roleAdmin  = "admin"
+)
+
+// apiTest sends requests through the app's router, backed by a mock database, storage and Paystack
+type apiTest struct {
+	DB       *mock_database.MockDB
+	Storage  *mock_database.MockStorage
+	Paystack *mock_database.MockPaystack
+	Router   *gin.Engine
+	tokens   map[string]string
+}
+
+// newAPITest sets up the router over mocks with buyer and seller, either of which can be nil, logged in
+func newAPITest(t *testing.T, buyer *models.Buyer, seller *models.Seller) *apiTest {
+	ctrl := gomock.NewController(t)
+	api := &apiTest{
+		DB:       mock_database.NewMockDB(ctrl),
+		Storage:  mock_database.NewMockStorage(ctrl),
+		Paystack: mock_database.NewMockPaystack(ctrl),
+		tokens:   map[string]string{},
+	}
+	api.Router, _ = router.SetupRouter(&handlers.Handler{DB: api.DB, Storage: api.Storage, Paystack: api.Paystack})
+	os.Setenv("ADMIN_TOKEN", "admintoken")
+	t.Cleanup(func() { os.Unsetenv("ADMIN_TOKEN") })
+
+	secret := os.Getenv("JWT_SECRET")
+	login := func(role, email string) {
+		claims, _ := services.GenerateClaims(email)
+		token, _ := services.GenerateToken(jwt.SigningMethodHS256, claims, &secret)
+		api.tokens[role] = *token
+	}
+	api.DB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	if buyer != nil {
+		login(roleBuyer, buyer.Email)
+		api.DB.EXPECT().FindBuyerByEmail(buyer.Email).Return(buyer, nil).AnyTimes()
+	}
+	if seller != nil {
+		login(roleSeller, seller.Email)
+		api.DB.EXPECT().FindSellerByEmail(seller.Email).Return(seller, nil).AnyTimes()
+	}
+	return api
+}
+
+// send makes a request to /api/v1 path as role, with body as it is
+func (api *apiTest) send(role, method, path, body string) *httptest.ResponseRecorder {
+	return api.sendBody(role, method, path, "", strings.NewReader(body))
+}
+
+// sendBody makes a request to /api/v1 path as role, with a body of contentType such as a multipart form
+func (api *apiTest) sendBody(role, method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/v1"+path, body)
+	switch role {
+	case "":
+	case roleAdmin:
+		req.Header.Set("X-Admin-Token", "admintoken")
+	default:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", api.tokens[role]))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return api.serve(req)
+}
+
+// serve runs req through the router
+func (api *apiTest) serve(req *http.Request) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	api.Router.ServeHTTP(rw, req)
+	return rw
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, nil, &seller)
+	mockDB := api.DB
+
+	t.Run("Test for creating an api key", func(t *testing.T) {
+		var saved *models.APIKey
+		mockDB.EXPECT().CreateAPIKey(gomock.Any()).DoAndReturn(func(key *models.APIKey) error {
+			saved = key
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/apikeys", `{"name":"inventory sync","scopes":["products:read","products:write"]}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Equal(t, seller.ID, saved.SellerID)
+		assert.Equal(t, "products:read,products:write", saved.ScopeList)
+		assert.True(t, strings.HasPrefix(saved.Prefix, services.APIKeyPrefix))
+		assert.Contains(t, rw.Body.String(), `"key":"`+saved.Prefix+"_")
+		assert.NotContains(t, rw.Body.String(), saved.KeyHash)
+	})
+
+	t.Run("Test for an api key with a bad scope or expiry", func(t *testing.T) {
+		rw := api.send(roleSeller, http.MethodPost, "/seller/apikeys", `{"name":"sync","scopes":["admin"],"expires_at":"2020-01-01T00:00:00Z"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "unknown scope admin")
+		assert.Contains(t, rw.Body.String(), "expires_at must be in the future")
+	})
+
+	t.Run("Test for revoking another seller's key", func(t *testing.T) {
+		mockDB.EXPECT().RevokeAPIKey(uint(3), seller.ID).Return(gorm.ErrRecordNotFound)
+		rw := api.send(roleSeller, http.MethodDelete, "/seller/apikeys/3", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+}
+
+func TestAPIKeyAuthorization(t *testing.T) {
+	api := newAPITest(t, nil, nil)
+	mockDB := api.DB
+
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	key, prefix, hash, err := services.GenerateAPIKey()
+	assert.NoError(t, err)
+	apiKey := func() *models.APIKey {
+		k := &models.APIKey{Model: gorm.Model{ID: 2}, SellerID: seller.ID, Seller: seller, Prefix: prefix, KeyHash: hash}
+		k.SetScopes([]string{models.ScopeOrdersRead})
+		return k
+	}
+
+	send := func(path, key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1"+path, nil)
+		req.Header.Set("X-API-Key", key)
+		return api.serve(req)
+	}
+
+	t.Run("Test for a key with the route's scope", func(t *testing.T) {
+		mockDB.EXPECT().FindAPIKeyByPrefix(prefix).Return(apiKey(), nil)
+		mockDB.EXPECT().TouchAPIKey(uint(2), gomock.Any()).Return(nil)
+		mockDB.EXPECT().GetSellerInvoices(seller.ID).Return([]models.Invoice{}, nil)
+		rw := send("/seller/invoices", key)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for a key sent as a bearer token", func(t *testing.T) {
+		used := time.Now()
+		k := apiKey()
+		k.LastUsedAt = &used
+		mockDB.EXPECT().FindAPIKeyByPrefix(prefix).Return(k, nil)
+		mockDB.EXPECT().GetSellerInvoices(seller.ID).Return([]models.Invoice{}, nil)
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/seller/invoices", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rw := api.serve(req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for a key without the route's scope", func(t *testing.T) {
+		mockDB.EXPECT().FindAPIKeyByPrefix(prefix).Return(apiKey(), nil)
+		rw := send("/seller/allproducts", key)
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+		assert.Contains(t, rw.Body.String(), "api key is missing the products:read scope")
+	})
+
+	t.Run("Test for a route keys cannot use", func(t *testing.T) {
+		mockDB.EXPECT().FindAPIKeyByPrefix(prefix).Return(apiKey(), nil)
+		rw := send("/seller/apikeys", key)
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+	})
+
+	t.Run("Test for a revoked or wrong key", func(t *testing.T) {
+		revoked := apiKey()
+		now := time.Now()
+		revoked.RevokedAt = &now
+		mockDB.EXPECT().FindAPIKeyByPrefix(prefix).Return(revoked, nil)
+		rw := send("/seller/invoices", key)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+		mockDB.EXPECT().FindAPIKeyByPrefix(prefix).Return(apiKey(), nil)
+		rw = send("/seller/invoices", prefix+"_0000")
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	})
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCategoryAttributes(t *testing.T) {
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, nil, &seller)
+	mockDB := api.DB
+
+	sendForm := func(fields map[string]string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for field, value := range fields {
+			writer.WriteField(field, value)
+		}
+		writer.Close()
+		return api.sendBody(roleSeller, http.MethodPost, "/createproduct", writer.FormDataContentType(), body)
+	}
+	schema := func() []models.CategoryAttribute {
+		brand := models.CategoryAttribute{CategoryID: 2, Key: "brand", Name: "Brand", Type: models.AttributeText, Required: true}
+		ram := models.CategoryAttribute{CategoryID: 5, Key: "ram", Name: "RAM", Type: models.AttributeSelect}
+		ram.SetAllowedValues([]string{"4GB", "8GB"})
+		return []models.CategoryAttribute{brand, ram}
+	}
+
+	t.Run("Test for a category's attribute schema", func(t *testing.T) {
+		mockDB.EXPECT().GetCategoryAttributes(uint(5)).Return(schema(), nil)
+		rw := api.send("", http.MethodGet, "/categories/5/attributes", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"allowed_values":["4GB","8GB"]`)
+
+		mockDB.EXPECT().GetCategoryAttributes(uint(50)).Return(nil, gorm.ErrRecordNotFound)
+		rw = api.send("", http.MethodGet, "/categories/50/attributes", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for saving a category's attributes", func(t *testing.T) {
+		mockDB.EXPECT().SaveCategoryAttributes(uint(5), gomock.Any()).DoAndReturn(
+			func(categoryID uint, attributes []models.CategoryAttribute) error {
+				assert.Equal(t, "storage_size", attributes[0].Key)
+				assert.Equal(t, `["64GB","128GB"]`, attributes[0].ValueList)
+				assert.True(t, attributes[0].Required)
+				return nil
+			})
+		rw := api.send(roleAdmin, http.MethodPut, "/admin/categories/5/attributes",
+			`[{"name":"Storage Size","type":"select","required":true,"allowed_values":["64GB","128GB"]}]`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for invalid attributes", func(t *testing.T) {
+		for _, body := range []string{
+			`[{"name":"RAM","type":"colour"}]`,
+			`[{"name":"RAM","type":"select"}]`,
+			`[{"name":"RAM","type":"text","allowed_values":["8GB"]}]`,
+			`[{"name":"RAM","type":"text"},{"name":"ram","type":"number"}]`,
+			`[{"name":"RAM","key":"Bad Key","type":"text"}]`,
+		} {
+			rw := api.send(roleAdmin, http.MethodPut, "/admin/categories/5/attributes", body)
+			assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+		}
+	})
+
+	t.Run("Test for creating a product with attributes", func(t *testing.T) {
+		mockDB.EXPECT().GetCategoryAttributes(uint(5)).Return(schema(), nil)
+		mockDB.EXPECT().CreateProduct(gomock.Any()).DoAndReturn(func(product models.Product) error {
+			assert.Equal(t, uint(5), product.CategoryId)
+			assert.Equal(t, []models.ProductAttribute{{Key: "brand", Value: "Samsung"}, {Key: "ram", Value: "8GB"}},
+				product.AttributeValues)
+			return nil
+		})
+		rw := sendForm(map[string]string{"title": "galaxy", "price": "300000", "rating": "5", "quantity": "4",
+			"category_id": "5", "attr.brand": "Samsung", "attr.ram": "8gb"})
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"attributes":{"brand":"Samsung","ram":"8GB"}`)
+	})
+
+	t.Run("Test for creating a product with invalid attributes", func(t *testing.T) {
+		for _, fields := range []map[string]string{
+			{"attr.ram": "8GB"},
+			{"attr.brand": "Samsung", "attr.ram": "16GB"},
+			{"attr.brand": "Samsung", "attr.colour": "red"},
+		} {
+			fields["category_id"] = "5"
+			mockDB.EXPECT().GetCategoryAttributes(uint(5)).Return(schema(), nil)
+			rw := sendForm(fields)
+			assert.Equal(t, http.StatusBadRequest, rw.Code, fields)
+		}
+		mockDB.EXPECT().GetCategoryAttributes(uint(50)).Return(nil, gorm.ErrRecordNotFound)
+		rw := sendForm(map[string]string{"category_id": "50"})
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "category not found")
+	})
+
+	t.Run("Test for updating a product's attributes", func(t *testing.T) {
+		product := &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, CategoryId: 5}
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		mockDB.EXPECT().GetCategoryAttributes(uint(5)).Return(schema(), nil)
+		mockDB.EXPECT().UpdateProductByID(uint(4), gomock.Any()).DoAndReturn(func(id uint, product models.Product) error {
+			assert.Equal(t, []models.ProductAttribute{{Key: "brand", Value: "Tecno"}}, product.AttributeValues)
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPut, "/update/product/4", `{"title":"camon","attributes":{"brand":"Tecno"}}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		mockDB.EXPECT().GetCategoryAttributes(uint(5)).Return(schema(), nil)
+		rw = api.send(roleSeller, http.MethodPut, "/update/product/4", `{"title":"camon","attributes":{"ram":"8GB"}}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "Brand is required")
+	})
+
+	t.Run("Test for filtering search by attributes", func(t *testing.T) {
+		mockDB.EXPECT().SearchProduct("", "", "phones", "", map[string][]string{
+			"brand": {"samsung", "apple"},
+			"ram":   {"8GB"},
+		}).Return([]models.Product{{Title: "galaxy"}}, nil)
+		rw := api.send("", http.MethodGet, "/searchproducts?category=phones&attr.brand=samsung,apple&attr.ram=8GB", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.True(t, strings.Contains(rw.Body.String(), "galaxy"))
+	})
+}
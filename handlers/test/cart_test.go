@@ -107,7 +107,7 @@ func TestCart(t *testing.T) {
 	t.Run("Testing for error in AddToChart", func(t *testing.T) {
 		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
 		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
-		mockDB.EXPECT().AddToCart(product, &buyer).Return(errors.New("error adding to cart"))
+		mockDB.EXPECT().AddToCart(product, nil, &buyer).Return(errors.New("error adding to cart"))
 		rw := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/addtocart", strings.NewReader(string(prodJASON)))
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
@@ -119,7 +119,7 @@ func TestCart(t *testing.T) {
 	t.Run("No error in AddToChart", func(t *testing.T) {
 		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
 		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
-		mockDB.EXPECT().AddToCart(product, &buyer).Return(nil)
+		mockDB.EXPECT().AddToCart(product, nil, &buyer).Return(nil)
 		rw := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/addtocart", strings.NewReader(string(prodJASON)))
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
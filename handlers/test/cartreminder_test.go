@@ -0,0 +1,155 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSendCartReminders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	settings := services.CartReminderSettings{
+		After:          24 * time.Hour,
+		SecondAfter:    48 * time.Hour,
+		CouponPercent:  10,
+		CouponValidity: 72 * time.Hour,
+	}
+	idle := time.Now().Add(-30 * time.Hour)
+	mockDB.EXPECT().GetAbandonedCarts(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(firstBefore, secondBefore time.Time, limit int) ([]models.AbandonedCart, error) {
+			assert.WithinDuration(t, time.Now().Add(-settings.After), firstBefore, time.Minute)
+			assert.WithinDuration(t, time.Now().Add(-settings.SecondAfter), secondBefore, time.Minute)
+			return []models.AbandonedCart{
+				{CartID: 1, BuyerID: 3, Email: "ada@yahoo.com", FirstName: "Ada", LastActivityAt: idle, Stage: 1},
+				{CartID: 2, BuyerID: 4, Email: "obi@yahoo.com", FirstName: "Obi", LastActivityAt: idle, Stage: 2},
+			}, nil
+		})
+	summary := &models.CheckoutSummary{
+		Lines:    []models.CheckoutLine{{Title: "rice cooker", Quantity: 1, TaxLine: models.TaxLine{Net: 20000, Tax: 1500, Gross: 21500}}},
+		Subtotal: 20000, Tax: 1500, Total: 21500,
+	}
+	mockDB.EXPECT().GetCheckoutSummary(gomock.Any()).Return(summary, nil).Times(2)
+
+	var coupon *models.Coupon
+	mockDB.EXPECT().CreateCartReminder(gomock.Any(), gomock.Any()).DoAndReturn(func(reminder *models.CartReminder, c *models.Coupon) error {
+		assert.Equal(t, idle, reminder.LastActivityAt)
+		assert.NotEmpty(t, reminder.Token)
+		if reminder.Stage == 1 {
+			assert.Nil(t, c)
+		} else {
+			assert.Equal(t, uint(4), c.BuyerID)
+			assert.Equal(t, uint(10), c.PercentOff)
+			coupon = c
+		}
+		return nil
+	}).Times(2)
+	messages := map[string]*models.EmailMessage{}
+	mockDB.EXPECT().EnqueueEmail(gomock.Any()).DoAndReturn(func(message *models.EmailMessage) error {
+		messages[message.To] = message
+		return nil
+	}).Times(2)
+
+	sent, err := jobs.SendCartReminders(mockDB, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+
+	first := messages["ada@yahoo.com"]
+	assert.Equal(t, "You left something in your cart", first.Subject)
+	assert.Contains(t, first.Text, "1 x rice cooker")
+	assert.Contains(t, first.Text, "/api/v1/cartreminders/click/")
+	assert.Contains(t, first.Text, "/api/v1/cartreminders/unsubscribe?token="+services.UnsubscribeToken(3))
+
+	second := messages["obi@yahoo.com"]
+	assert.Equal(t, "10% off the items in your cart", second.Subject)
+	assert.Contains(t, second.HTML, coupon.Code)
+}
+
+func TestCartReminderLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	t.Run("Test for following a reminder with a coupon", func(t *testing.T) {
+		mockDB.EXPECT().ClickCartReminder("abc123").
+			Return(&models.CartReminder{Stage: 2, Coupon: &models.Coupon{Code: "CART-MFRGG2LT"}}, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/cartreminders/click/abc123", nil)
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusFound, rw.Code)
+		assert.Equal(t, services.FrontendURL()+"/buyer/cart?coupon=CART-MFRGG2LT", rw.Header().Get("Location"))
+	})
+
+	t.Run("Test for unsubscribing", func(t *testing.T) {
+		mockDB.EXPECT().SetCartReminders(uint(3), true).Return(nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/cartreminders/unsubscribe?token="+services.UnsubscribeToken(3), nil)
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for a forged unsubscribe link", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/cartreminders/unsubscribe?token=4."+strings.Split(services.UnsubscribeToken(3), ".")[1], nil)
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+}
+
+func TestApplyCoupon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com"}}
+	secret := os.Getenv("JWT_SECRET")
+	claims, _ := services.GenerateClaims(buyer.Email)
+	token, _ := services.GenerateToken(jwt.SigningMethodHS256, claims, &secret)
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil).AnyTimes()
+
+	apply := func(code string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/buyer/cart/coupon", strings.NewReader(`{"code":"`+code+`"}`))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *token))
+		route.ServeHTTP(rw, req)
+		return rw
+	}
+
+	t.Run("Test for applying a coupon", func(t *testing.T) {
+		mockDB.EXPECT().ApplyCoupon(buyer.ID, "CART-MFRGG2LT").
+			Return(&models.Coupon{Code: "CART-MFRGG2LT", BuyerID: buyer.ID, PercentOff: 10}, nil)
+		rw := apply("CART-MFRGG2LT")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"percent_off":10`)
+	})
+
+	t.Run("Test for an expired coupon", func(t *testing.T) {
+		mockDB.EXPECT().ApplyCoupon(buyer.ID, "CART-OLD").Return(nil, database.ErrCouponInvalid)
+		rw := apply("CART-OLD")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "coupon is invalid or has expired")
+	})
+}
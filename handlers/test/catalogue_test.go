@@ -0,0 +1,188 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProductCatalogue(t *testing.T) {
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, nil, &seller)
+	mockDB, mockStorage := api.DB, api.Storage
+
+	upload := func(path, fileName, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", fileName)
+		_, _ = part.Write([]byte(content))
+		w.Close()
+		return api.sendBody(roleSeller, http.MethodPost, path, w.FormDataContentType(), &body)
+	}
+	schema := []models.CategoryAttribute{{Key: "colour", Name: "Colour", Type: models.AttributeText, Required: true}}
+	catalogue := "sku,title,description,category_id,price,quantity,image_urls,attr.colour\n" +
+		"TS-1,T-shirt,Cotton,2,5000,3,https://example.com/ts.png,red\n" +
+		"TS-2,Polo,,2,6000,1,,\n" +
+		"TS-3,Cap,,2,abc,1,,blue\n" +
+		"TS-1,Shirt,,2,7000,1,,white\n"
+
+	t.Run("Test for a dry run report", func(t *testing.T) {
+		mockDB.EXPECT().FindProductSKUs(seller.ID, []string{"TS-1", "TS-2", "TS-1"}).
+			Return(map[string]bool{"TS-1": true}, nil)
+		mockDB.EXPECT().GetCategoryAttributes(uint(2)).Return(schema, nil)
+		rw := upload("/seller/catalogue/import?dry_run=true", "catalogue.csv", catalogue)
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var res struct {
+			Data models.ProductImport `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &res))
+		assert.Equal(t, models.JobStatusDone, res.Data.Status)
+		assert.Equal(t, 4, res.Data.TotalRows)
+		assert.Equal(t, 0, res.Data.Created)
+		assert.Equal(t, 1, res.Data.Updated)
+		assert.Equal(t, 3, res.Data.Failed)
+		assert.Equal(t, []models.ImportRowError{
+			{Row: 3, SKU: "TS-3", Error: "price must be a whole number"},
+			{Row: 2, SKU: "TS-2", Error: "Colour is required"},
+			{Row: 4, SKU: "TS-1", Error: "sku TS-1 is already on row 1"},
+		}, res.Data.Errors)
+	})
+
+	t.Run("Test for a queued import", func(t *testing.T) {
+		mockDB.EXPECT().CreateProductImport(gomock.Any()).DoAndReturn(func(productImport *models.ProductImport) error {
+			assert.Equal(t, seller.ID, productImport.SellerID)
+			assert.Equal(t, models.CatalogueJSONL, productImport.Format)
+			assert.Equal(t, models.JobStatusPending, productImport.Status)
+			assert.Equal(t, 1, productImport.TotalRows)
+			productImport.ID = 9
+			return nil
+		})
+		mockDB.EXPECT().EnqueueJob(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+			assert.Equal(t, jobs.TypeProductImport, job.Type)
+			assert.JSONEq(t, `{"import_id":9}`, job.Payload)
+			return nil
+		})
+		rw := upload("/seller/catalogue/import", "catalogue.jsonl",
+			`{"sku":"TS-1","title":"T-shirt","category_id":2,"price":5000,"attributes":{"colour":"red"}}`)
+		assert.Equal(t, http.StatusAccepted, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"status":"pending"`)
+	})
+
+	t.Run("Test for a catalogue with an unknown column", func(t *testing.T) {
+		rw := upload("/seller/catalogue/import", "catalogue.csv", "sku,title,colour\nTS-1,T-shirt,red\n")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "unknown column colour")
+	})
+
+	t.Run("Test for another seller's import", func(t *testing.T) {
+		mockDB.EXPECT().GetProductImport(uint(9)).Return(&models.ProductImport{Model: gorm.Model{ID: 9}, SellerID: 8}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/catalogue/imports/9", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Contains(t, rw.Body.String(), "import not found")
+	})
+
+	products := []models.Product{{
+		Model: gorm.Model{ID: 4}, SellerId: seller.ID, SKU: "TS-1", Title: "T-shirt", CategoryId: 2, Price: 5000,
+		Quantity: 3, Images: []models.Image{{Url: "https://bucket.s3.amazonaws.com/product/a.png"},
+			{Url: "https://bucket.s3.amazonaws.com/product/b.png"}},
+		Attributes: map[string]string{"colour": "red"},
+	}}
+
+	t.Run("Test for exporting a CSV catalogue", func(t *testing.T) {
+		mockDB.EXPECT().GetSellerCatalogue(seller.ID).Return(products, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/catalogue/export", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `attachment; filename="catalogue.csv"`, rw.Header().Get("Content-Disposition"))
+		assert.Equal(t, "sku,title,description,category_id,price,quantity,image_urls,attr.colour\n"+
+			"TS-1,T-shirt,,2,5000,3,https://bucket.s3.amazonaws.com/product/a.png|https://bucket.s3.amazonaws.com/product/b.png,red\n",
+			rw.Body.String())
+	})
+
+	t.Run("Test for exporting a JSON lines catalogue", func(t *testing.T) {
+		mockDB.EXPECT().GetSellerCatalogue(seller.ID).Return(products, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/catalogue/export?format=jsonl", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		rows, rowErrors, err := services.ParseCatalogue(models.CatalogueJSONL, strings.NewReader(rw.Body.String()))
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		assert.Equal(t, "red", rows[0].Attributes["colour"])
+		assert.Len(t, rows[0].ImageURLs, 2)
+	})
+
+	t.Run("Test for running a queued import", func(t *testing.T) {
+		productImport := &models.ProductImport{Model: gorm.Model{ID: 9}, SellerID: seller.ID, Format: models.CatalogueCSV,
+			Status: models.JobStatusPending, Data: catalogue}
+		mockDB.EXPECT().GetProductImport(uint(9)).Return(productImport, nil)
+		mockDB.EXPECT().GetCategoryAttributes(uint(2)).Return(schema, nil)
+		mockDB.EXPECT().ImportProduct(seller.ID, gomock.Any(), gomock.Any(), nil).DoAndReturn(
+			func(sellerID uint, row models.ProductImportRow, attributes []models.ProductAttribute, flags []string) (*models.Product, bool, error) {
+				assert.Equal(t, "TS-1", row.SKU)
+				assert.Equal(t, []models.ProductAttribute{{Key: "colour", Value: "red"}}, attributes)
+				return &models.Product{Model: gorm.Model{ID: 4}}, true, nil
+			})
+		mockDB.EXPECT().EnqueueJob(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+			assert.Equal(t, jobs.TypeProductImages, job.Type)
+			assert.JSONEq(t, `{"product_id":4,"urls":["https://example.com/ts.png"]}`, job.Payload)
+			return nil
+		})
+		mockDB.EXPECT().SaveProductImport(productImport).Return(nil)
+		assert.NoError(t, jobs.RunProductImport(context.Background(), mockDB, 9))
+		assert.Equal(t, models.JobStatusDone, productImport.Status)
+		assert.Equal(t, 1, productImport.Created)
+		assert.Equal(t, 3, productImport.Failed)
+		assert.Empty(t, productImport.Data)
+	})
+
+	t.Run("Test for fetching an imported product's images", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/ts.png" {
+				http.NotFound(w, r)
+				return
+			}
+			_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+		}))
+		defer server.Close()
+		client := jobs.ImageClient
+		jobs.ImageClient = server.Client()
+		defer func() { jobs.ImageClient = client }()
+
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(7)
+		mockStorage.EXPECT().URL(gomock.Any()).DoAndReturn(func(key string) string {
+			return "https://bucket.s3.amazonaws.com/" + key
+		}).Times(7)
+		mockDB.EXPECT().ReplaceProductImages(uint(4), gomock.Any()).DoAndReturn(func(id uint, images []models.Image) error {
+			assert.Len(t, images, 1)
+			assert.Equal(t, []int{2, 2}, []int{images[0].Width, images[0].Height})
+			assert.Len(t, images[0].Variants, 6)
+			return nil
+		})
+		err := jobs.FetchProductImages(context.Background(), mockDB, mockStorage, 4, []string{server.URL + "/ts.png", server.URL + "/missing.png"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Test for images on the app's own network", func(t *testing.T) {
+		assert.Nil(t, jobs.ImageClient.Transport.(*http.Transport).Proxy)
+		for _, url := range []string{"http://127.0.0.1/ts.png", "http://10.0.0.4/ts.png", "http://100.64.0.1/ts.png",
+			"http://169.254.169.254/latest/meta-data", "http://[::ffff:127.0.0.1]/ts.png", "http://[::ffff:100.100.100.200]/ts.png"} {
+			_, err := jobs.ImageClient.Get(url)
+			if assert.Error(t, err, url) {
+				assert.Contains(t, err.Error(), "is not a public address", url)
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCategories(t *testing.T) {
+	api := newAPITest(t, nil, nil)
+	mockDB := api.DB
+
+	fashion := uint(1)
+
+	t.Run("Test for the category tree", func(t *testing.T) {
+		mockDB.EXPECT().GetCategories().Return([]models.Category{
+			{Model: gorm.Model{ID: 1}, Name: "fashion", Slug: "fashion", Path: "/1/"},
+			{Model: gorm.Model{ID: 4}, Name: "shoes", Slug: "shoes", ParentID: &fashion, Path: "/1/4/"},
+			{Model: gorm.Model{ID: 2}, Name: "electronics", Slug: "electronics", Path: "/2/"},
+		}, nil)
+		rw := api.send("", http.MethodGet, "/categories", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		body := rw.Body.String()
+		assert.Less(t, strings.Index(body, `"electronics"`), strings.Index(body, `"fashion"`))
+		assert.Contains(t, body, `"children":[{`)
+		assert.Contains(t, body, `"slug":"shoes"`)
+	})
+
+	t.Run("Test for creating a category", func(t *testing.T) {
+		mockDB.EXPECT().CreateCategory(&models.Category{Name: "Running Shoes", Slug: "running-shoes", ParentID: &fashion}).
+			DoAndReturn(func(category *models.Category) error {
+				category.ID = 9
+				category.Path = "/1/9/"
+				return nil
+			})
+		rw := api.send(roleAdmin, http.MethodPost, "/admin/categories", `{"name":" Running Shoes ","parent_id":1}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"path":"/1/9/"`)
+	})
+
+	t.Run("Test for an invalid slug", func(t *testing.T) {
+		rw := api.send(roleAdmin, http.MethodPost, "/admin/categories", `{"name":"Shoes","slug":"Shoes For Men"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for a slug that is taken", func(t *testing.T) {
+		mockDB.EXPECT().CreateCategory(gomock.Any()).Return(database.ErrCategorySlugTaken)
+		rw := api.send(roleAdmin, http.MethodPost, "/admin/categories", `{"name":"Shoes"}`)
+		assert.Equal(t, http.StatusConflict, rw.Code)
+	})
+
+	t.Run("Test for moving a category under its child", func(t *testing.T) {
+		mockDB.EXPECT().UpdateCategory(gomock.Any()).DoAndReturn(func(category *models.Category) error {
+			assert.Equal(t, uint(1), category.ID)
+			assert.Equal(t, uint(4), *category.ParentID)
+			return database.ErrCategoryCycle
+		})
+		rw := api.send(roleAdmin, http.MethodPut, "/admin/categories/1", `{"name":"fashion","parent_id":4}`)
+		assert.Equal(t, http.StatusConflict, rw.Code)
+	})
+
+	t.Run("Test for updating a category that doesn't exist", func(t *testing.T) {
+		mockDB.EXPECT().UpdateCategory(gomock.Any()).Return(gorm.ErrRecordNotFound)
+		rw := api.send(roleAdmin, http.MethodPut, "/admin/categories/40", `{"name":"fashion"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for deleting a category", func(t *testing.T) {
+		mockDB.EXPECT().DeleteCategory(uint(4), nil).Return(int64(12), nil)
+		rw := api.send(roleAdmin, http.MethodDelete, "/admin/categories/4", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"products_moved":12`)
+
+		target := uint(2)
+		mockDB.EXPECT().DeleteCategory(uint(1), &target).Return(int64(3), nil)
+		rw = api.send(roleAdmin, http.MethodDelete, "/admin/categories/1?reassign_to=2", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for deleting a top level category with products", func(t *testing.T) {
+		mockDB.EXPECT().DeleteCategory(uint(1), nil).Return(int64(0), database.ErrCategoryInUse)
+		rw := api.send(roleAdmin, http.MethodDelete, "/admin/categories/1", "")
+		assert.Equal(t, http.StatusConflict, rw.Code)
+		assert.Contains(t, rw.Body.String(), "choose a category")
+	})
+
+	t.Run("Test for category management without the admin token", func(t *testing.T) {
+		rw := api.send("", http.MethodPost, "/admin/categories", `{"name":"Shoes"}`)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	})
+}
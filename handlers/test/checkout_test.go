@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/decadevs/shoparena/database"
 	mock_database "github.com/decadevs/shoparena/database/mocks"
 	"github.com/decadevs/shoparena/handlers"
 	"github.com/decadevs/shoparena/models"
@@ -13,6 +14,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -50,11 +52,16 @@ func TestCheckout(t *testing.T) {
 
 	token, _ := services.GenerateToken(jwt.SigningMethodHS256, newClaims, &secret)
 
-	Incoming := handlers.Fees{Amount: 2000}
+	summary := &models.CheckoutSummary{
+		Lines:    []models.CheckoutLine{{ProductID: 1, Quantity: 2, TaxLine: models.TaxLine{Net: 2000, Tax: 150, Gross: 2150}}},
+		Subtotal: 2000,
+		Tax:      150,
+		Total:    2150,
+	}
 
 	transaction := handlers.Transaction{
 		UserID:      buyer.ID,
-		Amount:      Incoming.Amount * 100,
+		Amount:      float64(summary.Total) * 100,
 		FirstName:   buyer.FirstName,
 		LastName:    buyer.LastName,
 		Email:       buyer.Email,
@@ -64,9 +71,34 @@ func TestCheckout(t *testing.T) {
 
 	transJASON, _ := json.Marshal(transaction)
 
+	t.Run("Testing for empty cart", func(t *testing.T) {
+		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
+		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(&models.CheckoutSummary{}, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/pay", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "cart is empty")
+	})
+
+	t.Run("Testing for a cart with a removed variant", func(t *testing.T) {
+		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
+		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(nil, database.ErrVariantRemoved)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/pay", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "no longer sold")
+	})
+
 	t.Run("Testing for error in Initializing", func(t *testing.T) {
 		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
 		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(summary, nil)
 		mockPaystack.EXPECT().InitializePayment(gomock.Any()).Return("", errors.New("error in Initializing Payment"))
 		rw := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/pay", strings.NewReader(string(transJASON)))
@@ -76,4 +108,81 @@ func TestCheckout(t *testing.T) {
 		assert.Contains(t, rw.Body.String(), "not valid")
 	})
 
+	t.Run("Testing for tax-inclusive amount sent to paystack", func(t *testing.T) {
+		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
+		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(summary, nil)
+		mockPaystack.EXPECT().InitializePayment(gomock.Any()).DoAndReturn(func(info []byte) (string, error) {
+			var sent handlers.Transaction
+			_ = json.Unmarshal(info, &sent)
+			assert.Equal(t, float64(215000), sent.Amount)
+			return "https://checkout.paystack.com/abc", nil
+		})
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/pay", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), "checkout.paystack.com")
+	})
+
+	t.Run("Testing for a cart the coupon covers in full", func(t *testing.T) {
+		free := &models.CheckoutSummary{
+			Lines:      summary.Lines,
+			Discount:   2000,
+			CouponCode: "FREE100",
+		}
+		mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
+		mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil)
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(free, nil)
+		mockDB.EXPECT().DeletePaidFromCart(buyer.ID, gomock.Any(), uint(0)).Return(nil, nil)
+		mockDB.EXPECT().CompleteReferral(buyer.ID, gomock.Any()).Return(nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/pay", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), "Order placed")
+		assert.Contains(t, rw.Body.String(), "payment/successful")
+	})
+
+}
+
+func TestPaymentCallback(t *testing.T) {
+	api := newAPITest(t, nil, nil)
+	mockDB, mockPaystack := api.DB, api.Paystack
+
+	verified := func(body string) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+	}
+	paid := "https://shoparena-frontend-phi.vercel.app/buyer/payment/successful"
+	unpaid := "https://shoparena-frontend-phi.vercel.app/buyer/payment/unsuccessful"
+
+	t.Run("Test for a payment that did not succeed", func(t *testing.T) {
+		mockPaystack.EXPECT().VerifyReference("ref1").
+			Return(verified(`{"status":true,"data":{"status":"abandoned","amount":215000}}`), nil)
+		rw := api.send("", http.MethodGet, "/callback?reference=ref1", "")
+		assert.Equal(t, http.StatusFound, rw.Code)
+		assert.Equal(t, unpaid, rw.Header().Get("Location"))
+	})
+
+	t.Run("Test for a payment short of the total", func(t *testing.T) {
+		mockPaystack.EXPECT().VerifyReference("ref2").
+			Return(verified(`{"status":true,"data":{"status":"success","amount":100000}}`), nil)
+		mockPaystack.EXPECT().PayStackDecodeToken("ref2", gomock.Any()).Return(jwt.MapClaims{"cart_id": 3}, nil)
+		mockDB.EXPECT().DeletePaidFromCart(uint(3), "ref2", uint(1000)).
+			Return(nil, fmt.Errorf("1000 paid for 2150: %w", database.ErrPaymentAmount))
+		rw := api.send("", http.MethodGet, "/callback?reference=ref2", "")
+		assert.Equal(t, unpaid, rw.Header().Get("Location"))
+	})
+
+	t.Run("Test for a successful payment", func(t *testing.T) {
+		mockPaystack.EXPECT().VerifyReference("ref3").
+			Return(verified(`{"status":true,"data":{"status":"success","amount":215000}}`), nil)
+		mockPaystack.EXPECT().PayStackDecodeToken("ref3", gomock.Any()).Return(jwt.MapClaims{"cart_id": 3}, nil)
+		mockDB.EXPECT().DeletePaidFromCart(uint(3), "ref3", uint(2150)).Return(nil, nil)
+		mockDB.EXPECT().CompleteReferral(uint(3), gomock.Any()).Return(nil)
+		rw := api.send("", http.MethodGet, "/callback?reference=ref3", "")
+		assert.Equal(t, paid, rw.Header().Get("Location"))
+	})
 }
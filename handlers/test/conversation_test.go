@@ -0,0 +1,182 @@
+package test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestConversations(t *testing.T) {
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com", FirstName: "Ada"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com", FirstName: "Tunde"}}
+	api := newAPITest(t, &buyer, &seller)
+	mockDB, mockStorage := api.DB, api.Storage
+
+	conversation := func() *models.Conversation {
+		return &models.Conversation{Model: gorm.Model{ID: 12}, BuyerID: buyer.ID, SellerID: seller.ID}
+	}
+
+	t.Run("Test for a buyer asking about a product", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(&models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID}, nil)
+		mockDB.EXPECT().StartConversation(gomock.Any()).DoAndReturn(func(c *models.Conversation) error {
+			assert.Equal(t, buyer.ID, c.BuyerID)
+			assert.Equal(t, seller.ID, c.SellerID)
+			assert.Equal(t, uint(4), *c.ProductID)
+			c.ID = 12
+			return nil
+		})
+		mockDB.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *models.Message) error {
+			assert.Equal(t, uint(12), m.ConversationID)
+			assert.Equal(t, models.RecipientBuyer, m.SenderType)
+			assert.Equal(t, "Is this still available?", m.Body)
+			m.ID = 40
+			m.Conversation = conversation()
+			m.Conversation.SellerUnread = 1
+			m.Conversation.LastMessage = m.Body
+			return nil
+		})
+		// the seller had nothing unread, so they get a notification
+		mockDB.EXPECT().CreateNotifications(gomock.Any()).DoAndReturn(func(notifications []models.Notification) error {
+			assert.Equal(t, models.RecipientSeller, notifications[0].RecipientType)
+			assert.Equal(t, seller.ID, notifications[0].RecipientID)
+			assert.Equal(t, models.NotificationNewMessage, notifications[0].Type)
+			assert.Equal(t, "New message from Ada", notifications[0].Title)
+			return nil
+		})
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/conversations", `{"product_id":4,"body":"  Is this still available?  "}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"id":40`)
+	})
+
+	t.Run("Test for a follow up while the seller has unread messages", func(t *testing.T) {
+		mockDB.EXPECT().GetConversation(uint(12)).Return(conversation(), nil)
+		mockDB.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *models.Message) error {
+			m.Conversation = conversation()
+			m.Conversation.SellerUnread = 2
+			return nil
+		})
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/conversations/12/messages", `{"body":"Hello?"}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+	})
+
+	t.Run("Test for a message with an image", func(t *testing.T) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		_ = w.WriteField("body", "")
+		part, _ := w.CreateFormFile("images", "receipt.png")
+		_, _ = part.Write(pngImage(40, 30))
+		w.Close()
+
+		mockDB.EXPECT().GetConversation(uint(12)).Return(conversation(), nil)
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key string, body io.Reader, size int64) error {
+			assert.True(t, strings.HasPrefix(key, "messages/"))
+			assert.True(t, strings.HasSuffix(key, ".png"))
+			return nil
+		})
+		mockStorage.EXPECT().URL(gomock.Any()).Return("https://bucket.s3.amazonaws.com/messages/receipt.png")
+		mockDB.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *models.Message) error {
+			assert.Equal(t, models.RecipientSeller, m.SenderType)
+			assert.Equal(t, seller.ID, m.SenderID)
+			assert.Len(t, m.Attachments, 1)
+			m.Conversation = conversation()
+			m.Conversation.BuyerUnread = 3
+			return nil
+		})
+		rw := api.sendBody(roleSeller, http.MethodPost, "/seller/conversations/12/messages", w.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), "messages/receipt.png")
+	})
+
+	t.Run("Test for an empty message", func(t *testing.T) {
+		mockDB.EXPECT().GetConversation(uint(12)).Return(conversation(), nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/conversations/12/messages", `{"body":"   "}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "a message needs a body or an image")
+	})
+
+	t.Run("Test for a seller starting a conversation without an order", func(t *testing.T) {
+		rw := api.send(roleSeller, http.MethodPost, "/seller/conversations", `{"body":"hi"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "order_id is required")
+	})
+
+	t.Run("Test for a seller starting a conversation about another seller's order", func(t *testing.T) {
+		mockDB.EXPECT().GetOrder(uint(21)).Return(&models.Order{Model: gorm.Model{ID: 21}, SellerId: 8, BuyerId: buyer.ID}, nil)
+		rw := api.send(roleSeller, http.MethodPost, "/seller/conversations", `{"order_id":21,"body":"hi"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for listing conversations", func(t *testing.T) {
+		mockDB.EXPECT().GetConversations(models.RecipientSeller, seller.ID, 1, 20).Return(&models.ConversationPage{
+			Conversations: []models.Conversation{*conversation()},
+			Page:          1, Limit: 20, Total: 1, Unread: 2,
+		}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/conversations", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"unread":2`)
+	})
+
+	t.Run("Test for reading messages", func(t *testing.T) {
+		mockDB.EXPECT().GetConversation(uint(12)).Return(conversation(), nil)
+		mockDB.EXPECT().GetMessages(uint(12), uint(50), 2).Return([]models.Message{{ID: 45}, {ID: 41}}, nil)
+		rw := api.send(roleBuyer, http.MethodGet, "/buyer/conversations/12/messages?before=50&limit=2", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"next_before":41`)
+	})
+
+	t.Run("Test for someone else's conversation", func(t *testing.T) {
+		other := conversation()
+		other.BuyerID = 9
+		mockDB.EXPECT().GetConversation(uint(12)).Return(other, nil)
+		rw := api.send(roleBuyer, http.MethodGet, "/buyer/conversations/12/messages", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for read receipts", func(t *testing.T) {
+		mockDB.EXPECT().GetConversation(uint(12)).Return(conversation(), nil)
+		mockDB.EXPECT().MarkConversationRead(gomock.Any(), models.RecipientSeller).Return(int64(2), nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/conversations/12/read", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"marked":2`)
+	})
+
+	t.Run("Test for reporting a message", func(t *testing.T) {
+		received := &models.Message{ID: 40, SenderType: models.RecipientBuyer, SenderID: buyer.ID, Conversation: conversation()}
+		mockDB.EXPECT().GetMessage(uint(40)).Return(received, nil)
+		mockDB.EXPECT().ReportMessage(gomock.Any()).DoAndReturn(func(report *models.MessageReport) error {
+			assert.Equal(t, models.RecipientSeller, report.ReporterType)
+			assert.Equal(t, "spam", report.Reason)
+			report.ID = 2
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/messages/40/report", `{"reason":"spam"}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+
+		// the buyer can't report their own message
+		mockDB.EXPECT().GetMessage(uint(40)).Return(received, nil)
+		rw = api.send(roleBuyer, http.MethodPost, "/buyer/messages/40/report", `{"reason":"spam"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for reviewing message reports", func(t *testing.T) {
+		mockDB.EXPECT().GetMessageReports(models.ReportStatusOpen).Return([]models.MessageReport{{MessageID: 40, Reason: "spam"}}, nil)
+		rw := api.send(roleAdmin, http.MethodGet, "/admin/messagereports", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"reason":"spam"`)
+
+		mockDB.EXPECT().SetMessageReportStatus(uint(2), models.ReportStatusActioned).Return(nil)
+		rw = api.send(roleAdmin, http.MethodPut, "/admin/messagereports/2", `{"status":"actioned"}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		rw = api.send(roleAdmin, http.MethodPut, "/admin/messagereports/2", `{"status":"open"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := events.NewBus()
+	var seen []string
+	bus.Subscribe(models.EventOrderPaid, "orders", func(ctx context.Context, event events.Event) error {
+		seen = append(seen, "orders")
+		return nil
+	})
+	bus.Subscribe("*", "all", func(ctx context.Context, event events.Event) error {
+		seen = append(seen, "all")
+		return errors.New("sink down")
+	})
+
+	err := bus.Publish(context.Background(), events.Event{ID: 1, Type: models.EventProductCreated})
+	assert.EqualError(t, err, "publish ProductCreated 1: all: sink down")
+	assert.Equal(t, []string{"all"}, seen)
+
+	seen = nil
+	_ = bus.Publish(context.Background(), events.Event{ID: 2, Type: models.EventOrderPaid})
+	assert.Equal(t, []string{"orders", "all"}, seen)
+}
+
+func TestEventRelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	failing := map[uint]bool{}
+	var published []uint
+	bus := events.NewBus()
+	bus.Subscribe("*", "test", func(ctx context.Context, event events.Event) error {
+		if failing[event.ID] {
+			return errors.New("unreachable")
+		}
+		published = append(published, event.ID)
+		return nil
+	})
+	relay := &events.Relay{Store: mockDB, Bus: bus, BatchSize: 10, MaxAttempts: 3}
+
+	t.Run("Test for publishing and retrying events", func(t *testing.T) {
+		failing[2] = true
+		mockDB.EXPECT().ClaimEvents(10).Return([]models.DomainEvent{
+			{ID: 1, Type: models.EventProductCreated, Payload: `{"product_id":4}`, Status: models.EventStatusPending},
+			{ID: 2, Type: models.EventOrderPaid, Status: models.EventStatusPending},
+		}, nil)
+		saved := map[uint]models.DomainEvent{}
+		mockDB.EXPECT().SaveEventAttempt(gomock.Any()).DoAndReturn(func(event *models.DomainEvent) error {
+			saved[event.ID] = *event
+			return nil
+		}).Times(2)
+
+		count, err := relay.Flush(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []uint{1}, published)
+		assert.Equal(t, models.EventStatusPublished, saved[1].Status)
+		assert.NotNil(t, saved[1].PublishedAt)
+		assert.Equal(t, models.EventStatusPending, saved[2].Status)
+		assert.Contains(t, saved[2].LastError, "unreachable")
+		assert.True(t, saved[2].NextAttemptAt.After(time.Now()))
+	})
+
+	t.Run("Test for an event that keeps failing", func(t *testing.T) {
+		mockDB.EXPECT().ClaimEvents(10).Return([]models.DomainEvent{
+			{ID: 2, Type: models.EventOrderPaid, Status: models.EventStatusPending, Attempts: 2},
+		}, nil)
+		mockDB.EXPECT().SaveEventAttempt(gomock.Any()).DoAndReturn(func(event *models.DomainEvent) error {
+			assert.Equal(t, models.EventStatusDead, event.Status)
+			return nil
+		})
+		_, err := relay.Flush(context.Background())
+		assert.NoError(t, err)
+	})
+}
+
+func TestWebhookSink(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(events.SignatureHeader)
+		assert.Equal(t, models.EventOrderPaid, r.Header.Get("X-Oja-Event"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := events.WebhookSink(server.URL, "whsec", server.Client())
+	err := sink(context.Background(), events.Event{ID: 7, Type: models.EventOrderPaid, Payload: []byte(`{"order_id":3}`)})
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"payload":{"order_id":3}`)
+
+	parts := strings.Split(signature, ",")
+	assert.Len(t, parts, 2)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(parts[0], "t="), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, signature, events.Sign("whsec", time.Unix(timestamp, 0), body))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	err = events.WebhookSink(failing.URL, "", failing.Client())(context.Background(), events.Event{ID: 8})
+	assert.Error(t, err)
+}
@@ -37,12 +37,14 @@ func TestBuyerSendForgotPasswordEMailHandler(t *testing.T) {
 		Orders: nil,
 	}
 	secretString := os.Getenv("JWTSECRET")
-	privateAPIKey := os.Getenv("MAILGUN_API_KEY")
-	yourDomain := os.Getenv("DOMAIN_STRING")
 	mockDB.EXPECT().FindBuyerByEmail("test@testmail.com").Return(&buyer, nil)
 	mockMail.EXPECT().GenerateNonAuthToken("test@gmail.com", secretString).Return(&buyer.Email, nil)
-	Link := "<strong>Here is your reset <a href='https://shoparena-frontend.vercel.app/buyer/forgot/test@gmail.com'>link</a></strong>"
-	mockMail.EXPECT().SendMail("forgot Password", Link, "test@gmail.com", privateAPIKey, yourDomain).Return(nil)
+	Link := "https://shoparena-frontend.vercel.app/buyer/forgot/test@gmail.com"
+	mockDB.EXPECT().EnqueueEmail(gomock.Any()).DoAndReturn(func(message *models.EmailMessage) error {
+		assert.Equal(t, "test@gmail.com", message.To)
+		assert.Contains(t, message.Text, Link)
+		return nil
+	})
 	resetPasswordPayload, err := json.Marshal(resetPassword)
 	if err != nil {
 		log.Println(err)
@@ -114,12 +116,14 @@ func TestSellerSendForgotPasswordEMailHandler(t *testing.T) {
 		Orders: nil,
 	}
 	secretString := os.Getenv("JWTSECRET")
-	privateAPIKey := os.Getenv("MAILGUN_API_KEY")
-	yourDomain := os.Getenv("DOMAIN_STRING")
 	mockDB.EXPECT().FindSellerByEmail("test@testmail.com").Return(&seller, nil)
 	mockMail.EXPECT().GenerateNonAuthToken("test@gmail.com", secretString).Return(&seller.Email, nil)
-	Link := "<strong>Here is your reset <a href='https://shoparena-frontend.vercel.app/seller/forgot/test@gmail.com'>link</a></strong>"
-	mockMail.EXPECT().SendMail("forgot Password", Link, "test@gmail.com", privateAPIKey, yourDomain).Return(nil)
+	Link := "https://shoparena-frontend.vercel.app/seller/forgot/test@gmail.com"
+	mockDB.EXPECT().EnqueueEmail(gomock.Any()).DoAndReturn(func(message *models.EmailMessage) error {
+		assert.Equal(t, "test@gmail.com", message.To)
+		assert.Contains(t, message.Text, Link)
+		return nil
+	})
 	resetPasswordPayload, err := json.Marshal(resetPassword)
 	if err != nil {
 		log.Println(err)
@@ -93,8 +93,8 @@ func TestAllSellerOrders(t *testing.T) {
 	//}
 
 	category := models.Category{
-		testGormModel,
-		productCategory,
+		Model: testGormModel,
+		Name:  productCategory,
 	}
 	product := models.Product{
 		Model:       testGormModel,
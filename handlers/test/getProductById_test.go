@@ -35,11 +35,11 @@ func TestGetProductById(t *testing.T) {
 		UpdatedAt: time.Time{},
 	}
 	category := models.Category{
-		testGorm,
-		"Handgloves",
+		Model: testGorm,
+		Name:  "Handgloves",
 	}
 	images := models.Image{
-		testGorm, 5, "gre.com",
+		Model: testGorm, ProductId: 5, Url: "gre.com",
 	}
 	sliceImages := []models.Image{images}
 
@@ -55,7 +55,7 @@ func TestGetProductById(t *testing.T) {
 		Images:      sliceImages,
 		Rating:      6,
 		Quantity:    1000,
-
+		Status:      models.ProductActive,
 	}
 	bodyJSON, err := json.Marshal(product)
 	if err != nil {
@@ -75,6 +75,9 @@ func TestGetProductById(t *testing.T) {
 	t.Run("getting product by ID successful", func(t *testing.T) {
 		id := 1
 		mockDB.EXPECT().GetProductByID(uint(id)).Return(&product, nil)
+		mockDB.EXPECT().GetProductQuestions(uint(id), 1, 5).Return(&models.ProductQuestionPage{
+			Questions: []models.ProductQuestion{{Body: "Are they powder free?", Answers: []models.ProductAnswer{}}},
+		}, nil)
 		rw := httptest.NewRecorder()
 		idVal := strconv.Itoa(int(testGorm.ID))
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/product/"+idVal, strings.NewReader(string(bodyJSON)))
@@ -82,5 +85,6 @@ func TestGetProductById(t *testing.T) {
 		fmt.Println(rw.Body.String())
 		assert.Equal(t, http.StatusOK, rw.Code)
 		assert.Contains(t, rw.Body.String(), "product gotten by ID")
+		assert.Contains(t, rw.Body.String(), "Are they powder free?")
 	})
 }
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/chai2010/webp"
+	"github.com/decadevs/shoparena/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessImage(t *testing.T) {
+	t.Run("Test for scaled down variants", func(t *testing.T) {
+		processed, err := services.ProcessImage(pngImage(2000, 1000), services.ImageSizes)
+		assert.NoError(t, err)
+		assert.Equal(t, ".png", processed.Extension)
+		assert.Equal(t, []int{2000, 1000}, []int{processed.Width, processed.Height})
+		assert.Len(t, processed.Variants, 6)
+		for name, side := range map[string]int{"large": 1600, "medium": 800, "thumbnail": 200} {
+			assert.Equal(t, []int{side, side / 2}, []int{processed.Variants[name].Width, processed.Variants[name].Height})
+			assert.Equal(t, ".png", processed.Variants[name].Extension)
+			assert.Equal(t, ".webp", processed.Variants[name+"_webp"].Extension)
+			_, err := webp.DecodeConfig(bytes.NewReader(processed.Variants[name+"_webp"].Data))
+			assert.NoError(t, err)
+		}
+
+		small, err := services.ProcessImage(pngImage(100, 300), services.ImageSizes)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{100, 300}, []int{small.Variants["large"].Width, small.Variants["large"].Height})
+		assert.Equal(t, []int{66, 200}, []int{small.Variants["thumbnail"].Width, small.Variants["thumbnail"].Height})
+	})
+
+	t.Run("Test for files that aren't images", func(t *testing.T) {
+		for _, data := range [][]byte{[]byte("<html><script>alert(1)</script></html>"), []byte("GIF89a"), pngImage(4, 4)[:20]} {
+			_, err := services.ProcessImage(data, nil)
+			assert.True(t, errors.Is(err, services.ErrUnsupportedImage))
+		}
+	})
+
+	t.Run("Test for images too large", func(t *testing.T) {
+		_, err := services.ProcessImage(pngImage(9000, 1), nil)
+		assert.True(t, errors.Is(err, services.ErrImageDimensions))
+	})
+
+	t.Run("Test for webp images", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, webp.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20)), &webp.Options{Lossless: true}))
+		processed, err := services.ProcessImage(buf.Bytes(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, ".png", processed.Extension)
+		assert.Equal(t, []int{30, 20}, []int{processed.Width, processed.Height})
+	})
+
+	t.Run("Test for jpeg metadata", func(t *testing.T) {
+		picture := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		for x := 0; x < 20; x++ {
+			for y := 0; y < 20; y++ {
+				picture.Set(x, y, color.White)
+			}
+		}
+		var buf bytes.Buffer
+		assert.NoError(t, jpeg.Encode(&buf, picture, &jpeg.Options{Quality: 100}))
+		// the camera was turned on its side, so the picture has to be turned clockwise to be upright
+		data := withExif(buf.Bytes(), 6, "Lagos, 6.5244 N 3.3792 E")
+
+		processed, err := services.ProcessImage(data, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, ".jpg", processed.Extension)
+		assert.Equal(t, []int{20, 40}, []int{processed.Width, processed.Height})
+		assert.False(t, bytes.Contains(processed.Data, []byte("Exif")))
+		assert.False(t, strings.Contains(string(processed.Data), "Lagos"))
+
+		upright, err := jpeg.Decode(bytes.NewReader(processed.Data))
+		assert.NoError(t, err)
+		top, _, _, _ := upright.At(10, 5).RGBA()
+		bottom, _, _, _ := upright.At(10, 35).RGBA()
+		assert.Greater(t, top, uint32(0xc000))
+		assert.Less(t, bottom, uint32(0x4000))
+
+		// where the white half of the picture ends up, and a point in the black half, for each orientation
+		for orientation, points := range map[uint16][2]image.Point{
+			2: {{30, 10}, {10, 10}},
+			3: {{30, 10}, {10, 10}},
+			4: {{10, 10}, {30, 10}},
+			5: {{10, 5}, {10, 35}},
+			7: {{10, 35}, {10, 5}},
+			8: {{10, 35}, {10, 5}},
+		} {
+			processed, err := services.ProcessImage(withExif(buf.Bytes(), orientation, ""), nil)
+			assert.NoError(t, err)
+			upright, err := jpeg.Decode(bytes.NewReader(processed.Data))
+			assert.NoError(t, err)
+			white, _, _, _ := upright.At(points[0].X, points[0].Y).RGBA()
+			black, _, _, _ := upright.At(points[1].X, points[1].Y).RGBA()
+			assert.Greater(t, white, uint32(0xc000), "orientation %d", orientation)
+			assert.Less(t, black, uint32(0x4000), "orientation %d", orientation)
+		}
+	})
+}
+
+// withExif puts an EXIF segment, with an orientation and a description, at the start of a jpeg
+func withExif(data []byte, orientation uint16, description string) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{42})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(2))
+	// orientation, a short
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	// image description, an ascii string after the IFD
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{0x010E, 2})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(len(description)+1))
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8+2+2*12+4))
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString(description + "\x00")
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	return out.Bytes()
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestInvoicePDF(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "buyer@yahoo.com", FirstName: "Ada"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com", FirstName: "Obi"}}
+	invoice := &models.Invoice{
+		Model:            gorm.Model{ID: 11},
+		Number:           models.InvoiceNumber(seller.ID, 42),
+		SellerID:         seller.ID,
+		Seller:           seller,
+		BuyerID:          buyer.ID,
+		Buyer:            buyer,
+		PaymentReference: "ref-123",
+		Orders: []models.Order{{
+			Product:   models.Product{Title: "rice cooker"},
+			Quantity:  1,
+			Subtotal:  20000,
+			TaxRate:   750,
+			TaxAmount: 1500,
+			Total:     21500,
+		}},
+		Subtotal: 20000,
+		Tax:      1500,
+		Total:    21500,
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	buyerClaims, _ := services.GenerateClaims(buyer.Email)
+	buyerToken, _ := services.GenerateToken(jwt.SigningMethodHS256, buyerClaims, &secret)
+	sellerClaims, _ := services.GenerateClaims(seller.Email)
+	sellerToken, _ := services.GenerateToken(jwt.SigningMethodHS256, sellerClaims, &secret)
+
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil).AnyTimes()
+	mockDB.EXPECT().FindSellerByEmail(seller.Email).Return(&seller, nil).AnyTimes()
+
+	t.Run("Test for buyer downloading their invoice", func(t *testing.T) {
+		mockDB.EXPECT().GetInvoice(invoice.ID).Return(invoice, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/buyer/invoices/11/pdf", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *buyerToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "application/pdf", rw.Header().Get("Content-Type"))
+		assert.Contains(t, rw.Header().Get("Content-Disposition"), "INV-7-000042.pdf")
+		assert.True(t, strings.HasPrefix(rw.Body.String(), "%PDF"))
+	})
+
+	t.Run("Test for seller downloading their invoice", func(t *testing.T) {
+		mockDB.EXPECT().GetInvoice(invoice.ID).Return(invoice, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/seller/invoices/11/pdf", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *sellerToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for another buyer's invoice", func(t *testing.T) {
+		other := *invoice
+		other.BuyerID = 99
+		mockDB.EXPECT().GetInvoice(invoice.ID).Return(&other, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/buyer/invoices/11/pdf", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *buyerToken))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Contains(t, rw.Body.String(), "invoice not found")
+	})
+
+	t.Run("Test for an invoice with non-Latin names and titles", func(t *testing.T) {
+		other := *invoice
+		other.Buyer.FirstName = "Adébáyọ̀"
+		other.Orders = []models.Order{invoice.Orders[0]}
+		other.Orders[0].Product.Title = "Ọbẹ̀ pot Чайник"
+		pdf, err := services.RenderInvoicePDF(&other)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(pdf), "/Helvetica")
+		assert.Contains(t, pdfText(pdf), utf16Text("Ọbẹ̀ pot Чайник"))
+		assert.Contains(t, pdfText(pdf), utf16Text("Adébáyọ̀"))
+	})
+}
+
+// pdfText inflates every stream in a PDF so the text drawn on its pages can be searched
+func pdfText(pdf []byte) string {
+	var text strings.Builder
+	for _, part := range bytes.Split(pdf, []byte("stream\n"))[1:] {
+		r, err := zlib.NewReader(bytes.NewReader(part))
+		if err != nil {
+			continue
+		}
+		b, _ := io.ReadAll(r)
+		text.Write(b)
+	}
+	return text.String()
+}
+
+// utf16Text encodes s the way a UTF-8 font's text is written into a PDF page
+func utf16Text(s string) string {
+	var b strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		b.WriteByte(byte(u >> 8))
+		b.WriteByte(byte(u))
+	}
+	return b.String()
+}
@@ -0,0 +1,256 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2022, time.June, 15, 10, 7, 30, 0, time.UTC) // a Wednesday
+
+	for spec, want := range map[string]time.Time{
+		"*/15 * * * *": time.Date(2022, time.June, 15, 10, 15, 0, 0, time.UTC),
+		"30 2 * * *":   time.Date(2022, time.June, 16, 2, 30, 0, 0, time.UTC),
+		"0 9 * * 1-5":  time.Date(2022, time.June, 16, 9, 0, 0, 0, time.UTC),
+		"0 0 1 * *":    time.Date(2022, time.July, 1, 0, 0, 0, 0, time.UTC),
+		"@weekly":      time.Date(2022, time.June, 19, 0, 0, 0, 0, time.UTC),
+		"@every 10s":   time.Date(2022, time.June, 15, 10, 7, 40, 0, time.UTC),
+	} {
+		schedule, err := jobs.ParseSchedule(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, want, schedule.Next(from), spec)
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "@every soon"} {
+		_, err := jobs.ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestJobRunner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	runner := jobs.NewRunner(mockDB)
+	runner.PollInterval = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	results := map[uint]*models.Job{}
+	done := make(chan struct{})
+	runner.Handle("test.job", func(ctx context.Context, job *models.Job) error {
+		var payload struct{ Fail bool }
+		if err := jobs.Decode(job, &payload); err != nil {
+			return err
+		}
+		if job.ID == 3 {
+			panic("boom")
+		}
+		if payload.Fail {
+			return errors.New("not yet")
+		}
+		return nil
+	}, jobs.Concurrency(2), jobs.MaxAttempts(3))
+
+	claimed := []models.Job{
+		{Model: gorm.Model{ID: 1}, Type: "test.job", Payload: `{"Fail":false}`, Attempts: 1},
+		{Model: gorm.Model{ID: 2}, Type: "test.job", Payload: `{"Fail":true}`, Attempts: 1},
+		{Model: gorm.Model{ID: 3}, Type: "test.job", Attempts: 3},
+	}
+	first := mockDB.EXPECT().ClaimJobs("test.job", 2, runner.WorkerID, gomock.Any()).Return(claimed[:2], nil)
+	second := mockDB.EXPECT().ClaimJobs("test.job", gomock.Any(), runner.WorkerID, gomock.Any()).Return(claimed[2:], nil).After(first)
+	mockDB.EXPECT().ClaimJobs("test.job", gomock.Any(), runner.WorkerID, gomock.Any()).Return(nil, nil).After(second).AnyTimes()
+	mockDB.EXPECT().SaveJobResult(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		saved := *job
+		results[job.ID] = &saved
+		if len(results) == 3 {
+			close(done)
+		}
+		return nil
+	}).Times(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("jobs did not run")
+	}
+	cancel()
+	<-stopped
+
+	assert.Equal(t, models.JobStatusDone, results[1].Status)
+	assert.NotNil(t, results[1].FinishedAt)
+
+	assert.Equal(t, models.JobStatusPending, results[2].Status)
+	assert.Equal(t, "not yet", results[2].LastError)
+	assert.WithinDuration(t, time.Now().Add(jobs.Backoff(1)), results[2].RunAt, 5*time.Second)
+
+	assert.Equal(t, models.JobStatusFailed, results[3].Status)
+	assert.Equal(t, "panic: boom", results[3].LastError)
+}
+
+func TestJobRunnerGracefulShutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	runner := jobs.NewRunner(mockDB)
+	runner.PollInterval = 10 * time.Millisecond
+	started := make(chan struct{})
+	runner.Handle("slow.job", func(ctx context.Context, job *models.Job) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+
+	first := mockDB.EXPECT().ClaimJobs("slow.job", 1, runner.WorkerID, gomock.Any()).
+		Return([]models.Job{{Model: gorm.Model{ID: 9}, Type: "slow.job", Attempts: 1}}, nil)
+	mockDB.EXPECT().ClaimJobs("slow.job", gomock.Any(), runner.WorkerID, gomock.Any()).Return(nil, nil).After(first).AnyTimes()
+	mockDB.EXPECT().SaveJobResult(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+		assert.Equal(t, models.JobStatusDone, job.Status)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	<-started
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+}
+
+func TestJobSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	runner := jobs.NewRunner(mockDB)
+	runner.PollInterval = 10 * time.Millisecond
+	runner.Handle("tick.job", func(ctx context.Context, job *models.Job) error { return nil })
+	assert.NoError(t, runner.Schedule("@every 1s", "tick.job"))
+
+	queued := make(chan *models.Job, 1)
+	mockDB.EXPECT().ClaimJobs("tick.job", gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	mockDB.EXPECT().EnqueueJob(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+		select {
+		case queued <- job:
+		default:
+		}
+		return nil
+	}).MinTimes(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	var job *models.Job
+	select {
+	case job = <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled job was not queued")
+	}
+	cancel()
+	<-stopped
+
+	assert.Equal(t, "tick.job", job.Type)
+	assert.NotNil(t, job.UniqueKey)
+	assert.Contains(t, *job.UniqueKey, "schedule:tick.job:")
+}
+
+func TestJobPollers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	runner := jobs.NewRunner(mockDB)
+	runner.PollInterval = 10 * time.Millisecond
+	polled := make(chan struct{}, 3)
+	runner.Every(10*time.Millisecond, "outbox.flush", func(ctx context.Context) error {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+		return errors.New("smtp down")
+	})
+	// pollers run in process, so no job rows are queued for them
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-polled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("poller did not run")
+		}
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	os.Setenv("ADMIN_TOKEN", "admintoken")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	t.Run("Test for retrying a failed job", func(t *testing.T) {
+		mockDB.EXPECT().RetryJob(uint(5)).Return(nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/admin/jobs/5/retry", nil)
+		req.Header.Set("X-Admin-Token", "admintoken")
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for a job that has not failed", func(t *testing.T) {
+		mockDB.EXPECT().RetryJob(uint(6)).Return(gorm.ErrRecordNotFound)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/admin/jobs/6/retry", nil)
+		req.Header.Set("X-Admin-Token", "admintoken")
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestListingModeration(t *testing.T) {
+	os.Setenv("BANNED_KEYWORDS", "Replica, fake id")
+	defer os.Unsetenv("BANNED_KEYWORDS")
+
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{FirstName: "Chidi", Email: "seller@yahoo.com"}}
+	api := newAPITest(t, &buyer, &seller)
+	mockDB := api.DB
+	product := func() *models.Product {
+		return &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, Title: "Rice cooker", Price: 5000,
+			Status: models.ProductActive, ModerationStatus: models.ModerationApproved}
+	}
+
+	t.Run("Test for screening listings", func(t *testing.T) {
+		assert.Equal(t, []string{"replica", "fake id"},
+			services.ScreenProduct(&models.Product{Title: "REPLICA watch", Description: "comes with a fake ID"}))
+		assert.Empty(t, services.ScreenProduct(&models.Product{Title: "Replicator", Description: "fake identity"}))
+	})
+
+	t.Run("Test for a new listing waiting to be reviewed", func(t *testing.T) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for field, value := range map[string]string{"category_id": "2", "title": "Replica watch", "price": "5000",
+			"rating": "0", "quantity": "3"} {
+			_ = w.WriteField(field, value)
+		}
+		w.Close()
+
+		mockDB.EXPECT().GetCategoryAttributes(uint(2)).Return(nil, nil)
+		mockDB.EXPECT().CreateProduct(gomock.Any()).DoAndReturn(func(product models.Product) error {
+			assert.Equal(t, models.ProductPendingReview, product.Status)
+			assert.Equal(t, models.ModerationPending, product.ModerationStatus)
+			assert.Equal(t, []models.ListingReview{{SellerID: seller.ID, Trigger: models.ReviewNewListing,
+				Status: models.ModerationPending, Flags: "replica"}}, product.Reviews)
+			return nil
+		})
+		rw := api.sendBody(roleSeller, http.MethodPost, "/createproduct", w.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"status":"pending_review"`)
+	})
+
+	t.Run("Test for an edited listing", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(), nil)
+		mockDB.EXPECT().UpdateProductByID(uint(4), gomock.Any()).DoAndReturn(func(id uint, product models.Product) error {
+			assert.Equal(t, []models.ListingReview{models.NewListingReview(models.ReviewEditedListing, []string{"replica"})},
+				product.Reviews)
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPut, "/update/product/4",
+			`{"title":"Rice cooker","description":"a replica of the original","price":5000}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for an edit that leaves the listing as it is", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(), nil)
+		mockDB.EXPECT().UpdateProductByID(uint(4), gomock.Any()).DoAndReturn(func(id uint, product models.Product) error {
+			assert.Empty(t, product.Reviews)
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPut, "/update/product/4", `{"title":"Rice cooker","price":4500}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for reporting a listing", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(), nil)
+		mockDB.EXPECT().ReportListing(gomock.Any()).DoAndReturn(func(report *models.ListingReport) error {
+			assert.Equal(t, models.ListingReport{ProductID: 4, BuyerID: buyer.ID, Reason: "counterfeit",
+				Details: "The logo is wrong"}, *report)
+			report.ID, report.ReviewID = 1, 9
+			return nil
+		})
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/products/4/reports",
+			`{"reason":"Counterfeit","details":" The logo is wrong "}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"review_id":9`)
+	})
+
+	t.Run("Test for reporting a listing again", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(), nil)
+		mockDB.EXPECT().ReportListing(gomock.Any()).Return(nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/products/4/reports", `{"reason":"scam"}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), "listing already reported")
+	})
+
+	t.Run("Test for invalid reports", func(t *testing.T) {
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/products/4/reports", `{"reason":"boring"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "reason must be one of counterfeit")
+
+		draft := product()
+		draft.Status = models.ProductDraft
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(draft, nil)
+		rw = api.send(roleBuyer, http.MethodPost, "/buyer/products/4/reports", `{"reason":"scam"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for the review queue", func(t *testing.T) {
+		mockDB.EXPECT().GetListingReviews(models.ModerationPending).Return([]models.ListingReview{
+			{Model: gorm.Model{ID: 9}, ProductID: 4, Trigger: models.ReviewReported, Status: models.ModerationPending, ReportCount: 2},
+		}, nil)
+		rw := api.send(roleAdmin, http.MethodGet, "/admin/reviews", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"report_count":2`)
+
+		rw = api.send("", http.MethodGet, "/admin/reviews", "")
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	})
+
+	t.Run("Test for rejecting a listing", func(t *testing.T) {
+		rw := api.send(roleAdmin, http.MethodPut, "/admin/reviews/9", `{"status":"rejected"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "a rejection needs a reason")
+
+		rejected := product()
+		rejected.Status, rejected.ModerationStatus = models.ProductDraft, models.ModerationRejected
+		mockDB.EXPECT().DecideListingReview(uint(9), models.ModerationRejected, "Counterfeit goods aren't allowed").
+			Return(&models.ListingReview{Model: gorm.Model{ID: 9}, ProductID: 4, Product: rejected, SellerID: seller.ID,
+				Status: models.ModerationRejected, Reason: "Counterfeit goods aren't allowed"}, nil)
+		mockDB.EXPECT().FindSellerById(seller.ID).Return(&seller, nil)
+		mockDB.EXPECT().EnqueueEmail(gomock.Any()).DoAndReturn(func(message *models.EmailMessage) error {
+			assert.Equal(t, seller.Email, message.To)
+			assert.Equal(t, "Your listing Rice cooker was not approved", message.Subject)
+			assert.Contains(t, message.Text, "Reason: Counterfeit goods aren't allowed")
+			return nil
+		})
+		rw = api.send(roleAdmin, http.MethodPut, "/admin/reviews/9",
+			`{"status":"rejected","reason":" Counterfeit goods aren't allowed "}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"moderation_status":"rejected"`)
+	})
+
+	t.Run("Test for checking out a rejected listing", func(t *testing.T) {
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(nil, fmt.Errorf("Rice cooker: %w", database.ErrProductUnavailable))
+		rw := api.send(roleBuyer, http.MethodPost, "/pay", "")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "Rice cooker: this product is not available")
+	})
+
+	t.Run("Test for deciding a review twice", func(t *testing.T) {
+		mockDB.EXPECT().DecideListingReview(uint(9), models.ModerationApproved, "").Return(nil, database.ErrReviewDecided)
+		rw := api.send(roleAdmin, http.MethodPut, "/admin/reviews/9", `{"status":"approved"}`)
+		assert.Equal(t, http.StatusConflict, rw.Code)
+	})
+
+	t.Run("Test for a missing review", func(t *testing.T) {
+		mockDB.EXPECT().DecideListingReview(uint(10), models.ModerationApproved, "").Return(nil, gorm.ErrRecordNotFound)
+		rw := api.send(roleAdmin, http.MethodPut, "/admin/reviews/10", `{"status":"approved"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestNotificationInbox(t *testing.T) {
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, nil, &seller)
+	mockDB := api.DB
+
+	t.Run("Test for listing unread notifications", func(t *testing.T) {
+		mockDB.EXPECT().GetNotifications(models.RecipientSeller, seller.ID, true, 2, 10).Return(&models.NotificationPage{
+			Notifications: []models.Notification{{ID: 31, Type: models.NotificationNewOrder, Title: "New order #21"}},
+			Page:          2, Limit: 10, Total: 11, Unread: 11,
+		}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/notifications?unread=true&page=2&limit=10", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"title":"New order #21"`)
+		assert.Contains(t, rw.Body.String(), `"unread":11`)
+	})
+
+	t.Run("Test for a bad page", func(t *testing.T) {
+		rw := api.send(roleSeller, http.MethodGet, "/seller/notifications?page=0", "")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for the new order badge", func(t *testing.T) {
+		mockDB.EXPECT().CountUnreadNotifications(models.RecipientSeller, seller.ID).
+			Return(map[string]int64{models.NotificationNewOrder: 3, models.NotificationReview: 1}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/notifications/count", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"unread":4`)
+		assert.Contains(t, rw.Body.String(), `"new_order":3`)
+	})
+
+	t.Run("Test for marking a notification unread", func(t *testing.T) {
+		mockDB.EXPECT().SetNotificationRead(uint(31), models.RecipientSeller, seller.ID, false).Return(nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/notifications/31", `{"read":false}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().SetNotificationRead(uint(32), models.RecipientSeller, seller.ID, true).Return(gorm.ErrRecordNotFound)
+		rw = api.send(roleSeller, http.MethodPut, "/seller/notifications/32", `{"read":true}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for marking everything read", func(t *testing.T) {
+		mockDB.EXPECT().MarkAllNotificationsRead(models.RecipientSeller, seller.ID).Return(int64(4), nil)
+		rw := api.send(roleSeller, http.MethodPost, "/seller/notifications/readall", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"marked":4`)
+	})
+}
+
+func TestOrderNotifications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	bus := events.NewBus()
+	mockDB.EXPECT().EnqueueJob(gomock.Any()).Return(nil).AnyTimes()
+	assert.NoError(t, jobs.Register(jobs.NewRunner(mockDB), mockDB, nil, nil, bus))
+	// paid orders also go to seller webhooks
+	mockDB.EXPECT().QueueWebhookDeliveries(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+	// and to open event streams
+	mockDB.EXPECT().PublishRealtime(gomock.Any()).Return(nil).AnyTimes()
+
+	paid, _ := json.Marshal(models.OrderEvent{OrderID: 21, SellerID: 7, BuyerID: 3, ProductID: 4, Quantity: 2, Total: 21500})
+	mockDB.EXPECT().CreateNotifications(gomock.Any()).DoAndReturn(func(notifications []models.Notification) error {
+		assert.Len(t, notifications, 2)
+		assert.Equal(t, models.RecipientSeller, notifications[0].RecipientType)
+		assert.Equal(t, uint(7), notifications[0].RecipientID)
+		assert.Equal(t, models.NotificationNewOrder, notifications[0].Type)
+		assert.Equal(t, "2 x product #4, NGN 21,500", notifications[0].Body)
+		assert.Equal(t, uint(3), notifications[1].RecipientID)
+		assert.Equal(t, "event:60:seller", *notifications[0].DedupKey)
+		return nil
+	})
+	assert.NoError(t, bus.Publish(context.Background(), events.Event{ID: 60, Type: models.EventOrderPaid, Payload: paid}))
+
+	shipped, _ := json.Marshal(models.OrderEvent{OrderID: 21, SellerID: 7, BuyerID: 3, StatusNote: "GIG-20391"})
+	mockDB.EXPECT().CreateNotifications(gomock.Any()).DoAndReturn(func(notifications []models.Notification) error {
+		assert.Len(t, notifications, 1)
+		assert.Equal(t, "Your order #21 has shipped", notifications[0].Title)
+		assert.Equal(t, "GIG-20391", notifications[0].Body)
+		return nil
+	})
+	assert.NoError(t, bus.Publish(context.Background(), events.Event{ID: 61, Type: models.EventOrderShipped, Payload: shipped}))
+}
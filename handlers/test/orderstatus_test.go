@@ -0,0 +1,128 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUpdateOrderStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com", FirstName: "Obi"}}
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "buyer@yahoo.com", FirstName: "Ada"}}
+	order := func(status string) *models.Order {
+		return &models.Order{
+			Model:    gorm.Model{ID: 21},
+			SellerId: seller.ID,
+			Seller:   seller,
+			BuyerId:  buyer.ID,
+			Buyer:    buyer,
+			Product:  models.Product{Title: "rice cooker"},
+			Quantity: 1,
+			Total:    21500,
+			Status:   status,
+		}
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	claims, _ := services.GenerateClaims(seller.Email)
+	token, _ := services.GenerateToken(jwt.SigningMethodHS256, claims, &secret)
+
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindSellerByEmail(seller.Email).Return(&seller, nil).AnyTimes()
+
+	update := func(body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/seller/orders/21/status", strings.NewReader(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *token))
+		route.ServeHTTP(rw, req)
+		return rw
+	}
+
+	t.Run("Test for shipping an order", func(t *testing.T) {
+		mockDB.EXPECT().GetOrder(uint(21)).Return(order(models.OrderStatusPaid), nil)
+		mockDB.EXPECT().UpdateOrderStatus(uint(21), models.OrderStatusPaid, models.OrderStatusShipped, "GIG-20391").Return(nil)
+		mockDB.EXPECT().EnqueueEmail(gomock.Any()).DoAndReturn(func(message *models.EmailMessage) error {
+			assert.Equal(t, buyer.Email, message.To)
+			assert.Equal(t, "Your order #21 has shipped", message.Subject)
+			assert.Contains(t, message.HTML, "GIG-20391")
+			assert.Contains(t, message.Text, "1 x rice cooker")
+			return nil
+		})
+		rw := update(`{"status":"shipped","note":"GIG-20391"}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"status":"shipped"`)
+	})
+
+	t.Run("Test for an invalid status change", func(t *testing.T) {
+		mockDB.EXPECT().GetOrder(uint(21)).Return(order(models.OrderStatusPaid), nil)
+		rw := update(`{"status":"refunded"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "order cannot be moved from paid to refunded")
+	})
+
+	t.Run("Test for another seller's order", func(t *testing.T) {
+		other := order(models.OrderStatusPaid)
+		other.SellerId = 99
+		mockDB.EXPECT().GetOrder(uint(21)).Return(other, nil)
+		rw := update(`{"status":"shipped"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Contains(t, rw.Body.String(), "order not found")
+	})
+}
+
+func TestPreviewEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	os.Setenv("ADMIN_TOKEN", "admintoken")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	for _, name := range services.EmailTemplates() {
+		t.Run("Test for previewing "+name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/emails/"+name+"/preview", nil)
+			req.Header.Set("X-Admin-Token", "admintoken")
+			route.ServeHTTP(rw, req)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Contains(t, rw.Header().Get("Content-Type"), "text/html")
+			assert.Contains(t, rw.Body.String(), "Hi ")
+
+			rw = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodGet, "/api/v1/admin/emails/"+name+"/preview?format=text", nil)
+			req.Header.Set("X-Admin-Token", "admintoken")
+			route.ServeHTTP(rw, req)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.NotContains(t, rw.Body.String(), "<p>")
+		})
+	}
+
+	t.Run("Test for an unknown template", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/emails/nope/preview", nil)
+		req.Header.Set("X-Admin-Token", "admintoken")
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOutboxSender(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	mockMail := mock_database.NewMockMailer(ctrl)
+	sender := &services.OutboxSender{Store: mockDB, Mailer: mockMail, BatchSize: 10, MaxAttempts: 3}
+
+	t.Run("Test for a sent email", func(t *testing.T) {
+		email := models.EmailOutbox{Model: gorm.Model{ID: 1}, To: "ada@yahoo.com", Subject: "hi", Status: models.EmailStatusPending}
+		mockDB.EXPECT().ClaimDueEmails(10).Return([]models.EmailOutbox{email}, nil)
+		mockMail.EXPECT().Send(&models.EmailMessage{To: "ada@yahoo.com", Subject: "hi"}).Return(nil)
+		mockDB.EXPECT().SaveEmailAttempt(gomock.Any()).DoAndReturn(func(saved *models.EmailOutbox) error {
+			assert.Equal(t, models.EmailStatusSent, saved.Status)
+			assert.Equal(t, uint(1), saved.Attempts)
+			assert.NotNil(t, saved.SentAt)
+			return nil
+		})
+		sent, err := sender.Flush()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("Test for a failed email being retried later", func(t *testing.T) {
+		email := models.EmailOutbox{Model: gorm.Model{ID: 2}, To: "ada@yahoo.com", Status: models.EmailStatusPending, Attempts: 1}
+		mockDB.EXPECT().ClaimDueEmails(10).Return([]models.EmailOutbox{email}, nil)
+		mockMail.EXPECT().Send(gomock.Any()).Return(errors.New("connection refused"))
+		mockDB.EXPECT().SaveEmailAttempt(gomock.Any()).DoAndReturn(func(saved *models.EmailOutbox) error {
+			assert.Equal(t, models.EmailStatusPending, saved.Status)
+			assert.Equal(t, uint(2), saved.Attempts)
+			assert.Equal(t, "connection refused", saved.LastError)
+			assert.WithinDuration(t, time.Now().Add(2*time.Minute), saved.NextAttemptAt, 5*time.Second)
+			return nil
+		})
+		sent, err := sender.Flush()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent)
+	})
+
+	t.Run("Test for an email that used up its attempts", func(t *testing.T) {
+		email := models.EmailOutbox{Model: gorm.Model{ID: 3}, To: "ada@yahoo.com", Status: models.EmailStatusPending, Attempts: 2}
+		mockDB.EXPECT().ClaimDueEmails(10).Return([]models.EmailOutbox{email}, nil)
+		mockMail.EXPECT().Send(gomock.Any()).Return(errors.New("mailbox unavailable"))
+		mockDB.EXPECT().SaveEmailAttempt(gomock.Any()).DoAndReturn(func(saved *models.EmailOutbox) error {
+			assert.Equal(t, models.EmailStatusDead, saved.Status)
+			return nil
+		})
+		_, err := sender.Flush()
+		assert.NoError(t, err)
+	})
+}
+
+func TestEmailRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, services.EmailRetryDelay(1))
+	assert.Equal(t, 4*time.Minute, services.EmailRetryDelay(3))
+	assert.Equal(t, 12*time.Hour, services.EmailRetryDelay(30))
+}
+
+func TestBuildMIMEMessage(t *testing.T) {
+	body, err := services.BuildMIMEMessage("Oja Ecommerce <oja@example.com>", &models.EmailMessage{
+		To:      "ada@yahoo.com",
+		Subject: "Your invoice",
+		HTML:    "<p>hello</p>",
+		Text:    "hello",
+		Attachments: []models.EmailAttachment{
+			{Filename: "INV-7-000042.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.3")},
+		},
+	})
+	assert.NoError(t, err)
+	message := string(body)
+	assert.Contains(t, message, "To: ada@yahoo.com\r\n")
+	assert.Contains(t, message, "Content-Type: multipart/mixed")
+	assert.Contains(t, message, "multipart/alternative")
+	assert.Contains(t, message, "text/plain; charset=utf-8")
+	assert.Contains(t, message, "text/html; charset=utf-8")
+	assert.Contains(t, message, `attachment; filename=INV-7-000042.pdf`)
+	assert.True(t, strings.Count(message, "Content-Transfer-Encoding: base64") == 3)
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProductAlerts(t *testing.T) {
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com"}}
+	api := newAPITest(t, &buyer, nil)
+	mockDB := api.DB
+	product := &models.Product{Model: gorm.Model{ID: 4}, Title: "rice cooker", Price: 21500}
+
+	t.Run("Test for watching a product", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		mockDB.EXPECT().SaveProductAlert(gomock.Any()).DoAndReturn(func(alert *models.ProductAlert) error {
+			assert.Equal(t, buyer.ID, alert.BuyerID)
+			assert.True(t, alert.BackInStock)
+			assert.Equal(t, uint(20000), alert.PriceBelow)
+			return nil
+		})
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/alerts", `{"product_id":4,"back_in_stock":true,"price_below":20000}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+	})
+
+	t.Run("Test for an alert with nothing to watch", func(t *testing.T) {
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/alerts", `{"product_id":4}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for a price the product is already below", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/alerts", `{"product_id":4,"price_below":30000}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for removing another buyer's alert", func(t *testing.T) {
+		mockDB.EXPECT().DeleteProductAlert(uint(8), buyer.ID).Return(gorm.ErrRecordNotFound)
+		rw := api.send(roleBuyer, http.MethodDelete, "/buyer/alerts/8", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+}
+
+func TestProductAlertTriggers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	bus := events.NewBus()
+	mockDB.EXPECT().EnqueueJob(gomock.Any()).Return(nil).AnyTimes()
+	assert.NoError(t, jobs.Register(jobs.NewRunner(mockDB), mockDB, nil, nil, bus))
+
+	publish := func(id uint, eventType string, payload interface{}) {
+		data, _ := json.Marshal(payload)
+		assert.NoError(t, bus.Publish(context.Background(), events.Event{ID: id, Type: eventType, Payload: data}))
+	}
+
+	mockDB.EXPECT().QueueBackInStockAlerts(uint(4), uint(50), uint(21500)).Return(2, nil)
+	publish(50, models.EventProductStockChanged, models.StockChangedEvent{ProductID: 4, OldQuantity: 0, NewQuantity: 3, Price: 21500})
+	// restocking a product that was not sold out sets nothing off
+	publish(51, models.EventProductStockChanged, models.StockChangedEvent{ProductID: 4, OldQuantity: 3, NewQuantity: 8})
+
+	mockDB.EXPECT().QueuePriceDropAlerts(uint(4), uint(52), uint(21500), uint(19000)).Return(1, nil)
+	publish(52, models.EventProductPriceChanged, models.PriceChangedEvent{ProductID: 4, OldPrice: 21500, NewPrice: 19000})
+	publish(53, models.EventProductPriceChanged, models.PriceChangedEvent{ProductID: 4, OldPrice: 19000, NewPrice: 25000})
+}
+
+func TestSendProductAlerts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	ada := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com", FirstName: "Ada"}}
+	obi := models.Buyer{Model: gorm.Model{ID: 5}, User: models.User{Email: "obi@yahoo.com", FirstName: "Obi"}}
+	mockDB.EXPECT().ClaimAlertNotifications(gomock.Any()).Return([]models.AlertNotification{
+		{Model: gorm.Model{ID: 1}, BuyerID: 3, Buyer: ada, ProductID: 4, Product: models.Product{Title: "rice cooker"}, Kind: models.AlertBackInStock, Price: 21500},
+		{Model: gorm.Model{ID: 2}, BuyerID: 3, Buyer: ada, ProductID: 9, Product: models.Product{Title: "steel pot"}, Kind: models.AlertPriceDrop, Price: 4500},
+		{Model: gorm.Model{ID: 3}, BuyerID: 5, Buyer: obi, ProductID: 9, Product: models.Product{Title: "steel pot"}, Kind: models.AlertPriceDrop, Price: 4500},
+	}, nil)
+	messages := map[string]*models.EmailMessage{}
+	mockDB.EXPECT().SendAlertEmail(gomock.Any(), []uint{1, 2}).DoAndReturn(func(message *models.EmailMessage, ids []uint) error {
+		messages[message.To] = message
+		return nil
+	})
+	// obi's notification stays unsent, to be claimed again
+	mockDB.EXPECT().SendAlertEmail(gomock.Any(), []uint{3}).DoAndReturn(func(message *models.EmailMessage, ids []uint) error {
+		messages[message.To] = message
+		return errors.New("db down")
+	})
+
+	sent, err := jobs.SendProductAlerts(mockDB)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, "rice cooker is back in stock and more", messages["ada@yahoo.com"].Subject)
+	assert.Contains(t, messages["ada@yahoo.com"].Text, "steel pot: price dropped to NGN 4,500")
+	assert.Equal(t, "steel pot is now NGN 4,500", messages["obi@yahoo.com"].Subject)
+}
@@ -0,0 +1,190 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProductImages(t *testing.T) {
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, nil, &seller)
+	mockDB, mockStorage := api.DB, api.Storage
+	mockStorage.EXPECT().URL(gomock.Any()).DoAndReturn(func(key string) string {
+		return "https://bucket.s3.amazonaws.com/" + key
+	}).AnyTimes()
+
+	product := func(imageIDs ...uint) *models.Product {
+		p := &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, Title: "Kettle", Status: models.ProductActive}
+		for i, id := range imageIDs {
+			p.Images = append(p.Images, models.Image{Model: gorm.Model{ID: id}, ProductId: 4,
+				Url: fmt.Sprintf("https://s3-eu-west-3.amazonaws.com/arp-rental/product/%d.png", id), Position: i})
+		}
+		return p
+	}
+	upload := func(names []string, altTexts ...string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for _, name := range names {
+			part, _ := w.CreateFormFile("images", name)
+			if strings.HasSuffix(name, ".png") {
+				_, _ = part.Write(pngImage(40, 30))
+			} else {
+				_, _ = part.Write([]byte("GIF89a"))
+			}
+		}
+		for _, altText := range altTexts {
+			_ = w.WriteField("alt_text", altText)
+		}
+		w.Close()
+		return api.sendBody(roleSeller, http.MethodPost, "/seller/products/4/images", w.FormDataContentType(), &body)
+	}
+
+	t.Run("Test for uploading more images", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1), nil)
+		// a picture and its png and webp variant in each of the three sizes
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(14)
+		mockDB.EXPECT().AddProductImages(uint(4), gomock.Any()).DoAndReturn(func(id uint, images []models.Image) error {
+			assert.Len(t, images, 2)
+			assert.Equal(t, "Kettle from the side", images[0].AltText)
+			assert.Equal(t, []int{1, 2}, []int{images[0].Position, images[1].Position})
+			assert.Equal(t, []int{40, 30}, []int{images[0].Width, images[0].Height})
+			assert.Regexp(t, `^https://bucket\.s3\.amazonaws\.com/product/[0-9a-f-]+\.png$`, images[0].Url)
+			assert.Equal(t, strings.TrimSuffix(images[0].Url, ".png")+"_thumbnail.webp", images[0].Variants["thumbnail_webp"])
+			assert.Len(t, images[1].Variants, 6)
+			images[0].ID, images[1].ID = 2, 3
+			return nil
+		})
+		rw := upload([]string{"side.png", "top.png"}, " Kettle from the side ")
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"alt_text":"Kettle from the side"`)
+		assert.Contains(t, rw.Body.String(), `"medium_webp"`)
+	})
+
+	t.Run("Test for uploading too many images", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2, 3, 4, 5, 6, 7), nil)
+		rw := upload([]string{"a.png", "b.png"})
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "a product can have at most 8 images")
+	})
+
+	t.Run("Test for images uploaded while others were", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1), nil)
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(7)
+		mockDB.EXPECT().AddProductImages(uint(4), gomock.Any()).Return(database.ErrTooManyImages)
+		mockDB.EXPECT().EnqueueJob(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+			var payload jobs.ImagesDeletePayload
+			assert.Equal(t, jobs.TypeImagesDelete, job.Type)
+			assert.NoError(t, json.Unmarshal([]byte(job.Payload), &payload))
+			assert.Len(t, payload.URLs, 7)
+			return nil
+		})
+		rw := upload([]string{"a.png"})
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for unsupported images", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1), nil)
+		rw := upload([]string{"a.gif"})
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "a.gif: only png, jpeg and webp images are supported")
+	})
+
+	t.Run("Test for describing an image", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2), nil)
+		mockDB.EXPECT().UpdateProductImageAltText(uint(4), uint(2), "Kettle lid").
+			Return(&models.Image{Model: gorm.Model{ID: 2}, ProductId: 4, AltText: "Kettle lid"}, nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/images/2", `{"alt_text":"Kettle lid "}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2), nil)
+		rw = api.send(roleSeller, http.MethodPut, "/seller/products/4/images/2", fmt.Sprintf(`{"alt_text":"%s"}`, strings.Repeat("a", 251)))
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for reordering images", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2, 3), nil)
+		mockDB.EXPECT().ReorderProductImages(uint(4), []uint{2, 3, 1}).Return(nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/images", `{"image_ids":[2,3,1]}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		body := rw.Body.String()
+		assert.Less(t, strings.Index(body, "product/2.png"), strings.Index(body, "product/1.png"))
+
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2, 3), nil)
+		mockDB.EXPECT().ReorderProductImages(uint(4), []uint{2, 1}).Return(database.ErrImageOrder)
+		rw = api.send(roleSeller, http.MethodPut, "/seller/products/4/images", `{"image_ids":[2,1]}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for setting the primary image", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2, 3), nil)
+		mockDB.EXPECT().ReorderProductImages(uint(4), []uint{3, 1, 2}).Return(nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/images/3/primary", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2, 3), nil)
+		rw = api.send(roleSeller, http.MethodPut, "/seller/products/4/images/9/primary", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for deleting an image", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2), nil)
+		mockDB.EXPECT().DeleteProductImage(uint(4), uint(2)).Return(nil)
+		rw := api.send(roleSeller, http.MethodDelete, "/seller/products/4/images/2", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(1, 2), nil)
+		mockDB.EXPECT().DeleteProductImage(uint(4), uint(9)).Return(gorm.ErrRecordNotFound)
+		rw = api.send(roleSeller, http.MethodDelete, "/seller/products/4/images/9", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for another seller's product", func(t *testing.T) {
+		other := product(1)
+		other.SellerId = 8
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(other, nil)
+		rw := api.send(roleSeller, http.MethodDelete, "/seller/products/4/images/1", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for deleting removed images from storage", func(t *testing.T) {
+		store := &services.LocalStorage{Dir: t.TempDir(), BaseURL: "http://localhost:8081/files"}
+		assert.NoError(t, store.Put("product/2.png", strings.NewReader("png"), 3))
+		err := jobs.DeleteImages(store, []string{"http://localhost:8081/files/product/2.png", "https://example.com/kettle.png"})
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(store.Dir, "product", "2.png"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Test for sorting images", func(t *testing.T) {
+		p := &models.Product{Images: []models.Image{
+			{Model: gorm.Model{ID: 1}, Position: 2}, {Model: gorm.Model{ID: 2}, Position: 0}, {Model: gorm.Model{ID: 3}, Position: 0},
+		}}
+		p.SortImages()
+		assert.Equal(t, []uint{2, 3, 1}, []uint{p.Images[0].ID, p.Images[1].ID, p.Images[2].ID})
+	})
+}
+
+// pngImage encodes a plain picture of the given size as a png
+func pngImage(width, height int) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return buf.Bytes()
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProductStatus(t *testing.T) {
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, &buyer, &seller)
+	mockDB := api.DB
+	product := func(status string) *models.Product {
+		return &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, Title: "t-shirt", Price: 5000, Status: status}
+	}
+	publishAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	t.Run("Test for a draft on the product page", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(models.ProductDraft), nil)
+		rw := api.send("", http.MethodGet, "/product/4", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for scheduling a draft to publish", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(models.ProductActive), nil)
+		mockDB.EXPECT().UpdateProductStatus(uint(4), models.ProductDraft, gomock.Any(), nil).DoAndReturn(
+			func(productID uint, status string, at, unpublishAt *time.Time) (string, error) {
+				assert.True(t, publishAt.Equal(*at))
+				return status, nil
+			})
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/status",
+			fmt.Sprintf(`{"publish_at":%q}`, publishAt.Format(time.RFC3339)))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"status":"draft"`)
+		assert.Contains(t, rw.Body.String(), `"publish_at":"`+publishAt.Format(time.RFC3339))
+	})
+
+	t.Run("Test for archiving a product", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product(models.ProductActive), nil)
+		mockDB.EXPECT().UpdateProductStatus(uint(4), models.ProductArchived, nil, nil).Return(models.ProductArchived, nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/status", `{"status":"archived"}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for invalid schedules", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		for body, message := range map[string]string{
+			`{"status":"pending_review"}`: "status must be draft, active or archived",
+			fmt.Sprintf(`{"status":"active","publish_at":%q}`, publishAt.Format(time.RFC3339)): "only a draft can be scheduled to publish",
+			fmt.Sprintf(`{"publish_at":%q}`, past):                                             "publish_at must be in the future",
+			fmt.Sprintf(`{"publish_at":%q,"unpublish_at":%q}`, publishAt.Format(time.RFC3339),
+				publishAt.Add(-time.Minute).Format(time.RFC3339)): "unpublish_at must be after publish_at",
+		} {
+			mockDB.EXPECT().GetProductByID(uint(4)).Return(product(models.ProductActive), nil)
+			rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/status", body)
+			assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+			assert.Contains(t, rw.Body.String(), message, body)
+		}
+	})
+
+	t.Run("Test for publishing a listing waiting to be reviewed", func(t *testing.T) {
+		pending := product(models.ProductDraft)
+		pending.ModerationStatus = models.ModerationPending
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(pending, nil)
+		mockDB.EXPECT().UpdateProductStatus(uint(4), models.ProductActive, nil, nil).Return(models.ProductPendingReview, nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/status", `{"status":"active"}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"status":"pending_review"`)
+	})
+
+	t.Run("Test for publishing a rejected listing", func(t *testing.T) {
+		rejected := product(models.ProductDraft)
+		rejected.ModerationStatus = models.ModerationRejected
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(rejected, nil)
+		mockDB.EXPECT().UpdateProductStatus(uint(4), models.ProductActive, nil, nil).Return("", database.ErrListingRejected)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/status", `{"status":"active"}`)
+		assert.Equal(t, http.StatusConflict, rw.Code)
+		assert.Contains(t, rw.Body.String(), "edit it to have it reviewed again")
+	})
+
+	t.Run("Test for another seller's product", func(t *testing.T) {
+		other := product(models.ProductActive)
+		other.SellerId = 8
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(other, nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/status", `{"status":"archived"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for creating a draft", func(t *testing.T) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for field, value := range map[string]string{"category_id": "2", "title": "t-shirt", "price": "5000",
+			"rating": "0", "quantity": "3", "status": "draft"} {
+			_ = w.WriteField(field, value)
+		}
+		w.Close()
+
+		mockDB.EXPECT().GetCategoryAttributes(uint(2)).Return(nil, nil)
+		mockDB.EXPECT().CreateProduct(gomock.Any()).DoAndReturn(func(product models.Product) error {
+			assert.Equal(t, models.ProductDraft, product.Status)
+			assert.Nil(t, product.PublishAt)
+			return nil
+		})
+		rw := api.sendBody(roleSeller, http.MethodPost, "/createproduct", w.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for drafts in the seller's shop", func(t *testing.T) {
+		shop := func() *models.Seller {
+			return &models.Seller{Model: seller.Model, Product: []models.Product{
+				{Model: gorm.Model{ID: 4}, Title: "t-shirt", Quantity: 3, Status: models.ProductDraft},
+				{Model: gorm.Model{ID: 5}, Title: "hoodie", Quantity: 2, Status: models.ProductActive},
+			}}
+		}
+		mockDB.EXPECT().FindIndividualSellerShop(seller.ID).DoAndReturn(func(uint) (*models.Seller, error) {
+			return shop(), nil
+		}).Times(2)
+		rw := api.send("", http.MethodGet, "/seller/shop/7", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), "hoodie")
+		assert.NotContains(t, rw.Body.String(), "t-shirt")
+
+		rw = api.send(roleSeller, http.MethodGet, "/seller/total/product/count", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"Product_Quantity": 5`)
+	})
+
+	t.Run("Test for a seller without products", func(t *testing.T) {
+		mockDB.EXPECT().FindIndividualSellerShop(seller.ID).Return(&models.Seller{Model: seller.Model}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/total/product/count", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"Product_Count": 0`)
+	})
+
+	t.Run("Test for adding an unavailable product to the cart", func(t *testing.T) {
+		mockDB.EXPECT().AddToCart(gomock.Any(), nil, &buyer).Return(database.ErrProductUnavailable)
+		rw := api.send(roleBuyer, http.MethodPost, "/addtocart", `{"ID":4,"quantity":1}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "this product is not available")
+	})
+
+	t.Run("Test for checking out a cart with a product that is no longer available", func(t *testing.T) {
+		unavailable := fmt.Errorf("Kettle: %w", database.ErrProductUnavailable)
+		mockDB.EXPECT().GetCheckoutSummary(&buyer).Return(nil, unavailable).Times(2)
+		rw := api.send(roleBuyer, http.MethodGet, "/checkout/summary", "")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "Kettle: this product is not available")
+
+		rw = api.send(roleBuyer, http.MethodPost, "/pay", "")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "Kettle: this product is not available")
+	})
+}
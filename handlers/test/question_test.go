@@ -0,0 +1,122 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProductQuestions(t *testing.T) {
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com", FirstName: "Ada", LastName: "Obi"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com", FirstName: "Tunde"}}
+	api := newAPITest(t, &buyer, &seller)
+	mockDB := api.DB
+	product := &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, Title: "rice cooker"}
+	question := func() *models.ProductQuestion {
+		return &models.ProductQuestion{Model: gorm.Model{ID: 15}, ProductID: product.ID, Product: product, BuyerID: 9}
+	}
+
+	t.Run("Test for asking a question", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		mockDB.EXPECT().CreateProductQuestion(gomock.Any()).DoAndReturn(func(q *models.ProductQuestion) error {
+			assert.Equal(t, "Ada Obi", q.AskerName)
+			assert.Equal(t, "Does it have a steamer?", q.Body)
+			q.ID = 15
+			return nil
+		})
+		mockDB.EXPECT().CreateNotifications(gomock.Any()).DoAndReturn(func(notifications []models.Notification) error {
+			assert.Equal(t, models.RecipientSeller, notifications[0].RecipientType)
+			assert.Equal(t, seller.ID, notifications[0].RecipientID)
+			assert.Equal(t, "New question on rice cooker", notifications[0].Title)
+			return nil
+		})
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/products/4/questions", `{"body":"Does it have a steamer?"}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"answers":[]`)
+	})
+
+	t.Run("Test for a question that is too long", func(t *testing.T) {
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/products/4/questions", `{"body":"`+strings.Repeat("a", 1001)+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for the seller answering", func(t *testing.T) {
+		mockDB.EXPECT().GetProductQuestion(uint(15)).Return(question(), nil)
+		mockDB.EXPECT().CreateProductAnswer(gomock.Any()).DoAndReturn(func(a *models.ProductAnswer) error {
+			assert.Equal(t, models.RecipientSeller, a.AnswererType)
+			assert.Equal(t, "Tunde", a.AnswererName)
+			return nil
+		})
+		mockDB.EXPECT().CreateNotifications(gomock.Any()).DoAndReturn(func(notifications []models.Notification) error {
+			assert.Equal(t, uint(9), notifications[0].RecipientID)
+			assert.Equal(t, models.NotificationAnswer, notifications[0].Type)
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/questions/15/answers", `{"body":"Yes it does"}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+	})
+
+	t.Run("Test for another seller answering", func(t *testing.T) {
+		other := question()
+		other.Product = &models.Product{Model: gorm.Model{ID: 4}, SellerId: 8}
+		mockDB.EXPECT().GetProductQuestion(uint(15)).Return(other, nil)
+		rw := api.send(roleSeller, http.MethodPost, "/seller/questions/15/answers", `{"body":"Yes it does"}`)
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+	})
+
+	t.Run("Test for a buyer who has not bought the product answering", func(t *testing.T) {
+		mockDB.EXPECT().GetProductQuestion(uint(15)).Return(question(), nil)
+		mockDB.EXPECT().HasBoughtProduct(buyer.ID, uint(4)).Return(false, nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/questions/15/answers", `{"body":"I think so"}`)
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+	})
+
+	t.Run("Test for a verified buyer answering", func(t *testing.T) {
+		mockDB.EXPECT().GetProductQuestion(uint(15)).Return(question(), nil)
+		mockDB.EXPECT().HasBoughtProduct(buyer.ID, uint(4)).Return(true, nil)
+		mockDB.EXPECT().CreateProductAnswer(gomock.Any()).Return(nil)
+		mockDB.EXPECT().CreateNotifications(gomock.Any()).Return(nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/questions/15/answers", `{"body":"Mine did"}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+	})
+
+	t.Run("Test for upvoting an answer", func(t *testing.T) {
+		mockDB.EXPECT().SetAnswerUpvote(uint(30), buyer.ID, true).Return(int64(4), nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/answers/30/upvote", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"upvotes":4`)
+
+		mockDB.EXPECT().SetAnswerUpvote(uint(30), buyer.ID, false).Return(int64(3), nil)
+		rw = api.send(roleBuyer, http.MethodDelete, "/buyer/answers/30/upvote", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().SetAnswerUpvote(uint(31), buyer.ID, true).Return(int64(0), gorm.ErrRecordNotFound)
+		rw = api.send(roleBuyer, http.MethodPost, "/buyer/answers/31/upvote", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for listing a product's questions", func(t *testing.T) {
+		mockDB.EXPECT().GetProductQuestions(uint(4), 2, 10).Return(&models.ProductQuestionPage{
+			Questions: []models.ProductQuestion{{Body: "Does it have a steamer?", Answers: []models.ProductAnswer{{Body: "Yes it does", Upvotes: 4}}}},
+			Page:      2, Limit: 10, Total: 11,
+		}, nil)
+		rw := api.send("", http.MethodGet, "/product/4/questions?page=2", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"upvotes":4`)
+	})
+
+	t.Run("Test for the seller's unanswered questions", func(t *testing.T) {
+		mockDB.EXPECT().GetUnansweredQuestions(seller.ID, 1, 20).Return(&models.ProductQuestionPage{
+			Questions: []models.ProductQuestion{*question()}, Page: 1, Limit: 20, Total: 1,
+		}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/questions/unanswered", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"total":1`)
+		assert.NotContains(t, rw.Body.String(), "buyer_id")
+	})
+}
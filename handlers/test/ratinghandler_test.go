@@ -64,6 +64,10 @@ func TestSelerRating(t *testing.T) {
 	mockDB.EXPECT().FindBuyerByEmail("chuks@gmail.com").Return(&buyer, nil)
 	mockDB.EXPECT().FindSellerById(uint(1)).Return(&seller, nil)
 	mockDB.EXPECT().UpdateSellerRating(uint(0), &updateData).Return(nil)
+	mockDB.EXPECT().CreateNotifications(gomock.Any()).DoAndReturn(func(notifications []models.Notification) error {
+		assert.Equal(t, models.NotificationReview, notifications[0].Type)
+		return nil
+	})
 
 	ratingPayload, err := json.Marshal(rating)
 	if err != nil {
@@ -131,6 +135,7 @@ func TestProductRating(t *testing.T) {
 	mockDB.EXPECT().FindBuyerByEmail("chuks@gmail.com").Return(&buyer, nil)
 	mockDB.EXPECT().FindProductById(uint(1)).Return(&product, nil)
 	mockDB.EXPECT().UpdateProductRating(uint(0), &updateData).Return(nil)
+	mockDB.EXPECT().CreateNotifications(gomock.Any()).Return(nil)
 
 	ratingPayload, err := json.Marshal(rating)
 	if err != nil {
@@ -0,0 +1,202 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/realtime"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEventStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	hub := realtime.NewHub()
+	h := &handlers.Handler{DB: mockDB, Hub: hub}
+	route, _ := router.SetupRouter(h)
+	server := httptest.NewServer(route)
+	defer server.Close()
+
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com"}}
+	secret := os.Getenv("JWT_SECRET")
+	claims, _ := services.GenerateClaims(buyer.Email)
+	token, _ := services.GenerateToken(jwt.SigningMethodHS256, claims, &secret)
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil).AnyTimes()
+
+	open := func(ctx context.Context, path string, header bool) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1"+path, nil)
+		if header {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *token))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+	// next reads one event off the stream as "name data"
+	next := func(reader *bufio.Reader) string {
+		var event, data string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return ""
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				data = strings.TrimPrefix(line, "data:")
+			case line == "" && event != "":
+				return event + " " + data
+			}
+		}
+	}
+
+	t.Run("Test for pushing updates to the buyer's stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		resp, reader := open(ctx, "/buyer/events", true)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Contains(t, next(reader), "ready ")
+		assert.Equal(t, 1, hub.Count())
+
+		// another buyer's updates don't reach this stream
+		hub.Publish(models.RealtimeMessage{RecipientType: models.RecipientBuyer, RecipientID: 4, Event: models.RealtimeOrderStatus, Data: json.RawMessage(`{"order_id":9}`)})
+		hub.Publish(models.RealtimeMessage{RecipientType: models.RecipientBuyer, RecipientID: 3, Event: models.RealtimeOrderStatus, Data: json.RawMessage(`{"order_id":21,"status":"shipped"}`)})
+		assert.Equal(t, `order.status {"order_id":21,"status":"shipped"}`, next(reader))
+
+		cancel()
+		assert.Eventually(t, func() bool { return hub.Count() == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Test for the token in the query", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resp, reader := open(ctx, "/buyer/events?access_token="+*token, false)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, next(reader), "ready ")
+	})
+
+	t.Run("Test for a stream without a token", func(t *testing.T) {
+		resp, _ := open(context.Background(), "/buyer/events", false)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Test for too many streams", func(t *testing.T) {
+		hub.MaxPerRecipient = 1
+		defer func() { hub.MaxPerRecipient = realtime.DefaultMaxPerRecipient }()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		first, reader := open(ctx, "/buyer/events", true)
+		defer first.Body.Close()
+		next(reader)
+
+		second, _ := open(context.Background(), "/buyer/events", true)
+		second.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, second.StatusCode)
+	})
+
+	t.Run("Test for streams ending on shutdown", func(t *testing.T) {
+		resp, reader := open(context.Background(), "/buyer/events", true)
+		defer resp.Body.Close()
+		next(reader)
+		hub.Close()
+		assert.Equal(t, "", next(reader))
+	})
+}
+
+func TestEventStreamTokenNotLogged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	var logged strings.Builder
+	defer func(writer io.Writer) { gin.DefaultWriter = writer }(gin.DefaultWriter)
+	gin.DefaultWriter = &logged
+	route, _ := router.SetupRouter(&handlers.Handler{DB: mockDB, Hub: realtime.NewHub()})
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/buyer/events?access_token=secret.jwt.value&since=4", nil)
+	route.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Contains(t, logged.String(), "/api/v1/buyer/events?access_token=REDACTED&since=4")
+	assert.NotContains(t, logged.String(), "secret.jwt.value")
+}
+
+func TestRealtimeHub(t *testing.T) {
+	hub := realtime.NewHub()
+	hub.Buffer = 1
+	slow, _ := hub.Subscribe(models.RecipientSeller, 7)
+	fast, _ := hub.Subscribe(models.RecipientSeller, 7)
+
+	msg := models.RealtimeMessage{RecipientType: models.RecipientSeller, RecipientID: 7, Event: models.RealtimeOrderCreated}
+	hub.Publish(msg)
+	<-fast.Messages()
+	hub.Publish(msg)
+
+	// the slow client's buffer was full, so it is dropped and the fast one still gets the update
+	<-slow.Messages()
+	_, open := <-slow.Messages()
+	assert.False(t, open)
+	_, open = <-fast.Messages()
+	assert.True(t, open)
+	assert.Equal(t, 1, hub.Count())
+
+	hub.Unsubscribe(slow)
+	hub.Unsubscribe(fast)
+	hub.Unsubscribe(fast)
+	assert.Equal(t, 0, hub.Count())
+}
+
+func TestRealtimeOrderEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	bus := events.NewBus()
+	mockDB.EXPECT().EnqueueJob(gomock.Any()).Return(nil).AnyTimes()
+	assert.NoError(t, jobs.Register(jobs.NewRunner(mockDB), mockDB, nil, nil, bus))
+	// the buyer's inbox and the seller's webhooks get the update too
+	mockDB.EXPECT().CreateNotifications(gomock.Any()).Return(nil).AnyTimes()
+	mockDB.EXPECT().QueueWebhookDeliveries(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+
+	shipped, _ := json.Marshal(models.OrderEvent{OrderID: 21, SellerID: 7, BuyerID: 3, Status: models.OrderStatusShipped})
+	mockDB.EXPECT().PublishRealtime(gomock.Any()).DoAndReturn(func(messages []models.RealtimeMessage) error {
+		assert.Len(t, messages, 2)
+		assert.Equal(t, models.RecipientSeller, messages[0].RecipientType)
+		assert.Equal(t, uint(7), messages[0].RecipientID)
+		assert.Equal(t, models.RecipientBuyer, messages[1].RecipientType)
+		assert.Equal(t, uint(3), messages[1].RecipientID)
+		assert.Equal(t, models.RealtimeOrderStatus, messages[1].Event)
+		assert.JSONEq(t, string(shipped), string(messages[1].Data))
+		return nil
+	})
+	assert.NoError(t, bus.Publish(context.Background(), events.Event{ID: 5, Type: models.EventOrderShipped, Payload: shipped}))
+}
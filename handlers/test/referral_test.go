@@ -0,0 +1,199 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/database"
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestGetBuyerReferrals(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	buyer := models.Buyer{
+		Model:         gorm.Model{ID: 4},
+		User:          models.User{Email: "ada@yahoo.com"},
+		ReferralCode:  "ABCD2345",
+		WalletBalance: 500,
+	}
+	secret := os.Getenv("JWT_SECRET")
+	accClaims, _ := services.GenerateClaims(buyer.Email)
+	acc, _ := services.GenerateToken(jwt.SigningMethodHS256, accClaims, &secret)
+
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil).AnyTimes()
+
+	t.Run("Test for error getting referrals", func(t *testing.T) {
+		mockDB.EXPECT().GetBuyerReferrals(buyer.ID).Return(nil, errors.New("db error"))
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/buyer/referrals", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *acc))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		assert.Contains(t, rw.Body.String(), "unable to get referrals")
+	})
+
+	t.Run("Test for referrals and earnings", func(t *testing.T) {
+		referrals := []models.Referral{
+			{RefereeUsername: "tobi", Status: models.ReferralStatusRewarded, RewardAmount: 500},
+			{RefereeUsername: "kemi", Status: models.ReferralStatusPending},
+		}
+		mockDB.EXPECT().GetBuyerReferrals(buyer.ID).Return(referrals, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/buyer/referrals", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *acc))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"successful_referrals":1`)
+		assert.Contains(t, rw.Body.String(), `"total_earnings":500`)
+		assert.Contains(t, rw.Body.String(), "ABCD2345")
+	})
+}
+
+func TestBuyerSignUpWithInvalidReferralCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	buyer := models.BuyerSignup{
+		FirstName:   "tobi",
+		LastName:    "bello",
+		Email:       "tobi@yahoo.com",
+		Username:    "tobi",
+		Password:    "password",
+		PhoneNumber: "08033334444",
+		InviteCode:  "nope1234",
+	}
+	body, _ := json.Marshal(buyer)
+
+	mockDB.EXPECT().FindBuyerByUsername(buyer.Username).Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByPhone(buyer.PhoneNumber).Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByReferralCode("NOPE1234").Return(nil, errors.New("not found"))
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/buyersignup", strings.NewReader(string(body)))
+	route.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "invalid referral code")
+}
+
+func TestCheckReferral(t *testing.T) {
+	referrer := &models.Buyer{User: models.User{Email: "john.doe@gmail.com", PhoneNumber: "08012345678"}}
+
+	sameInbox := &models.Buyer{User: models.User{Email: "johndoe+2@gmail.com", PhoneNumber: "08099999999"}}
+	assert.Error(t, services.CheckReferral(referrer, sameInbox))
+
+	samePhone := &models.Buyer{User: models.User{Email: "jane@yahoo.com", PhoneNumber: "+2348012345678"}}
+	assert.Error(t, services.CheckReferral(referrer, samePhone))
+
+	companyReferrer := &models.Buyer{User: models.User{Email: "ops@acme.ng", PhoneNumber: "08011111111"}}
+	sameCompany := &models.Buyer{User: models.User{Email: "sales@acme.ng", PhoneNumber: "08022222222"}}
+	assert.Error(t, services.CheckReferral(companyReferrer, sameCompany))
+
+	friend := &models.Buyer{User: models.User{Email: "friend@gmail.com", PhoneNumber: "08033333333"}}
+	assert.NoError(t, services.CheckReferral(referrer, friend))
+}
+
+func TestBuyerSignUpWhenReferralCodesCantBeChecked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	buyer := models.Buyer{
+		User: models.User{
+			FirstName:   "tobi",
+			LastName:    "bello",
+			Email:       "tobi@yahoo.com",
+			Username:    "tobi",
+			Password:    "password",
+			PhoneNumber: "08033334444",
+		},
+	}
+	body, _ := json.Marshal(buyer)
+
+	mockDB.EXPECT().FindBuyerByUsername(buyer.Username).Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByPhone(buyer.PhoneNumber).Return(nil, errors.New("not found"))
+	// a code that can't be looked up may already be someone else's
+	mockDB.EXPECT().FindBuyerByReferralCode(gomock.Any()).Return(nil, errors.New("connection refused"))
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/buyersignup", strings.NewReader(string(body)))
+	route.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func TestBuyerSignUpSetsTheirOwnWallet(t *testing.T) {
+	api := newAPITest(t, nil, nil)
+	mockDB := api.DB
+
+	body := `{"first_name":"tobi","last_name":"bello","email":"tobi@yahoo.com","username":"tobi",` +
+		`"password":"password","phone_number":"08033334444","wallet_balance":50000,"cart_reminders_off":true}`
+
+	mockDB.EXPECT().FindBuyerByUsername("tobi").Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByEmail("tobi@yahoo.com").Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByPhone("08033334444").Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByReferralCode(gomock.Any()).Return(nil, gorm.ErrRecordNotFound)
+	mockDB.EXPECT().CreateBuyer(gomock.Any()).DoAndReturn(func(buyer *models.Buyer) (*models.Buyer, error) {
+		assert.Equal(t, uint(0), buyer.WalletBalance)
+		assert.False(t, buyer.CartRemindersOff)
+		assert.Equal(t, "tobi@yahoo.com", buyer.Email)
+		buyer.ID = 9
+		return buyer, nil
+	})
+	mockDB.EXPECT().CreateBuyerCart(gomock.Any()).Return(&models.Cart{}, nil)
+	mockDB.EXPECT().EnqueueEmail(gomock.Any()).Return(nil)
+
+	rw := api.send("", http.MethodPost, "/buyersignup", body)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+}
+
+func TestBuyerSignUpWhenTheirReferralCodeIsTaken(t *testing.T) {
+	api := newAPITest(t, nil, nil)
+	mockDB := api.DB
+
+	mockDB.EXPECT().FindBuyerByUsername("tobi").Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByEmail("tobi@yahoo.com").Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByPhone("08033334444").Return(nil, errors.New("not found"))
+	mockDB.EXPECT().FindBuyerByReferralCode(gomock.Any()).Return(nil, gorm.ErrRecordNotFound).Times(2)
+	// another signup took the code after it was checked
+	var codes []string
+	mockDB.EXPECT().CreateBuyer(gomock.Any()).DoAndReturn(func(buyer *models.Buyer) (*models.Buyer, error) {
+		codes = append(codes, buyer.ReferralCode)
+		if len(codes) == 1 {
+			return buyer, database.ErrReferralCodeTaken
+		}
+		return buyer, nil
+	}).Times(2)
+	mockDB.EXPECT().CreateBuyerCart(gomock.Any()).Return(&models.Cart{}, nil)
+	mockDB.EXPECT().EnqueueEmail(gomock.Any()).Return(nil)
+
+	rw := api.send("", http.MethodPost, "/buyersignup", `{"first_name":"tobi","last_name":"bello",`+
+		`"email":"tobi@yahoo.com","username":"tobi","password":"password","phone_number":"08033334444"}`)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Len(t, codes, 2)
+}
@@ -143,7 +143,7 @@ func TestSearchAllProduct(t *testing.T) {
 	}
 
 	t.Run("Testing for empty queries", func(t *testing.T) {
-		mockDB.EXPECT().SearchProduct("", "", "", "").Return(product, nil)
+		mockDB.EXPECT().SearchProduct("", "", "", "", nil).Return(product, nil)
 		rw := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/searchproducts", strings.NewReader(string(productJSON)))
 		route.ServeHTTP(rw, req)
@@ -154,7 +154,7 @@ func TestSearchAllProduct(t *testing.T) {
 	})
 
 	t.Run("Testing for error", func(t *testing.T) {
-		mockDB.EXPECT().SearchProduct("", "", category3.Name, "").Return(nil, err)
+		mockDB.EXPECT().SearchProduct("", "", category3.Name, "", nil).Return(nil, err)
 		rw := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/searchproducts?category=shoe", strings.NewReader(string(productJSON)))
 		route.ServeHTTP(rw, req)
@@ -165,7 +165,7 @@ func TestSearchAllProduct(t *testing.T) {
 	})
 
 	t.Run("Testing for queries", func(t *testing.T) {
-		mockDB.EXPECT().SearchProduct("3000", "8000", "shirt", "shirts").Return(productCat1, nil)
+		mockDB.EXPECT().SearchProduct("3000", "8000", "shirt", "shirts", nil).Return(productCat1, nil)
 		rw := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/searchproducts?category=shirt&lower-price=3000&upper-price=8000&name=shirts", strings.NewReader(string(productcat1JSON)))
 		route.ServeHTTP(rw, req)
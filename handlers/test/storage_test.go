@@ -0,0 +1,127 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorage(t *testing.T) {
+	store := &services.LocalStorage{Dir: t.TempDir(), BaseURL: "http://localhost:8081/files", Secret: "storagesecret"}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := &handlers.Handler{DB: mock_database.NewMockDB(ctrl), Storage: store}
+	route, _ := router.SetupRouter(h)
+	get := func(url string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, strings.TrimPrefix(url, "http://localhost:8081"), nil)
+		route.ServeHTTP(rw, req)
+		return rw
+	}
+	assert.NoError(t, store.Put("product/kettle.txt", strings.NewReader("a kettle"), 8))
+
+	t.Run("Test for serving a stored file", func(t *testing.T) {
+		url := store.URL("product/kettle.txt")
+		assert.Equal(t, "http://localhost:8081/files/product/kettle.txt", url)
+		key, ok := store.Key(url)
+		assert.True(t, ok)
+		assert.Equal(t, "product/kettle.txt", key)
+		_, ok = store.Key("https://example.com/product/kettle.txt")
+		assert.False(t, ok)
+
+		rw := get(url)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "a kettle", rw.Body.String())
+		assert.Equal(t, http.StatusNotFound, get("/files/product/missing.txt").Code)
+	})
+
+	t.Run("Test for signed urls", func(t *testing.T) {
+		url, err := store.SignedURL(http.MethodGet, "product/kettle.txt", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, get(url).Code)
+
+		assert.Equal(t, http.StatusForbidden, get(strings.Replace(url, "kettle", "other", 1)).Code)
+		expired, _ := store.SignedURL(http.MethodGet, "product/kettle.txt", -time.Minute)
+		assert.Equal(t, http.StatusForbidden, get(expired).Code)
+		put, _ := store.SignedURL(http.MethodPut, "product/kettle.txt", time.Minute)
+		assert.Equal(t, http.StatusForbidden, get(put).Code)
+	})
+
+	t.Run("Test for signed uploads", func(t *testing.T) {
+		url, err := store.SignedUpload("uploads/seller/7/lid.png", "image/png", 5, time.Minute)
+		assert.NoError(t, err)
+		put := func(url, contentType, body string) int {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, strings.TrimPrefix(url, "http://localhost:8081"), strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			route.ServeHTTP(rw, req)
+			return rw.Code
+		}
+		assert.Equal(t, http.StatusForbidden, put(url, "text/html", "a lid"))
+		assert.Equal(t, http.StatusForbidden, put(url, "image/png", "a bigger lid"))
+		assert.Equal(t, http.StatusForbidden, put(strings.Replace(url, "lid", "pot", 1), "image/png", "a lid"))
+		getURL, _ := store.SignedURL(http.MethodGet, "uploads/seller/7/lid.png", time.Minute)
+		assert.Equal(t, http.StatusForbidden, put(getURL, "image/png", "a lid"))
+		_, _, err = store.Get("uploads/seller/7/lid.png")
+		assert.ErrorIs(t, err, services.ErrFileNotFound)
+
+		assert.Equal(t, http.StatusOK, put(url, "image/png", "a lid"))
+		file, size, err := store.Get("uploads/seller/7/lid.png")
+		assert.NoError(t, err)
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, int64(5), size)
+		assert.Equal(t, "a lid", string(data))
+
+		// an upload is private until it is confirmed
+		assert.Equal(t, http.StatusNotFound, get(store.URL("uploads/seller/7/lid.png")).Code)
+		assert.Equal(t, http.StatusNotFound, get(getURL).Code)
+	})
+
+	t.Run("Test for deleting unconfirmed uploads", func(t *testing.T) {
+		deleted, err := store.DeleteOlderThan("missing", time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+
+		assert.NoError(t, store.Put("uploads/buyer/3/old.png", strings.NewReader("old"), 3))
+		assert.NoError(t, store.Put("uploads/buyer/3/new.png", strings.NewReader("new"), 3))
+		lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+		assert.NoError(t, os.Chtimes(filepath.Join(store.Dir, "uploads", "buyer", "3", "old.png"), lastWeek, lastWeek))
+
+		deleted, err = store.DeleteOlderThan(services.PendingUploads, time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+		_, _, err = store.Get("uploads/buyer/3/old.png")
+		assert.ErrorIs(t, err, services.ErrFileNotFound)
+		file, _, err := store.Get("uploads/buyer/3/new.png")
+		assert.NoError(t, err)
+		file.Close()
+		file, _, err = store.Get("product/kettle.txt")
+		assert.NoError(t, err)
+		file.Close()
+	})
+
+	t.Run("Test for keys outside the storage", func(t *testing.T) {
+		assert.Error(t, store.Put("../kettle.txt", strings.NewReader("a kettle"), 8))
+		assert.Error(t, store.Delete("product/../../kettle.txt"))
+		assert.Equal(t, http.StatusNotFound, get("/files/product/..%2F..%2Fkettle.txt").Code)
+	})
+
+	t.Run("Test for deleting a file", func(t *testing.T) {
+		assert.NoError(t, store.Delete("product/kettle.txt"))
+		assert.NoError(t, store.Delete("product/kettle.txt"))
+		assert.Equal(t, http.StatusNotFound, get("/files/product/kettle.txt").Code)
+	})
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/router"
+	"github.com/decadevs/shoparena/services"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTaxRuleApply(t *testing.T) {
+	exclusive := models.TaxRule{Name: "VAT", RateBasisPoints: 750}
+	line := exclusive.Apply(1000)
+	assert.Equal(t, uint(1000), line.Net)
+	assert.Equal(t, uint(75), line.Tax)
+	assert.Equal(t, uint(1075), line.Gross)
+
+	inclusive := models.TaxRule{Name: "VAT", RateBasisPoints: 750, Inclusive: true}
+	line = inclusive.Apply(1075)
+	assert.Equal(t, uint(1000), line.Net)
+	assert.Equal(t, uint(75), line.Tax)
+	assert.Equal(t, uint(1075), line.Gross)
+
+	exempt := models.TaxRule{Name: "VAT", RateBasisPoints: 750, Exempt: true}
+	line = exempt.Apply(1000)
+	assert.Equal(t, uint(0), line.Tax)
+	assert.Equal(t, uint(1000), line.Gross)
+}
+
+func TestBuildCheckoutSummary(t *testing.T) {
+	rules := models.TaxRules{
+		{Name: "VAT", RateBasisPoints: 750},
+		{CategoryID: 6, Name: "VAT", Exempt: true},
+	}
+	products := map[uint]models.Product{
+		1: {Model: gorm.Model{ID: 1}, CategoryId: 2, SellerId: 9, Title: "phone"},
+		2: {Model: gorm.Model{ID: 2}, CategoryId: 6, SellerId: 9, Title: "rice"},
+	}
+	cart := []models.CartProduct{
+		{ProductID: 1, TotalPrice: 2000, TotalQuantity: 1},
+		{ProductID: 2, TotalPrice: 1000, TotalQuantity: 2},
+	}
+
+	summary := models.BuildCheckoutSummary(cart, products, rules, nil)
+	assert.Equal(t, uint(3000), summary.Subtotal)
+	assert.Equal(t, uint(150), summary.Tax)
+	assert.Equal(t, uint(3150), summary.Total)
+	assert.Len(t, summary.TaxLines, 2)
+	assert.Equal(t, uint(9), summary.Lines[0].SellerID)
+
+	coupon := &models.Coupon{Code: "CART-MFRGG2LT", PercentOff: 10}
+	summary = models.BuildCheckoutSummary(cart, products, rules, coupon)
+	assert.Equal(t, uint(300), summary.Discount)
+	assert.Equal(t, uint(2700), summary.Subtotal)
+	assert.Equal(t, uint(135), summary.Tax)
+	assert.Equal(t, uint(2835), summary.Total)
+	assert.Equal(t, "CART-MFRGG2LT", summary.CouponCode)
+
+	// referral rewards pay what they can of the total, tax included
+	summary.ApplyWallet(500)
+	assert.Equal(t, uint(500), summary.WalletCredit)
+	assert.Equal(t, uint(2335), summary.Total)
+
+	summary = models.BuildCheckoutSummary(cart, products, rules, nil)
+	summary.ApplyWallet(5000)
+	assert.Equal(t, uint(3150), summary.WalletCredit)
+	assert.Equal(t, uint(0), summary.Total)
+}
+
+func TestAdminTaxRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	os.Setenv("ADMIN_TOKEN", "admintoken")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	t.Run("Test for missing admin token", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/taxrules", nil)
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	})
+
+	t.Run("Test for saving an exempt category", func(t *testing.T) {
+		mockDB.EXPECT().SaveTaxRule(&models.TaxRule{CategoryID: 6, Name: "VAT", Exempt: true}).
+			DoAndReturn(func(rule *models.TaxRule) (*models.TaxRule, error) { return rule, nil })
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/taxrules",
+			strings.NewReader(`{"category_id":6,"name":"VAT","exempt":true}`))
+		req.Header.Set("X-Admin-Token", "admintoken")
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), "tax rule saved successfully")
+	})
+}
+
+func TestSellerSalesReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+	h := &handlers.Handler{DB: mockDB}
+	route, _ := router.SetupRouter(h)
+
+	seller := models.Seller{Model: gorm.Model{ID: 9}, User: models.User{Email: "shop@yahoo.com"}}
+	secret := os.Getenv("JWT_SECRET")
+	accClaims, _ := services.GenerateClaims(seller.Email)
+	acc, _ := services.GenerateToken(jwt.SigningMethodHS256, accClaims, &secret)
+
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindSellerByEmail(seller.Email).Return(&seller, nil).AnyTimes()
+
+	t.Run("Test for bad date", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/seller/salesreport?from=yesterday", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *acc))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("Test for report error", func(t *testing.T) {
+		mockDB.EXPECT().GetSellerSalesReport(seller.ID, gomock.Any(), gomock.Any()).Return(nil, errors.New("db error"))
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/seller/salesreport", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *acc))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	})
+
+	t.Run("Test for report with tax", func(t *testing.T) {
+		from := time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local)
+		to := time.Date(2022, 5, 31, 0, 0, 0, 0, time.Local)
+		report := &models.SalesReport{From: "2022-05-01", To: "2022-05-31", OrderCount: 2, Net: 3000, Tax: 225, Gross: 3225}
+		mockDB.EXPECT().GetSellerSalesReport(seller.ID, from, to).Return(report, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/seller/salesreport?from=2022-05-01&to=2022-05-31", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *acc))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"tax":225`)
+	})
+}
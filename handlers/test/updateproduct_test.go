@@ -36,6 +36,7 @@ func TestUpdateProduct(t *testing.T) {
 	route, _ := router.SetupRouter(h)
 
 	seller := models.Seller{
+		Model: gorm.Model{ID: 2},
 		User: models.User{
 			Model: gorm.Model{
 				ID: 23,
@@ -87,12 +88,15 @@ func TestUpdateProduct(t *testing.T) {
 		t.Fail()
 	}
 
-	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false)
-	mockDB.EXPECT().FindSellerByEmail(seller.Email).Return(&seller, nil)
+	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
+	mockDB.EXPECT().FindSellerByEmail(seller.Email).Return(&seller, nil).AnyTimes()
 
 	t.Run("Testing for valid update", func(t *testing.T) {
 		mockDB.EXPECT().GetProductByID(product.ID).Return(product, nil)
-		mockDB.EXPECT().UpdateProductByID(uint(4), prod)
+		// the title and description changed, so the listing goes back in the moderation queue
+		expected := prod
+		expected.Reviews = []models.ListingReview{models.NewListingReview(models.ReviewEditedListing, nil)}
+		mockDB.EXPECT().UpdateProductByID(uint(4), expected)
 		rw := httptest.NewRecorder()
 		req, err := http.NewRequest(http.MethodPut,
 			"/api/v1/update/product/4",
@@ -106,4 +110,15 @@ func TestUpdateProduct(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rw.Code)
 		assert.Contains(t, rw.Body.String(), "product updated successfully")
 	})
+
+	t.Run("Testing for another seller's product", func(t *testing.T) {
+		other := *product
+		other.SellerId = 9
+		mockDB.EXPECT().GetProductByID(product.ID).Return(&other, nil)
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/update/product/4", strings.NewReader(string(prodJSON)))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *acc))
+		route.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
 }
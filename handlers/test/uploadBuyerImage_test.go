@@ -59,9 +59,17 @@ func prepfile(file *os.File) (*bytes.Buffer, string, error) {
 	w := multipart.NewWriter(&b)
 	defer w.Close()
 	fmt.Println(file.Name())
-	if _, err := w.CreateFormFile("profile_picture", file.Name()); err != nil {
+	part, err := w.CreateFormFile("profile_picture", file.Name())
+	if err != nil {
 		return nil, "", fmt.Errorf("%v", err)
 	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", err
+	}
 	fmt.Println(w.FormDataContentType())
 	return &b, w.FormDataContentType(), nil
 }
@@ -70,7 +78,8 @@ func TestUploadprofielpic(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockDB := mock_database.NewMockDB(ctrl)
-	h := &handlers.Handler{DB: mockDB}
+	mockStorage := mock_database.NewMockStorage(ctrl)
+	h := &handlers.Handler{DB: mockDB, Storage: mockStorage}
 	route, _ := router.SetupRouter(h)
 
 	accessClaims, _ := services.GenerateClaims("ceciliaorji@yahoo.com")
@@ -99,10 +108,11 @@ func TestUploadprofielpic(t *testing.T) {
 
 	mockDB.EXPECT().FindBuyerByEmail(buyer.Email).Return(&buyer, nil).AnyTimes()
 	mockDB.EXPECT().TokenInBlacklist(gomock.Any()).Return(false).AnyTimes()
-	mockDB.EXPECT().UploadFileToS3(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(buyer.Image, nil).AnyTimes()
+	mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockStorage.EXPECT().URL(gomock.Any()).Return(buyer.Image).AnyTimes()
 
 	t.Run("Test Upload buyer profile pic image to DB", func(t *testing.T) {
-		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, buyer.Image, buyer.ID).Return(errors.New("error exist"))
+		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, buyer.Image, buyer.ID, gomock.Any()).Return(errors.New("error exist"))
 		b, content_type, err := prepfile(file)
 		if err != nil {
 			fmt.Errorf("%v", err)
@@ -117,7 +127,7 @@ func TestUploadprofielpic(t *testing.T) {
 	})
 
 	t.Run("Test Upload profile pic error", func(t *testing.T) {
-		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, buyer.Image, buyer.ID).Return(errors.New("error exist"))
+		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, buyer.Image, buyer.ID, gomock.Any()).Return(errors.New("error exist"))
 		b, content_type, err := prepfile(file)
 		if err != nil {
 			fmt.Errorf("%v", err)
@@ -133,7 +143,7 @@ func TestUploadprofielpic(t *testing.T) {
 	})
 
 	t.Run("Test Upload profile pic success", func(t *testing.T) {
-		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, buyer.Image, buyer.ID).Return(nil)
+		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, buyer.Image, buyer.ID, gomock.Any()).Return(nil)
 		b, content_type, err := prepfile(file)
 		if err != nil {
 			fmt.Errorf("%v", err)
@@ -146,5 +156,21 @@ func TestUploadprofielpic(t *testing.T) {
 		assert.Equal(t, http.StatusOK, 200)
 		log.Println("response here", resp.Body.String())
 		assert.Contains(t, resp.Body.String(), buyer.Image)
+		assert.Contains(t, resp.Body.String(), `"thumbnail_webp"`)
+	})
+
+	t.Run("Test Upload profile pic that isn't an image", func(t *testing.T) {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		part, _ := w.CreateFormFile("profile_picture", "avatar.png")
+		_, _ = part.Write([]byte("<script>alert(1)</script>"))
+		w.Close()
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/uploadbuyerpic", &b)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *accToken))
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		route.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "only png, jpeg and webp images are supported")
 	})
 }
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDirectUploads(t *testing.T) {
+	buyer := models.Buyer{Model: gorm.Model{ID: 23}, User: models.User{Username: "cece", Email: "cece@yahoo.com"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Username: "kettles", Email: "seller@yahoo.com"}}
+	api := newAPITest(t, &buyer, &seller)
+	mockDB := api.DB
+	mockStorage := api.Storage
+	mockStorage.EXPECT().URL(gomock.Any()).DoAndReturn(func(key string) string {
+		return "https://bucket.s3.amazonaws.com/" + key
+	}).AnyTimes()
+	uploaded := func(key string, data []byte) {
+		mockStorage.EXPECT().Get(key).Return(io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil)
+	}
+
+	t.Run("Test for creating an upload", func(t *testing.T) {
+		mockStorage.EXPECT().SignedUpload(gomock.Any(), "image/png", int64(48213), gomock.Any()).
+			DoAndReturn(func(key, contentType string, size int64, _ interface{}) (string, error) {
+				assert.Regexp(t, `^uploads/seller/7/[0-9a-f-]+\.png$`, key)
+				return "https://bucket.s3.amazonaws.com/" + key + "?X-Amz-Signature=abc", nil
+			})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/uploads", `{"content_type":"image/png","size":48213}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		var body struct {
+			Data struct {
+				Key       string            `json:"key"`
+				UploadURL string            `json:"upload_url"`
+				Method    string            `json:"method"`
+				Headers   map[string]string `json:"headers"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+		assert.True(t, strings.HasPrefix(body.Data.UploadURL, "https://bucket.s3.amazonaws.com/"+body.Data.Key+"?"))
+		assert.Equal(t, http.MethodPut, body.Data.Method)
+		assert.Equal(t, map[string]string{"Content-Type": "image/png", "Content-Length": "48213"}, body.Data.Headers)
+	})
+
+	t.Run("Test for uploads that aren't allowed", func(t *testing.T) {
+		rw := api.send(roleBuyer, http.MethodPost, "/buyer/uploads", `{"content_type":"image/gif","size":100}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "only png, jpeg and webp images are supported")
+
+		rw = api.send(roleBuyer, http.MethodPost, "/buyer/uploads", `{"content_type":"image/jpeg","size":3000000}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "size must be between 1 and 2048000 bytes")
+	})
+
+	t.Run("Test for confirming a profile picture", func(t *testing.T) {
+		key := "uploads/buyer/23/avatar.png"
+		uploaded(key, pngImage(300, 300))
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(7)
+		mockStorage.EXPECT().Delete(key).Return(nil)
+		mockDB.EXPECT().UpdateBuyerImageURL(buyer.Username, gomock.Any(), buyer.ID, gomock.Any()).
+			DoAndReturn(func(username, url string, id uint, variants models.ImageVariants) error {
+				assert.Regexp(t, `^https://bucket\.s3\.amazonaws\.com/profile_picture/[0-9a-f-]+\.png$`, url)
+				assert.Len(t, variants, 6)
+				return nil
+			})
+		rw := api.send(roleBuyer, http.MethodPut, "/uploadbuyerpic/confirm", fmt.Sprintf(`{"key":"%s"}`, key))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"thumbnail_webp"`)
+	})
+
+	t.Run("Test for confirming someone else's upload", func(t *testing.T) {
+		for _, key := range []string{"uploads/buyer/24/avatar.png", "uploads/buyer/23/../24/avatar.png", "product/avatar.png"} {
+			rw := api.send(roleBuyer, http.MethodPut, "/uploadbuyerpic/confirm", fmt.Sprintf(`{"key":"%s"}`, key))
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Contains(t, rw.Body.String(), "upload not found")
+		}
+	})
+
+	t.Run("Test for confirming an upload that wasn't made", func(t *testing.T) {
+		mockStorage.EXPECT().Get("uploads/seller/7/avatar.png").Return(nil, int64(0), services.ErrFileNotFound)
+		rw := api.send(roleSeller, http.MethodPut, "/uploadsellerpic/confirm", `{"key":"uploads/seller/7/avatar.png"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "upload not found")
+	})
+
+	t.Run("Test for confirming an upload that isn't an image", func(t *testing.T) {
+		uploaded("uploads/seller/7/avatar.png", []byte("<html></html>"))
+		mockStorage.EXPECT().Delete("uploads/seller/7/avatar.png").Return(nil)
+		rw := api.send(roleSeller, http.MethodPut, "/uploadsellerpic/confirm", `{"key":"uploads/seller/7/avatar.png"}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "avatar.png: only png, jpeg and webp images are supported")
+	})
+
+	t.Run("Test for the seller picture routes", func(t *testing.T) {
+		// they used to sit behind buyer auth, where the handler never found a seller
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		_ = form.WriteField("name", "kettles")
+		form.Close()
+		rw := api.sendBody(roleSeller, http.MethodPut, "/uploadsellerpic", form.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "image not supplied")
+
+		mockDB.EXPECT().FindSellerByEmail(buyer.Email).Return(nil, gorm.ErrRecordNotFound).Times(2)
+		rw = api.send(roleBuyer, http.MethodPut, "/uploadsellerpic", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		rw = api.send(roleBuyer, http.MethodPut, "/uploadsellerpic/confirm", `{"key":"uploads/buyer/23/avatar.png"}`)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for an upload that can't be read", func(t *testing.T) {
+		mockStorage.EXPECT().Get("uploads/seller/7/avatar.png").Return(nil, int64(0), errors.New("connection reset"))
+		rw := api.send(roleSeller, http.MethodPut, "/uploadsellerpic/confirm", `{"key":"uploads/seller/7/avatar.png"}`)
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	})
+
+	product := &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, Title: "Kettle", Status: models.ProductActive,
+		Images: []models.Image{{Model: gorm.Model{ID: 1}, ProductId: 4, Url: "https://bucket.s3.amazonaws.com/product/1.png"}}}
+
+	t.Run("Test for confirming product images", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		uploaded("uploads/seller/7/side.png", pngImage(40, 30))
+		uploaded("uploads/seller/7/top.png", pngImage(30, 40))
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(14)
+		mockStorage.EXPECT().Delete("uploads/seller/7/side.png").Return(nil)
+		mockStorage.EXPECT().Delete("uploads/seller/7/top.png").Return(nil)
+		mockDB.EXPECT().AddProductImages(uint(4), gomock.Any()).DoAndReturn(func(id uint, images []models.Image) error {
+			assert.Len(t, images, 2)
+			assert.Equal(t, "Kettle from the side", images[0].AltText)
+			assert.Equal(t, []int{1, 2}, []int{images[0].Position, images[1].Position})
+			assert.Equal(t, []int{30, 40}, []int{images[1].Width, images[1].Height})
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/products/4/images/confirm",
+			`{"keys":["uploads/seller/7/side.png","uploads/seller/7/top.png"],"alt_text":["Kettle from the side"]}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"medium_webp"`)
+	})
+
+	t.Run("Test for confirming product images when one is missing", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		uploaded("uploads/seller/7/side.png", pngImage(40, 30))
+		mockStorage.EXPECT().Get("uploads/seller/7/top.png").Return(nil, int64(0), services.ErrFileNotFound)
+		mockStorage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(7)
+		mockStorage.EXPECT().Delete("uploads/seller/7/side.png").Return(nil)
+		mockDB.EXPECT().EnqueueJob(gomock.Any()).DoAndReturn(func(job *models.Job) error {
+			assert.Equal(t, jobs.TypeImagesDelete, job.Type)
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/products/4/images/confirm",
+			`{"keys":["uploads/seller/7/side.png","uploads/seller/7/top.png"]}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "upload not found")
+	})
+
+	t.Run("Test for confirming too many product images", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		keys, _ := json.Marshal(strings.Split(strings.Repeat("uploads/seller/7/a.png,", 8), ",")[:8])
+		rw := api.send(roleSeller, http.MethodPost, "/seller/products/4/images/confirm", fmt.Sprintf(`{"keys":%s}`, keys))
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "a product can have at most 8 images")
+	})
+}
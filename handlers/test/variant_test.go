@@ -0,0 +1,93 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProductVariants(t *testing.T) {
+	buyer := models.Buyer{Model: gorm.Model{ID: 3}, User: models.User{Email: "ada@yahoo.com"}}
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, &buyer, &seller)
+	mockDB := api.DB
+	product := &models.Product{Model: gorm.Model{ID: 4}, SellerId: seller.ID, Title: "t-shirt", Price: 5000}
+	matrix := `{"options":[{"name":"Size","values":["S","M"]},{"name":"Colour","values":["Red"]}],
+		"variants":[{"sku":"TS-S-RED","options":{"Size":"S","Colour":"Red"},"quantity":3},
+		{"sku":"TS-M-RED","options":{"Size":"M","Colour":"Red"},"price":5500,"quantity":2}]}`
+
+	t.Run("Test for saving a variant matrix", func(t *testing.T) {
+		price := uint(5500)
+		saved := *product
+		saved.Quantity = 5
+		saved.Variants = []models.ProductVariant{
+			{ID: 1, SKU: "TS-S-RED", Title: "S / Red", Quantity: 3},
+			{ID: 2, SKU: "TS-M-RED", Title: "M / Red", Price: &price, Quantity: 2},
+		}
+		assert.NoError(t, saved.AfterFind(nil))
+
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		mockDB.EXPECT().SaveProductVariants(uint(4), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(productID uint, options []models.ProductOption, variants []models.ProductVariant) error {
+				assert.Len(t, options, 2)
+				assert.Equal(t, 1, options[1].Position)
+				assert.Equal(t, `["S","M"]`, options[0].ValueList)
+				assert.Equal(t, "M / Red", variants[1].Title)
+				assert.Equal(t, uint(5500), *variants[1].Price)
+				return nil
+			})
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(&saved, nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/variants", matrix)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"title":"S / Red"`)
+		assert.Contains(t, rw.Body.String(), `"unit_price":5000`)
+		assert.Contains(t, rw.Body.String(), `"unit_price":5500`)
+	})
+
+	t.Run("Test for an invalid variant matrix", func(t *testing.T) {
+		for _, body := range []string{
+			`{"options":[{"name":"Size","values":["S"]}],"variants":[{"sku":"A","options":{"Size":"XL"}}]}`,
+			`{"options":[{"name":"Size","values":["S"]}],"variants":[{"sku":"A","options":{"Size":"S"}},{"sku":"A","options":{"Size":"S"}}]}`,
+			`{"options":[{"name":"Size","values":["S"]}],"variants":[{"sku":"A","options":{"Size":"S"}},{"sku":"B","options":{"Size":"S"}}]}`,
+			`{"options":[{"name":"Size","values":[]}]}`,
+			`{"variants":[{"sku":"A","options":{}}]}`,
+		} {
+			mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+			rw := api.send(roleSeller, http.MethodPut, "/seller/products/4/variants", body)
+			assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+		}
+	})
+
+	t.Run("Test for another seller's product", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(5)).Return(&models.Product{Model: gorm.Model{ID: 5}, SellerId: 8}, nil)
+		rw := api.send(roleSeller, http.MethodPut, "/seller/products/5/variants", matrix)
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for images on a variant the product doesn't have", func(t *testing.T) {
+		mockDB.EXPECT().GetProductByID(uint(4)).Return(product, nil)
+		rw := api.send(roleSeller, http.MethodPost, "/seller/products/4/variants/9/images", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for adding a variant to the cart", func(t *testing.T) {
+		variantID := uint(2)
+		mockDB.EXPECT().AddToCart(gomock.Any(), &variantID, &buyer).Return(nil)
+		rw := api.send(roleBuyer, http.MethodPost, "/addtocart", `{"ID":4,"variant_id":2}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		mockDB.EXPECT().AddToCart(gomock.Any(), nil, &buyer).Return(database.ErrVariantRequired)
+		rw = api.send(roleBuyer, http.MethodPost, "/addtocart", `{"ID":4}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "choose a variant")
+
+		mockDB.EXPECT().AddToCart(gomock.Any(), &variantID, &buyer).Return(database.ErrOutOfStock)
+		rw = api.send(roleBuyer, http.MethodPost, "/addtocart", `{"ID":4,"variant_id":2}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+}
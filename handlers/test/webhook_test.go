@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mock_database "github.com/decadevs/shoparena/database/mocks"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/webhooks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSellerWebhooks(t *testing.T) {
+	seller := models.Seller{Model: gorm.Model{ID: 7}, User: models.User{Email: "seller@yahoo.com"}}
+	api := newAPITest(t, nil, &seller)
+	mockDB := api.DB
+
+	t.Run("Test for creating a webhook", func(t *testing.T) {
+		mockDB.EXPECT().CreateWebhookEndpoint(gomock.Any()).DoAndReturn(func(endpoint *models.WebhookEndpoint) error {
+			assert.Equal(t, seller.ID, endpoint.SellerID)
+			assert.Equal(t, "OrderPaid,OrderShipped", endpoint.EventTypes)
+			assert.True(t, endpoint.Active)
+			assert.True(t, strings.HasPrefix(endpoint.Secret, "whsec_"))
+			endpoint.ID = 4
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPost, "/seller/webhooks",
+			`{"url":"https://shop.example.com/hooks","events":["OrderPaid","OrderShipped"]}`)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"secret":"whsec_`)
+	})
+
+	t.Run("Test for a webhook with a bad url or event", func(t *testing.T) {
+		rw := api.send(roleSeller, http.MethodPost, "/seller/webhooks", `{"url":"ftp://shop.example.com","events":["UserDeleted"]}`)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Contains(t, rw.Body.String(), "url must be an absolute http or https url")
+		assert.Contains(t, rw.Body.String(), "unknown event UserDeleted")
+	})
+
+	t.Run("Test for listing webhooks without their secrets", func(t *testing.T) {
+		endpoint := models.WebhookEndpoint{Model: gorm.Model{ID: 4}, SellerID: seller.ID, URL: "https://shop.example.com/hooks", Secret: "whsec_abc", Active: true}
+		endpoint.SetEvents([]string{models.EventOrderPaid})
+		mockDB.EXPECT().GetSellerWebhookEndpoints(seller.ID).Return([]models.WebhookEndpoint{endpoint}, nil)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/webhooks", "")
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"events":["OrderPaid"]`)
+		assert.NotContains(t, rw.Body.String(), "whsec_abc")
+	})
+
+	t.Run("Test for disabling a webhook", func(t *testing.T) {
+		mockDB.EXPECT().GetWebhookEndpoint(uint(4), seller.ID).Return(&models.WebhookEndpoint{Model: gorm.Model{ID: 4}, SellerID: seller.ID, Active: true}, nil)
+		mockDB.EXPECT().UpdateWebhookEndpoint(gomock.Any()).DoAndReturn(func(endpoint *models.WebhookEndpoint) error {
+			assert.False(t, endpoint.Active)
+			assert.Equal(t, models.EventProductStockChanged, endpoint.EventTypes)
+			return nil
+		})
+		rw := api.send(roleSeller, http.MethodPut, "/seller/webhooks/4",
+			`{"url":"https://shop.example.com/hooks","events":["ProductStockChanged"],"active":false}`)
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("Test for another seller's webhook", func(t *testing.T) {
+		mockDB.EXPECT().GetWebhookEndpoint(uint(9), seller.ID).Return(nil, gorm.ErrRecordNotFound)
+		rw := api.send(roleSeller, http.MethodGet, "/seller/webhooks/9/deliveries", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+
+		mockDB.EXPECT().DeleteWebhookEndpoint(uint(9), seller.ID).Return(gorm.ErrRecordNotFound)
+		rw = api.send(roleSeller, http.MethodDelete, "/seller/webhooks/9", "")
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+	})
+
+	t.Run("Test for replaying a delivery", func(t *testing.T) {
+		mockDB.EXPECT().GetWebhookEndpoint(uint(4), seller.ID).Return(&models.WebhookEndpoint{Model: gorm.Model{ID: 4}, SellerID: seller.ID}, nil)
+		original := uint(30)
+		mockDB.EXPECT().ReplayWebhookDelivery(uint(30), uint(4)).
+			Return(&models.WebhookDelivery{Model: gorm.Model{ID: 31}, EndpointID: 4, ReplayOfID: &original, Status: models.DeliveryStatusPending}, nil)
+		rw := api.send(roleSeller, http.MethodPost, "/seller/webhooks/4/deliveries/30/replay", "")
+		assert.Equal(t, http.StatusAccepted, rw.Code)
+		assert.Contains(t, rw.Body.String(), `"replay_of_id":30`)
+	})
+}
+
+func TestWebhookSender(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	var body []byte
+	var header http.Header
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("received"))
+	}))
+	defer server.Close()
+
+	sender := &webhooks.Sender{Store: mockDB, Client: server.Client(), BatchSize: 10, MaxAttempts: 2}
+	endpoint := models.WebhookEndpoint{Model: gorm.Model{ID: 4}, URL: server.URL, Secret: "whsec_abc", Active: true}
+	delivery := func(attempts uint) models.WebhookDelivery {
+		return models.WebhookDelivery{
+			Model: gorm.Model{ID: 12}, EndpointID: 4, Endpoint: endpoint, EventID: 40,
+			EventType: models.EventOrderPaid, Payload: `{"id":40,"type":"OrderPaid"}`,
+			Status: models.DeliveryStatusPending, Attempts: attempts,
+		}
+	}
+
+	t.Run("Test for a signed delivery", func(t *testing.T) {
+		mockDB.EXPECT().ClaimWebhookDeliveries(10).Return([]models.WebhookDelivery{delivery(0)}, nil)
+		mockDB.EXPECT().SaveWebhookDelivery(gomock.Any()).DoAndReturn(func(d *models.WebhookDelivery) error {
+			assert.Equal(t, models.DeliveryStatusSucceeded, d.Status)
+			assert.Equal(t, http.StatusOK, d.ResponseStatus)
+			assert.Equal(t, "received", d.ResponseBody)
+			assert.NotNil(t, d.DeliveredAt)
+			return nil
+		})
+		count, err := sender.Flush(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		assert.Equal(t, `{"id":40,"type":"OrderPaid"}`, string(body))
+		assert.Equal(t, models.EventOrderPaid, header.Get("X-Oja-Event"))
+		assert.Equal(t, "12", header.Get("X-Oja-Delivery"))
+		signature := header.Get(events.SignatureHeader)
+		timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, events.Sign("whsec_abc", time.Unix(timestamp, 0), body), signature)
+	})
+
+	t.Run("Test for retrying then failing a delivery", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		mockDB.EXPECT().ClaimWebhookDeliveries(10).Return([]models.WebhookDelivery{delivery(0)}, nil)
+		mockDB.EXPECT().SaveWebhookDelivery(gomock.Any()).DoAndReturn(func(d *models.WebhookDelivery) error {
+			assert.Equal(t, models.DeliveryStatusPending, d.Status)
+			assert.Equal(t, "endpoint responded 500", d.LastError)
+			assert.WithinDuration(t, time.Now().Add(webhooks.RetryDelay(1)), d.NextAttemptAt, 5*time.Second)
+			return nil
+		})
+		count, err := sender.Flush(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		mockDB.EXPECT().ClaimWebhookDeliveries(10).Return([]models.WebhookDelivery{delivery(1)}, nil)
+		mockDB.EXPECT().SaveWebhookDelivery(gomock.Any()).DoAndReturn(func(d *models.WebhookDelivery) error {
+			assert.Equal(t, models.DeliveryStatusFailed, d.Status)
+			assert.Equal(t, uint(2), d.Attempts)
+			return nil
+		})
+		_, err = sender.Flush(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("Test for a delivery to a deleted endpoint", func(t *testing.T) {
+		deleted := delivery(0)
+		deleted.Endpoint = models.WebhookEndpoint{}
+		body = nil
+		mockDB.EXPECT().ClaimWebhookDeliveries(10).Return([]models.WebhookDelivery{deleted}, nil)
+		mockDB.EXPECT().SaveWebhookDelivery(gomock.Any()).DoAndReturn(func(d *models.WebhookDelivery) error {
+			assert.Equal(t, models.DeliveryStatusFailed, d.Status)
+			assert.Equal(t, "endpoint was deleted", d.LastError)
+			return nil
+		})
+		_, err := sender.Flush(context.Background())
+		assert.NoError(t, err)
+		assert.Nil(t, body)
+	})
+}
+
+func TestWebhookFanout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDB := mock_database.NewMockDB(ctrl)
+
+	bus := events.NewBus()
+	webhooks.Subscribe(bus, mockDB)
+
+	payload, _ := json.Marshal(models.OrderEvent{OrderID: 21, SellerID: 7})
+	mockDB.EXPECT().QueueWebhookDeliveries(uint(7), uint(40), models.EventOrderPaid, gomock.Any()).
+		DoAndReturn(func(sellerID, eventID uint, eventType, body string) (int, error) {
+			assert.Contains(t, body, `"order_id":21`)
+			return 1, nil
+		})
+	err := bus.Publish(context.Background(), events.Event{ID: 40, Type: models.EventOrderPaid, Payload: payload})
+	assert.NoError(t, err)
+}
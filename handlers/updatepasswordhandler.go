@@ -3,6 +3,7 @@ package handlers
 import (
 	"github.com/decadevs/shoparena/database"
 	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/realtime"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"log"
@@ -12,6 +13,10 @@ type Handler struct {
 	DB       database.DB
 	Mail     database.Mailer
 	Paystack database.Paystack
+	// Storage keeps uploaded images
+	Storage database.Storage
+	// Hub holds the open event streams, without it /events is unavailable
+	Hub *realtime.Hub
 }
 
 func PingHandler(c *gin.Context) {
@@ -3,9 +3,11 @@ package handlers
 import (
 	"fmt"
 	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
 )
 
@@ -35,9 +37,31 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Product with ID does not exist"})
 		return
 	}
+	// sellers, and their api keys, can only change their own products
+	if productInDb.SellerId != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Product with ID does not exist"})
+		return
+	}
 
 	fmt.Println(product.Title, product.Price)
 
+	// attribute values are checked against the product's category and replaced only when they are sent
+	if product.Attributes != nil {
+		attributes, errs := h.productAttributes(productInDb.CategoryId, product.Attributes)
+		if errs != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid attributes", "errors": errs})
+			return
+		}
+		product.SetAttributes(attributes)
+	}
+	// a changed listing goes back in the moderation queue
+	if product.Title != productInDb.Title || product.Description != productInDb.Description ||
+		(product.Attributes != nil && !reflect.DeepEqual(product.Attributes, productInDb.Attributes)) {
+		product.Reviews = []models.ListingReview{
+			models.NewListingReview(models.ReviewEditedListing, services.ScreenProduct(&product)),
+		}
+	}
+
 	err = h.DB.UpdateProductByID(prodIdUint, product)
 	if err != nil {
 		log.Println(err)
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadURLValidity is how long a presigned upload url works for
+const uploadURLValidity = 15 * time.Minute
+
+// uploadExtensions are the content types images can be uploaded straight to storage as, with their extensions
+var uploadExtensions = map[string]string{"image/png": ".png", "image/jpeg": ".jpg", "image/webp": ".webp"}
+
+// CreateUpload issues a presigned url the logged in buyer or seller can PUT an image to, straight to storage,
+// e.g. {"content_type":"image/png","size":48213}. The upload must be sent with that Content-Type and
+// Content-Length, and is then attached to a profile or product by confirming its key.
+func (h *Handler) CreateUpload(participantType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ownerID, ok := h.notificationRecipient(c, participantType)
+		if !ok {
+			return
+		}
+		var request struct {
+			ContentType string `json:"content_type" binding:"required"`
+			Size        int64  `json:"size" binding:"required"`
+		}
+		if errs := h.Decode(c, &request); errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		extension, ok := uploadExtensions[request.ContentType]
+		if !ok {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{services.ErrUnsupportedImage.Error()})
+			return
+		}
+		if request.Size <= 0 || request.Size > maxImageSize {
+			response.JSON(c, "", http.StatusBadRequest, nil, []string{fmt.Sprintf("size must be between 1 and %d bytes", maxImageSize)})
+			return
+		}
+
+		key := services.NewFileKey(uploadFolder(participantType, ownerID), extension)
+		url, err := h.Storage.SignedUpload(key, request.ContentType, request.Size, uploadURLValidity)
+		if err != nil {
+			log.Printf("sign upload error: %v\n", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to create upload"})
+			return
+		}
+		response.JSON(c, "upload created successfully", http.StatusCreated, gin.H{
+			"key":        key,
+			"upload_url": url,
+			"method":     http.MethodPut,
+			"headers": gin.H{
+				"Content-Type":   request.ContentType,
+				"Content-Length": strconv.FormatInt(request.Size, 10),
+			},
+			"expires_at": time.Now().Add(uploadURLValidity),
+		}, nil)
+	}
+}
+
+// uploadFolder is where a user's direct uploads wait to be confirmed, e.g. uploads/seller/7. Uploads that
+// are never confirmed are deleted by the uploads.expire job.
+func uploadFolder(participantType string, ownerID uint) string {
+	return fmt.Sprintf("%s/%s/%d", services.PendingUploads, participantType, ownerID)
+}
+
+// confirmUpload processes an image the user PUT straight to storage as key the way uploadImage does a
+// multipart one, then removes what was uploaded. The errors are the user's fault; no image without them
+// means processing failed and the upload is kept so it can be confirmed again.
+func (h *Handler) confirmUpload(key, participantType string, ownerID uint, folder string, sizes []services.ImageSize) (*models.Image, []string) {
+	if !strings.HasPrefix(key, uploadFolder(participantType, ownerID)+"/") || path.Clean(key) != key {
+		return nil, []string{"upload not found"}
+	}
+	file, size, err := h.Storage.Get(key)
+	if errors.Is(err, services.ErrFileNotFound) {
+		return nil, []string{"upload not found"}
+	}
+	if err != nil {
+		log.Printf("get upload error: %v\n", err)
+		return nil, nil
+	}
+	defer file.Close()
+
+	var image *models.Image
+	var errs []string
+	if size > maxImageSize {
+		errs = []string{"image too large"}
+	} else {
+		data, err := io.ReadAll(io.LimitReader(file, maxImageSize))
+		if err != nil {
+			log.Printf("read upload error: %v\n", err)
+			return nil, nil
+		}
+		image, errs = h.storeImage(data, path.Base(key), folder, sizes)
+	}
+	if image == nil && errs == nil {
+		return nil, nil
+	}
+	if err := h.Storage.Delete(key); err != nil {
+		log.Printf("delete upload error: %v\n", err)
+	}
+	return image, errs
+}
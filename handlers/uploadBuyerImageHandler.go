@@ -6,8 +6,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
-	"path/filepath"
-	"strings"
 )
 
 // UploadImageHandler uploads a buyer's profile picture
@@ -30,37 +28,55 @@ func (h *Handler) UploadBuyerImageHandler(c *gin.Context) {
 				return
 			}
 			defer file.Close()
-			fileExtension, ok := services.CheckSupportedFile(strings.ToLower(fileHeader.Filename))
-			log.Println(filepath.Ext(strings.ToLower(fileHeader.Filename)))
-			if ok {
-				log.Println(fileExtension)
-				c.JSON(http.StatusBadRequest, []string{fileExtension})
-				return
-			}
-			session, tempFileName, err := services.PreAWS(fileExtension, "profile_picture")
-			if err != nil {
-				log.Printf("could not upload file: %v\n", err)
-			}
-			url, err := h.DB.UploadFileToS3(session, file, tempFileName, fileHeader.Size)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
-				return
-			}
-			buyerID := user.ID
-			user.Image = url
-			err = h.DB.UpdateBuyerImageURL(user.Username, user.Image, buyerID)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{
-				"imageurl": user.Image,
-			})
+			image, errs := h.uploadImage(fileHeader, "profile_picture", services.ImageSizes)
+			h.saveBuyerImage(c, user, image, errs...)
 			return
 
 		}
 	}
 	c.JSON(http.StatusUnauthorized, []string{"unable to retrieve authenticated user"})
 }
+
+// ConfirmBuyerImageUpload makes an image the buyer uploaded with a presigned url their profile picture,
+// e.g. {"key":"uploads/buyer/23/<uuid>.png"}
+func (h *Handler) ConfirmBuyerImageUpload(c *gin.Context) {
+	user, err := h.GetBuyerFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request struct {
+		Key string `json:"key" binding:"required"`
+	}
+	if errs := h.Decode(c, &request); errs != nil {
+		c.JSON(http.StatusBadRequest, errs)
+		return
+	}
+	image, errs := h.confirmUpload(request.Key, models.RecipientBuyer, user.ID, "profile_picture", services.ImageSizes)
+	h.saveBuyerImage(c, user, image, errs...)
+}
+
+// saveBuyerImage makes an uploaded image the buyer's profile picture, responding with its urls.
+// No image means the upload failed, for the reasons in errs when they are the user's fault.
+func (h *Handler) saveBuyerImage(c *gin.Context, user *models.Buyer, image *models.Image, errs ...string) {
+	if errs != nil {
+		c.JSON(http.StatusBadRequest, errs)
+		return
+	}
+	if image == nil {
+		c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
+		return
+	}
+	buyerID := user.ID
+	user.Image, user.ImageVariants = image.Url, image.Variants
+	err := h.DB.UpdateBuyerImageURL(user.Username, user.Image, buyerID, user.ImageVariants)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"imageurl":       user.Image,
+		"image_variants": user.ImageVariants,
+	})
+}
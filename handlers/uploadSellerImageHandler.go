@@ -3,8 +3,6 @@ package handlers
 import (
 	"log"
 	"net/http"
-	"path/filepath"
-	"strings"
 
 	"github.com/decadevs/shoparena/models"
 	"github.com/decadevs/shoparena/services"
@@ -32,36 +30,54 @@ func (h *Handler) UploadSellerImageHandler(c *gin.Context) {
 				return
 			}
 			defer file.Close()
-			fileExtension, ok := services.CheckSupportedFile(strings.ToLower(fileHeader.Filename))
-			log.Println(filepath.Ext(strings.ToLower(fileHeader.Filename)))
-			if ok {
-				log.Println(fileExtension)
-				c.JSON(http.StatusBadRequest, []string{fileExtension})
-				return
-			}
-			session, tempFileName, err := services.PreAWS(fileExtension, "profile_picture")
-			if err != nil {
-				log.Printf("could not upload file: %v\n", err)
-			}
-			url, err := h.DB.UploadFileToS3(session, file, tempFileName, fileHeader.Size)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
-				return
-			}
-			sellerID := user.ID
-			user.Image = url
-			err = h.DB.UpdateSellerImageURL(user.Username, user.Image, sellerID)
-			if err != nil {
-				log.Println(err)
-				c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{
-				"imageurl": user.Image,
-			})
+			image, errs := h.uploadImage(fileHeader, "profile_picture", services.ImageSizes)
+			h.saveSellerImage(c, user, image, errs...)
 			return
 		}
 	}
 	c.JSON(http.StatusUnauthorized, []string{"unable to retrieve authenticated user"})
 }
+
+// ConfirmSellerImageUpload makes an image the seller uploaded with a presigned url their profile picture,
+// e.g. {"key":"uploads/seller/23/<uuid>.png"}
+func (h *Handler) ConfirmSellerImageUpload(c *gin.Context) {
+	user, err := h.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request struct {
+		Key string `json:"key" binding:"required"`
+	}
+	if errs := h.Decode(c, &request); errs != nil {
+		c.JSON(http.StatusBadRequest, errs)
+		return
+	}
+	image, errs := h.confirmUpload(request.Key, models.RecipientSeller, user.ID, "profile_picture", services.ImageSizes)
+	h.saveSellerImage(c, user, image, errs...)
+}
+
+// saveSellerImage makes an uploaded image the seller's profile picture, responding with its urls.
+// No image means the upload failed, for the reasons in errs when they are the user's fault.
+func (h *Handler) saveSellerImage(c *gin.Context, user *models.Seller, image *models.Image, errs ...string) {
+	if errs != nil {
+		c.JSON(http.StatusBadRequest, errs)
+		return
+	}
+	if image == nil {
+		c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
+		return
+	}
+	sellerID := user.ID
+	user.Image, user.ImageVariants = image.Url, image.Variants
+	err := h.DB.UpdateSellerImageURL(user.Username, user.Image, sellerID, user.ImageVariants)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, []string{"an error occurred while uploading the image"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"imageurl":       user.Image,
+		"image_variants": user.ImageVariants,
+	})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxVariantImages is how many pictures can be uploaded to a variant at once
+const maxVariantImages = 4
+
+type productOptionRequest struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type productVariantRequest struct {
+	SKU      string            `json:"sku"`
+	Options  map[string]string `json:"options"`
+	Price    *uint             `json:"price"`
+	Quantity uint              `json:"quantity"`
+}
+
+type productVariantsRequest struct {
+	Options  []productOptionRequest  `json:"options"`
+	Variants []productVariantRequest `json:"variants"`
+}
+
+// SaveProductVariants replaces the options a seller's product comes in and its variant matrix,
+// e.g. {"options":[{"name":"Size","values":["S","M"]}],"variants":[{"sku":"TS-S","options":{"Size":"S"},"quantity":4}]}.
+// A variant without a price sells at the product's price. Sending no options or variants removes them.
+func (h *Handler) SaveProductVariants(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	var request productVariantsRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+
+	options := make([]models.ProductOption, 0, len(request.Options))
+	for _, option := range request.Options {
+		options = append(options, models.ProductOption{Name: option.Name, Values: option.Values})
+	}
+	variants := make([]models.ProductVariant, 0, len(request.Variants))
+	for _, variant := range request.Variants {
+		variants = append(variants, models.ProductVariant{
+			SKU:      variant.SKU,
+			Options:  variant.Options,
+			Price:    variant.Price,
+			Quantity: variant.Quantity,
+		})
+	}
+	if err := models.SetVariants(options, variants); err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{err.Error()})
+		return
+	}
+
+	if err := h.DB.SaveProductVariants(product.ID, options, variants); err != nil {
+		log.Printf("save product variants error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to save variants"})
+		return
+	}
+	product, err := h.DB.GetProductByID(product.ID)
+	if err != nil {
+		log.Printf("get product error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get product"})
+		return
+	}
+	response.JSON(c, "variants saved successfully", http.StatusOK, product, nil)
+}
+
+// UploadVariantImages adds up to four pictures, sent as a multipart form under "images", to one of a product's variants
+func (h *Handler) UploadVariantImages(c *gin.Context) {
+	product, ok := h.sellerProduct(c)
+	if !ok {
+		return
+	}
+	variantID, err := strconv.Atoi(c.Param("variant_id"))
+	if err != nil || product.Variant(uint(variantID)) == nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"variant not found"})
+		return
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"images too large"})
+		return
+	}
+	images := form.File["images"]
+	if len(images) == 0 || len(images) > maxVariantImages {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"upload between 1 and 4 images"})
+		return
+	}
+
+	uploaded := make([]models.VariantImage, 0, len(images))
+	for _, image := range images {
+		upload, errs := h.uploadImage(image, "variants", services.ImageSizes)
+		if errs != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs)
+			return
+		}
+		if upload == nil {
+			response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to upload image"})
+			return
+		}
+		uploaded = append(uploaded, models.VariantImage{Url: upload.Url, Variants: upload.Variants})
+	}
+	saved, err := h.DB.AddVariantImages(product.ID, uint(variantID), uploaded)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"variant not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("add variant images error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to save images"})
+		return
+	}
+	response.JSON(c, "images uploaded successfully", http.StatusCreated, saved, nil)
+}
+
+// sellerProduct loads the :id product, responding with an error unless it belongs to the logged in seller
+func (h *Handler) sellerProduct(c *gin.Context) (*models.Product, bool) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return nil, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid product id"})
+		return nil, false
+	}
+	product, err := h.DB.GetProductByID(uint(id))
+	if err != nil || product.SellerId != seller.ID {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"product not found"})
+		return nil, false
+	}
+	return product, true
+}
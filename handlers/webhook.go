@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/decadevs/shoparena/webhooks"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type webhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// validate checks the url and that every event is one sellers can subscribe to
+func (r *webhookRequest) validate() []string {
+	var errs []string
+	if err := webhooks.ValidateURL(r.URL); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(r.Events) == 0 {
+		errs = append(errs, "choose at least one event")
+	}
+	for _, event := range r.Events {
+		known := false
+		for _, allowed := range models.SellerWebhookEvents {
+			if event == allowed {
+				known = true
+				break
+			}
+		}
+		if !known {
+			errs = append(errs, fmt.Sprintf("unknown event %s", event))
+		}
+	}
+	return errs
+}
+
+// CreateWebhookEndpoint registers a url for the seller's events.
+// The signing secret is returned once, here, and cannot be read again.
+func (h *Handler) CreateWebhookEndpoint(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	var request webhookRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if errs := request.validate(); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		log.Printf("webhook secret error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to create webhook"})
+		return
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		SellerID:    seller.ID,
+		URL:         request.URL,
+		Description: request.Description,
+		Secret:      secret,
+		Active:      request.Active == nil || *request.Active,
+	}
+	endpoint.SetEvents(request.Events)
+	if err := h.DB.CreateWebhookEndpoint(endpoint); err != nil {
+		log.Printf("create webhook error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to create webhook"})
+		return
+	}
+	response.JSON(c, "webhook created successfully, keep the secret safe as it will not be shown again",
+		http.StatusCreated, gin.H{"webhook": endpoint, "secret": secret}, nil)
+}
+
+// GetWebhookEndpoints lists the seller's webhooks
+func (h *Handler) GetWebhookEndpoints(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	endpoints, err := h.DB.GetSellerWebhookEndpoints(seller.ID)
+	if err != nil {
+		log.Printf("get webhooks error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get webhooks"})
+		return
+	}
+	response.JSON(c, "webhooks retrieved successfully", http.StatusOK, endpoints, nil)
+}
+
+// UpdateWebhookEndpoint changes a webhook's url, events, description or whether it is active
+func (h *Handler) UpdateWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := h.sellerWebhook(c)
+	if !ok {
+		return
+	}
+	var request webhookRequest
+	if errs := h.Decode(c, &request); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	if errs := request.validate(); errs != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, errs)
+		return
+	}
+	endpoint.URL = request.URL
+	endpoint.Description = request.Description
+	endpoint.SetEvents(request.Events)
+	if request.Active != nil {
+		endpoint.Active = *request.Active
+	}
+	if err := h.DB.UpdateWebhookEndpoint(endpoint); err != nil {
+		log.Printf("update webhook error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to update webhook"})
+		return
+	}
+	response.JSON(c, "webhook updated successfully", http.StatusOK, endpoint, nil)
+}
+
+// DeleteWebhookEndpoint removes one of the seller's webhooks
+func (h *Handler) DeleteWebhookEndpoint(c *gin.Context) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid webhook id"})
+		return
+	}
+	err = h.DB.DeleteWebhookEndpoint(uint(id), seller.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"webhook not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("delete webhook error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to delete webhook"})
+		return
+	}
+	response.JSON(c, "webhook deleted successfully", http.StatusOK, nil, nil)
+}
+
+// GetWebhookDeliveries shows the latest deliveries to a webhook with the responses they got
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	endpoint, ok := h.sellerWebhook(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"limit must be between 1 and 200"})
+		return
+	}
+	deliveries, err := h.DB.GetWebhookDeliveries(endpoint.ID, limit)
+	if err != nil {
+		log.Printf("get webhook deliveries error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to get deliveries"})
+		return
+	}
+	response.JSON(c, "deliveries retrieved successfully", http.StatusOK, deliveries, nil)
+}
+
+// ReplayWebhookDelivery sends a past delivery to its webhook again
+func (h *Handler) ReplayWebhookDelivery(c *gin.Context) {
+	endpoint, ok := h.sellerWebhook(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid delivery id"})
+		return
+	}
+	replay, err := h.DB.ReplayWebhookDelivery(uint(deliveryID), endpoint.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"delivery not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("replay webhook delivery error: %v\n", err)
+		response.JSON(c, "", http.StatusInternalServerError, nil, []string{"unable to replay delivery"})
+		return
+	}
+	response.JSON(c, "delivery queued to be sent again", http.StatusAccepted, replay, nil)
+}
+
+// sellerWebhook loads the webhook in the url, responding with an error when it is not the seller's
+func (h *Handler) sellerWebhook(c *gin.Context) (*models.WebhookEndpoint, bool) {
+	seller, err := h.GetUserFromContext(c)
+	if err != nil {
+		response.JSON(c, "", http.StatusUnauthorized, nil, []string{"unable to retrieve authenticated user"})
+		return nil, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"invalid webhook id"})
+		return nil, false
+	}
+	endpoint, err := h.DB.GetWebhookEndpoint(uint(id), seller.ID)
+	if err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"webhook not found"})
+		return nil, false
+	}
+	return endpoint, true
+}
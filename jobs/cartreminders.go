@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"gorm.io/gorm"
+)
+
+// cartReminderBatch is how many carts are reminded per run
+const cartReminderBatch = 100
+
+// SendCartReminders emails the buyers whose carts are due a reminder and returns how many were sent.
+// The second reminder carries a coupon when settings.CouponPercent is set.
+func SendCartReminders(db database.DB, settings services.CartReminderSettings) (int, error) {
+	now := time.Now()
+	var secondBefore time.Time
+	if settings.SecondAfter > 0 {
+		secondBefore = now.Add(-settings.SecondAfter)
+	}
+	carts, err := db.GetAbandonedCarts(now.Add(-settings.After), secondBefore, cartReminderBatch)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, cart := range carts {
+		if err := sendCartReminder(db, settings, cart); err != nil {
+			log.Printf("cart %d reminder error: %v\n", cart.CartID, err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func sendCartReminder(db database.DB, settings services.CartReminderSettings, cart models.AbandonedCart) error {
+	summary, err := db.GetCheckoutSummary(&models.Buyer{Model: gorm.Model{ID: cart.BuyerID}})
+	if err != nil {
+		return err
+	}
+	if len(summary.Lines) == 0 {
+		return nil
+	}
+
+	token, err := services.NewReminderToken()
+	if err != nil {
+		return err
+	}
+	reminder := &models.CartReminder{
+		CartID:         cart.CartID,
+		BuyerID:        cart.BuyerID,
+		Stage:          cart.Stage,
+		LastActivityAt: cart.LastActivityAt,
+		Token:          token,
+	}
+	var coupon *models.Coupon
+	if cart.Stage == 2 && settings.CouponPercent > 0 {
+		code, err := services.NewCouponCode()
+		if err != nil {
+			return err
+		}
+		coupon = &models.Coupon{
+			Code:       code,
+			BuyerID:    cart.BuyerID,
+			PercentOff: settings.CouponPercent,
+			ExpiresAt:  time.Now().Add(settings.CouponValidity),
+		}
+	}
+	// the reminder is recorded first, so a cart is never emailed twice for the same stage
+	if err := db.CreateCartReminder(reminder, coupon); err != nil {
+		return err
+	}
+
+	data := services.EmailData{
+		Name:        cart.FirstName,
+		Link:        services.APIURL() + "/api/v1/cartreminders/click/" + token,
+		Order:       services.CartEmail(summary),
+		Unsubscribe: services.APIURL() + "/api/v1/cartreminders/unsubscribe?token=" + services.UnsubscribeToken(cart.BuyerID),
+	}
+	if coupon != nil {
+		data.Coupon = &services.CouponEmail{
+			Code:       coupon.Code,
+			PercentOff: coupon.PercentOff,
+			ExpiresAt:  coupon.ExpiresAt.Format("2 January 2006"),
+		}
+	}
+	message, err := services.RenderEmail(services.EmailCartReminder, cart.Email, data)
+	if err != nil {
+		return err
+	}
+	return db.EnqueueEmail(message)
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+)
+
+// ImagesDeletePayload is the payload of a TypeImagesDelete job
+type ImagesDeletePayload struct {
+	URLs []string `json:"urls"`
+}
+
+// subscribeImageCleanup deletes pictures from storage once products no longer use them
+func subscribeImageCleanup(bus *events.Bus, db database.DB) {
+	bus.Subscribe(models.EventProductImagesRemoved, "image cleanup", func(ctx context.Context, event events.Event) error {
+		var removed models.ImagesRemovedEvent
+		if err := event.Decode(&removed); err != nil {
+			return err
+		}
+		return Enqueue(db, TypeImagesDelete, ImagesDeletePayload{URLs: removed.URLs})
+	})
+}
+
+// DeleteImages deletes the files at urls from storage. Urls that aren't in storage, such as
+// pictures imported from elsewhere, are skipped.
+func DeleteImages(store database.Storage, urls []string) error {
+	for _, url := range urls {
+		key, ok := store.Key(url)
+		if !ok {
+			continue
+		}
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+)
+
+// orderStatusTitles are the buyer's notification titles for each order status event
+var orderStatusTitles = map[string]string{
+	models.EventOrderShipped:   "Your order #%d has shipped",
+	models.EventOrderDelivered: "Your order #%d has been delivered",
+	models.EventOrderCancelled: "Your order #%d has been cancelled",
+	models.EventOrderRefunded:  "Your refund for order #%d has been processed",
+}
+
+// subscribeNotifications adds order events to buyers' and sellers' in-app inboxes
+func subscribeNotifications(bus *events.Bus, db database.DB) {
+	bus.Subscribe(models.EventOrderPaid, "notifications", func(ctx context.Context, event events.Event) error {
+		var order models.OrderEvent
+		if err := event.Decode(&order); err != nil {
+			return err
+		}
+		amount := fmt.Sprintf("%d x product #%d, NGN %s", order.Quantity, order.ProductID, services.FormatAmount(order.Total))
+		return db.CreateNotifications([]models.Notification{
+			{
+				RecipientType: models.RecipientSeller,
+				RecipientID:   order.SellerID,
+				Type:          models.NotificationNewOrder,
+				Title:         fmt.Sprintf("New order #%d", order.OrderID),
+				Body:          amount,
+				Link:          services.FrontendURL() + "/seller/orders",
+				DedupKey:      notificationKey(event, models.RecipientSeller),
+			},
+			{
+				RecipientType: models.RecipientBuyer,
+				RecipientID:   order.BuyerID,
+				Type:          models.NotificationPaymentSuccess,
+				Title:         fmt.Sprintf("Payment received for order #%d", order.OrderID),
+				Body:          amount,
+				Link:          services.FrontendURL() + "/buyer/orders",
+				DedupKey:      notificationKey(event, models.RecipientBuyer),
+			},
+		})
+	})
+
+	for eventType, title := range orderStatusTitles {
+		title := title
+		bus.Subscribe(eventType, "notifications", func(ctx context.Context, event events.Event) error {
+			var order models.OrderEvent
+			if err := event.Decode(&order); err != nil {
+				return err
+			}
+			return db.CreateNotifications([]models.Notification{{
+				RecipientType: models.RecipientBuyer,
+				RecipientID:   order.BuyerID,
+				Type:          models.NotificationOrderStatus,
+				Title:         fmt.Sprintf(title, order.OrderID),
+				Body:          order.StatusNote,
+				Link:          services.FrontendURL() + "/buyer/orders",
+				DedupKey:      notificationKey(event, models.RecipientBuyer),
+			}})
+		})
+	}
+}
+
+func notificationKey(event events.Event, recipientType string) *string {
+	key := fmt.Sprintf("event:%d:%s", event.ID, recipientType)
+	return &key
+}
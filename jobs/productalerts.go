@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+)
+
+// alertBatch is how many alert notifications are emailed per run
+const alertBatch = 500
+
+// subscribeProductAlerts sets off buyers' product alerts as stock and price change events are relayed
+func subscribeProductAlerts(bus *events.Bus, db database.DB) {
+	bus.Subscribe(models.EventProductStockChanged, "product alerts", func(ctx context.Context, event events.Event) error {
+		var change models.StockChangedEvent
+		if err := event.Decode(&change); err != nil {
+			return err
+		}
+		if change.OldQuantity > 0 || change.NewQuantity == 0 {
+			return nil
+		}
+		_, err := db.QueueBackInStockAlerts(change.ProductID, event.ID, change.Price)
+		return err
+	})
+	bus.Subscribe(models.EventProductPriceChanged, "product alerts", func(ctx context.Context, event events.Event) error {
+		var change models.PriceChangedEvent
+		if err := event.Decode(&change); err != nil {
+			return err
+		}
+		if change.NewPrice >= change.OldPrice {
+			return nil
+		}
+		_, err := db.QueuePriceDropAlerts(change.ProductID, event.ID, change.OldPrice, change.NewPrice)
+		return err
+	})
+}
+
+// SendProductAlerts emails buyers the alerts that have gone off, one email per buyer,
+// and returns how many emails were queued
+func SendProductAlerts(db database.DB) (int, error) {
+	notifications, err := db.ClaimAlertNotifications(alertBatch)
+	if err != nil {
+		return 0, err
+	}
+	// notifications come ordered by buyer
+	sent := 0
+	for start := 0; start < len(notifications); {
+		end := start
+		for end < len(notifications) && notifications[end].BuyerID == notifications[start].BuyerID {
+			end++
+		}
+		if err := sendProductAlerts(db, notifications[start:end]); err != nil {
+			log.Printf("buyer %d product alerts error: %v\n", notifications[start].BuyerID, err)
+		} else {
+			sent++
+		}
+		start = end
+	}
+	return sent, nil
+}
+
+func sendProductAlerts(db database.DB, notifications []models.AlertNotification) error {
+	buyer := notifications[0].Buyer
+	data := services.EmailData{Name: buyer.FirstName, Link: services.FrontendURL() + "/buyer/alerts"}
+	ids := make([]uint, 0, len(notifications))
+	for _, n := range notifications {
+		ids = append(ids, n.ID)
+		data.Alerts = append(data.Alerts, services.AlertEmail{
+			Title:       n.Product.Title,
+			BackInStock: n.Kind == models.AlertBackInStock,
+			Price:       services.FormatAmount(n.Price),
+			Link:        services.FrontendURL() + "/product/" + strconv.Itoa(int(n.ProductID)),
+		})
+	}
+	message, err := services.RenderEmail(services.EmailProductAlert, buyer.Email, data)
+	if err != nil {
+		return err
+	}
+	// a failure leaves the notifications unsent, so they are claimed again once the claim runs out
+	return db.SendAlertEmail(message, ids)
+}
@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+)
+
+// ProductImportPayload is the payload of a TypeProductImport job
+type ProductImportPayload struct {
+	ImportID uint `json:"import_id"`
+}
+
+// ProductImagesPayload is the payload of a TypeProductImages job
+type ProductImagesPayload struct {
+	ProductID uint     `json:"product_id"`
+	URLs      []string `json:"urls"`
+}
+
+// maxImportImageSize caps the size of an image fetched for an imported product
+const maxImportImageSize = 5 << 20
+
+// ImageClient fetches imported products' images. It only connects to public addresses, and never
+// through a proxy, so a seller can't point an import at the app's own network.
+var ImageClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if !publicAddress(net.ParseIP(host)) {
+					return fmt.Errorf("%s is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+// sharedAddressSpace is 100.64.0.0/10, the carrier-grade NAT range cloud providers use internally
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+// publicAddress reports whether ip can be reached from the internet. IPv4 addresses written
+// as IPv6, such as ::ffff:127.0.0.1, are checked as IPv4.
+func publicAddress(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// RunProductImport imports the products of a queued import, saving its progress as it goes.
+// The rows are upserts, so an import cut short is simply run again from the start.
+func RunProductImport(ctx context.Context, db database.DB, importID uint) error {
+	productImport, err := db.GetProductImport(importID)
+	if err != nil {
+		return err
+	}
+	if productImport.Status == models.JobStatusDone || productImport.Status == models.JobStatusFailed {
+		return nil
+	}
+
+	rows, rowErrors, err := services.ParseCatalogue(productImport.Format, strings.NewReader(productImport.Data))
+	if err != nil {
+		now := time.Now()
+		productImport.Status = models.JobStatusFailed
+		productImport.FinishedAt = &now
+		productImport.AddError(models.ProductImportRow{}, err)
+		return db.SaveProductImport(productImport)
+	}
+	productImport.Status = models.JobStatusRunning
+	importer := &services.ProductImporter{
+		Store:    db,
+		Progress: db.SaveProductImport,
+		Imported: func(product *models.Product, imageURLs []string) error {
+			return Enqueue(db, TypeProductImages, ProductImagesPayload{ProductID: product.ID, URLs: imageURLs})
+		},
+	}
+	if err := importer.Run(ctx, productImport, rows, rowErrors); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	productImport.Status = models.JobStatusDone
+	productImport.FinishedAt = &now
+	// the file isn't needed once it is imported
+	productImport.Data = ""
+	if err := db.SaveProductImport(productImport); err != nil {
+		return err
+	}
+	log.Printf("import %d for seller %d: %d created, %d updated, %d failed\n", productImport.ID,
+		productImport.SellerID, productImport.Created, productImport.Updated, productImport.Failed)
+	return nil
+}
+
+// FetchProductImages downloads an imported product's images, puts them in storage and makes them the
+// product's pictures. Images that can't be fetched are skipped; if none can be, the job is retried.
+func FetchProductImages(ctx context.Context, db database.DB, store database.Storage, productID uint, urls []string) error {
+	uploaded := make([]models.Image, 0, len(urls))
+	for _, url := range urls {
+		image, err := fetchProductImage(ctx, store, url)
+		if err != nil {
+			log.Printf("product %d image %s error: %v\n", productID, url, err)
+			continue
+		}
+		uploaded = append(uploaded, *image)
+	}
+	if len(uploaded) == 0 && len(urls) > 0 {
+		return fmt.Errorf("none of product %d's images could be fetched", productID)
+	}
+	return db.ReplaceProductImages(productID, uploaded)
+}
+
+// fetchProductImage downloads a png, jpeg or webp and puts it in storage the way uploaded pictures are,
+// returning where it and its variants now are
+func fetchProductImage(ctx context.Context, store database.Storage, url string) (*models.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := ImageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxImportImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportImageSize {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxImportImageSize)
+	}
+
+	processed, err := services.ProcessImage(data, services.ImageSizes)
+	if err != nil {
+		return nil, err
+	}
+	location, variants, err := services.StoreImage(store, "product", processed)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Image{Url: location, Width: processed.Width, Height: processed.Height, Variants: variants}, nil
+}
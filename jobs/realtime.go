@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+)
+
+// subscribeRealtime pushes order events to the buyer's and seller's open event streams
+func subscribeRealtime(bus *events.Bus, db database.DB) {
+	bus.Subscribe(models.EventOrderPaid, "realtime", func(ctx context.Context, event events.Event) error {
+		var order models.OrderEvent
+		if err := event.Decode(&order); err != nil {
+			return err
+		}
+		return db.PublishRealtime([]models.RealtimeMessage{
+			{RecipientType: models.RecipientSeller, RecipientID: order.SellerID, Event: models.RealtimeOrderCreated, Data: event.Payload},
+			{RecipientType: models.RecipientBuyer, RecipientID: order.BuyerID, Event: models.RealtimePaymentConfirmed, Data: event.Payload},
+		})
+	})
+
+	for eventType := range orderStatusTitles {
+		bus.Subscribe(eventType, "realtime", func(ctx context.Context, event events.Event) error {
+			var order models.OrderEvent
+			if err := event.Decode(&order); err != nil {
+				return err
+			}
+			return db.PublishRealtime([]models.RealtimeMessage{
+				{RecipientType: models.RecipientSeller, RecipientID: order.SellerID, Event: models.RealtimeOrderStatus, Data: event.Payload},
+				{RecipientType: models.RecipientBuyer, RecipientID: order.BuyerID, Event: models.RealtimeOrderStatus, Data: event.Payload},
+			})
+		})
+	}
+}
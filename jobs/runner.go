@@ -0,0 +1,347 @@
+// Package jobs runs background work from a Postgres-backed queue.
+//
+// Work is queued as models.Job rows and picked up by a Runner, which can run inside the
+// API server or on its own through cmd/worker. Any number of runners can share a queue:
+// jobs are claimed with SKIP LOCKED and held under a lease, so a job whose worker dies is
+// picked up again once the lease runs out.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// Store is the queue the runner takes jobs from
+type Store interface {
+	EnqueueJob(job *models.Job) error
+	ClaimJobs(jobType string, limit int, workerID string, lease time.Duration) ([]models.Job, error)
+	SaveJobResult(job *models.Job) error
+}
+
+// HandlerFunc does the work for a job. Returning an error retries the job later.
+type HandlerFunc func(ctx context.Context, job *models.Job) error
+
+// HandlerOption tunes how jobs of one type are run
+type HandlerOption func(*handler)
+
+// Concurrency caps how many jobs of the type run at once in this runner. The default is 1.
+func Concurrency(n int) HandlerOption {
+	return func(h *handler) {
+		if n > 0 {
+			h.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// Timeout bounds how long a single run may take. The default is five minutes.
+func Timeout(d time.Duration) HandlerOption {
+	return func(h *handler) { h.timeout = d }
+}
+
+// MaxAttempts is how many times a job is tried before it is marked failed,
+// for jobs queued without their own limit. The default is 5.
+func MaxAttempts(n uint) HandlerOption {
+	return func(h *handler) { h.maxAttempts = n }
+}
+
+type handler struct {
+	run         HandlerFunc
+	slots       chan struct{}
+	timeout     time.Duration
+	maxAttempts uint
+}
+
+type scheduled struct {
+	jobType  string
+	schedule Schedule
+	next     time.Time
+}
+
+type poller struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Runner claims jobs from a Store and runs them with the registered handlers
+type Runner struct {
+	store    Store
+	handlers map[string]*handler
+	// order keeps polling fair and predictable
+	order     []string
+	schedules []*scheduled
+	pollers   []*poller
+	slots     chan struct{}
+	wg        sync.WaitGroup
+
+	WorkerID        string
+	PollInterval    time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// NewRunner creates a runner that runs at most JOB_CONCURRENCY jobs at once, 4 by default
+func NewRunner(store Store) *Runner {
+	concurrency, err := strconv.Atoi(os.Getenv("JOB_CONCURRENCY"))
+	if err != nil || concurrency < 1 {
+		concurrency = 4
+	}
+	host, _ := os.Hostname()
+	return &Runner{
+		store:           store,
+		handlers:        map[string]*handler{},
+		slots:           make(chan struct{}, concurrency),
+		WorkerID:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		PollInterval:    time.Second,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// Handle registers the handler for a job type
+func (r *Runner) Handle(jobType string, run HandlerFunc, opts ...HandlerOption) {
+	h := &handler{
+		run:         run,
+		slots:       make(chan struct{}, 1),
+		timeout:     5 * time.Minute,
+		maxAttempts: 5,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if _, ok := r.handlers[jobType]; !ok {
+		r.order = append(r.order, jobType)
+	}
+	r.handlers[jobType] = h
+}
+
+// Schedule queues a job of the type on a cron-like schedule, see ParseSchedule.
+// When several runners share a schedule only one job is queued per tick.
+func (r *Runner) Schedule(spec, jobType string) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	r.schedules = append(r.schedules, &scheduled{jobType: jobType, schedule: schedule})
+	return nil
+}
+
+// Every runs work such as flushing the email outbox every interval in this process, rather than as a
+// scheduled job, so polling every few seconds doesn't add a job row each time. Every runner does the
+// work, so it must be safe to do from several at once, e.g. by claiming the rows it handles.
+func (r *Runner) Every(interval time.Duration, name string, run func(ctx context.Context) error) {
+	r.pollers = append(r.pollers, &poller{name: name, interval: interval, run: run})
+}
+
+// Run polls for due jobs until ctx is cancelled, then waits up to ShutdownTimeout
+// for running jobs to finish. Jobs still running after that are cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	now := time.Now()
+	for _, s := range r.schedules {
+		s.next = s.schedule.Next(now)
+	}
+	log.Printf("job runner %s started with %d job types\n", r.WorkerID, len(r.handlers))
+	for _, p := range r.pollers {
+		r.wg.Add(1)
+		go r.loop(ctx, jobCtx, p)
+	}
+
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		r.enqueueScheduled(time.Now())
+		r.poll(jobCtx)
+		select {
+		case <-ctx.Done():
+			r.shutdown(cancelJobs)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) shutdown(cancelJobs context.CancelFunc) {
+	log.Printf("job runner %s shutting down\n", r.WorkerID)
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(r.ShutdownTimeout):
+		log.Println("job runner shutdown timed out, cancelling running jobs")
+		cancelJobs()
+		<-done
+	}
+	log.Printf("job runner %s stopped\n", r.WorkerID)
+}
+
+// loop does a poller's work every interval until stop is cancelled
+func (r *Runner) loop(stop, ctx context.Context, p *poller) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	run := func(ctx context.Context, job *models.Job) error { return p.run(ctx) }
+	for {
+		select {
+		case <-stop.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := safeRun(ctx, run, &models.Job{Type: p.name}); err != nil {
+			log.Printf("%s error: %v\n", p.name, err)
+		}
+	}
+}
+
+// enqueueScheduled queues a job for each schedule that is due
+func (r *Runner) enqueueScheduled(now time.Time) {
+	for _, s := range r.schedules {
+		if s.next.IsZero() || now.Before(s.next) {
+			continue
+		}
+		key := fmt.Sprintf("schedule:%s:%d", s.jobType, s.next.Unix())
+		job := &models.Job{Type: s.jobType, RunAt: s.next, UniqueKey: &key}
+		if h, ok := r.handlers[s.jobType]; ok {
+			job.MaxAttempts = h.maxAttempts
+		}
+		if err := r.store.EnqueueJob(job); err != nil {
+			log.Printf("schedule %s job error: %v\n", s.jobType, err)
+			continue
+		}
+		s.next = s.schedule.Next(now)
+	}
+}
+
+// poll claims as many jobs as there are free slots for, type by type
+func (r *Runner) poll(ctx context.Context) {
+	for _, jobType := range r.order {
+		h := r.handlers[jobType]
+		free := cap(h.slots) - len(h.slots)
+		if globalFree := cap(r.slots) - len(r.slots); globalFree < free {
+			free = globalFree
+		}
+		if free <= 0 {
+			continue
+		}
+		claimed, err := r.store.ClaimJobs(jobType, free, r.WorkerID, h.timeout+time.Minute)
+		if err != nil {
+			log.Printf("claim %s jobs error: %v\n", jobType, err)
+			continue
+		}
+		for i := range claimed {
+			job := claimed[i]
+			h.slots <- struct{}{}
+			r.slots <- struct{}{}
+			r.wg.Add(1)
+			go func() {
+				defer func() {
+					<-h.slots
+					<-r.slots
+					r.wg.Done()
+				}()
+				r.execute(ctx, h, &job)
+			}()
+		}
+	}
+}
+
+// execute runs one job and records the result, retrying it with backoff when it fails
+func (r *Runner) execute(ctx context.Context, h *handler, job *models.Job) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	err := safeRun(ctx, h.run, job)
+	now := time.Now()
+	job.LockedBy = ""
+	job.LockedUntil = nil
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = h.maxAttempts
+	}
+	switch {
+	case err == nil:
+		job.Status = models.JobStatusDone
+		job.LastError = ""
+		job.FinishedAt = &now
+	case job.Attempts >= maxAttempts:
+		job.Status = models.JobStatusFailed
+		job.LastError = err.Error()
+		job.FinishedAt = &now
+		log.Printf("job %d (%s) failed after %d attempts: %v\n", job.ID, job.Type, job.Attempts, err)
+	default:
+		job.Status = models.JobStatusPending
+		job.LastError = err.Error()
+		job.RunAt = now.Add(Backoff(job.Attempts))
+		log.Printf("job %d (%s) attempt %d failed, retrying at %s: %v\n",
+			job.ID, job.Type, job.Attempts, job.RunAt.Format(time.RFC3339), err)
+	}
+	if err := r.store.SaveJobResult(job); err != nil {
+		log.Printf("save job %d result error: %v\n", job.ID, err)
+	}
+}
+
+// safeRun turns a panicking job into a failed one rather than taking the runner down
+func safeRun(ctx context.Context, run HandlerFunc, job *models.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+			log.Printf("job %d (%s) panicked: %v\n%s", job.ID, job.Type, p, debug.Stack())
+		}
+	}()
+	return run(ctx, job)
+}
+
+// Backoff is how long a job waits before it is retried after attempts failures:
+// ten seconds, doubling each time, up to an hour
+func Backoff(attempts uint) time.Duration {
+	delay := 10 * time.Second
+	for i := uint(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= time.Hour {
+			return time.Hour
+		}
+	}
+	return delay
+}
+
+// NewJob builds a job of the type with payload encoded as json
+func NewJob(jobType string, payload interface{}) (*models.Job, error) {
+	job := &models.Job{Type: jobType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = string(data)
+	}
+	return job, nil
+}
+
+// Enqueue queues a job of the type with payload, to run as soon as a worker is free
+func Enqueue(store Store, jobType string, payload interface{}) error {
+	job, err := NewJob(jobType, payload)
+	if err != nil {
+		return err
+	}
+	return store.EnqueueJob(job)
+}
+
+// Decode reads a job's json payload into v
+func Decode(job *models.Job, v interface{}) error {
+	if job.Payload == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(job.Payload), v)
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/decadevs/shoparena/webhooks"
+)
+
+// Job types run by the app
+const (
+	TypeBlacklistCleanup = "blacklist.cleanup"
+	TypeJobsPrune        = "jobs.prune"
+	TypeCartReminders    = "cart.reminders"
+	TypeProductAlerts    = "product.alerts"
+	TypeProductImport    = "products.import"
+	TypeProductImages    = "products.images"
+	TypeProductSchedule  = "products.schedule"
+	TypeImagesDelete     = "images.delete"
+	TypeUploadsExpire    = "uploads.expire"
+)
+
+// Work every runner polls for, see Runner.Every
+const (
+	PollEmailFlush      = "email.flush"
+	PollEventsRelay     = "events.relay"
+	PollWebhooksDeliver = "webhooks.deliver"
+)
+
+// jobRetention is how long finished jobs are kept before they are pruned
+const jobRetention = 7 * 24 * time.Hour
+
+// uploadRetention is how long a direct upload is kept waiting to be confirmed before it is deleted
+const uploadRetention = 24 * time.Hour
+
+// Register sets up the app's job handlers and schedules on r.
+// Domain events are published to bus and uploaded files are kept in store.
+func Register(r *Runner, db database.DB, mail database.Mailer, store database.Storage, bus *events.Bus) error {
+	outbox := services.NewOutboxSender(db, mail)
+	r.Every(10*time.Second, PollEmailFlush, func(ctx context.Context) error {
+		_, err := outbox.Flush()
+		return err
+	})
+
+	r.Handle(TypeBlacklistCleanup, func(ctx context.Context, job *models.Job) error {
+		// a token outlives neither of these, so older blacklist entries can go
+		validity := services.AccessTokenValidity
+		if services.RefreshTokenValidity > validity {
+			validity = services.RefreshTokenValidity
+		}
+		deleted, err := db.DeleteExpiredBlacklistTokens(time.Now().Add(-validity))
+		if err == nil && deleted > 0 {
+			log.Printf("removed %d expired tokens from the blacklist\n", deleted)
+		}
+		return err
+	})
+
+	r.Handle(TypeJobsPrune, func(ctx context.Context, job *models.Job) error {
+		_, err := db.PruneJobs(time.Now().Add(-jobRetention))
+		return err
+	})
+
+	webhooks.Subscribe(bus, db)
+	subscribeProductAlerts(bus, db)
+	subscribeImageCleanup(bus, db)
+	subscribeNotifications(bus, db)
+	subscribeRealtime(bus, db)
+	relay := events.NewRelay(db, bus)
+	r.Every(5*time.Second, PollEventsRelay, func(ctx context.Context) error {
+		_, err := relay.Flush(ctx)
+		return err
+	})
+
+	sender := webhooks.NewSender(db)
+	r.Every(5*time.Second, PollWebhooksDeliver, func(ctx context.Context) error {
+		_, err := sender.Flush(ctx)
+		return err
+	})
+
+	r.Handle(TypeCartReminders, func(ctx context.Context, job *models.Job) error {
+		sent, err := SendCartReminders(db, services.CartReminderConfig())
+		if sent > 0 {
+			log.Printf("sent %d abandoned cart reminders\n", sent)
+		}
+		return err
+	}, MaxAttempts(1))
+
+	r.Handle(TypeProductAlerts, func(ctx context.Context, job *models.Job) error {
+		_, err := SendProductAlerts(db)
+		return err
+	}, MaxAttempts(1))
+
+	r.Handle(TypeProductImport, func(ctx context.Context, job *models.Job) error {
+		var payload ProductImportPayload
+		if err := Decode(job, &payload); err != nil {
+			return err
+		}
+		return RunProductImport(ctx, db, payload.ImportID)
+	}, MaxAttempts(3), Timeout(30*time.Minute))
+
+	r.Handle(TypeProductImages, func(ctx context.Context, job *models.Job) error {
+		var payload ProductImagesPayload
+		if err := Decode(job, &payload); err != nil {
+			return err
+		}
+		return FetchProductImages(ctx, db, store, payload.ProductID, payload.URLs)
+	}, MaxAttempts(3), Concurrency(4))
+
+	r.Handle(TypeImagesDelete, func(ctx context.Context, job *models.Job) error {
+		var payload ImagesDeletePayload
+		if err := Decode(job, &payload); err != nil {
+			return err
+		}
+		return DeleteImages(store, payload.URLs)
+	}, MaxAttempts(5))
+
+	r.Handle(TypeUploadsExpire, func(ctx context.Context, job *models.Job) error {
+		deleted, err := store.DeleteOlderThan(services.PendingUploads, time.Now().Add(-uploadRetention))
+		if deleted > 0 {
+			log.Printf("deleted %d unconfirmed uploads\n", deleted)
+		}
+		return err
+	}, MaxAttempts(1))
+
+	r.Handle(TypeProductSchedule, func(ctx context.Context, job *models.Job) error {
+		published, unpublished, err := db.PublishScheduledProducts(time.Now())
+		if published > 0 || unpublished > 0 {
+			log.Printf("published %d and unpublished %d scheduled products\n", published, unpublished)
+		}
+		return err
+	}, MaxAttempts(1))
+
+	for _, s := range []struct{ spec, jobType string }{
+		{"*/15 * * * *", TypeCartReminders},
+		{"*/10 * * * *", TypeProductAlerts},
+		{"* * * * *", TypeProductSchedule},
+		{"30 2 * * *", TypeBlacklistCleanup},
+		{"0 3 * * *", TypeJobsPrune},
+		{"15 * * * *", TypeUploadsExpire},
+	} {
+		if err := r.Schedule(s.spec, s.jobType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
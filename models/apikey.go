@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes an API key can be given
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeOrdersRead    = "orders:read"
+	ScopeOrdersWrite   = "orders:write"
+)
+
+// APIKeyScopes are every scope a seller can grant a key
+var APIKeyScopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeOrdersRead, ScopeOrdersWrite}
+
+// APIKey lets a seller's integrations call the api without logging in.
+// Only a hash of the key is kept, the key itself is shown once when it is created.
+type APIKey struct {
+	gorm.Model
+	SellerID uint   `json:"seller_id" gorm:"index"`
+	Seller   Seller `json:"-"`
+	Name     string `json:"name"`
+	// Prefix is the start of the key, used to find it and to tell keys apart
+	Prefix  string `json:"prefix" gorm:"uniqueIndex"`
+	KeyHash string `json:"-"`
+	// ScopeList is the comma separated list behind Scopes
+	ScopeList  string     `json:"-"`
+	Scopes     []string   `json:"scopes" gorm:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// SetScopes sets the scopes granted to the key
+func (k *APIKey) SetScopes(scopes []string) {
+	k.Scopes = scopes
+	k.ScopeList = strings.Join(scopes, ",")
+}
+
+func (k *APIKey) AfterFind(tx *gorm.DB) error {
+	k.Scopes = nil
+	if k.ScopeList != "" {
+		k.Scopes = strings.Split(k.ScopeList, ",")
+	}
+	return nil
+}
+
+// HasScope reports whether the key was granted the scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Split(k.ScopeList, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the key can still be used at the time
+func (k *APIKey) Usable(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Types of value a category attribute takes
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeSelect  = "select"
+)
+
+// AttributeFilterPrefix marks the search query parameters that filter on attributes, e.g. attr.brand=samsung
+const AttributeFilterPrefix = "attr."
+
+// CategoryAttribute is an attribute the products in a category and the categories below it have,
+// such as RAM for electronics or material for fashion
+type CategoryAttribute struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CategoryID uint      `json:"category_id" gorm:"uniqueIndex:idx_category_attribute"`
+	// Key names the attribute in product attributes and search filters
+	Key      string `json:"key" gorm:"uniqueIndex:idx_category_attribute"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Position int    `json:"position"`
+	// ValueList is the JSON list behind AllowedValues
+	ValueList     string   `json:"-"`
+	AllowedValues []string `json:"allowed_values,omitempty" gorm:"-"`
+}
+
+func (a *CategoryAttribute) SetAllowedValues(values []string) {
+	a.AllowedValues = values
+	list, _ := json.Marshal(values)
+	a.ValueList = string(list)
+}
+
+func (a *CategoryAttribute) AfterFind(tx *gorm.DB) error {
+	a.AllowedValues = nil
+	if a.ValueList == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(a.ValueList), &a.AllowedValues)
+}
+
+// Check checks an attribute definition, keying it by its name when it has no key
+func (a *CategoryAttribute) Check() error {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Key == "" {
+		a.Key = strings.ReplaceAll(Slugify(a.Name), "-", "_")
+	}
+	if a.Name == "" || a.Key == "" || a.Key != strings.ReplaceAll(Slugify(a.Key), "-", "_") {
+		return fmt.Errorf("attribute %q needs a name and a key of lowercase letters, numbers and underscores", a.Name)
+	}
+	switch a.Type {
+	case AttributeSelect:
+		if len(a.AllowedValues) == 0 {
+			return fmt.Errorf("attribute %s needs allowed values", a.Key)
+		}
+	case AttributeText, AttributeNumber, AttributeBoolean:
+		if len(a.AllowedValues) > 0 {
+			return fmt.Errorf("only select attributes have allowed values, %s is %s", a.Key, a.Type)
+		}
+	default:
+		return fmt.Errorf("attribute %s must be text, number, boolean or select", a.Key)
+	}
+	a.SetAllowedValues(a.AllowedValues)
+	return nil
+}
+
+// value checks a product's value for the attribute and returns it as it is stored
+func (a *CategoryAttribute) value(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch a.Type {
+	case AttributeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", fmt.Errorf("%s must be a number", a.Name)
+		}
+	case AttributeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be true or false", a.Name)
+		}
+		value = strconv.FormatBool(b)
+	case AttributeSelect:
+		for _, allowed := range a.AllowedValues {
+			if strings.EqualFold(allowed, value) {
+				return allowed, nil
+			}
+		}
+		return "", fmt.Errorf("%s must be one of %s", a.Name, strings.Join(a.AllowedValues, ", "))
+	}
+	return value, nil
+}
+
+// ProductAttribute is a product's value for one of its category's attributes
+type ProductAttribute struct {
+	ProductID uint   `gorm:"primaryKey;autoIncrement:false"`
+	Key       string `gorm:"primaryKey"`
+	Value     string `gorm:"index"`
+}
+
+// SetAttributes sets the product's attribute values along with the map of them
+func (p *Product) SetAttributes(attributes []ProductAttribute) {
+	p.AttributeValues = attributes
+	p.Attributes = make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		p.Attributes[attribute.Key] = attribute.Value
+	}
+}
+
+// ValidateAttributes checks a product's attribute values against its category's attributes and
+// returns them the way they are stored. Every required attribute needs a value and there can be
+// no values for attributes the category doesn't have.
+func ValidateAttributes(definitions []CategoryAttribute, values map[string]string) ([]ProductAttribute, error) {
+	byKey := make(map[string]*CategoryAttribute, len(definitions))
+	for i := range definitions {
+		byKey[definitions[i].Key] = &definitions[i]
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]ProductAttribute, 0, len(values))
+	for _, key := range keys {
+		definition, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("products in this category have no %s attribute", key)
+		}
+		if strings.TrimSpace(values[key]) == "" {
+			continue
+		}
+		value, err := definition.value(values[key])
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, ProductAttribute{Key: key, Value: value})
+	}
+	for _, definition := range definitions {
+		if !definition.Required {
+			continue
+		}
+		if strings.TrimSpace(values[definition.Key]) == "" {
+			return nil, fmt.Errorf("%s is required", definition.Name)
+		}
+	}
+	return attributes, nil
+}
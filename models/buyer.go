@@ -5,6 +5,39 @@ import "gorm.io/gorm"
 type Buyer struct {
 	gorm.Model
 	User
-	Cart   Cart    `json:"cart"`
-	Orders []Order `json:"orders" gorm:"oneToMany"`
+	Cart          Cart    `json:"cart"`
+	Orders        []Order `json:"orders" gorm:"oneToMany"`
+	ReferralCode  string  `json:"referral_code" gorm:"uniqueIndex:idx_buyers_referral_code_unique,where:referral_code <> ''"`
+	WalletBalance uint    `json:"wallet_balance"`
+	// CartRemindersOff stops abandoned cart reminder emails
+	CartRemindersOff bool `json:"cart_reminders_off"`
+}
+
+// BuyerSignup is what a buyer sends to sign up. Everything else on their account, like their
+// wallet balance, is set by the server.
+type BuyerSignup struct {
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Email           string `json:"email"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+	Address         string `json:"address"`
+	PhoneNumber     string `json:"phone_number"`
+	// InviteCode is the referral code of the buyer who invited them
+	InviteCode string `json:"invite_code"`
+}
+
+// Buyer is the account the signup creates
+func (s *BuyerSignup) Buyer() *Buyer {
+	return &Buyer{User: User{
+		FirstName:       s.FirstName,
+		LastName:        s.LastName,
+		Email:           s.Email,
+		Username:        s.Username,
+		Password:        s.Password,
+		ConfirmPassword: s.ConfirmPassword,
+		Address:         s.Address,
+		PhoneNumber:     s.PhoneNumber,
+	}}
 }
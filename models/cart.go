@@ -6,17 +6,22 @@ type Cart struct {
 	gorm.Model
 	BuyerID uint          `json:"buyers_id"`
 	Product []CartProduct `json:"product"`
+	// CouponID is the coupon the buyer has applied to their next checkout
+	CouponID *uint   `json:"coupon_id"`
+	Coupon   *Coupon `json:"coupon,omitempty"`
 }
 
 type CartProduct struct {
 	gorm.Model
-	CartID        uint `json:"cart_id"`
-	ProductID     uint `json:"product_id"`
-	TotalPrice    uint `json:"total_price"`
-	TotalQuantity uint `json:"total_quantity"`
-	OrderStatus   bool `json:"order_status"`
-	SellerId      uint `json:"seller_id"`
-	BuyerId       uint `json:"buyer_id"`
+	CartID    uint `json:"cart_id"`
+	ProductID uint `json:"product_id"`
+	// VariantID is the size, colour and so on the buyer picked when the product has variants
+	VariantID     *uint `json:"variant_id"`
+	TotalPrice    uint  `json:"total_price"`
+	TotalQuantity uint  `json:"total_quantity"`
+	OrderStatus   bool  `json:"order_status"`
+	SellerId      uint  `json:"seller_id"`
+	BuyerId       uint  `json:"buyer_id"`
 }
 
 type ProductDetails struct {
@@ -26,4 +31,5 @@ type ProductDetails struct {
 	Quantity      uint
 	Images        []Image
 	CartProductID uint
+	VariantTitle  string
 }
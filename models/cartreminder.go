@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CartReminder is an email sent to a buyer about the unpaid products left in their cart.
+// A cart gets at most one reminder per stage for each spell of inactivity.
+type CartReminder struct {
+	gorm.Model
+	CartID  uint `json:"cart_id" gorm:"uniqueIndex:idx_cart_reminder_stage"`
+	BuyerID uint `json:"buyer_id" gorm:"index"`
+	// Stage is 1 for the first reminder and 2 for the follow up, which may carry a coupon
+	Stage uint `json:"stage" gorm:"uniqueIndex:idx_cart_reminder_stage"`
+	// LastActivityAt is when the cart was last changed before the reminder was sent
+	LastActivityAt time.Time `json:"last_activity_at" gorm:"uniqueIndex:idx_cart_reminder_stage"`
+	// Token identifies the reminder in the link back to the cart
+	Token    string  `json:"-" gorm:"uniqueIndex"`
+	CouponID *uint   `json:"coupon_id"`
+	Coupon   *Coupon `json:"coupon,omitempty"`
+	// ClickedAt is when the buyer first followed the link, ConvertedAt when the cart was then paid for
+	ClickedAt        *time.Time `json:"clicked_at"`
+	ConvertedAt      *time.Time `json:"converted_at"`
+	PaymentReference string     `json:"payment_reference"`
+}
+
+// AbandonedCart is a cart with unpaid products that is due a reminder
+type AbandonedCart struct {
+	CartID         uint
+	BuyerID        uint
+	Email          string
+	FirstName      string
+	LastActivityAt time.Time
+	Stage          uint
+}
+
+// CartReminderStats sums up how the reminders of one stage did
+type CartReminderStats struct {
+	Stage     uint  `json:"stage"`
+	Sent      int64 `json:"sent"`
+	Clicked   int64 `json:"clicked"`
+	Converted int64 `json:"converted"`
+}
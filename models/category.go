@@ -1,8 +1,94 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
 
+	"gorm.io/gorm"
+)
+
+// Category is a node in the category tree. Path holds the ids from the root down to the category,
+// e.g. "/1/4/", so its descendants are the categories whose path starts with its own.
 type Category struct {
 	gorm.Model
+	Name     string     `json:"name"`
+	Slug     string     `json:"slug" gorm:"uniqueIndex"`
+	ParentID *uint      `json:"parent_id" gorm:"index"`
+	Path     string     `json:"path" gorm:"index"`
+	Children []Category `json:"children,omitempty" gorm:"-"`
+}
+
+// Breadcrumb is one category on the way from the root down to a product's category
+type Breadcrumb struct {
+	ID   uint   `json:"id"`
 	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// ChildPath is the path of a category with id under this one
+func (c *Category) ChildPath(id uint) string {
+	return c.Path + strconv.FormatUint(uint64(id), 10) + "/"
+}
+
+// IsAncestorOf reports whether other is this category or below it
+func (c *Category) IsAncestorOf(other *Category) bool {
+	return strings.HasPrefix(other.Path, c.Path)
+}
+
+// PathIDs are the ids of the categories from the root down to this one
+func (c *Category) PathIDs() []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(c.Path, "/"), "/") {
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// CategoryTree nests a flat list of categories under their parents, each level sorted by name
+func CategoryTree(categories []Category) []Category {
+	children := map[uint][]Category{}
+	for _, category := range categories {
+		parent := uint(0)
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		children[parent] = append(children[parent], category)
+	}
+	var build func(parent uint) []Category
+	build = func(parent uint) []Category {
+		level := children[parent]
+		sort.Slice(level, func(i, j int) bool { return level[i].Name < level[j].Name })
+		for i := range level {
+			level[i].Children = build(level[i].ID)
+		}
+		return level
+	}
+	tree := build(0)
+	if tree == nil {
+		tree = []Category{}
+	}
+	return tree
+}
+
+// Slugify makes a url slug out of a category name, "Health & Beauty" becomes "health-beauty"
+func Slugify(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return slug.String()
 }
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Message report statuses
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+// Conversation is a message thread between a buyer and a seller, optionally about one of the seller's products or orders
+type Conversation struct {
+	gorm.Model
+	// a buyer and seller have one live thread about each product and order, or about neither
+	BuyerID   uint    `json:"buyer_id" gorm:"index;uniqueIndex:idx_conversation_thread,priority:1,where:deleted_at IS NULL"`
+	Buyer     *Buyer  `json:"buyer,omitempty"`
+	SellerID  uint    `json:"seller_id" gorm:"index;uniqueIndex:idx_conversation_thread,priority:2"`
+	Seller    *Seller `json:"seller,omitempty"`
+	ProductID *uint   `json:"product_id" gorm:"uniqueIndex:idx_conversation_thread,priority:3,expression:COALESCE(product_id\\,0)"`
+	OrderID   *uint   `json:"order_id" gorm:"uniqueIndex:idx_conversation_thread,priority:4,expression:COALESCE(order_id\\,0)"`
+	// LastMessage previews the newest message in a conversation list
+	LastMessage   string    `json:"last_message"`
+	LastMessageAt time.Time `json:"last_message_at" gorm:"index"`
+	BuyerUnread   int64     `json:"buyer_unread"`
+	SellerUnread  int64     `json:"seller_unread"`
+}
+
+// HasParticipant reports whether the buyer or seller is in the conversation
+func (c *Conversation) HasParticipant(participantType string, participantID uint) bool {
+	if participantType == RecipientSeller {
+		return c.SellerID == participantID
+	}
+	return c.BuyerID == participantID
+}
+
+// Counterpart is the other side of the conversation from participantType
+func (c *Conversation) Counterpart(participantType string) (string, uint) {
+	if participantType == RecipientSeller {
+		return RecipientBuyer, c.BuyerID
+	}
+	return RecipientSeller, c.SellerID
+}
+
+// Message is one message in a conversation
+type Message struct {
+	ID             uint                `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time           `json:"created_at"`
+	ConversationID uint                `json:"conversation_id" gorm:"index"`
+	Conversation   *Conversation       `json:"-"`
+	SenderType     string              `json:"sender_type"`
+	SenderID       uint                `json:"sender_id"`
+	Body           string              `json:"body"`
+	Attachments    []MessageAttachment `json:"attachments"`
+	// ReadAt is the read receipt, set when the other side opens the conversation
+	ReadAt *time.Time `json:"read_at"`
+}
+
+// MessageAttachment is an image sent with a message
+type MessageAttachment struct {
+	ID        uint   `json:"id" gorm:"primarykey"`
+	MessageID uint   `json:"-" gorm:"index"`
+	URL       string `json:"url"`
+}
+
+// MessageReport is a participant flagging a message from the other side as abusive
+type MessageReport struct {
+	gorm.Model
+	MessageID    uint     `json:"message_id" gorm:"uniqueIndex:idx_message_report"`
+	Message      *Message `json:"message,omitempty"`
+	ReporterType string   `json:"reporter_type" gorm:"uniqueIndex:idx_message_report"`
+	ReporterID   uint     `json:"reporter_id" gorm:"uniqueIndex:idx_message_report"`
+	Reason       string   `json:"reason"`
+	Status       string   `json:"status" gorm:"index"`
+}
+
+// ConversationPage is one page of a user's conversations
+type ConversationPage struct {
+	Conversations []Conversation `json:"conversations"`
+	Page          int            `json:"page"`
+	Limit         int            `json:"limit"`
+	Total         int64          `json:"total"`
+	// Unread counts the unread messages across all of the user's conversations
+	Unread int64 `json:"unread"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Coupon is a one-off percentage discount for a single buyer
+type Coupon struct {
+	gorm.Model
+	Code       string    `json:"code" gorm:"uniqueIndex"`
+	BuyerID    uint      `json:"buyer_id" gorm:"index"`
+	PercentOff uint      `json:"percent_off"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// RedeemedAt is set, with the reference paid with, once an order uses the coupon
+	RedeemedAt       *time.Time `json:"redeemed_at"`
+	PaymentReference string     `json:"payment_reference"`
+}
+
+// Usable reports whether the buyer can use the coupon at the time
+func (c *Coupon) Usable(buyerID uint, at time.Time) bool {
+	return c.BuyerID == buyerID && c.RedeemedAt == nil && at.Before(c.ExpiresAt) &&
+		c.PercentOff > 0 && c.PercentOff <= 100
+}
+
+// Discount is the part of amount the coupon takes off, rounded down
+func (c *Coupon) Discount(amount uint) uint {
+	return amount * c.PercentOff / 100
+}
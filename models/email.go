@@ -0,0 +1,17 @@
+package models
+
+// EmailMessage is an email ready to hand to a Mailer
+type EmailMessage struct {
+	To          string            `json:"to"`
+	Subject     string            `json:"subject"`
+	HTML        string            `json:"html"`
+	Text        string            `json:"text"`
+	Attachments []EmailAttachment `json:"attachments"`
+}
+
+// EmailAttachment is a file sent along with an email
+type EmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
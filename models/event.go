@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+)
+
+// Domain event types
+const (
+	EventProductCreated       = "ProductCreated"
+	EventProductPriceChanged  = "ProductPriceChanged"
+	EventProductStockChanged  = "ProductStockChanged"
+	EventProductStatusChanged = "ProductStatusChanged"
+	EventProductImagesRemoved = "ProductImagesRemoved"
+	EventOrderPaid            = "OrderPaid"
+	EventOrderShipped         = "OrderShipped"
+	EventOrderDelivered       = "OrderDelivered"
+	EventOrderCancelled       = "OrderCancelled"
+	EventOrderRefunded        = "OrderRefunded"
+)
+
+// OrderStatusEvents maps an order status to the event recorded when an order moves to it
+var OrderStatusEvents = map[string]string{
+	OrderStatusShipped:   EventOrderShipped,
+	OrderStatusDelivered: EventOrderDelivered,
+	OrderStatusCancelled: EventOrderCancelled,
+	OrderStatusRefunded:  EventOrderRefunded,
+}
+
+// Aggregates events are recorded against. Events for one aggregate are delivered in order.
+const (
+	AggregateProduct = "product"
+	AggregateOrder   = "order"
+)
+
+// Domain event statuses. Dead events failed too many times and no longer hold up their aggregate.
+const (
+	EventStatusPending   = "pending"
+	EventStatusPublished = "published"
+	EventStatusDead      = "dead"
+)
+
+// DomainEvent is an event in the outbox. It is written in the same transaction as the change it
+// describes, so an event is recorded if and only if the change was committed.
+type DomainEvent struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time `json:"occurred_at"`
+	Type          string    `json:"type" gorm:"index"`
+	AggregateType string    `json:"aggregate_type" gorm:"uniqueIndex:idx_domain_events_aggregate_sequence"`
+	AggregateID   uint      `json:"aggregate_id" gorm:"uniqueIndex:idx_domain_events_aggregate_sequence"`
+	// Sequence numbers the events of one aggregate from 1
+	Sequence      uint       `json:"sequence" gorm:"uniqueIndex:idx_domain_events_aggregate_sequence"`
+	Payload       string     `json:"payload" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"index"`
+	Attempts      uint       `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError     string     `json:"last_error"`
+	PublishedAt   *time.Time `json:"published_at"`
+}
+
+// ProductEvent is the payload of ProductCreated
+type ProductEvent struct {
+	ProductID  uint   `json:"product_id"`
+	SellerID   uint   `json:"seller_id"`
+	CategoryID uint   `json:"category_id"`
+	Title      string `json:"title"`
+	Price      uint   `json:"price"`
+	Quantity   uint   `json:"quantity"`
+}
+
+// ProductStatusEvent is the payload of ProductStatusChanged
+type ProductStatusEvent struct {
+	ProductID uint   `json:"product_id"`
+	SellerID  uint   `json:"seller_id"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+}
+
+// ImagesRemovedEvent is the payload of ProductImagesRemoved. The files at URLs are no longer used.
+type ImagesRemovedEvent struct {
+	ProductID uint     `json:"product_id"`
+	SellerID  uint     `json:"seller_id"`
+	URLs      []string `json:"urls"`
+}
+
+// PriceChangedEvent is the payload of ProductPriceChanged
+type PriceChangedEvent struct {
+	ProductID uint `json:"product_id"`
+	SellerID  uint `json:"seller_id"`
+	OldPrice  uint `json:"old_price"`
+	NewPrice  uint `json:"new_price"`
+}
+
+// StockChangedEvent is the payload of ProductStockChanged
+type StockChangedEvent struct {
+	ProductID   uint `json:"product_id"`
+	SellerID    uint `json:"seller_id"`
+	OldQuantity uint `json:"old_quantity"`
+	NewQuantity uint `json:"new_quantity"`
+	Price       uint `json:"price"`
+}
+
+// OrderEvent is the payload of the order events
+type OrderEvent struct {
+	OrderID          uint   `json:"order_id"`
+	InvoiceNumber    string `json:"invoice_number,omitempty"`
+	SellerID         uint   `json:"seller_id"`
+	BuyerID          uint   `json:"buyer_id"`
+	ProductID        uint   `json:"product_id"`
+	Quantity         uint   `json:"quantity"`
+	Subtotal         uint   `json:"subtotal"`
+	TaxAmount        uint   `json:"tax_amount"`
+	Total            uint   `json:"total"`
+	Status           string `json:"status"`
+	StatusNote       string `json:"status_note,omitempty"`
+	PaymentReference string `json:"payment_reference,omitempty"`
+}
+
+// NewOrderEvent describes an order for an event payload
+func NewOrderEvent(order *Order) OrderEvent {
+	return OrderEvent{
+		OrderID:          order.ID,
+		SellerID:         order.SellerId,
+		BuyerID:          order.BuyerId,
+		ProductID:        order.ProductId,
+		Quantity:         order.Quantity,
+		Subtotal:         order.Subtotal,
+		TaxAmount:        order.TaxAmount,
+		Total:            order.Total,
+		Status:           order.Status,
+		StatusNote:       order.StatusNote,
+		PaymentReference: order.PaymentReference,
+	}
+}
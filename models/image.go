@@ -1,9 +1,85 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sort"
 
+	"gorm.io/gorm"
+)
+
+// MaxProductImages is how many pictures a product can have
+const MaxProductImages = 8
+
+// Image is a picture of a product. The one at the lowest position is the product's primary image.
 type Image struct {
 	gorm.Model
-	ProductId uint   `json:"product_id"`
+	ProductId uint   `json:"product_id" gorm:"index"`
 	Url       string `json:"url"`
+	AltText   string `json:"alt_text"`
+	Position  int    `json:"position"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	// Variants are the picture scaled down, with and without WebP
+	Variants ImageVariants `json:"variants,omitempty" gorm:"type:text"`
+}
+
+// ImageVariants maps a scaled down version of a picture, e.g. "thumbnail" or "thumbnail_webp", to its url.
+// It is stored as json.
+type ImageVariants map[string]string
+
+// URLs returns where the variants are stored
+func (v ImageVariants) URLs() []string {
+	urls := make([]string, 0, len(v))
+	for _, url := range v {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func (v ImageVariants) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func (v *ImageVariants) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	case []byte:
+		return json.Unmarshal(data, v)
+	}
+	return errors.New("image variants must be stored as text")
+}
+
+// SortImages puts the product's pictures in order, primary first
+func (p *Product) SortImages() {
+	sort.SliceStable(p.Images, func(i, j int) bool {
+		if p.Images[i].Position != p.Images[j].Position {
+			return p.Images[i].Position < p.Images[j].Position
+		}
+		return p.Images[i].ID < p.Images[j].ID
+	})
+}
+
+// ImageURLs returns where the product's pictures and its variants' pictures are stored, in every size
+func (p *Product) ImageURLs() []string {
+	var urls []string
+	for _, image := range p.Images {
+		urls = append(append(urls, image.Url), image.Variants.URLs()...)
+	}
+	for _, variant := range p.Variants {
+		for _, image := range variant.Images {
+			urls = append(append(urls, image.Url), image.Variants.URLs()...)
+		}
+	}
+	return urls
 }
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invoice groups the orders a buyer paid a single seller for in one payment.
+// Sequence is numbered per seller, so each seller's invoices have no gaps.
+type Invoice struct {
+	gorm.Model
+	Number           string     `json:"number" gorm:"uniqueIndex"`
+	SellerID         uint       `json:"seller_id" gorm:"uniqueIndex:idx_invoices_seller_sequence"`
+	Sequence         uint       `json:"sequence" gorm:"uniqueIndex:idx_invoices_seller_sequence"`
+	Seller           Seller     `json:"-"`
+	BuyerID          uint       `json:"buyer_id" gorm:"index"`
+	Buyer            Buyer      `json:"-"`
+	PaymentReference string     `json:"payment_reference"`
+	Orders           []Order    `json:"orders"`
+	Subtotal         uint       `json:"subtotal"`
+	Tax              uint       `json:"tax"`
+	Shipping         uint       `json:"shipping"`
+	Total            uint       `json:"total"`
+	EmailedAt        *time.Time `json:"emailed_at"`
+}
+
+// InvoiceNumber formats a seller's invoice sequence, e.g. INV-12-000042
+func InvoiceNumber(sellerID, sequence uint) string {
+	return fmt.Sprintf("INV-%d-%06d", sellerID, sequence)
+}
+
+// FileName is the name the invoice PDF is downloaded and attached as
+func (i Invoice) FileName() string {
+	return i.Number + ".pdf"
+}
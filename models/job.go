@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Background job statuses. Failed jobs have used up their attempts and are not retried.
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// Job is a unit of background work picked up by the job runner
+type Job struct {
+	gorm.Model
+	Type        string    `json:"type" gorm:"index"`
+	Payload     string    `json:"payload" gorm:"type:text"`
+	Status      string    `json:"status" gorm:"index"`
+	Attempts    uint      `json:"attempts"`
+	MaxAttempts uint      `json:"max_attempts"`
+	RunAt       time.Time `json:"run_at" gorm:"index"`
+	// UniqueKey stops the same job being queued twice, e.g. one scheduled run per tick across workers
+	UniqueKey   *string    `json:"unique_key,omitempty" gorm:"uniqueIndex"`
+	LockedBy    string     `json:"locked_by"`
+	LockedUntil *time.Time `json:"locked_until"`
+	LastError   string     `json:"last_error"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Listing moderation statuses. A product's moderation status is whether its listing as it stands has
+// been approved; a review's is whether an admin has decided it yet.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// What put a listing in the review queue
+const (
+	ReviewNewListing    = "new_listing"
+	ReviewEditedListing = "edited_listing"
+	ReviewReported      = "reported"
+)
+
+// ListingReportReasons are the reasons a buyer can report a listing for
+var ListingReportReasons = []string{"counterfeit", "prohibited", "misleading", "offensive", "scam", "other"}
+
+// ListingReview is a product in the moderation queue. A product has at most one pending review;
+// edits and reports made while it waits are added to it.
+type ListingReview struct {
+	gorm.Model
+	ProductID uint     `json:"product_id" gorm:"index"`
+	Product   *Product `json:"product,omitempty"`
+	SellerID  uint     `json:"seller_id" gorm:"index"`
+	Trigger   string   `json:"trigger"`
+	Status    string   `json:"status" gorm:"index"`
+	// Flags are the banned keywords pre-screening found in the listing, comma separated
+	Flags       string          `json:"flags"`
+	ReportCount int             `json:"report_count"`
+	Reports     []ListingReport `json:"reports,omitempty" gorm:"foreignKey:ReviewID"`
+	// Reason is the admin's reason for their decision, which is emailed to the seller
+	Reason     string     `json:"reason,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+// NewListingReview is a pending review, flagged with the banned keywords found in the listing
+func NewListingReview(trigger string, flags []string) ListingReview {
+	return ListingReview{Trigger: trigger, Status: ModerationPending, Flags: strings.Join(flags, ",")}
+}
+
+// HoldForReview puts a new listing in the moderation queue, keeping it from buyers until it is approved
+func (p *Product) HoldForReview(flags []string) {
+	review := NewListingReview(ReviewNewListing, flags)
+	review.SellerID = p.SellerId
+	p.Reviews = []ListingReview{review}
+	p.ModerationStatus = ModerationPending
+	if p.Status == ProductActive {
+		p.Status = ProductPendingReview
+	}
+}
+
+// Holds reports whether the listing is kept from buyers until it is approved: new listings are,
+// and so are ones pre-screening flagged
+func (r *ListingReview) Holds() bool {
+	return r.Trigger == ReviewNewListing || r.Flags != ""
+}
+
+// AddFlags adds banned keywords found in the listing to the review's flags
+func (r *ListingReview) AddFlags(flags []string) {
+	existing := map[string]bool{}
+	var all []string
+	for _, flag := range append(strings.Split(r.Flags, ","), flags...) {
+		if flag != "" && !existing[flag] {
+			existing[flag] = true
+			all = append(all, flag)
+		}
+	}
+	r.Flags = strings.Join(all, ",")
+}
+
+// ListingReport is a buyer reporting a listing, which puts it in the review queue
+type ListingReport struct {
+	gorm.Model
+	ProductID uint   `json:"product_id" gorm:"uniqueIndex:idx_listing_report"`
+	BuyerID   uint   `json:"buyer_id" gorm:"uniqueIndex:idx_listing_report"`
+	ReviewID  uint   `json:"review_id" gorm:"index"`
+	Reason    string `json:"reason"`
+	Details   string `json:"details"`
+}
+
+// Check validates the report's reason
+func (r *ListingReport) Check() error {
+	for _, reason := range ListingReportReasons {
+		if r.Reason == reason {
+			return nil
+		}
+	}
+	return fmt.Errorf("reason must be one of %s", strings.Join(ListingReportReasons, ", "))
+}
+
+// ScreenListing returns the banned keywords that appear as whole words in any of texts, ignoring case
+func ScreenListing(keywords []string, texts ...string) []string {
+	text := strings.Join(texts, "\n")
+	var found []string
+	for _, keyword := range keywords {
+		pattern := `(?i)\b` + regexp.QuoteMeta(keyword) + `\b`
+		if regexp.MustCompile(pattern).MatchString(text) {
+			found = append(found, keyword)
+		}
+	}
+	return found
+}
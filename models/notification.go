@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Who a notification is for
+const (
+	RecipientBuyer  = "buyer"
+	RecipientSeller = "seller"
+)
+
+// Notification types
+const (
+	NotificationNewOrder       = "new_order"
+	NotificationPaymentSuccess = "payment_success"
+	NotificationOrderStatus    = "order_status"
+	NotificationReview         = "review"
+	NotificationNewMessage     = "new_message"
+	NotificationQuestion       = "question"
+	NotificationAnswer         = "answer"
+)
+
+// Notification is an entry in a buyer's or seller's in-app inbox
+type Notification struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RecipientType string     `json:"-" gorm:"index:idx_notification_recipient"`
+	RecipientID   uint       `json:"-" gorm:"index:idx_notification_recipient"`
+	Type          string     `json:"type"`
+	Title         string     `json:"title"`
+	Body          string     `json:"body"`
+	Link          string     `json:"link"`
+	Read          bool       `json:"read" gorm:"index"`
+	ReadAt        *time.Time `json:"read_at"`
+	// DedupKey stops an event redelivered by the relay notifying anyone twice
+	DedupKey *string `json:"-" gorm:"uniqueIndex"`
+}
+
+// NotificationPage is one page of a user's notifications
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	Page          int            `json:"page"`
+	Limit         int            `json:"limit"`
+	Total         int64          `json:"total"`
+	Unread        int64          `json:"unread"`
+}
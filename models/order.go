@@ -1,9 +1,28 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Order statuses. An order is paid when it is created and the seller moves it on from there.
+const (
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// orderTransitions lists the statuses an order can move to from each status
+var orderTransitions = map[string][]string{
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusCancelled},
+	OrderStatusDelivered: {OrderStatusRefunded},
+	OrderStatusCancelled: {OrderStatusRefunded},
+}
+
 type Order struct {
 	gorm.Model
 	SellerId  uint `json:"seller_id"`
@@ -12,13 +31,50 @@ type Order struct {
 	Buyer     Buyer
 	ProductId uint `json:"product_id"`
 	Product   Product
+	// VariantID, VariantTitle and SKU are the variant that was bought, the title and SKU as they were then
+	VariantID    *uint  `json:"variant_id"`
+	VariantTitle string `json:"variant_title"`
+	SKU          string `json:"sku"`
+	Quantity     uint   `json:"quantity"`
+	// Subtotal is the amount before tax, TaxAmount the tax charged and Total what the buyer paid
+	Subtotal     uint   `json:"subtotal"`
+	TaxName      string `json:"tax_name"`
+	TaxRate      uint   `json:"tax_rate_basis_points"`
+	TaxInclusive bool   `json:"tax_inclusive"`
+	TaxExempt    bool   `json:"tax_exempt"`
+	TaxAmount    uint   `json:"tax_amount"`
+	Total        uint   `json:"total"`
+	// Discount is what a coupon took off the listed price, before tax
+	Discount uint `json:"discount"`
+	// PaymentReference is the paystack reference the order was paid with
+	PaymentReference string `json:"payment_reference"`
+	InvoiceID        *uint  `json:"invoice_id" gorm:"index"`
+	Status           string `json:"status" gorm:"default:paid;index"`
+	// StatusNote is the seller's note on the latest status change, e.g. a tracking number
+	StatusNote      string     `json:"status_note"`
+	StatusUpdatedAt *time.Time `json:"status_updated_at"`
+}
+
+// CanMoveTo reports whether the order can go from its current status to status
+func (o Order) CanMoveTo(status string) bool {
+	for _, next := range orderTransitions[o.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
 }
 
 type OrderProducts struct {
+	OrderID      uint
+	Status       string
 	Fname        string
 	Lname        string
 	CategoryName string
 	Title        string
 	Price        uint
 	Quantity     uint
+	Subtotal     uint
+	TaxAmount    uint
+	Total        uint
 }
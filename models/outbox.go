@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outbox email statuses. Dead emails have used up their attempts and are no longer retried.
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusDead    = "dead"
+)
+
+// EmailOutbox is an email waiting to be sent, or the record of one that was
+type EmailOutbox struct {
+	gorm.Model
+	To            string           `json:"to"`
+	Subject       string           `json:"subject"`
+	HTML          string           `json:"-"`
+	Text          string           `json:"-"`
+	Attachments   EmailAttachments `json:"-" gorm:"type:bytea"`
+	Status        string           `json:"status" gorm:"index"`
+	Attempts      uint             `json:"attempts"`
+	NextAttemptAt time.Time        `json:"next_attempt_at" gorm:"index"`
+	LastError     string           `json:"last_error"`
+	SentAt        *time.Time       `json:"sent_at"`
+}
+
+// Message is the email to hand to a Mailer
+func (e *EmailOutbox) Message() *EmailMessage {
+	return &EmailMessage{
+		To:          e.To,
+		Subject:     e.Subject,
+		HTML:        e.HTML,
+		Text:        e.Text,
+		Attachments: e.Attachments,
+	}
+}
+
+// EmailAttachments is stored as json so queued emails keep their files
+type EmailAttachments []EmailAttachment
+
+func (a EmailAttachments) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *EmailAttachments) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+	data, ok := value.([]byte)
+	if !ok {
+		return errors.New("email attachments must be stored as bytes")
+	}
+	return json.Unmarshal(data, a)
+}
@@ -1,12 +1,16 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Product struct {
 	gorm.Model
-	SellerId uint `json:"seller_id"`
+	SellerId uint `json:"seller_id" gorm:"index:idx_product_seller_sku,unique,where:sku <> ''"`
+	// SKU is the seller's own code for the product, which bulk imports match products on
+	SKU string `json:"sku,omitempty" gorm:"index:idx_product_seller_sku,unique,where:sku <> ''"`
 
 	CategoryId              uint `gorm:"foreignKey:categories(id)" json:"category_id"`
 	Category                Category
@@ -18,4 +22,23 @@ type Product struct {
 	TotalRatings            uint    `json:"total_ratings"`
 	NumberOfRatingsReceived uint    `json:"number_of_ratings_received"`
 	Quantity                uint    `json:"quantity"`
+	// Status is where the product is in being listed, see ProductActive. Products made before
+	// there were statuses are active.
+	Status string `json:"status" gorm:"default:active;index"`
+	// PublishAt and UnpublishAt are when a draft goes live and when an active product is archived
+	PublishAt   *time.Time `json:"publish_at,omitempty" gorm:"index"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty" gorm:"index"`
+	// ModerationStatus is whether an admin has approved the listing as it stands, see ModerationApproved.
+	// Reviews are the times it has been in the moderation queue.
+	ModerationStatus string          `json:"moderation_status" gorm:"default:approved"`
+	Reviews          []ListingReview `json:"-"`
+	// Options and Variants are set when the product comes in sizes, colours and the like.
+	// Quantity is then the stock of all the variants together.
+	Options  []ProductOption  `json:"options,omitempty"`
+	Variants []ProductVariant `json:"variants,omitempty"`
+	// Attributes are the product's values for its category's attributes, by attribute key
+	Attributes      map[string]string  `json:"attributes,omitempty" gorm:"-"`
+	AttributeValues []ProductAttribute `json:"-" gorm:"foreignKey:ProductID"`
+	// Breadcrumbs are the product's category and the ones above it, starting at the root
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty" gorm:"-"`
 }
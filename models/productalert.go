@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of product alert notification
+const (
+	AlertBackInStock = "back_in_stock"
+	AlertPriceDrop   = "price_drop"
+)
+
+// ProductAlert is a buyer watching a product for it coming back in stock or its price
+// dropping below PriceBelow. A buyer has one alert per product.
+type ProductAlert struct {
+	gorm.Model
+	BuyerID     uint    `json:"buyer_id" gorm:"uniqueIndex:idx_product_alert_buyer"`
+	ProductID   uint    `json:"product_id" gorm:"uniqueIndex:idx_product_alert_buyer;index"`
+	Product     Product `json:"product"`
+	BackInStock bool    `json:"back_in_stock"`
+	// PriceBelow is 0 when the buyer is not watching the price
+	PriceBelow uint `json:"price_below"`
+}
+
+// AlertNotification is an alert that has gone off, waiting to be emailed with the buyer's others
+type AlertNotification struct {
+	gorm.Model
+	AlertID   uint    `json:"alert_id"`
+	BuyerID   uint    `json:"buyer_id" gorm:"index"`
+	Buyer     Buyer   `json:"-"`
+	ProductID uint    `json:"product_id"`
+	Product   Product `json:"-"`
+	Kind      string  `json:"kind"`
+	// Price is the product's price when the alert went off
+	Price uint `json:"price"`
+	// DedupKey stops an event redelivered by the relay setting an alert off twice
+	DedupKey string     `json:"-" gorm:"uniqueIndex"`
+	SentAt   *time.Time `json:"sent_at" gorm:"index"`
+	// ClaimedUntil hides the notification from other senders while it is being emailed
+	ClaimedUntil *time.Time `json:"-"`
+}
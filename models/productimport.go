@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Formats a seller's catalogue is imported and exported in
+const (
+	CatalogueCSV   = "csv"
+	CatalogueJSONL = "jsonl"
+)
+
+// ProductImport is a bulk import of a seller's products, run in the background.
+// It goes through the job statuses and counts its rows as they are done.
+type ProductImport struct {
+	gorm.Model
+	SellerID      uint   `json:"seller_id" gorm:"index"`
+	Format        string `json:"format"`
+	Status        string `json:"status"`
+	TotalRows     int    `json:"total_rows"`
+	ProcessedRows int    `json:"processed_rows"`
+	Created       int    `json:"created"`
+	Updated       int    `json:"updated"`
+	Failed        int    `json:"failed"`
+	// Data is the uploaded file, kept until the import has run
+	Data string `json:"-" gorm:"type:text"`
+	// ErrorList is the JSON list behind Errors
+	ErrorList  string           `json:"-" gorm:"type:text"`
+	Errors     []ImportRowError `json:"errors" gorm:"-"`
+	FinishedAt *time.Time       `json:"finished_at"`
+}
+
+// AddError records why a row wasn't imported
+func (i *ProductImport) AddError(row ProductImportRow, err error) {
+	i.Failed++
+	i.Errors = append(i.Errors, ImportRowError{Row: row.Row, SKU: row.SKU, Error: err.Error()})
+	list, _ := json.Marshal(i.Errors)
+	i.ErrorList = string(list)
+}
+
+func (i *ProductImport) AfterFind(tx *gorm.DB) error {
+	i.Errors = []ImportRowError{}
+	if i.ErrorList == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(i.ErrorList), &i.Errors)
+}
+
+// ImportRowError is a row of an import that failed. Row counts from 1 for the first product.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// ProductImportRow is one product in an imported or exported catalogue
+type ProductImportRow struct {
+	Row         int               `json:"-"`
+	SKU         string            `json:"sku"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	CategoryID  uint              `json:"category_id"`
+	Price       uint              `json:"price"`
+	Quantity    uint              `json:"quantity"`
+	ImageURLs   []string          `json:"image_urls,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// Check checks the fields every imported product needs
+func (r *ProductImportRow) Check() error {
+	r.SKU = strings.TrimSpace(r.SKU)
+	r.Title = strings.TrimSpace(r.Title)
+	switch {
+	case r.SKU == "":
+		return fmt.Errorf("sku is required")
+	case r.Title == "":
+		return fmt.Errorf("title is required")
+	case r.CategoryID == 0:
+		return fmt.Errorf("category_id is required")
+	case r.Price == 0:
+		return fmt.Errorf("price must be more than 0")
+	}
+	for _, url := range r.ImageURLs {
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return fmt.Errorf("image url %s must be http or https", url)
+		}
+	}
+	return nil
+}
+
+// CatalogueRow is how a product is exported, in the same shape it is imported
+func CatalogueRow(product *Product) ProductImportRow {
+	row := ProductImportRow{
+		SKU:         product.SKU,
+		Title:       product.Title,
+		Description: product.Description,
+		CategoryID:  product.CategoryId,
+		Price:       product.Price,
+		Quantity:    product.Quantity,
+		Attributes:  product.Attributes,
+	}
+	for _, image := range product.Images {
+		row.ImageURLs = append(row.ImageURLs, image.Url)
+	}
+	return row
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Product statuses. Only active products are shown to buyers; pending review products are waiting
+// for an admin and archived ones are no longer sold.
+const (
+	ProductDraft         = "draft"
+	ProductPendingReview = "pending_review"
+	ProductActive        = "active"
+	ProductArchived      = "archived"
+)
+
+// sellerProductStatuses are the statuses a seller can move their product to
+var sellerProductStatuses = map[string]bool{ProductDraft: true, ProductActive: true, ProductArchived: true}
+
+// ProductStatusRequest is a seller's change to a product's status and when it goes live or comes down
+type ProductStatusRequest struct {
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// Check validates the request as of now. A product with a publish time waits for it as a draft,
+// so the status can be left out and becomes draft, or active when nothing is scheduled.
+func (r *ProductStatusRequest) Check(now time.Time) error {
+	if r.Status == "" {
+		r.Status = ProductActive
+		if r.PublishAt != nil {
+			r.Status = ProductDraft
+		}
+	}
+	if !sellerProductStatuses[r.Status] {
+		return fmt.Errorf("status must be %s, %s or %s", ProductDraft, ProductActive, ProductArchived)
+	}
+	if r.PublishAt != nil {
+		if r.Status != ProductDraft {
+			return fmt.Errorf("only a draft can be scheduled to publish")
+		}
+		if !r.PublishAt.After(now) {
+			return fmt.Errorf("publish_at must be in the future")
+		}
+	}
+	if r.UnpublishAt != nil {
+		if r.Status == ProductArchived {
+			return fmt.Errorf("an archived product can't be scheduled to unpublish")
+		}
+		if !r.UnpublishAt.After(now) {
+			return fmt.Errorf("unpublish_at must be in the future")
+		}
+		if r.PublishAt != nil && !r.UnpublishAt.After(*r.PublishAt) {
+			return fmt.Errorf("unpublish_at must be after publish_at")
+		}
+	}
+	return nil
+}
+
+// IsActive reports whether buyers can see and buy the product
+func (p *Product) IsActive() bool {
+	return p.Status == ProductActive
+}
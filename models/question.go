@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductQuestion is a buyer's public question on a product
+type ProductQuestion struct {
+	gorm.Model
+	ProductID uint     `json:"product_id" gorm:"index"`
+	Product   *Product `json:"product,omitempty"`
+	BuyerID   uint     `json:"-" gorm:"index"`
+	// AskerName is the buyer's name when they asked, shown publicly instead of their account
+	AskerName   string `json:"asker_name"`
+	Body        string `json:"body"`
+	AnswerCount int64  `json:"answer_count"`
+	// SellerAnswered is set once the product's seller answers, until then it is on their unanswered list
+	SellerAnswered bool            `json:"seller_answered" gorm:"index"`
+	Answers        []ProductAnswer `json:"answers" gorm:"foreignKey:QuestionID"`
+}
+
+// ProductAnswer answers a product question, from the product's seller or a buyer who bought it
+type ProductAnswer struct {
+	gorm.Model
+	QuestionID   uint   `json:"question_id" gorm:"index"`
+	AnswererType string `json:"answerer_type"`
+	AnswererID   uint   `json:"-"`
+	AnswererName string `json:"answerer_name"`
+	Body         string `json:"body"`
+	Upvotes      int64  `json:"upvotes"`
+}
+
+// AnswerUpvote is one buyer's upvote on an answer
+type AnswerUpvote struct {
+	AnswerID  uint `gorm:"primaryKey"`
+	BuyerID   uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// ProductQuestionPage is one page of questions with their answers, most upvoted first
+type ProductQuestionPage struct {
+	Questions []ProductQuestion `json:"questions"`
+	Page      int               `json:"page"`
+	Limit     int               `json:"limit"`
+	Total     int64             `json:"total"`
+}
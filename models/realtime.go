@@ -0,0 +1,24 @@
+package models
+
+import "encoding/json"
+
+// Live update names pushed to a buyer's or seller's open event streams
+const (
+	RealtimeOrderCreated     = "order.created"
+	RealtimePaymentConfirmed = "payment.confirmed"
+	RealtimeOrderStatus      = "order.status"
+	RealtimeNotification     = "notification"
+	RealtimeMessageSent      = "message"
+	RealtimeMessagesRead     = "message.read"
+)
+
+// RealtimeChannel is the Postgres channel live updates travel on between the worker and api processes
+const RealtimeChannel = "oja_realtime"
+
+// RealtimeMessage is a live update for every open event stream of one buyer or seller
+type RealtimeMessage struct {
+	RecipientType string          `json:"recipient_type"`
+	RecipientID   uint            `json:"recipient_id"`
+	Event         string          `json:"event"`
+	Data          json.RawMessage `json:"data"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReferralStatusPending  = "pending"
+	ReferralStatusRewarded = "rewarded"
+	ReferralStatusRejected = "rejected"
+)
+
+// Referral tracks a buyer who signed up with another buyer's invite code
+type Referral struct {
+	gorm.Model
+	ReferrerID      uint       `json:"referrer_id" gorm:"index"`
+	RefereeID       uint       `json:"referee_id" gorm:"uniqueIndex"`
+	RefereeUsername string     `json:"referee_username"`
+	Code            string     `json:"code"`
+	Status          string     `json:"status" gorm:"index"`
+	RejectReason    string     `json:"reject_reason,omitempty"`
+	RewardAmount    uint       `json:"reward_amount"`
+	RewardedAt      *time.Time `json:"rewarded_at"`
+}
@@ -10,4 +10,5 @@ type Seller struct {
 	Rating                  uint      `json:"rating"`
 	TotalRatings            uint      `json:"total_ratings"`
 	NumberOfRatingsReceived uint      `json:"number_of_ratings_received"`
+	LastInvoiceSequence     uint      `json:"-"`
 }
@@ -0,0 +1,168 @@
+package models
+
+import "gorm.io/gorm"
+
+// TaxRule is the VAT treatment for products in a category.
+// The rule with CategoryID 0 applies to every category without its own rule.
+type TaxRule struct {
+	gorm.Model
+	CategoryID uint   `json:"category_id" gorm:"uniqueIndex"`
+	Name       string `json:"name"`
+	// RateBasisPoints is the rate in hundredths of a percent, so 750 is 7.5%
+	RateBasisPoints uint `json:"rate_basis_points"`
+	Exempt          bool `json:"exempt"`
+	// Inclusive means listed prices already contain the tax
+	Inclusive bool `json:"inclusive"`
+}
+
+// TaxLine is the tax worked out for an amount under a single rule
+type TaxLine struct {
+	Name            string `json:"name"`
+	RateBasisPoints uint   `json:"rate_basis_points"`
+	Exempt          bool   `json:"exempt"`
+	Inclusive       bool   `json:"inclusive"`
+	Net             uint   `json:"net"`
+	Tax             uint   `json:"tax"`
+	Gross           uint   `json:"gross"`
+}
+
+// Apply works out the tax on a listed amount, rounding half up to the nearest unit
+func (r TaxRule) Apply(amount uint) TaxLine {
+	line := TaxLine{
+		Name:            r.Name,
+		RateBasisPoints: r.RateBasisPoints,
+		Exempt:          r.Exempt,
+		Inclusive:       r.Inclusive,
+		Net:             amount,
+		Gross:           amount,
+	}
+	if r.Exempt || r.RateBasisPoints == 0 {
+		line.RateBasisPoints = 0
+		return line
+	}
+	if r.Inclusive {
+		divisor := 10000 + r.RateBasisPoints
+		line.Tax = (amount*r.RateBasisPoints + divisor/2) / divisor
+		line.Net = amount - line.Tax
+		return line
+	}
+	line.Tax = (amount*r.RateBasisPoints + 5000) / 10000
+	line.Gross = amount + line.Tax
+	return line
+}
+
+// TaxRules is the full set of configured rules
+type TaxRules []TaxRule
+
+// For returns the rule for a category, falling back to the default rule
+func (rules TaxRules) For(categoryID uint) TaxRule {
+	var fallback TaxRule
+	for _, rule := range rules {
+		if rule.CategoryID == categoryID {
+			return rule
+		}
+		if rule.CategoryID == 0 {
+			fallback = rule
+		}
+	}
+	return fallback
+}
+
+// CheckoutLine is a cart product priced with its tax
+type CheckoutLine struct {
+	CartProductID uint   `json:"cart_product_id"`
+	ProductID     uint   `json:"product_id"`
+	VariantID     *uint  `json:"variant_id,omitempty"`
+	VariantTitle  string `json:"variant_title,omitempty"`
+	SKU           string `json:"sku,omitempty"`
+	SellerID      uint   `json:"seller_id"`
+	BuyerID       uint   `json:"buyer_id"`
+	CategoryID    uint   `json:"category_id"`
+	Title         string `json:"title"`
+	Quantity      uint   `json:"quantity"`
+	// Discount is what the coupon took off the listed price, tax is worked out on the rest
+	Discount uint `json:"discount"`
+	TaxLine
+}
+
+// CheckoutSummary is what a buyer pays for the unpaid products in their cart
+type CheckoutSummary struct {
+	Lines    []CheckoutLine `json:"lines"`
+	Subtotal uint           `json:"subtotal"`
+	Tax      uint           `json:"tax"`
+	Total    uint           `json:"total"`
+	TaxLines []TaxLine      `json:"tax_lines"`
+	// Discount is the total taken off by CouponCode
+	Discount   uint   `json:"discount"`
+	CouponCode string `json:"coupon_code,omitempty"`
+	// WalletCredit is what the buyer's referral rewards pay of the total, Total is what is left to pay
+	WalletCredit uint `json:"wallet_credit"`
+}
+
+// ApplyWallet pays as much of the total as balance covers
+func (s *CheckoutSummary) ApplyWallet(balance uint) {
+	s.WalletCredit = balance
+	if s.WalletCredit > s.Total {
+		s.WalletCredit = s.Total
+	}
+	s.Total -= s.WalletCredit
+}
+
+// BuildCheckoutSummary prices cart products against the tax rules, less coupon when it is not nil.
+// products must contain every product referenced by the cart.
+func BuildCheckoutSummary(cartProducts []CartProduct, products map[uint]Product, rules TaxRules, coupon *Coupon) CheckoutSummary {
+	summary := CheckoutSummary{}
+	if coupon != nil {
+		summary.CouponCode = coupon.Code
+	}
+	byRule := map[TaxLine]int{}
+	for _, cartProduct := range cartProducts {
+		product := products[cartProduct.ProductID]
+		rule := rules.For(product.CategoryId)
+		var discount uint
+		if coupon != nil {
+			discount = coupon.Discount(cartProduct.TotalPrice)
+		}
+		line := CheckoutLine{
+			CartProductID: cartProduct.ID,
+			ProductID:     cartProduct.ProductID,
+			VariantID:     cartProduct.VariantID,
+			SellerID:      product.SellerId,
+			BuyerID:       cartProduct.BuyerId,
+			CategoryID:    product.CategoryId,
+			Title:         product.Title,
+			Quantity:      cartProduct.TotalQuantity,
+			Discount:      discount,
+			TaxLine:       rule.Apply(cartProduct.TotalPrice - discount),
+		}
+		summary.Lines = append(summary.Lines, line)
+		summary.Subtotal += line.Net
+		summary.Tax += line.Tax
+		summary.Total += line.Gross
+		summary.Discount += line.Discount
+
+		// lines taxed the same way are totalled together
+		key := TaxLine{Name: line.Name, RateBasisPoints: line.RateBasisPoints, Exempt: line.Exempt, Inclusive: line.Inclusive}
+		i, ok := byRule[key]
+		if !ok {
+			i = len(summary.TaxLines)
+			byRule[key] = i
+			summary.TaxLines = append(summary.TaxLines, key)
+		}
+		summary.TaxLines[i].Net += line.Net
+		summary.TaxLines[i].Tax += line.Tax
+		summary.TaxLines[i].Gross += line.Gross
+	}
+	return summary
+}
+
+// SalesReport totals a seller's orders over a period
+type SalesReport struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	OrderCount int64     `json:"order_count"`
+	Net        uint      `json:"net"`
+	Tax        uint      `json:"tax"`
+	Gross      uint      `json:"gross"`
+	TaxLines   []TaxLine `json:"tax_lines"`
+}
@@ -18,8 +18,10 @@ type User struct {
 	Address         string `json:"address"`
 	PhoneNumber     string `json:"phone_number"`
 	Image           string `json:"image"`
-	IsActive        bool   `json:"status"`
-	Token           string `json:"token"`
+	// ImageVariants are the profile picture scaled down, with and without WebP
+	ImageVariants ImageVariants `json:"image_variants,omitempty" gorm:"type:text"`
+	IsActive      bool          `json:"status"`
+	Token         string        `json:"token"`
 }
 
 type UpdateUser struct {
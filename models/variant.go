@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductOption is an option a product comes in, such as Size with the values S, M and L
+type ProductOption struct {
+	ID        uint   `json:"id" gorm:"primarykey"`
+	ProductID uint   `json:"-" gorm:"index"`
+	Name      string `json:"name"`
+	Position  int    `json:"position"`
+	// ValueList is the JSON list behind Values
+	ValueList string   `json:"-"`
+	Values    []string `json:"values" gorm:"-"`
+}
+
+func (o *ProductOption) SetValues(values []string) {
+	o.Values = values
+	list, _ := json.Marshal(values)
+	o.ValueList = string(list)
+}
+
+func (o *ProductOption) AfterFind(tx *gorm.DB) error {
+	o.Values = []string{}
+	if o.ValueList == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(o.ValueList), &o.Values)
+}
+
+// HasValue reports whether value is one of the option's values
+func (o *ProductOption) HasValue(value string) bool {
+	for _, v := range o.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ProductVariant is one combination of a product's option values, with its own SKU, stock and images.
+// Removed variants are soft deleted so carts that were paid for meanwhile can still be ordered.
+type ProductVariant struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ProductID uint           `json:"product_id" gorm:"uniqueIndex:idx_product_variant_sku"`
+	SKU       string         `json:"sku" gorm:"uniqueIndex:idx_product_variant_sku"`
+	// Title names the variant by its option values in option order, e.g. "M / Red"
+	Title string `json:"title"`
+	// OptionList is the JSON object behind Options
+	OptionList string            `json:"-"`
+	Options    map[string]string `json:"options" gorm:"-"`
+	// Price overrides the product's price when it is set
+	Price     *uint          `json:"price"`
+	UnitPrice uint           `json:"unit_price" gorm:"-"`
+	Quantity  uint           `json:"quantity"`
+	Images    []VariantImage `json:"images" gorm:"foreignKey:VariantID"`
+}
+
+func (v *ProductVariant) SetOptions(options map[string]string) {
+	v.Options = options
+	list, _ := json.Marshal(options)
+	v.OptionList = string(list)
+}
+
+func (v *ProductVariant) AfterFind(tx *gorm.DB) error {
+	v.Options = map[string]string{}
+	if v.OptionList == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(v.OptionList), &v.Options)
+}
+
+// VariantImage is a picture of one variant, such as the red shirt
+type VariantImage struct {
+	ID        uint          `json:"id" gorm:"primarykey"`
+	VariantID uint          `json:"-" gorm:"index"`
+	Url       string        `json:"url"`
+	Variants  ImageVariants `json:"variants,omitempty" gorm:"type:text"`
+}
+
+// PriceFor is what one of the product's variant costs, the product's price unless the variant overrides it
+func (p *Product) PriceFor(variant *ProductVariant) uint {
+	if variant != nil && variant.Price != nil {
+		return *variant.Price
+	}
+	return p.Price
+}
+
+// AfterFind prices the product's variants, maps its attribute values and orders its pictures.
+// gorm runs it after the variants, attribute values and images are preloaded.
+func (p *Product) AfterFind(tx *gorm.DB) error {
+	p.SortImages()
+	for i := range p.Variants {
+		p.Variants[i].UnitPrice = p.PriceFor(&p.Variants[i])
+	}
+	if len(p.AttributeValues) > 0 {
+		p.SetAttributes(p.AttributeValues)
+	}
+	return nil
+}
+
+// Variant returns the product's variant with id, or nil
+func (p *Product) Variant(id uint) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].ID == id {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// SetVariants checks a variant matrix against its options and titles each variant. Every variant must
+// pick one value of every option, and no two variants can share a SKU or the same values.
+func SetVariants(options []ProductOption, variants []ProductVariant) error {
+	names := map[string]bool{}
+	for i := range options {
+		option := &options[i]
+		option.Name = strings.TrimSpace(option.Name)
+		option.Position = i
+		if option.Name == "" || names[option.Name] {
+			return fmt.Errorf("option %d needs a unique name", i+1)
+		}
+		names[option.Name] = true
+		if len(option.Values) == 0 {
+			return fmt.Errorf("option %s needs at least one value", option.Name)
+		}
+		option.SetValues(option.Values)
+	}
+	if len(options) == 0 && len(variants) > 0 {
+		return errors.New("variants need options")
+	}
+
+	skus := map[string]bool{}
+	combinations := map[string]bool{}
+	for i := range variants {
+		variant := &variants[i]
+		variant.SKU = strings.TrimSpace(variant.SKU)
+		if variant.SKU == "" || skus[variant.SKU] {
+			return fmt.Errorf("variant %d needs a unique sku", i+1)
+		}
+		skus[variant.SKU] = true
+		if len(variant.Options) != len(options) {
+			return fmt.Errorf("variant %s must pick a value for each option", variant.SKU)
+		}
+		values := make([]string, 0, len(options))
+		for _, option := range options {
+			value, ok := variant.Options[option.Name]
+			if !ok || !option.HasValue(value) {
+				return fmt.Errorf("variant %s has no valid %s", variant.SKU, option.Name)
+			}
+			values = append(values, value)
+		}
+		variant.Title = strings.Join(values, " / ")
+		if combinations[variant.Title] {
+			return fmt.Errorf("more than one variant is %s", variant.Title)
+		}
+		combinations[variant.Title] = true
+		variant.SetOptions(variant.Options)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SellerWebhookEvents are the events a seller's webhook endpoint can subscribe to
+var SellerWebhookEvents = []string{
+	EventOrderPaid,
+	EventOrderShipped,
+	EventOrderDelivered,
+	EventOrderCancelled,
+	EventOrderRefunded,
+	EventProductCreated,
+	EventProductPriceChanged,
+	EventProductStockChanged,
+	EventProductStatusChanged,
+}
+
+// Webhook delivery statuses. Failed deliveries have used up their attempts and can only be replayed.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// WebhookEndpoint is a url a seller wants their events posted to
+type WebhookEndpoint struct {
+	gorm.Model
+	SellerID    uint   `json:"seller_id" gorm:"index"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	// Secret signs the payloads, it is only shown when the endpoint is created
+	Secret string `json:"-"`
+	// EventTypes is the comma separated list behind Events
+	EventTypes string   `json:"-"`
+	Events     []string `json:"events" gorm:"-"`
+	Active     bool     `json:"active"`
+}
+
+// SetEvents sets the event types the endpoint subscribes to
+func (e *WebhookEndpoint) SetEvents(events []string) {
+	e.Events = events
+	e.EventTypes = strings.Join(events, ",")
+}
+
+func (e *WebhookEndpoint) AfterFind(tx *gorm.DB) error {
+	e.Events = nil
+	if e.EventTypes != "" {
+		e.Events = strings.Split(e.EventTypes, ",")
+	}
+	return nil
+}
+
+// Subscribes reports whether the endpoint wants events of the type
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, t := range strings.Split(e.EventTypes, ",") {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one attempt, with its retries, to post an event to an endpoint
+type WebhookDelivery struct {
+	gorm.Model
+	EndpointID uint            `json:"endpoint_id" gorm:"index"`
+	Endpoint   WebhookEndpoint `json:"-"`
+	SellerID   uint            `json:"seller_id" gorm:"index"`
+	EventID    uint            `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	// Payload is the exact body posted, so a replay sends the same thing again
+	Payload string `json:"payload" gorm:"type:text"`
+	// DedupKey stops an event redelivered by the relay reaching an endpoint twice. Replays have none.
+	DedupKey       *string    `json:"-" gorm:"uniqueIndex"`
+	ReplayOfID     *uint      `json:"replay_of_id,omitempty"`
+	Status         string     `json:"status" gorm:"index"`
+	Attempts       uint       `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `json:"response_body"`
+	LastError      string     `json:"last_error"`
+	DurationMs     int64      `json:"duration_ms"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
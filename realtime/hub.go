@@ -0,0 +1,162 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// Defaults for NewHub
+const (
+	DefaultBuffer          = 32
+	DefaultMaxPerRecipient = 10
+)
+
+// ErrTooManyStreams is returned when a buyer or seller already has the most streams a hub allows
+var ErrTooManyStreams = errors.New("too many open event streams")
+
+// Client is one open event stream
+type Client struct {
+	key  string
+	send chan models.RealtimeMessage
+}
+
+// Messages is closed when the client is unsubscribed, has fallen behind or the hub is closed
+func (c *Client) Messages() <-chan models.RealtimeMessage {
+	return c.send
+}
+
+// Hub hands live updates to the streams open on this process
+type Hub struct {
+	Buffer          int
+	MaxPerRecipient int
+
+	mu      sync.RWMutex
+	clients map[string]map[*Client]struct{}
+	closed  bool
+}
+
+// NewHub returns a Hub with the default limits
+func NewHub() *Hub {
+	return &Hub{
+		Buffer:          DefaultBuffer,
+		MaxPerRecipient: DefaultMaxPerRecipient,
+		clients:         map[string]map[*Client]struct{}{},
+	}
+}
+
+func key(recipientType string, recipientID uint) string {
+	return fmt.Sprintf("%s:%d", recipientType, recipientID)
+}
+
+// Subscribe opens a stream for a buyer or seller. It must be given back with Unsubscribe.
+func (h *Hub) Subscribe(recipientType string, recipientID uint) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, errors.New("hub is closed")
+	}
+	k := key(recipientType, recipientID)
+	if h.MaxPerRecipient > 0 && len(h.clients[k]) >= h.MaxPerRecipient {
+		return nil, ErrTooManyStreams
+	}
+	client := &Client{key: k, send: make(chan models.RealtimeMessage, h.Buffer)}
+	if h.clients[k] == nil {
+		h.clients[k] = map[*Client]struct{}{}
+	}
+	h.clients[k][client] = struct{}{}
+	return client, nil
+}
+
+// Unsubscribe closes a stream, it is safe to call more than once
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(client)
+}
+
+func (h *Hub) remove(client *Client) {
+	clients, ok := h.clients[client.key]
+	if !ok {
+		return
+	}
+	if _, ok := clients[client]; !ok {
+		return
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.clients, client.key)
+	}
+	close(client.send)
+}
+
+// Publish hands msg to each of its recipient's streams without waiting.
+// A stream whose buffer is full is dropped so one slow client can't hold up the rest, it reconnects and reloads.
+func (h *Hub) Publish(msg models.RealtimeMessage) {
+	var slow []*Client
+	h.mu.RLock()
+	for client := range h.clients[key(msg.RecipientType, msg.RecipientID)] {
+		select {
+		case client.send <- msg:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, client := range slow {
+		h.remove(client)
+	}
+	h.mu.Unlock()
+}
+
+// Count returns how many streams are open
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	count := 0
+	for _, clients := range h.clients {
+		count += len(clients)
+	}
+	return count
+}
+
+// Close ends every stream and refuses new ones, for shutting the server down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, clients := range h.clients {
+		for client := range clients {
+			h.remove(client)
+		}
+	}
+}
+
+// Source feeds live updates from other processes to handle until ctx is done or the connection drops
+type Source func(ctx context.Context, handle func(models.RealtimeMessage)) error
+
+// Run publishes everything from source on the hub, reconnecting after a dropped connection
+func (h *Hub) Run(ctx context.Context, source Source) {
+	for {
+		err := source(ctx, h.Publish)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("realtime listener error: %v\n", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -2,7 +2,9 @@ package router
 
 import (
 	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/models"
 	"github.com/decadevs/shoparena/server/middleware"
+	"github.com/decadevs/shoparena/services"
 	"github.com/gin-contrib/cors"
 	"net/http"
 	"os"
@@ -16,8 +18,41 @@ type Router struct {
 	handlers    map[string]func(w http.ResponseWriter, r *http.Request)
 }
 
+// sellerAPIKeyScopes are the seller routes an api key can call, with the scope each one needs.
+// Every other seller route, including managing keys and webhooks, needs a login.
+var sellerAPIKeyScopes = map[string]string{
+	"GET /api/v1/seller/product":                                   models.ScopeProductsRead,
+	"GET /api/v1/seller/allproducts":                               models.ScopeProductsRead,
+	"GET /api/v1/seller/total/product/count":                       models.ScopeProductsRead,
+	"GET /api/v1/seller/total/product/sold":                        models.ScopeProductsRead,
+	"GET /api/v1/seller/remaining/product/count":                   models.ScopeProductsRead,
+	"POST /api/v1/createproduct":                                   models.ScopeProductsWrite,
+	"PUT /api/v1/update/product/:id":                               models.ScopeProductsWrite,
+	"DELETE /api/v1/deleteproduct/:id":                             models.ScopeProductsWrite,
+	"PUT /api/v1/seller/products/:id/variants":                     models.ScopeProductsWrite,
+	"PUT /api/v1/seller/products/:id/status":                       models.ScopeProductsWrite,
+	"POST /api/v1/seller/products/:id/variants/:variant_id/images": models.ScopeProductsWrite,
+	"POST /api/v1/seller/products/:id/images":                      models.ScopeProductsWrite,
+	"POST /api/v1/seller/products/:id/images/confirm":              models.ScopeProductsWrite,
+	"POST /api/v1/seller/uploads":                                  models.ScopeProductsWrite,
+	"PUT /api/v1/seller/products/:id/images":                       models.ScopeProductsWrite,
+	"PUT /api/v1/seller/products/:id/images/:image_id":             models.ScopeProductsWrite,
+	"PUT /api/v1/seller/products/:id/images/:image_id/primary":     models.ScopeProductsWrite,
+	"DELETE /api/v1/seller/products/:id/images/:image_id":          models.ScopeProductsWrite,
+	"POST /api/v1/seller/catalogue/import":                         models.ScopeProductsWrite,
+	"GET /api/v1/seller/catalogue/imports/:id":                     models.ScopeProductsRead,
+	"GET /api/v1/seller/catalogue/export":                          models.ScopeProductsRead,
+	"GET /api/v1/sellerorders":                                     models.ScopeOrdersRead,
+	"GET /api/v1/seller/totalorder/":                               models.ScopeOrdersRead,
+	"GET /api/v1/seller/salesreport":                               models.ScopeOrdersRead,
+	"GET /api/v1/seller/invoices":                                  models.ScopeOrdersRead,
+	"GET /api/v1/seller/invoices/:id/pdf":                          models.ScopeOrdersRead,
+	"PUT /api/v1/seller/orders/:id/status":                         models.ScopeOrdersWrite,
+}
+
 func SetupRouter(h *handlers.Handler) (*gin.Engine, string) {
-	router := gin.Default()
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -28,6 +63,12 @@ func SetupRouter(h *handlers.Handler) (*gin.Engine, string) {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// files kept in local storage are served, and uploaded with signed urls, by the api itself
+	if local, ok := h.Storage.(*services.LocalStorage); ok {
+		router.GET("/files/*key", local.ServeFile)
+		router.PUT("/files/*key", local.UploadFile)
+	}
+
 	apirouter := router.Group("/api/v1")
 
 	apirouter.GET("/ping", handlers.PingHandler)
@@ -35,11 +76,16 @@ func SetupRouter(h *handlers.Handler) (*gin.Engine, string) {
 	apirouter.GET("/products", h.GetAllProducts)
 	apirouter.GET("/sellers", h.GetSellers)
 	apirouter.GET("/product/:id", h.GetProductById)
+	apirouter.GET("/product/:id/questions", h.GetProductQuestions)
+	apirouter.GET("/categories", h.GetCategories)
+	apirouter.GET("/categories/:id/attributes", h.GetCategoryAttributes)
 	apirouter.POST("/loginbuyer", h.LoginBuyerHandler)
 	apirouter.POST("/loginseller", h.LoginSellerHandler)
 	apirouter.POST("/buyersignup", h.BuyerSignUpHandler)
 	apirouter.POST("/sellersignup", h.SellerSignUpHandler)
 	apirouter.GET("/callback", h.Callback)
+	apirouter.GET("/cartreminders/click/:token", h.ClickCartReminder)
+	apirouter.GET("/cartreminders/unsubscribe", h.UnsubscribeCartReminders)
 
 	apirouter.GET("/seller/shop/:id", h.HandleGetSellerShopByProfileAndProduct())
 
@@ -49,6 +95,12 @@ func SetupRouter(h *handlers.Handler) (*gin.Engine, string) {
 	apirouter.PUT("/sellerresetpassword", h.SellerForgotPasswordResetHandler)
 	apirouter.PUT("/buyerresetpassword", h.BuyerForgotPasswordResetHandler)
 
+	// event streams also take the token from the query, which EventSource can't send as a header
+	apirouter.GET("/buyer/events", middleware.TokenFromQuery(),
+		middleware.AuthorizeBuyer(h.DB.FindBuyerByEmail, h.DB.TokenInBlacklist), h.StreamEvents(models.RecipientBuyer))
+	apirouter.GET("/seller/events", middleware.TokenFromQuery(),
+		middleware.AuthorizeSeller(h.DB.FindSellerByEmail, h.DB.TokenInBlacklist), h.StreamEvents(models.RecipientSeller))
+
 	//All authorized routes here
 	authorizedRoutesBuyer := apirouter.Group("/")
 	authorizedRoutesBuyer.Use(middleware.AuthorizeBuyer(h.DB.FindBuyerByEmail, h.DB.TokenInBlacklist))
@@ -57,22 +109,76 @@ func SetupRouter(h *handlers.Handler) (*gin.Engine, string) {
 		authorizedRoutesBuyer.GET("/getbuyerprofile", h.GetBuyerProfileHandler)
 		authorizedRoutesBuyer.POST("/addtocart", h.AddToCart)
 		authorizedRoutesBuyer.GET("/viewcart", h.ViewCartProducts)
+		authorizedRoutesBuyer.POST("/buyer/cart/coupon", h.ApplyCoupon)
+		authorizedRoutesBuyer.DELETE("/buyer/cart/coupon", h.RemoveCoupon)
+		authorizedRoutesBuyer.PUT("/buyer/cartreminders", h.UpdateCartReminders)
+		authorizedRoutesBuyer.POST("/buyer/alerts", h.CreateProductAlert)
+		authorizedRoutesBuyer.GET("/buyer/alerts", h.GetProductAlerts)
+		authorizedRoutesBuyer.DELETE("/buyer/alerts/:id", h.DeleteProductAlert)
+		authorizedRoutesBuyer.GET("/buyer/notifications", h.ListNotifications(models.RecipientBuyer))
+		authorizedRoutesBuyer.GET("/buyer/notifications/count", h.CountNotifications(models.RecipientBuyer))
+		authorizedRoutesBuyer.PUT("/buyer/notifications/:id", h.MarkNotification(models.RecipientBuyer))
+		authorizedRoutesBuyer.POST("/buyer/notifications/readall", h.MarkAllNotificationsRead(models.RecipientBuyer))
+		authorizedRoutesBuyer.POST("/buyer/conversations", h.StartConversation(models.RecipientBuyer))
+		authorizedRoutesBuyer.GET("/buyer/conversations", h.ListConversations(models.RecipientBuyer))
+		authorizedRoutesBuyer.GET("/buyer/conversations/:id/messages", h.GetConversationMessages(models.RecipientBuyer))
+		authorizedRoutesBuyer.POST("/buyer/conversations/:id/messages", h.SendMessage(models.RecipientBuyer))
+		authorizedRoutesBuyer.PUT("/buyer/conversations/:id/read", h.MarkConversationRead(models.RecipientBuyer))
+		authorizedRoutesBuyer.POST("/buyer/messages/:id/report", h.ReportMessage(models.RecipientBuyer))
+		authorizedRoutesBuyer.POST("/buyer/products/:id/questions", h.AskProductQuestion)
+		authorizedRoutesBuyer.POST("/buyer/products/:id/reports", h.ReportListing)
+		authorizedRoutesBuyer.POST("/buyer/questions/:id/answers", h.AnswerProductQuestion(models.RecipientBuyer))
+		authorizedRoutesBuyer.POST("/buyer/answers/:id/upvote", h.UpvoteAnswer)
+		authorizedRoutesBuyer.DELETE("/buyer/answers/:id/upvote", h.UpvoteAnswer)
 		authorizedRoutesBuyer.POST("/pay", h.Pay)
+		authorizedRoutesBuyer.GET("/checkout/summary", h.CheckoutSummary)
 		authorizedRoutesBuyer.PUT("/buyer/updatepassword", h.BuyerUpdatePassword)
 		authorizedRoutesBuyer.PUT("/uploadbuyerpic", h.UploadBuyerImageHandler)
+		authorizedRoutesBuyer.POST("/buyer/uploads", h.CreateUpload(models.RecipientBuyer))
+		authorizedRoutesBuyer.PUT("/uploadbuyerpic/confirm", h.ConfirmBuyerImageUpload)
 		authorizedRoutesBuyer.DELETE("/deletefromcart/:id", h.DeleteFromCart)
 		authorizedRoutesBuyer.DELETE("/deleteallcart", h.DeleteAllCartProducts)
 		authorizedRoutesBuyer.POST("/buyer/logout", h.HandleLogoutBuyer)
 		authorizedRoutesBuyer.GET("/buyerorders", h.AllBuyerOrders)
 		authorizedRoutesBuyer.POST("/buyer/rateaseller", h.SellerRating)
 		authorizedRoutesBuyer.POST("/buyer/rateaproduct", h.ProductRating)
+		authorizedRoutesBuyer.GET("/buyer/referrals", h.GetBuyerReferrals)
+		authorizedRoutesBuyer.GET("/buyer/invoices", h.BuyerInvoices)
+		authorizedRoutesBuyer.GET("/buyer/invoices/:id/pdf", h.BuyerInvoicePDF)
 	}
 	authorizedRoutesSeller := apirouter.Group("/")
-	authorizedRoutesSeller.Use(middleware.AuthorizeSeller(h.DB.FindSellerByEmail, h.DB.TokenInBlacklist))
+	authorizedRoutesSeller.Use(middleware.AuthorizeSellerOrAPIKey(
+		middleware.AuthorizeSeller(h.DB.FindSellerByEmail, h.DB.TokenInBlacklist),
+		sellerAPIKeyScopes, h.DB.FindAPIKeyByPrefix, h.DB.TouchAPIKey))
 	{
 
 		authorizedRoutesSeller.PUT("/updatesellerprofile", h.UpdateSellerProfileHandler)
 		authorizedRoutesSeller.GET("/sellerorders", h.AllSellerOrders)
+		authorizedRoutesSeller.PUT("/seller/orders/:id/status", h.UpdateOrderStatus)
+		authorizedRoutesSeller.GET("/seller/salesreport", h.SellerSalesReport)
+		authorizedRoutesSeller.GET("/seller/invoices", h.SellerInvoices)
+		authorizedRoutesSeller.GET("/seller/invoices/:id/pdf", h.SellerInvoicePDF)
+		authorizedRoutesSeller.POST("/seller/webhooks", h.CreateWebhookEndpoint)
+		authorizedRoutesSeller.GET("/seller/webhooks", h.GetWebhookEndpoints)
+		authorizedRoutesSeller.PUT("/seller/webhooks/:id", h.UpdateWebhookEndpoint)
+		authorizedRoutesSeller.DELETE("/seller/webhooks/:id", h.DeleteWebhookEndpoint)
+		authorizedRoutesSeller.GET("/seller/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+		authorizedRoutesSeller.POST("/seller/webhooks/:id/deliveries/:delivery_id/replay", h.ReplayWebhookDelivery)
+		authorizedRoutesSeller.GET("/seller/notifications", h.ListNotifications(models.RecipientSeller))
+		authorizedRoutesSeller.GET("/seller/notifications/count", h.CountNotifications(models.RecipientSeller))
+		authorizedRoutesSeller.PUT("/seller/notifications/:id", h.MarkNotification(models.RecipientSeller))
+		authorizedRoutesSeller.POST("/seller/notifications/readall", h.MarkAllNotificationsRead(models.RecipientSeller))
+		authorizedRoutesSeller.POST("/seller/conversations", h.StartConversation(models.RecipientSeller))
+		authorizedRoutesSeller.GET("/seller/conversations", h.ListConversations(models.RecipientSeller))
+		authorizedRoutesSeller.GET("/seller/conversations/:id/messages", h.GetConversationMessages(models.RecipientSeller))
+		authorizedRoutesSeller.POST("/seller/conversations/:id/messages", h.SendMessage(models.RecipientSeller))
+		authorizedRoutesSeller.PUT("/seller/conversations/:id/read", h.MarkConversationRead(models.RecipientSeller))
+		authorizedRoutesSeller.POST("/seller/messages/:id/report", h.ReportMessage(models.RecipientSeller))
+		authorizedRoutesSeller.GET("/seller/questions/unanswered", h.GetUnansweredQuestions)
+		authorizedRoutesSeller.POST("/seller/questions/:id/answers", h.AnswerProductQuestion(models.RecipientSeller))
+		authorizedRoutesSeller.POST("/seller/apikeys", h.CreateAPIKey)
+		authorizedRoutesSeller.GET("/seller/apikeys", h.GetAPIKeys)
+		authorizedRoutesSeller.DELETE("/seller/apikeys/:id", h.RevokeAPIKey)
 		authorizedRoutesSeller.GET("/seller/totalorder/", h.SellerTotalOrders)
 		authorizedRoutesSeller.GET("/getsellerprofile", h.GetSellerProfileHandler)
 		authorizedRoutesSeller.GET("/seller/total/product/sold", h.GetTotalSoldProductCount)
@@ -83,13 +189,48 @@ func SetupRouter(h *handlers.Handler) (*gin.Engine, string) {
 		authorizedRoutesSeller.GET("/seller/total/product/count", h.GetTotalProductCountForSeller)
 		authorizedRoutesSeller.GET("/seller/product", h.SellerIndividualProduct)
 		authorizedRoutesSeller.PUT("/update/product/:id", h.UpdateProduct)
+		authorizedRoutesSeller.PUT("/seller/products/:id/variants", h.SaveProductVariants)
+		authorizedRoutesSeller.PUT("/seller/products/:id/status", h.UpdateProductStatus)
+		authorizedRoutesSeller.POST("/seller/products/:id/variants/:variant_id/images", h.UploadVariantImages)
+		authorizedRoutesSeller.POST("/seller/products/:id/images", h.UploadProductImages)
+		authorizedRoutesSeller.POST("/seller/products/:id/images/confirm", h.ConfirmProductImages)
+		authorizedRoutesSeller.POST("/seller/uploads", h.CreateUpload(models.RecipientSeller))
+		authorizedRoutesSeller.PUT("/seller/products/:id/images", h.ReorderProductImages)
+		authorizedRoutesSeller.PUT("/seller/products/:id/images/:image_id", h.UpdateProductImage)
+		authorizedRoutesSeller.PUT("/seller/products/:id/images/:image_id/primary", h.SetPrimaryProductImage)
+		authorizedRoutesSeller.DELETE("/seller/products/:id/images/:image_id", h.DeleteProductImage)
+		authorizedRoutesSeller.POST("/seller/catalogue/import", h.ImportCatalogue)
+		authorizedRoutesSeller.GET("/seller/catalogue/imports/:id", h.GetProductImport)
+		authorizedRoutesSeller.GET("/seller/catalogue/export", h.ExportCatalogue)
 		authorizedRoutesSeller.GET("/seller/allproducts", h.SellerAllProducts)
 		authorizedRoutesSeller.GET("/seller/remaining/product/count", h.GetRemainingProductsCountSellerCount)
-		authorizedRoutesBuyer.PUT("/uploadsellerpic", h.UploadSellerImageHandler)
+		authorizedRoutesSeller.PUT("/uploadsellerpic", h.UploadSellerImageHandler)
+		authorizedRoutesSeller.PUT("/uploadsellerpic/confirm", h.ConfirmSellerImageUpload)
 		authorizedRoutesSeller.POST("/seller/logout", h.HandleLogoutSeller)
 		authorizedRoutesSeller.DELETE("/deleteallsellerproducts/:seller_id", h.DeleteAllSellerProducts)
 	}
 
+	adminRoutes := apirouter.Group("/admin")
+	adminRoutes.Use(middleware.AuthorizeAdmin())
+	{
+		adminRoutes.GET("/taxrules", h.GetTaxRules)
+		adminRoutes.PUT("/taxrules", h.SaveTaxRule)
+		adminRoutes.DELETE("/taxrules/:id", h.DeleteTaxRule)
+		adminRoutes.GET("/emails", h.ListEmailTemplates)
+		adminRoutes.GET("/emails/:template/preview", h.PreviewEmail)
+		adminRoutes.GET("/jobs", h.GetJobs)
+		adminRoutes.GET("/cartreminders/stats", h.CartReminderStats)
+		adminRoutes.GET("/messagereports", h.GetMessageReports)
+		adminRoutes.PUT("/messagereports/:id", h.UpdateMessageReport)
+		adminRoutes.GET("/reviews", h.GetListingReviews)
+		adminRoutes.PUT("/reviews/:id", h.DecideListingReview)
+		adminRoutes.POST("/categories", h.CreateCategory)
+		adminRoutes.PUT("/categories/:id", h.UpdateCategory)
+		adminRoutes.DELETE("/categories/:id", h.DeleteCategory)
+		adminRoutes.PUT("/categories/:id/attributes", h.SaveCategoryAttributes)
+		adminRoutes.POST("/jobs/:id/retry", h.RetryJob)
+	}
+
 	port := ":" + os.Getenv("PORT")
 	if port == ":" {
 		port = ":8081"
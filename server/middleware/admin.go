@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthorizeAdmin only lets through requests whose X-Admin-Token header matches ADMIN_TOKEN.
+// Every admin route is closed when ADMIN_TOKEN is not set.
+func AuthorizeAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := os.Getenv("ADMIN_TOKEN")
+		token := c.GetHeader("X-Admin-Token")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			RespondAndAbort(c, "", http.StatusUnauthorized, nil, []string{"unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
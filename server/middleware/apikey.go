@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/decadevs/shoparena/services"
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyTouchInterval limits how often a key's last used time is written
+const apiKeyTouchInterval = time.Minute
+
+// AuthorizeSellerOrAPIKey lets a seller in with an api key, sent as X-API-Key or as a bearer token,
+// and falls back to sellerAuth, the usual login token check, for every other request.
+// Keys only reach the routes listed in scopes, keyed by method and route path, and only when
+// they were granted the scope listed for the route.
+func AuthorizeSellerOrAPIKey(sellerAuth gin.HandlerFunc, scopes map[string]string,
+	findAPIKey func(prefix string) (*models.APIKey, error), touchAPIKey func(id uint, at time.Time) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" && strings.HasPrefix(services.GetTokenFromHeader(c), services.APIKeyPrefix) {
+			key = services.GetTokenFromHeader(c)
+		}
+		if key == "" {
+			sellerAuth(c)
+			return
+		}
+
+		prefix, ok := services.APIKeyLookupPrefix(key)
+		if !ok {
+			RespondAndAbort(c, "", http.StatusUnauthorized, nil, []string{"invalid api key"})
+			return
+		}
+		apiKey, err := findAPIKey(prefix)
+		if err != nil || subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(services.HashAPIKey(key))) != 1 {
+			RespondAndAbort(c, "", http.StatusUnauthorized, nil, []string{"invalid api key"})
+			return
+		}
+		now := time.Now()
+		if !apiKey.Usable(now) {
+			RespondAndAbort(c, "", http.StatusUnauthorized, nil, []string{"api key has expired or been revoked"})
+			return
+		}
+
+		scope, ok := scopes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			RespondAndAbort(c, "", http.StatusForbidden, nil, []string{"this route cannot be used with an api key"})
+			return
+		}
+		if !apiKey.HasScope(scope) {
+			RespondAndAbort(c, "", http.StatusForbidden, nil, []string{"api key is missing the " + scope + " scope"})
+			return
+		}
+
+		if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+			if err := touchAPIKey(apiKey.ID, now); err != nil {
+				log.Printf("touch api key error: %v\n", err)
+			}
+		}
+
+		c.Set("user", &apiKey.Seller)
+		c.Set("api_key", apiKey)
+		c.Next()
+	}
+}
@@ -11,7 +11,10 @@ import (
 	"time"
 
 	"github.com/decadevs/shoparena/database"
+	"github.com/decadevs/shoparena/events"
 	"github.com/decadevs/shoparena/handlers"
+	"github.com/decadevs/shoparena/jobs"
+	"github.com/decadevs/shoparena/realtime"
 	"github.com/decadevs/shoparena/services"
 
 	"github.com/decadevs/shoparena/router"
@@ -25,29 +28,103 @@ type Server struct {
 }
 
 func Start() error {
+	PDB, Mail, Storage, err := setup()
+	if err != nil {
+		return err
+	}
+	var Paystack = services.NewPaystack()
+	hub := realtime.NewHub()
+	h := &handlers.Handler{DB: PDB, Mail: Mail, Paystack: Paystack, Storage: Storage, Hub: hub}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// live updates can be sent from the worker, so they come to the hub through Postgres
+	go hub.Run(ctx, PDB.ListenRealtime)
+
+	// background jobs run alongside the api unless they are left to cmd/worker
+	runnerDone := make(chan struct{})
+	if os.Getenv("JOBS_IN_PROCESS") != "false" {
+		runner := jobs.NewRunner(PDB)
+		if err := jobs.Register(runner, PDB, Mail, Storage, events.DefaultBus()); err != nil {
+			return err
+		}
+		go func() {
+			runner.Run(ctx)
+			close(runnerDone)
+		}()
+	} else {
+		close(runnerDone)
+	}
+
+	route, port := router.SetupRouter(h)
+	srv := &http.Server{Addr: port, Handler: route}
+	// open event streams would otherwise hold up the shutdown
+	srv.RegisterOnShutdown(hub.Close)
+	go func() {
+		fmt.Println("connected on port ", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error from SetupRouter :%v", err)
+			stop()
+		}
+	}()
 
+	<-ctx.Done()
+	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Server forced to shutdown:", err)
+	}
+	<-runnerDone
+	log.Println("Server exiting")
+	return nil
+}
+
+// StartWorker runs only the background jobs, for deployments that keep them apart from the api
+func StartWorker() error {
+	PDB, Mail, Storage, err := setup()
+	if err != nil {
+		return err
+	}
+	runner := jobs.NewRunner(PDB)
+	if err := jobs.Register(runner, PDB, Mail, Storage, events.DefaultBus()); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	runner.Run(ctx)
+	return nil
+}
+
+// setup connects to the database, picks the mail backend from MAIL_DRIVER and the file storage
+// from STORAGE_DRIVER
+func setup() (*database.PostgresDb, database.Mailer, database.Storage, error) {
 	values := database.InitDBParams()
 
 	//Setting up the Postgres Database
 	var PDB = new(database.PostgresDb)
-	var Mail = new(services.Service)
-	var Paystack = services.NewPaystack()
-	h := &handlers.Handler{DB: PDB, Mail: Mail, Paystack: Paystack}
 	err := PDB.Init(values.Host, values.User, values.Password, values.DbName, values.Port)
 	if err != nil {
 		log.Println("Error trying to Init", err)
-		return err
+		return nil, nil, nil, err
 	}
 
-	route, port := router.SetupRouter(h)
-	fmt.Println("connected on port ", port)
-	err = route.Run(port)
-	if err != nil {
-		log.Printf("Error from SetupRouter :%v", err)
-		return err
+	var Mail database.Mailer = new(services.Service)
+	if os.Getenv("MAIL_DRIVER") == "smtp" {
+		Mail = services.NewSMTPMailer()
 	}
 
-	return nil
+	var Storage database.Storage = services.NewLocalStorage()
+	if os.Getenv("STORAGE_DRIVER") != "local" {
+		bucket, err := services.NewS3Storage()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		Storage = bucket
+	}
+	return PDB, Mail, Storage, nil
 }
 
 func (s *Server) defineRoutes(router *gin.Engine) {
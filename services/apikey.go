@@ -0,0 +1,46 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every seller api key, so keys are easy to spot in code and logs
+const APIKeyPrefix = "oja_sk_"
+
+// GenerateAPIKey makes a new api key. It returns the key to hand to the seller,
+// its lookup prefix and the hash to store in its place.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 24)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	key = prefix + "_" + hex.EncodeToString(secret)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey is the hash an api key is stored as. The keys are long and random,
+// so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyLookupPrefix returns the prefix a key is stored under, or false when it is not an api key
+func APIKeyLookupPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", false
+	}
+	i := strings.LastIndex(key, "_")
+	if i <= len(APIKeyPrefix) {
+		return "", false
+	}
+	return key[:i], true
+}
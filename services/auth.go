@@ -2,15 +2,9 @@ package services
 
 import (
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 )
 
@@ -82,27 +76,6 @@ func GenerateClaims(email string) (jwt.MapClaims, jwt.MapClaims) {
 	return accessClaims, refreshClaims
 }
 
-func CheckSupportedFile(filename string) (string, bool) {
-	supportedFileTypes := map[string]bool{
-		".png":  true,
-		".jpeg": true,
-		".jpg":  true,
-	}
-	fileExtension := filepath.Ext(filename)
-	return fileExtension, !supportedFileTypes[fileExtension]
-}
-
-func PreAWS(fileExtension, folder string) (*session.Session, string, error) {
-	secret := os.Getenv("AWS_SECRET_KEY")
-	id := os.Getenv("AWS_SECRET_ID")
-	tempFileName := folder + "/" + uuid.NewString() + fileExtension
-	session, err := session.NewSession(&aws.Config{
-		Region:      aws.String(os.Getenv("AWS_REGION")),
-		Credentials: credentials.NewStaticCredentials(secret, id, ""),
-	})
-	return session, tempFileName, err
-}
-
 func (s *Service) GenerateNonAuthToken(UserEmail string, secret string) (*string, error) {
 
 	// Define expiration time
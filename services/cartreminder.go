@@ -0,0 +1,93 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CartReminderSettings controls when abandoned cart reminders go out and the coupon in the second one
+type CartReminderSettings struct {
+	// After is how long a cart sits untouched before the first reminder
+	After time.Duration
+	// SecondAfter is how long after the first reminder the second is sent, 0 sends no second reminder
+	SecondAfter time.Duration
+	// CouponPercent is the discount offered in the second reminder, 0 offers none
+	CouponPercent  uint
+	CouponValidity time.Duration
+}
+
+// CartReminderConfig reads the reminder settings from CART_REMINDER_AFTER, CART_REMINDER_SECOND_AFTER,
+// CART_REMINDER_COUPON_PERCENT and CART_REMINDER_COUPON_VALIDITY,
+// defaulting to a day, two more days, 10% and three days
+func CartReminderConfig() CartReminderSettings {
+	settings := CartReminderSettings{
+		After:          24 * time.Hour,
+		SecondAfter:    48 * time.Hour,
+		CouponPercent:  10,
+		CouponValidity: 72 * time.Hour,
+	}
+	if d, err := time.ParseDuration(os.Getenv("CART_REMINDER_AFTER")); err == nil && d > 0 {
+		settings.After = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("CART_REMINDER_SECOND_AFTER")); err == nil && d >= 0 {
+		settings.SecondAfter = d
+	}
+	if p, err := strconv.Atoi(os.Getenv("CART_REMINDER_COUPON_PERCENT")); err == nil && p >= 0 && p <= 100 {
+		settings.CouponPercent = uint(p)
+	}
+	if d, err := time.ParseDuration(os.Getenv("CART_REMINDER_COUPON_VALIDITY")); err == nil && d > 0 {
+		settings.CouponValidity = d
+	}
+	return settings
+}
+
+// NewCouponCode makes a coupon code that is easy to read out and type
+func NewCouponCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "CART-" + base32.StdEncoding.EncodeToString(b), nil
+}
+
+// NewReminderToken makes the token that identifies a reminder in its link
+func NewReminderToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UnsubscribeToken is the token in a buyer's link to turn off cart reminders.
+// It is signed so it cannot be made up for another buyer, and it does not expire.
+func UnsubscribeToken(buyerID uint) string {
+	id := strconv.Itoa(int(buyerID))
+	return id + "." + unsubscribeSignature(id)
+}
+
+// ParseUnsubscribeToken returns the buyer an unsubscribe token was made for
+func ParseUnsubscribeToken(token string) (uint, bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(unsubscribeSignature(parts[0]))) {
+		return 0, false
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func unsubscribeSignature(id string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("cart-reminders:" + id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
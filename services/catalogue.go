@@ -0,0 +1,338 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/decadevs/shoparena/models"
+	"gorm.io/gorm"
+)
+
+// MaxImportRows caps how many products one import can have
+const MaxImportRows = 5000
+
+// importProgressEvery is how many rows an import goes through between saving its progress
+const importProgressEvery = 50
+
+// catalogueColumns are the CSV columns every catalogue has, attributes follow as attr.<key>
+var catalogueColumns = []string{"sku", "title", "description", "category_id", "price", "quantity", "image_urls"}
+
+// imageURLSeparator separates a CSV row's image urls
+const imageURLSeparator = "|"
+
+// ParseCatalogue reads the products in a CSV or JSON lines catalogue. Rows that can't be read come
+// back as errors so the rest can still be imported; an error means the file can't be read at all.
+func ParseCatalogue(format string, r io.Reader) ([]models.ProductImportRow, []models.ImportRowError, error) {
+	switch format {
+	case models.CatalogueCSV:
+		return parseCatalogueCSV(r)
+	case models.CatalogueJSONL:
+		return parseCatalogueJSONL(r)
+	}
+	return nil, nil, fmt.Errorf("format must be %s or %s", models.CatalogueCSV, models.CatalogueJSONL)
+}
+
+func parseCatalogueCSV(r io.Reader) ([]models.ProductImportRow, []models.ImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the header row: %v", err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		known := strings.HasPrefix(column, models.AttributeFilterPrefix)
+		for _, c := range catalogueColumns {
+			known = known || c == column
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("unknown column %s", column)
+		}
+		columns[column] = i
+	}
+	for _, required := range []string{"sku", "title", "category_id", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("missing column %s", required)
+		}
+	}
+
+	var rows []models.ProductImportRow
+	var rowErrors []models.ImportRowError
+	for number := 1; ; number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if number > MaxImportRows {
+			return nil, nil, fmt.Errorf("a catalogue can have at most %d products", MaxImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, models.ImportRowError{Row: number, Error: parseErr.Err.Error()})
+			continue
+		}
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := models.ProductImportRow{
+			Row:         number,
+			SKU:         field("sku"),
+			Title:       field("title"),
+			Description: field("description"),
+		}
+		var numberErr error
+		for column, value := range map[string]*uint{"category_id": &row.CategoryID, "price": &row.Price, "quantity": &row.Quantity} {
+			if field(column) == "" {
+				continue
+			}
+			n, err := strconv.ParseUint(field(column), 10, 64)
+			if err != nil {
+				numberErr = fmt.Errorf("%s must be a whole number", column)
+				break
+			}
+			*value = uint(n)
+		}
+		if numberErr != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: number, SKU: row.SKU, Error: numberErr.Error()})
+			continue
+		}
+		for _, url := range strings.Split(field("image_urls"), imageURLSeparator) {
+			if url = strings.TrimSpace(url); url != "" {
+				row.ImageURLs = append(row.ImageURLs, url)
+			}
+		}
+		for column := range columns {
+			key := strings.TrimPrefix(column, models.AttributeFilterPrefix)
+			if key == column || field(column) == "" {
+				continue
+			}
+			if row.Attributes == nil {
+				row.Attributes = map[string]string{}
+			}
+			row.Attributes[key] = field(column)
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+func parseCatalogueJSONL(r io.Reader) ([]models.ProductImportRow, []models.ImportRowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var rows []models.ProductImportRow
+	var rowErrors []models.ImportRowError
+	number := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		number++
+		if number > MaxImportRows {
+			return nil, nil, fmt.Errorf("a catalogue can have at most %d products", MaxImportRows)
+		}
+		var row models.ProductImportRow
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: number, Error: "invalid json: " + err.Error()})
+			continue
+		}
+		row.Row = number
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return rows, rowErrors, nil
+}
+
+// WriteCatalogue writes a seller's products in a format they can be imported from again
+func WriteCatalogue(format string, w io.Writer, products []models.Product) error {
+	rows := make([]models.ProductImportRow, 0, len(products))
+	for i := range products {
+		rows = append(rows, models.CatalogueRow(&products[i]))
+	}
+	switch format {
+	case models.CatalogueCSV:
+		return writeCatalogueCSV(w, rows)
+	case models.CatalogueJSONL:
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("format must be %s or %s", models.CatalogueCSV, models.CatalogueJSONL)
+}
+
+func writeCatalogueCSV(w io.Writer, rows []models.ProductImportRow) error {
+	keys := map[string]bool{}
+	for _, row := range rows {
+		for key := range row.Attributes {
+			keys[key] = true
+		}
+	}
+	attributes := make([]string, 0, len(keys))
+	for key := range keys {
+		attributes = append(attributes, key)
+	}
+	sort.Strings(attributes)
+
+	writer := csv.NewWriter(w)
+	header := append([]string{}, catalogueColumns...)
+	for _, key := range attributes {
+		header = append(header, models.AttributeFilterPrefix+key)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.SKU,
+			row.Title,
+			row.Description,
+			strconv.FormatUint(uint64(row.CategoryID), 10),
+			strconv.FormatUint(uint64(row.Price), 10),
+			strconv.FormatUint(uint64(row.Quantity), 10),
+			strings.Join(row.ImageURLs, imageURLSeparator),
+		}
+		for _, key := range attributes {
+			record = append(record, row.Attributes[key])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// CatalogueStore is where an import checks products against their categories and saves them
+type CatalogueStore interface {
+	GetCategoryAttributes(categoryID uint) ([]models.CategoryAttribute, error)
+	FindProductSKUs(sellerID uint, skus []string) (map[string]bool, error)
+	ImportProduct(sellerID uint, row models.ProductImportRow, attributes []models.ProductAttribute, flags []string) (*models.Product, bool, error)
+}
+
+// ProductImporter checks the rows of an import and, unless it is a dry run, saves them.
+// A row that fails is recorded on the import and the rest carry on.
+type ProductImporter struct {
+	Store  CatalogueStore
+	DryRun bool
+	// Progress is called every few rows with the import's counts so far
+	Progress func(productImport *models.ProductImport) error
+	// Imported is called for each saved product whose row has image urls, to fetch them
+	Imported func(product *models.Product, imageURLs []string) error
+}
+
+// Run goes through rows, counting them on productImport. Rows that couldn't be read are counted as failed.
+// It stops early only when ctx is done or progress can't be saved.
+func (i *ProductImporter) Run(ctx context.Context, productImport *models.ProductImport, rows []models.ProductImportRow,
+	rowErrors []models.ImportRowError) error {
+	productImport.TotalRows = len(rows) + len(rowErrors)
+	productImport.ProcessedRows, productImport.Created, productImport.Updated, productImport.Failed = 0, 0, 0, 0
+	productImport.Errors, productImport.ErrorList = nil, ""
+	for _, rowError := range rowErrors {
+		productImport.AddError(models.ProductImportRow{Row: rowError.Row, SKU: rowError.SKU}, errors.New(rowError.Error))
+		productImport.ProcessedRows++
+	}
+
+	var existing map[string]bool
+	if i.DryRun {
+		skus := make([]string, 0, len(rows))
+		for _, row := range rows {
+			skus = append(skus, strings.TrimSpace(row.SKU))
+		}
+		var err error
+		if existing, err = i.Store.FindProductSKUs(productImport.SellerID, skus); err != nil {
+			return err
+		}
+	}
+
+	schemas := map[uint][]models.CategoryAttribute{}
+	seen := map[string]int{}
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		created, err := i.importRow(productImport.SellerID, row, schemas, seen, existing)
+		switch {
+		case err != nil:
+			productImport.AddError(row, err)
+		case created:
+			productImport.Created++
+		default:
+			productImport.Updated++
+		}
+		productImport.ProcessedRows++
+		if i.Progress != nil && productImport.ProcessedRows%importProgressEvery == 0 {
+			if err := i.Progress(productImport); err != nil {
+				return err
+			}
+		}
+	}
+	if productImport.Errors == nil {
+		productImport.Errors = []models.ImportRowError{}
+	}
+	return nil
+}
+
+// importRow checks a row and saves it, reporting whether a product was created
+func (i *ProductImporter) importRow(sellerID uint, row models.ProductImportRow, schemas map[uint][]models.CategoryAttribute,
+	seen map[string]int, existing map[string]bool) (bool, error) {
+	if err := row.Check(); err != nil {
+		return false, err
+	}
+	if first, ok := seen[row.SKU]; ok {
+		return false, fmt.Errorf("sku %s is already on row %d", row.SKU, first)
+	}
+	seen[row.SKU] = row.Row
+
+	schema, ok := schemas[row.CategoryID]
+	if !ok {
+		var err error
+		schema, err = i.Store.GetCategoryAttributes(row.CategoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, fmt.Errorf("category %d not found", row.CategoryID)
+		}
+		if err != nil {
+			return false, err
+		}
+		schemas[row.CategoryID] = schema
+	}
+	attributes, err := models.ValidateAttributes(schema, row.Attributes)
+	if err != nil {
+		return false, err
+	}
+
+	if i.DryRun {
+		return !existing[row.SKU], nil
+	}
+	listing := &models.Product{Title: row.Title, Description: row.Description, Attributes: row.Attributes}
+	product, created, err := i.Store.ImportProduct(sellerID, row, attributes, ScreenProduct(listing))
+	if err != nil {
+		return false, err
+	}
+	if len(row.ImageURLs) > 0 && i.Imported != nil {
+		if err := i.Imported(product, row.ImageURLs); err != nil {
+			return created, fmt.Errorf("saved but its images couldn't be queued: %v", err)
+		}
+	}
+	return created, nil
+}
@@ -2,63 +2,48 @@ package services
 
 import (
 	"context"
+	"github.com/decadevs/shoparena/models"
 	"github.com/mailgun/mailgun-go/v4"
 	"log"
+	"os"
 	"time"
 )
 
+// Service sends email through mailgun
 type Service struct{}
 
-// SendMail  METHOD THAT  WILL BE USED TO SEND EMAILS TO USERS
-func (s *Service) SendMail(subject, body, recipient, Private, Domain string) error {
-	privateAPIKey := Private
-	yourDomain := Domain
-
-	mg := mailgun.NewMailgun(yourDomain, privateAPIKey)
+// Send delivers a fully rendered email, with any attachments, through mailgun
+func (s *Service) Send(message *models.EmailMessage) error {
+	mg := mailgun.NewMailgun(os.Getenv("DOMAIN_STRING"), os.Getenv("MAILGUN_API_KEY"))
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
-	// Create a new template
-
-	//err := mg.CreateTemplate(ctx, &mailgun.Template{
-	//	Name: "i_template",
-	//	Version: mailgun.TemplateVersion{
-	//		Template: `'<div class="entry"> <h1>{{.title}}</h1> <div class="body"> {{.body}} </div> </div>'`,
-	//		Engine:   mailgun.TemplateEngineGo,
-	//		Tag:      "v1",
-	//	},
-	//})
-	//if err != nil {
-	//	return err
-	//}
-
-	// Create a new message with template
-	m := mg.NewMessage("Oja Ecommerce <Oja@Decadev.gon>", subject, "")
-	m.SetTemplate("i_template")
-
-	// Add recipients
-	err := m.AddRecipient(recipient)
-	if err != nil {
-		return err
+	m := mg.NewMessage(mailFrom(), message.Subject, message.Text)
+	if message.HTML != "" {
+		m.SetHtml(message.HTML)
 	}
-
-	// Add the variables recipient be used by the template
-	err = m.AddVariable("title", subject)
-	if err != nil {
-		return err
+	for _, attachment := range message.Attachments {
+		m.AddBufferAttachment(attachment.Filename, attachment.Data)
 	}
-	err = m.AddVariable("body", body)
+
+	err := m.AddRecipient(message.To)
 	if err != nil {
 		return err
 	}
 
-	// Send the message with a 10 second timeout
 	_, _, err = mg.Send(ctx, m)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	return nil
+}
 
-	return err
+// mailFrom is the sender address, MAIL_FROM if it is set
+func mailFrom() string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	return "Oja Ecommerce <Oja@Decadev.gon>"
 }
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// Names of the transactional email templates under templates/email
+const (
+	EmailWelcome         = "welcome"
+	EmailPasswordReset   = "password_reset"
+	EmailPaymentSuccess  = "payment_success"
+	EmailNewOrder        = "new_order"
+	EmailOrderShipped    = "order_shipped"
+	EmailOrderDelivered  = "order_delivered"
+	EmailOrderCancelled  = "order_cancelled"
+	EmailOrderRefunded   = "order_refunded"
+	EmailCartReminder    = "cart_reminder"
+	EmailProductAlert    = "product_alert"
+	EmailListingApproved = "listing_approved"
+	EmailListingRejected = "listing_rejected"
+)
+
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+// emailSubjects holds the subject line of every template, itself a text template
+var emailSubjects = map[string]string{
+	EmailWelcome:         "Welcome to Oja Ecommerce, {{.Name}}",
+	EmailPasswordReset:   "Reset your Oja Ecommerce password",
+	EmailPaymentSuccess:  "Payment received for order {{.Order.Number}}",
+	EmailNewOrder:        "New order {{.Order.Number}} from {{.Order.CustomerName}}",
+	EmailOrderShipped:    "Your order {{.Order.Number}} has shipped",
+	EmailOrderDelivered:  "Your order {{.Order.Number}} has been delivered",
+	EmailOrderCancelled:  "Your order {{.Order.Number}} has been cancelled",
+	EmailOrderRefunded:   "Your refund for order {{.Order.Number}} has been processed",
+	EmailProductAlert:    "{{with index .Alerts 0}}{{.Title}} is {{if .BackInStock}}back in stock{{else}}now NGN {{.Price}}{{end}}{{end}}{{if gt (len .Alerts) 1}} and more{{end}}",
+	EmailListingApproved: "Your listing {{.Listing}} has been approved",
+	EmailListingRejected: "Your listing {{.Listing}} was not approved",
+	EmailCartReminder:    "{{if .Coupon}}{{.Coupon.PercentOff}}% off the items in your cart{{else}}You left something in your cart{{end}}",
+}
+
+// EmailData is what the email templates are rendered with
+type EmailData struct {
+	// Name is the recipient's first name
+	Name  string
+	Role  string
+	Link  string
+	Note  string
+	Order *OrderEmail
+	// Coupon is a discount offered in the email
+	Coupon *CouponEmail
+	// Alerts are the product alerts that have gone off
+	Alerts []AlertEmail
+	// Listing is the title of the listing a moderation email is about
+	Listing string
+	// Unsubscribe is a link to stop emails like this one, shown in the footer
+	Unsubscribe string
+}
+
+// CouponEmail describes a coupon offered in an email
+type CouponEmail struct {
+	Code       string
+	PercentOff uint
+	ExpiresAt  string
+}
+
+// OrderEmail describes an order, with amounts already formatted for display
+type OrderEmail struct {
+	Number       string
+	Reference    string
+	CustomerName string
+	SellerName   string
+	Items        []OrderEmailItem
+	Subtotal     string
+	Tax          string
+	Total        string
+}
+
+type OrderEmailItem struct {
+	Title    string
+	Quantity uint
+	Total    string
+}
+
+// AlertEmail describes a product alert that has gone off
+type AlertEmail struct {
+	Title       string
+	BackInStock bool
+	Price       string
+	Link        string
+}
+
+// EmailTemplates lists the names of the available email templates
+func EmailTemplates() []string {
+	names := make([]string, 0, len(emailSubjects))
+	for name := range emailSubjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderEmail renders the named template into an html email with a plain-text alternative
+func RenderEmail(name, to string, data EmailData) (*models.EmailMessage, error) {
+	subjectTemplate, ok := emailSubjects[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject bytes.Buffer
+	st, err := texttemplate.New("subject").Parse(subjectTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err := st.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	layoutData := struct {
+		Subject string
+		Data    EmailData
+	}{subject.String(), data}
+
+	htmlLayout, htmlContent, err := readEmailTemplate(name, "html")
+	if err != nil {
+		return nil, err
+	}
+	ht, err := htmltemplate.New("layout").Parse(htmlLayout)
+	if err == nil {
+		_, err = ht.New("content").Parse(htmlContent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var html bytes.Buffer
+	if err := ht.ExecuteTemplate(&html, "layout", layoutData); err != nil {
+		return nil, err
+	}
+
+	textLayout, textContent, err := readEmailTemplate(name, "txt")
+	if err != nil {
+		return nil, err
+	}
+	tt, err := texttemplate.New("layout").Parse(textLayout)
+	if err == nil {
+		_, err = tt.New("content").Parse(textContent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var text bytes.Buffer
+	if err := tt.ExecuteTemplate(&text, "layout", layoutData); err != nil {
+		return nil, err
+	}
+
+	return &models.EmailMessage{
+		To:      to,
+		Subject: subject.String(),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+func readEmailTemplate(name, ext string) (layout, content string, err error) {
+	l, err := emailTemplateFS.ReadFile(path.Join("templates/email", "layout."+ext))
+	if err != nil {
+		return "", "", err
+	}
+	c, err := emailTemplateFS.ReadFile(path.Join("templates/email", name+"."+ext))
+	if err != nil {
+		return "", "", err
+	}
+	return string(l), string(c), nil
+}
+
+// EmailPreviewData is sample data for previewing a template
+func EmailPreviewData(name string) EmailData {
+	data := EmailData{Name: "Ada", Role: "buyer", Link: FrontendURL()}
+	switch name {
+	case EmailWelcome:
+		return data
+	case EmailPasswordReset:
+		data.Link = FrontendURL() + "/buyer/forgot/sample-reset-token"
+		return data
+	case EmailProductAlert:
+		data.Link = FrontendURL() + "/buyer/alerts"
+		data.Alerts = []AlertEmail{
+			{Title: "Rice cooker", BackInStock: true, Price: FormatAmount(21500), Link: FrontendURL() + "/product/4"},
+			{Title: "Stainless steel pot", Price: FormatAmount(4500), Link: FrontendURL() + "/product/9"},
+		}
+		return data
+	case EmailListingApproved, EmailListingRejected:
+		data.Name = "Chidi"
+		data.Link = FrontendURL() + "/seller/products"
+		data.Listing = "Rice cooker"
+		if name == EmailListingRejected {
+			data.Note = "Photos of the product itself are needed, not stock images"
+		}
+		return data
+	case EmailCartReminder:
+		data.Link = FrontendURL() + "/buyer/cart"
+		data.Unsubscribe = FrontendURL() + "/cart-reminders/unsubscribe"
+		data.Order = &OrderEmail{
+			Items:    []OrderEmailItem{{Title: "Rice cooker", Quantity: 1, Total: FormatAmount(21500)}},
+			Subtotal: FormatAmount(20000),
+			Tax:      FormatAmount(1500),
+			Total:    FormatAmount(21500),
+		}
+		data.Coupon = &CouponEmail{Code: "CART-MFRGG2LT", PercentOff: 10, ExpiresAt: "21 June 2022"}
+		return data
+	}
+	data.Order = &OrderEmail{
+		Number:       models.InvoiceNumber(7, 42),
+		Reference:    "T685312322670591",
+		CustomerName: "Ada Obi",
+		SellerName:   "Chidi Stores",
+		Items: []OrderEmailItem{
+			{Title: "Rice cooker", Quantity: 1, Total: FormatAmount(21500)},
+			{Title: "Stainless steel pot", Quantity: 2, Total: FormatAmount(10750)},
+		},
+		Subtotal: FormatAmount(30000),
+		Tax:      FormatAmount(2250),
+		Total:    FormatAmount(32250),
+	}
+	switch name {
+	case EmailNewOrder:
+		data.Name = "Chidi"
+	case EmailOrderShipped:
+		data.Note = "Sent with GIG Logistics, tracking number GIG-20391"
+	case EmailOrderCancelled:
+		data.Note = "The item is out of stock"
+	}
+	return data
+}
+
+// InvoiceEmail describes every order on an invoice
+func InvoiceEmail(invoice *models.Invoice) *OrderEmail {
+	order := &OrderEmail{
+		Number:       invoice.Number,
+		Reference:    invoice.PaymentReference,
+		CustomerName: fullName(invoice.Buyer.User),
+		SellerName:   fullName(invoice.Seller.User),
+		Subtotal:     FormatAmount(invoice.Subtotal),
+		Tax:          FormatAmount(invoice.Tax),
+		Total:        FormatAmount(invoice.Total),
+	}
+	for _, o := range invoice.Orders {
+		order.Items = append(order.Items, orderEmailItem(o))
+	}
+	return order
+}
+
+// CartEmail describes the products in a checkout summary
+func CartEmail(summary *models.CheckoutSummary) *OrderEmail {
+	cart := &OrderEmail{
+		Subtotal: FormatAmount(summary.Subtotal),
+		Tax:      FormatAmount(summary.Tax),
+		Total:    FormatAmount(summary.Total),
+	}
+	for _, line := range summary.Lines {
+		cart.Items = append(cart.Items, OrderEmailItem{Title: line.Title, Quantity: line.Quantity, Total: FormatAmount(line.Gross)})
+	}
+	return cart
+}
+
+// OrderLineEmail describes a single order line, as used for status updates
+func OrderLineEmail(o *models.Order) *OrderEmail {
+	return &OrderEmail{
+		Number:       "#" + strconv.Itoa(int(o.ID)),
+		Reference:    o.PaymentReference,
+		CustomerName: fullName(o.Buyer.User),
+		SellerName:   fullName(o.Seller.User),
+		Items:        []OrderEmailItem{orderEmailItem(*o)},
+		Total:        FormatAmount(o.Total),
+	}
+}
+
+// OrderStatusEmail is the template sent to the buyer when an order moves to status
+func OrderStatusEmail(status string) (string, bool) {
+	switch status {
+	case models.OrderStatusShipped:
+		return EmailOrderShipped, true
+	case models.OrderStatusDelivered:
+		return EmailOrderDelivered, true
+	case models.OrderStatusCancelled:
+		return EmailOrderCancelled, true
+	case models.OrderStatusRefunded:
+		return EmailOrderRefunded, true
+	}
+	return "", false
+}
+
+// FrontendURL is the base url of the web app, used for links in emails
+func FrontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://shoparena-frontend-phi.vercel.app"
+}
+
+// APIURL is the base url of this api, used for links in emails that come back to it
+func APIURL() string {
+	if url := os.Getenv("API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://oja-ecommerce.herokuapp.com"
+}
+
+func orderEmailItem(o models.Order) OrderEmailItem {
+	title := o.Product.Title
+	if title == "" {
+		title = "Product #" + strconv.Itoa(int(o.ProductId))
+	}
+	return OrderEmailItem{Title: title, Quantity: o.Quantity, Total: FormatAmount(o.Total)}
+}
+
+func fullName(user models.User) string {
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/decadevs/shoparena/models"
+	"golang.org/x/image/draw"
+)
+
+// Limits on an uploaded image's dimensions. Anything bigger is more likely a decompression bomb than a photo.
+const (
+	maxImageSide   = 8000
+	maxImagePixels = 40000000
+)
+
+// Errors for uploads that aren't usable images
+var (
+	ErrUnsupportedImage = errors.New("only png, jpeg and webp images are supported")
+	ErrImageDimensions  = fmt.Errorf("images can be at most %d pixels on a side", maxImageSide)
+)
+
+// ImageSize is a size pictures are scaled down to, so they fit in a square of Side pixels
+type ImageSize struct {
+	Name string
+	Side int
+}
+
+// ImageSizes are the sizes made of product and profile pictures, largest first
+var ImageSizes = []ImageSize{{"large", 1600}, {"medium", 800}, {"thumbnail", 200}}
+
+// EncodedImage is a picture ready to be stored
+type EncodedImage struct {
+	Extension string
+	Data      []byte
+	Width     int
+	Height    int
+}
+
+// ProcessedImage is an uploaded picture, cleaned of its metadata, with its scaled down variants by name
+type ProcessedImage struct {
+	EncodedImage
+	Variants map[string]EncodedImage
+}
+
+// ProcessImage checks by its content that data is a png, jpeg or webp picture of a sensible size, turns it
+// upright and encodes it again, which drops metadata such as where a photo was taken. Webp pictures become
+// png. It is also scaled down to each of sizes, as "<size>" in its own format and "<size>_webp" in webp.
+func ProcessImage(data []byte, sizes []ImageSize) (*ProcessedImage, error) {
+	extension := ".png"
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		extension = ".jpg"
+	case "image/png", "image/webp":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width > maxImageSide || config.Height > maxImageSide || config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageDimensions
+	}
+	picture, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if extension == ".jpg" {
+		picture = orient(picture, jpegOrientation(data))
+	}
+
+	original, err := encodeImage(picture, extension)
+	if err != nil {
+		return nil, err
+	}
+	processed := &ProcessedImage{EncodedImage: *original, Variants: map[string]EncodedImage{}}
+	// each size is scaled from the one before, which is quicker than scaling the original every time
+	scaled := picture
+	for _, size := range sizes {
+		scaled = scaleDown(scaled, size.Side)
+		for name, format := range map[string]string{size.Name: extension, size.Name + "_webp": ".webp"} {
+			variant, err := encodeImage(scaled, format)
+			if err != nil {
+				return nil, err
+			}
+			processed.Variants[name] = *variant
+		}
+	}
+	return processed, nil
+}
+
+// FileStore is where processed pictures are put, such as a database.Storage
+type FileStore interface {
+	Put(key string, body io.Reader, size int64) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+// StoreImage puts a processed picture and its variants in a folder in store, returning their urls.
+// Variants are stored next to the picture, e.g. product/<uuid>_thumbnail.webp.
+func StoreImage(store FileStore, folder string, processed *ProcessedImage) (string, models.ImageVariants, error) {
+	key := NewFileKey(folder, processed.Extension)
+	base := strings.TrimSuffix(key, processed.Extension)
+	stored := []string{}
+	put := func(key string, image EncodedImage) error {
+		if err := store.Put(key, bytes.NewReader(image.Data), int64(len(image.Data))); err != nil {
+			// don't leave part of the picture behind
+			for _, key := range stored {
+				_ = store.Delete(key)
+			}
+			return err
+		}
+		stored = append(stored, key)
+		return nil
+	}
+
+	if err := put(key, processed.EncodedImage); err != nil {
+		return "", nil, err
+	}
+	var variants models.ImageVariants
+	for name, variant := range processed.Variants {
+		variantKey := base + "_" + strings.TrimSuffix(name, "_webp") + variant.Extension
+		if err := put(variantKey, variant); err != nil {
+			return "", nil, err
+		}
+		if variants == nil {
+			variants = models.ImageVariants{}
+		}
+		variants[name] = store.URL(variantKey)
+	}
+	return store.URL(key), variants, nil
+}
+
+// encodeImage encodes a picture as a jpeg, png or webp by extension. webp goes through libwebp with cgo,
+// so the app can't be built with CGO_ENABLED=0.
+func encodeImage(picture image.Image, extension string) (*EncodedImage, error) {
+	var buf bytes.Buffer
+	var err error
+	switch extension {
+	case ".jpg":
+		err = jpeg.Encode(&buf, picture, &jpeg.Options{Quality: 85})
+	case ".webp":
+		err = webp.Encode(&buf, picture, &webp.Options{Quality: 80})
+	default:
+		err = png.Encode(&buf, picture)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bounds := picture.Bounds()
+	return &EncodedImage{Extension: extension, Data: buf.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// scaleDown shrinks a picture to fit in a square of side pixels, keeping its shape.
+// Pictures that already fit are left as they are.
+func scaleDown(picture image.Image, side int) image.Image {
+	bounds := picture.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= side && height <= side {
+		return picture
+	}
+	if width >= height {
+		width, height = side, height*side/width
+	} else {
+		width, height = width*side/height, side
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), picture, bounds, draw.Src, nil)
+	return scaled
+}
+
+// jpegOrientation reads the EXIF orientation of a jpeg, from 1 (upright) to 8. It is 1 when there is none.
+func jpegOrientation(data []byte) int {
+	segments := data[2:]
+	for len(segments) >= 4 && segments[0] == 0xFF {
+		marker := segments[1]
+		// the image data starts at SOS, after which there is no more metadata
+		if marker == 0xDA {
+			break
+		}
+		size := int(binary.BigEndian.Uint16(segments[2:4]))
+		if size < 2 || len(segments) < 2+size {
+			break
+		}
+		segment := segments[4 : 2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		segments = segments[2+size:]
+	}
+	return 1
+}
+
+// exifOrientation finds the orientation tag in the first IFD of EXIF's TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := order.Uint32(tiff[4:8])
+	if offset < 8 || uint64(offset)+2 > uint64(len(tiff)) {
+		return 1
+	}
+	ifd := int(offset)
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// orient turns a picture upright from how a camera with an EXIF orientation stored it.
+// The pixels are copied between RGBA buffers, as going through At and Set is far too slow for photos.
+func orient(picture image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return picture
+	}
+	bounds := picture.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	source, ok := picture.(*image.RGBA)
+	if !ok {
+		// jpegs decode to YCbCr, which draw converts a row at a time
+		source = image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(source, source.Bounds(), picture, bounds.Min, draw.Src)
+	}
+	upright := image.NewRGBA(image.Rect(0, 0, width, height))
+	if orientation >= 5 {
+		// orientations 5 to 8 are turned on their side
+		upright = image.NewRGBA(image.Rect(0, 0, height, width))
+	}
+	for y := 0; y < height; y++ {
+		from := source.PixOffset(source.Rect.Min.X, source.Rect.Min.Y+y)
+		for x := 0; x < width; x++ {
+			var ux, uy int
+			switch orientation {
+			case 2:
+				ux, uy = width-1-x, y
+			case 3:
+				ux, uy = width-1-x, height-1-y
+			case 4:
+				ux, uy = x, height-1-y
+			case 5:
+				ux, uy = y, x
+			case 6:
+				ux, uy = height-1-y, x
+			case 7:
+				ux, uy = height-1-y, width-1-x
+			case 8:
+				ux, uy = y, width-1-x
+			}
+			to := upright.PixOffset(ux, uy)
+			copy(upright.Pix[to:to+4], source.Pix[from:from+4])
+			from += 4
+		}
+	}
+	return upright
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decadevs/shoparena/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// invoiceFont is DejaVu Sans Condensed, a UTF-8 font with the accents and scripts that names and
+// product titles use. The PDF core fonts only cover cp1252.
+const invoiceFont = "DejaVu"
+
+var (
+	//go:embed templates/fonts/DejaVuSansCondensed.ttf
+	invoiceFontRegular []byte
+	//go:embed templates/fonts/DejaVuSansCondensed-Bold.ttf
+	invoiceFontBold []byte
+)
+
+// RenderInvoicePDF draws an invoice as a PDF receipt.
+// The invoice must have its Seller, Buyer and Orders.Product loaded.
+func RenderInvoicePDF(invoice *models.Invoice) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(invoiceFont, "", invoiceFontRegular)
+	pdf.AddUTF8FontFromBytes(invoiceFont, "B", invoiceFontBold)
+	pdf.SetTitle("Invoice "+invoice.Number, false)
+	pdf.SetAuthor("Oja Ecommerce", false)
+	pdf.AddPage()
+
+	pdf.SetFont(invoiceFont, "B", 20)
+	pdf.CellFormat(0, 10, "Oja Ecommerce", "", 1, "L", false, 0, "")
+	pdf.SetFont(invoiceFont, "B", 14)
+	pdf.CellFormat(0, 8, "INVOICE / RECEIPT", "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont(invoiceFont, "", 10)
+	pdf.CellFormat(0, 6, "Invoice number: "+invoice.Number, "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Date: "+invoice.CreatedAt.Format("02 Jan 2006"), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Status: PAID", "", 1, "L", false, 0, "")
+	pdf.MultiCell(0, 6, "Payment reference: "+invoice.PaymentReference, "", "L", false)
+	pdf.Ln(4)
+
+	top := pdf.GetY()
+	writeParty(pdf, 10, top, "Sold by", invoice.Seller.User)
+	writeParty(pdf, 110, top, "Billed to", invoice.Buyer.User)
+	pdf.SetXY(10, top+34)
+
+	widths := []float64{80, 15, 30, 30, 35}
+	pdf.SetFont(invoiceFont, "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, heading := range []string{"Item", "Qty", "Net", "Tax", "Total"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, heading, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(invoiceFont, "", 10)
+	for _, order := range invoice.Orders {
+		title := order.Product.Title
+		if title == "" {
+			title = "Product #" + strconv.Itoa(int(order.ProductId))
+		}
+		pdf.CellFormat(widths[0], 8, truncate(title, 45), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 8, strconv.Itoa(int(order.Quantity)), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 8, FormatAmount(order.Subtotal), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 8, taxLabel(order), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 8, FormatAmount(order.Total), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	for _, total := range []struct {
+		label  string
+		amount uint
+	}{
+		{"Subtotal", invoice.Subtotal},
+		{"Tax", invoice.Tax},
+		{"Shipping", invoice.Shipping},
+		{"Total paid", invoice.Total},
+	} {
+		if total.label == "Total paid" {
+			pdf.SetFont(invoiceFont, "B", 11)
+		}
+		pdf.CellFormat(155, 7, total.label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 7, "NGN "+FormatAmount(total.amount), "", 1, "R", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeParty(pdf *gofpdf.Fpdf, x, y float64, heading string, user models.User) {
+	pdf.SetXY(x, y)
+	pdf.SetFont(invoiceFont, "B", 10)
+	pdf.CellFormat(90, 6, heading, "", 2, "L", false, 0, "")
+	pdf.SetFont(invoiceFont, "", 10)
+	for _, line := range []string{
+		strings.TrimSpace(user.FirstName + " " + user.LastName),
+		user.Email,
+		user.PhoneNumber,
+		user.Address,
+	} {
+		if line != "" {
+			pdf.CellFormat(90, 6, truncate(line, 50), "", 2, "L", false, 0, "")
+		}
+	}
+}
+
+func taxLabel(order models.Order) string {
+	if order.TaxExempt {
+		return "exempt"
+	}
+	rate := strconv.FormatFloat(float64(order.TaxRate)/100, 'f', -1, 64)
+	return fmt.Sprintf("%s (%s%%)", FormatAmount(order.TaxAmount), rate)
+}
+
+// FormatAmount writes an amount with thousands separators, e.g. 1,250,000
+func FormatAmount(amount uint) string {
+	digits := strconv.FormatUint(uint64(amount), 10)
+	var sb strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(d)
+	}
+	return sb.String()
+}
+
+// truncate shortens s to at most max characters, cutting between characters rather than bytes
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"os"
+	"strings"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// BannedKeywords is the list new and edited listings are screened against, from the comma separated
+// BANNED_KEYWORDS. Listings with any of them are held for an admin to review.
+func BannedKeywords() []string {
+	var keywords []string
+	for _, keyword := range strings.Split(os.Getenv("BANNED_KEYWORDS"), ",") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// ScreenProduct returns the banned keywords in a product's title, description and attribute values
+func ScreenProduct(product *models.Product) []string {
+	texts := []string{product.Title, product.Description}
+	for _, value := range product.Attributes {
+		texts = append(texts, value)
+	}
+	return models.ScreenListing(BannedKeywords(), texts...)
+}
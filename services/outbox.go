@@ -0,0 +1,99 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// EmailOutboxStore is where the outbox sender finds queued emails and records how sending went
+type EmailOutboxStore interface {
+	ClaimDueEmails(limit int) ([]models.EmailOutbox, error)
+	SaveEmailAttempt(email *models.EmailOutbox) error
+}
+
+// EmailSender delivers a rendered email
+type EmailSender interface {
+	Send(message *models.EmailMessage) error
+}
+
+// OutboxSender sends queued emails in the background, retrying failures with exponential backoff.
+// An email that still fails after MaxAttempts is marked dead and left for someone to look at.
+type OutboxSender struct {
+	Store       EmailOutboxStore
+	Mailer      EmailSender
+	BatchSize   int
+	MaxAttempts uint
+}
+
+// NewOutboxSender creates a sender that gives up on an email after EMAIL_MAX_ATTEMPTS tries, 8 by default
+func NewOutboxSender(store EmailOutboxStore, mailer EmailSender) *OutboxSender {
+	maxAttempts, err := strconv.Atoi(os.Getenv("EMAIL_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts < 1 {
+		maxAttempts = 8
+	}
+	return &OutboxSender{
+		Store:       store,
+		Mailer:      mailer,
+		BatchSize:   20,
+		MaxAttempts: uint(maxAttempts),
+	}
+}
+
+// Flush sends every email that is due and returns how many went out
+func (s *OutboxSender) Flush() (int, error) {
+	sent := 0
+	for {
+		emails, err := s.Store.ClaimDueEmails(s.BatchSize)
+		if err != nil {
+			return sent, err
+		}
+		for i := range emails {
+			if s.send(&emails[i]) {
+				sent++
+			}
+		}
+		if len(emails) < s.BatchSize {
+			return sent, nil
+		}
+	}
+}
+
+func (s *OutboxSender) send(email *models.EmailOutbox) bool {
+	now := time.Now()
+	email.Attempts++
+	err := s.Mailer.Send(email.Message())
+	if err == nil {
+		email.Status = models.EmailStatusSent
+		email.SentAt = &now
+		email.LastError = ""
+	} else {
+		email.LastError = err.Error()
+		if email.Attempts >= s.MaxAttempts {
+			email.Status = models.EmailStatusDead
+			log.Printf("email %d to %s is dead after %d attempts: %v\n", email.ID, email.To, email.Attempts, err)
+		} else {
+			email.NextAttemptAt = now.Add(EmailRetryDelay(email.Attempts))
+		}
+	}
+	if saveErr := s.Store.SaveEmailAttempt(email); saveErr != nil {
+		log.Printf("save email attempt error: %v\n", saveErr)
+	}
+	return err == nil
+}
+
+// EmailRetryDelay is how long to wait before the next try after attempts failures:
+// a minute, doubling each time, up to twelve hours
+func EmailRetryDelay(attempts uint) time.Duration {
+	delay := time.Minute
+	for i := uint(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= 12*time.Hour {
+			return 12 * time.Hour
+		}
+	}
+	return delay
+}
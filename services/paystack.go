@@ -25,6 +25,37 @@ type Data struct {
 	} `json:"data"`
 }
 
+// PaymentVerification is what Paystack reports about a transaction when it is verified
+type PaymentVerification struct {
+	Status bool `json:"status"`
+	Data   struct {
+		Status string `json:"status"`
+		// Amount is in kobo
+		Amount uint `json:"amount"`
+	} `json:"data"`
+}
+
+// ReadVerification decodes the response to VerifyReference and closes it
+func ReadVerification(resp *http.Response) (*PaymentVerification, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("verify transaction: paystack responded %s", resp.Status)
+	}
+	verification := &PaymentVerification{}
+	if err := json.NewDecoder(resp.Body).Decode(verification); err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// Paid returns the naira paid, ok is false when the transaction didn't succeed
+func (v *PaymentVerification) Paid() (amount uint, ok bool) {
+	if !v.Status || v.Data.Status != "success" || v.Data.Amount%100 != 0 {
+		return 0, false
+	}
+	return v.Data.Amount / 100, true
+}
+
 func NewPaystack() *PayStack {
 	secretKey := os.Getenv("PRIVATE_KEY")
 	return &PayStack{
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/decadevs/shoparena/models"
+)
+
+// referralAlphabet leaves out characters that are easy to mistake for each other
+const referralAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const defaultReferralReward = 500
+
+// publicMailDomains are shared by unrelated users, so a matching domain says nothing about fraud
+var publicMailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+	"yahoo.com":      true,
+	"ymail.com":      true,
+	"outlook.com":    true,
+	"hotmail.com":    true,
+	"live.com":       true,
+	"icloud.com":     true,
+	"aol.com":        true,
+	"proton.me":      true,
+	"protonmail.com": true,
+}
+
+// GenerateReferralCode returns a random invite code a buyer can share
+func GenerateReferralCode() (string, error) {
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(referralAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = referralAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeReferralCode formats a code the way it is stored
+func NormalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ReferralRewardAmount is the wallet credit each party gets, set with REFERRAL_REWARD_AMOUNT
+func ReferralRewardAmount() uint {
+	amount, err := strconv.Atoi(os.Getenv("REFERRAL_REWARD_AMOUNT"))
+	if err != nil || amount < 0 {
+		return defaultReferralReward
+	}
+	return uint(amount)
+}
+
+// CheckReferral returns an error when the referee looks like the referrer signing up again
+func CheckReferral(referrer, referee *models.Buyer) error {
+	if referrer.ID != 0 && referrer.ID == referee.ID {
+		return errors.New("self referral")
+	}
+	if normalizeEmail(referrer.Email) == normalizeEmail(referee.Email) {
+		return errors.New("self referral")
+	}
+	referrerPhone, refereePhone := normalizePhone(referrer.PhoneNumber), normalizePhone(referee.PhoneNumber)
+	if referrerPhone != "" && referrerPhone == refereePhone {
+		return errors.New("same phone number as referrer")
+	}
+	referrerDomain, refereeDomain := emailDomain(referrer.Email), emailDomain(referee.Email)
+	if referrerDomain != "" && referrerDomain == refereeDomain && !publicMailDomains[referrerDomain] {
+		return errors.New("same email domain as referrer")
+	}
+	return nil
+}
+
+// normalizeEmail strips "+tag" suffixes and gmail dots so aliases compare equal
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+func emailDomain(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return email[at+1:]
+}
+
+// normalizePhone keeps the last ten digits so 080..., +23480... and 23480... match
+func normalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	d := digits.String()
+	if len(d) > 10 {
+		d = d[len(d)-10:]
+	}
+	return d
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/decadevs/shoparena/server/response"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrFileNotFound is returned for keys with no file stored under them
+var ErrFileNotFound = errors.New("file not found")
+
+// PendingUploads is the folder files PUT straight to storage wait in until they are confirmed.
+// They are private until then, and deleted when they are never confirmed.
+const PendingUploads = "uploads"
+
+// NewFileKey names a new file in folder, e.g. product/<uuid>.png
+func NewFileKey(folder, fileExtension string) string {
+	return folder + "/" + uuid.NewString() + fileExtension
+}
+
+// S3Storage keeps uploaded files in an S3 bucket, where anyone can read them
+type S3Storage struct {
+	Client *s3.S3
+	Bucket string
+	// BaseURL is where the bucket's files are served from
+	BaseURL string
+}
+
+// NewS3Storage configures an S3Storage from AWS_REGION, AWS_SECRET_KEY, AWS_SECRET_ID, S3_BUCKET_NAME and S3_URL
+func NewS3Storage() (*S3Storage, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(os.Getenv("AWS_REGION")),
+		Credentials: credentials.NewStaticCredentials(os.Getenv("AWS_SECRET_KEY"), os.Getenv("AWS_SECRET_ID"), ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &S3Storage{
+		Client:  s3.New(sess),
+		Bucket:  os.Getenv("S3_BUCKET_NAME"),
+		BaseURL: strings.TrimSuffix(os.Getenv("S3_URL"), "/"),
+	}
+	if s.BaseURL == "" {
+		s.BaseURL = "https://s3-eu-west-3.amazonaws.com/arp-rental"
+	}
+	return s, nil
+}
+
+// Put uploads size bytes of body to the bucket as key
+func (s *S3Storage) Put(key string, body io.Reader, size int64) error {
+	// get the file size and read the file content into a buffer
+	buffer := make([]byte, size)
+	if _, err := io.ReadFull(body, buffer); err != nil {
+		return err
+	}
+	_, err := s.Client.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(s.Bucket),
+		Key:                  aws.String(key),
+		ACL:                  aws.String("public-read"),
+		Body:                 bytes.NewReader(buffer),
+		ContentLength:        aws.Int64(size),
+		ContentType:          aws.String(http.DetectContentType(buffer)),
+		ContentDisposition:   aws.String("attachment"),
+		ServerSideEncryption: aws.String("AES256"),
+		StorageClass:         aws.String("INTELLIGENT_TIERING"),
+	})
+	return err
+}
+
+// Get opens the file stored as key in the bucket
+func (s *S3Storage) Get(key string) (io.ReadCloser, int64, error) {
+	res, err := s.Client.GetObject(&s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, 0, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.Body, aws.Int64Value(res.ContentLength), nil
+}
+
+// Delete removes key from the bucket
+func (s *S3Storage) Delete(key string) error {
+	_, err := s.Client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	return err
+}
+
+// URL returns where the file stored as key is served from
+func (s *S3Storage) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+// Key returns the key of the file at url, or false if it isn't in the bucket
+func (s *S3Storage) Key(url string) (string, bool) {
+	return keyUnder(s.BaseURL, url)
+}
+
+// SignedURL presigns a GET or PUT of key that works until expires has passed
+func (s *S3Storage) SignedURL(method, key string, expires time.Duration) (string, error) {
+	var req *request.Request
+	switch method {
+	case http.MethodGet:
+		req, _ = s.Client.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	case http.MethodPut:
+		req, _ = s.Client.PutObjectRequest(&s3.PutObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key),
+			ACL: aws.String("public-read")})
+	default:
+		return "", fmt.Errorf("can't sign a %s request", method)
+	}
+	return req.Presign(expires)
+}
+
+// SignedUpload presigns a PUT of key whose Content-Type and Content-Length headers must match the signed ones.
+// The upload is private, it only becomes public once it has been processed and stored again.
+func (s *S3Storage) SignedUpload(key, contentType string, size int64, expires time.Duration) (string, error) {
+	req, _ := s.Client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.Bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+	return req.Presign(expires)
+}
+
+// DeleteOlderThan removes the files under folder last changed before before, returning how many it removed
+func (s *S3Storage) DeleteOlderThan(folder string, before time.Time) (int, error) {
+	deleted := 0
+	var deleteErr error
+	err := s.Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(s.Bucket), Prefix: aws.String(folder + "/")},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			var stale []*s3.ObjectIdentifier
+			for _, object := range page.Contents {
+				if object.LastModified != nil && object.LastModified.Before(before) {
+					stale = append(stale, &s3.ObjectIdentifier{Key: object.Key})
+				}
+			}
+			if len(stale) == 0 {
+				return true
+			}
+			_, deleteErr = s.Client.DeleteObjects(&s3.DeleteObjectsInput{
+				Bucket: aws.String(s.Bucket),
+				Delete: &s3.Delete{Objects: stale, Quiet: aws.Bool(true)},
+			})
+			if deleteErr != nil {
+				return false
+			}
+			deleted += len(stale)
+			return true
+		})
+	if deleteErr != nil {
+		return deleted, deleteErr
+	}
+	return deleted, err
+}
+
+// LocalStorage keeps uploaded files in a directory, for running the app without AWS.
+// The router serves them from BaseURL, which should end in /files.
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+	// Secret signs urls
+	Secret string
+}
+
+// NewLocalStorage configures a LocalStorage in STORAGE_DIR, ./uploads by default, served by this api.
+// Urls are signed with STORAGE_SECRET, or JWT_SECRET without it.
+func NewLocalStorage() *LocalStorage {
+	s := &LocalStorage{
+		Dir:     os.Getenv("STORAGE_DIR"),
+		BaseURL: APIURL() + "/files",
+		Secret:  os.Getenv("STORAGE_SECRET"),
+	}
+	if s.Dir == "" {
+		s.Dir = "uploads"
+	}
+	if s.Secret == "" {
+		s.Secret = os.Getenv("JWT_SECRET")
+	}
+	return s
+}
+
+// errBadKey is returned for keys that would be stored outside the storage's directory
+var errBadKey = errors.New("invalid file key")
+
+// path returns where the file stored as key is kept
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned != "/"+key {
+		return "", errBadKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes size bytes of body to the file stored as key
+func (s *LocalStorage) Put(key string, body io.Reader, size int64) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	// write to a temporary file first so a failed upload doesn't leave half a file behind
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.CopyN(tmp, body, size); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Get opens the file stored as key
+func (s *LocalStorage) Get(key string) (io.ReadCloser, int64, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, 0, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return nil, 0, ErrFileNotFound
+	}
+	return file, info.Size(), nil
+}
+
+// Delete removes the file stored as key. A file that is already gone is not an error.
+func (s *LocalStorage) Delete(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteOlderThan removes the files under folder last changed before before, returning how many it removed
+func (s *LocalStorage) DeleteOlderThan(folder string, before time.Time) (int, error) {
+	dir, err := s.path(folder)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			return err
+		}
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+// URL returns where the file stored as key is served from
+func (s *LocalStorage) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+// Key returns the key of the file at url, or false if it isn't one of the storage's
+func (s *LocalStorage) Key(url string) (string, bool) {
+	return keyUnder(s.BaseURL, url)
+}
+
+// SignedURL returns the file's url with an expiry and a signature of method, key and the expiry
+func (s *LocalStorage) SignedURL(method, key string, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	return s.URL(key) + "?expires=" + expiry + "&signature=" + s.sign(method, key, expiry), nil
+}
+
+// SignedUpload returns a url for UploadFile that is also signed for the upload's content type and size,
+// so a PUT with other Content-Type or Content-Length headers is refused
+func (s *LocalStorage) SignedUpload(key, contentType string, size int64, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	signature := s.sign(http.MethodPut, key, expiry, contentType, strconv.FormatInt(size, 10))
+	return s.URL(key) + "?expires=" + expiry + "&signature=" + signature, nil
+}
+
+// sign is the hex hmac of a signed url, covering any constraints on the request as well
+func (s *LocalStorage) sign(method, key, expiry string, constraints ...string) string {
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	mac.Write([]byte(strings.Join(append([]string{method, key, expiry}, constraints...), "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether the request carries a current signature for method, key and constraints
+func (s *LocalStorage) verify(c *gin.Context, method, key string, constraints ...string) bool {
+	expiry := c.Query("expires")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(c.Query("signature")), []byte(s.sign(method, key, expiry, constraints...)))
+}
+
+// ServeFile serves GET /files/*key. Files are public, like those on S3, except uploads waiting to be
+// confirmed; a url that carries a signature must be signed for the file and not have expired.
+func (s *LocalStorage) ServeFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	name, err := s.path(key)
+	if err != nil || strings.HasPrefix(key, PendingUploads+"/") {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"file not found"})
+		return
+	}
+	if c.Query("signature") != "" && !s.verify(c, http.MethodGet, key) {
+		response.JSON(c, "", http.StatusForbidden, nil, []string{"invalid or expired signature"})
+		return
+	}
+	if info, err := os.Stat(name); err != nil || info.IsDir() {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"file not found"})
+		return
+	}
+	c.File(name)
+}
+
+// UploadFile serves PUT /files/*key, storing the body when the url was made by SignedUpload for
+// the request's Content-Type and Content-Length
+func (s *LocalStorage) UploadFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if _, err := s.path(key); err != nil {
+		response.JSON(c, "", http.StatusNotFound, nil, []string{"file not found"})
+		return
+	}
+	size := c.Request.ContentLength
+	if size < 0 || !s.verify(c, http.MethodPut, key, c.GetHeader("Content-Type"), strconv.FormatInt(size, 10)) {
+		response.JSON(c, "", http.StatusForbidden, nil, []string{"invalid or expired signature"})
+		return
+	}
+	if err := s.Put(key, c.Request.Body, size); err != nil {
+		response.JSON(c, "", http.StatusBadRequest, nil, []string{"unable to store file"})
+		return
+	}
+	response.JSON(c, "file uploaded successfully", http.StatusOK, nil, nil)
+}
+
+// keyUnder returns the key of a file at url under baseURL
+func keyUnder(baseURL, url string) (string, bool) {
+	prefix := baseURL + "/"
+	if !strings.HasPrefix(url, prefix) || len(url) == len(prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, prefix), true
+}
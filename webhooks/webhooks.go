@@ -0,0 +1,226 @@
+// Package webhooks posts domain events to the endpoints sellers register.
+//
+// The seller subscriber fans each event out into a delivery per matching endpoint, and the
+// Sender posts due deliveries, signed with the endpoint's secret, retrying with backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/decadevs/shoparena/events"
+	"github.com/decadevs/shoparena/models"
+)
+
+// Store is where deliveries are queued and recorded
+type Store interface {
+	QueueWebhookDeliveries(sellerID, eventID uint, eventType, payload string) (int, error)
+	ClaimWebhookDeliveries(limit int) ([]models.WebhookDelivery, error)
+	SaveWebhookDelivery(delivery *models.WebhookDelivery) error
+}
+
+// Subscribe queues deliveries for every seller webhook event published on the bus
+func Subscribe(bus *events.Bus, store Store) {
+	for _, eventType := range models.SellerWebhookEvents {
+		bus.Subscribe(eventType, "seller webhooks", fanout(store))
+	}
+}
+
+func fanout(store Store) events.Handler {
+	return func(ctx context.Context, event events.Event) error {
+		var owner struct {
+			SellerID uint `json:"seller_id"`
+		}
+		if err := event.Decode(&owner); err != nil {
+			return err
+		}
+		if owner.SellerID == 0 {
+			return nil
+		}
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = store.QueueWebhookDeliveries(owner.SellerID, event.ID, event.Type, string(body))
+		return err
+	}
+}
+
+// Sender posts queued deliveries to their endpoints
+type Sender struct {
+	Store       Store
+	Client      *http.Client
+	BatchSize   int
+	MaxAttempts uint
+}
+
+func NewSender(store Store) *Sender {
+	return &Sender{Store: store, Client: SafeClient(), BatchSize: 20, MaxAttempts: 8}
+}
+
+// Flush posts every delivery that is due and returns how many were accepted
+func (s *Sender) Flush(ctx context.Context) (int, error) {
+	succeeded := 0
+	for {
+		deliveries, err := s.Store.ClaimWebhookDeliveries(s.BatchSize)
+		if err != nil {
+			return succeeded, err
+		}
+		for i := range deliveries {
+			if ctx.Err() != nil {
+				return succeeded, ctx.Err()
+			}
+			if s.deliver(ctx, &deliveries[i]) {
+				succeeded++
+			}
+		}
+		if len(deliveries) < s.BatchSize {
+			return succeeded, nil
+		}
+	}
+}
+
+func (s *Sender) deliver(ctx context.Context, delivery *models.WebhookDelivery) bool {
+	// a deleted endpoint isn't loaded with its deliveries, and won't come back to be retried
+	if delivery.Endpoint.ID == 0 {
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.LastError = "endpoint was deleted"
+		if err := s.Store.SaveWebhookDelivery(delivery); err != nil {
+			log.Printf("save webhook delivery error: %v\n", err)
+		}
+		return false
+	}
+	delivery.Attempts++
+	started := time.Now()
+	status, body, err := s.post(ctx, delivery)
+	delivery.DurationMs = time.Since(started).Milliseconds()
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("endpoint responded %d", status)
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryStatusSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= s.MaxAttempts:
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(RetryDelay(delivery.Attempts))
+	}
+	if saveErr := s.Store.SaveWebhookDelivery(delivery); saveErr != nil {
+		log.Printf("save webhook delivery error: %v\n", saveErr)
+	}
+	return err == nil
+}
+
+// post sends the delivery and returns the response status and the start of its body
+func (s *Sender) post(ctx context.Context, delivery *models.WebhookDelivery) (int, string, error) {
+	if !delivery.Endpoint.Active {
+		return 0, "", errors.New("endpoint is disabled")
+	}
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Oja-Webhooks/1.0")
+	req.Header.Set("X-Oja-Event", delivery.EventType)
+	req.Header.Set("X-Oja-Event-Id", strconv.Itoa(int(delivery.EventID)))
+	req.Header.Set("X-Oja-Delivery", strconv.Itoa(int(delivery.ID)))
+	req.Header.Set(events.SignatureHeader, events.Sign(delivery.Endpoint.Secret, time.Now(), body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	// keep enough of the response for the delivery log
+	excerpt, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, string(excerpt), nil
+}
+
+// RetryDelay is half a minute, doubling with each failure, up to six hours
+func RetryDelay(attempts uint) time.Duration {
+	delay := 30 * time.Second
+	for i := uint(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= 6*time.Hour {
+			return 6 * time.Hour
+		}
+	}
+	return delay
+}
+
+// NewSecret makes a signing secret for an endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// ValidateURL checks an endpoint url is an absolute http or https url
+func ValidateURL(raw string) error {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("url must be an absolute http or https url")
+	}
+	if os.Getenv("GIN_MODE") == "release" && u.Scheme != "https" {
+		return errors.New("url must use https")
+	}
+	return nil
+}
+
+// SafeClient is an http client that refuses to connect to loopback, private and link-local
+// addresses, so an endpoint cannot be pointed at our own network.
+// WEBHOOK_ALLOW_PRIVATE=true lifts this for local development.
+func SafeClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE") != "true" {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}